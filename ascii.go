@@ -0,0 +1,76 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// ASCIIMode controls how non-ASCII characters are written for monitoring cores that cannot
+// handle UTF-8. It only applies to the plugin output; the CheckResult itself keeps the
+// original text for sinks that support UTF-8.
+type ASCIIMode int
+
+const (
+	// ASCIIKeep writes UTF-8 unchanged
+	ASCIIKeep ASCIIMode = iota
+	// ASCIITransliterate replaces characters with their closest ASCII spelling, or "?" if there is none
+	ASCIITransliterate
+	// ASCIIStrip removes non-ASCII characters
+	ASCIIStrip
+)
+
+// transliterations maps common non-ASCII characters to ASCII spellings.
+var transliterations = map[rune]string{
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Æ': "AE", 'Ç': "C",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I",
+	'Ð': "D", 'Ñ': "N", 'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "O", 'Ø': "O",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U", 'Ý': "Y", 'Þ': "TH", 'ß': "ss",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae", 'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ð': "d", 'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'þ': "th", 'ÿ': "y",
+	'Ł': "L", 'ł': "l", 'Œ': "OE", 'œ': "oe", 'Š': "S", 'š': "s", 'Ž': "Z", 'ž': "z",
+	' ': " ", '‘': "'", '’': "'", '‚': ",", '“': "\"", '”': "\"", '„': "\"",
+	'–': "-", '—': "-", '…': "...", '•': "*", '·': ".", '×': "x", '÷': "/",
+	'°': "deg", 'µ': "u", 'μ': "u", '±': "+/-", '≤': "<=", '≥': ">=", '≠': "!=",
+	'→': "->", '←': "<-", '©': "(c)", '®': "(R)", '™': "(TM)",
+	'€': "EUR", '£': "GBP", '¥': "JPY", '¢': "c",
+}
+
+// toASCII rewrites the non-ASCII characters of s according to mode. Invalid UTF-8 bytes are
+// treated like characters without a transliteration.
+func toASCII(s string, mode ASCIIMode) string {
+	if mode == ASCIIKeep || !strings.ContainsFunc(s, func(r rune) bool { return r >= utf8.RuneSelf }) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case mode == ASCIIStrip:
+		case transliterations[r] != "":
+			b.WriteString(transliterations[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package gomonitor
+
+import "testing"
+
+func TestToASCII(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		mode ASCIIMode
+		want string
+	}{
+		{"Test keep", "Ünïcode 20°C", ASCIIKeep, "Ünïcode 20°C"},
+		{"Test plain ASCII", "disk / 95% used", ASCIITransliterate, "disk / 95% used"},
+		{"Test transliterate", "Ünïcode 20°C – “ok”", ASCIITransliterate, "Unicode 20degC - \"ok\""},
+		{"Test transliterate unmapped", "CPU 温度", ASCIITransliterate, "CPU ??"},
+		{"Test strip", "Ünïcode 20°C", ASCIIStrip, "ncode 20C"},
+		{"Test invalid UTF-8", "bad \xff byte", ASCIITransliterate, "bad ? byte"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := toASCII(tc.in, tc.mode); got != tc.want {
+				t.Errorf("toASCII(%q) got %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatResultASCII(t *testing.T) {
+	testCases := []struct {
+		name string
+		mode ASCIIMode
+		want string
+	}{
+		{"Test keep", ASCIIKeep, "OK - Température normale | 'température'=21;0;0;0;0 'é'=1;0;0;0;0 "},
+		{"Test transliterate", ASCIITransliterate, "OK - Temperature normale | 'temperature'=21;0;0;0;0 'e'=1;0;0;0;0 "},
+		// A label with nothing left after stripping is left out
+		{"Test strip", ASCIIStrip, "OK - Temprature normale | 'temprature'=21;0;0;0;0 "},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NewCheckResult()
+			result.Profile.ASCII = tc.mode
+			result.SetResult(OK, "Température normale")
+			result.AddPerformanceData("température", PerformanceMetric{Value: 21, Integer: true})
+			result.AddPerformanceData("é", PerformanceMetric{Value: 1, Integer: true})
+			if got := result.FormatResult(); got != tc.want {
+				t.Errorf("FormatResult got %q, want %q", got, tc.want)
+			}
+			// The result keeps the original text for UTF-8 sinks
+			if result.Message != "Température normale" {
+				t.Errorf("got message %q, want it unchanged", result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CheckmkLocal renders the result as a Checkmk local check line:
+//
+//	<status> <item> <metric>=<value>;<warn>;<crit>;<min>;<max>|... <message>
+//
+// The item names the service and, like metric names, has characters Checkmk does not accept
+// replaced with underscores. A "-" stands in for the perfdata when there is none. Units of
+// measure are not part of the local check format and are dropped, range thresholds are
+// written as their upper bound and metrics without a finite value are left out. Long output
+// is appended to the message as literal "\n" sequences, which Checkmk turns into the
+// service's details.
+func (cr *CheckResult) CheckmkLocal(item string) string {
+	var metrics []string
+	for name, metric := range cr.Metrics() {
+		if metric.Unknown || math.IsNaN(metric.Value) || math.IsInf(metric.Value, 0) {
+			continue
+		}
+		prec := cr.MetricPrecision(metric)
+		metrics = append(metrics, checkmkName(name)+"="+formatNumber(metric.Value, prec)+";"+
+			cr.checkmkLevel(metric.Warn, metric.WarnSet, metric.WarnRange, prec)+";"+
+			cr.checkmkLevel(metric.Crit, metric.CritSet, metric.CritRange, prec)+";"+
+			cr.numberField(metric.Min, metric.MinSet, prec)+";"+cr.numberField(metric.Max, metric.MaxSet, prec))
+	}
+	perfdata := "-"
+	if len(metrics) > 0 {
+		perfdata = strings.Join(metrics, "|")
+	}
+	lines := append([]string{cr.Message}, cr.longOutput()...)
+	for i, line := range lines {
+		lines[i] = strings.ReplaceAll(line, "\n", `\n`)
+	}
+	return strconv.Itoa(cr.ExitCode.Int()) + " " + checkmkName(item) + " " + perfdata + " " + strings.Join(lines, `\n`)
+}
+
+// checkmkLevel renders a warn or crit level, using a range's upper bound.
+func (cr *CheckResult) checkmkLevel(limit float64, set bool, r *Range, prec int) string {
+	if r != nil {
+		if math.IsInf(r.End, 0) {
+			return ""
+		}
+		return formatNumber(r.End, prec)
+	}
+	return cr.numberField(limit, set, prec)
+}
+
+// checkmkName replaces the characters Checkmk does not accept in item and metric names with
+// underscores.
+func checkmkName(s string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+	if name == "" {
+		return "_"
+	}
+	return name
+}
@@ -0,0 +1,44 @@
+package gomonitor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckmkLocal(t *testing.T) {
+	testCases := []struct {
+		name  string
+		setup func(cr *CheckResult)
+		want  string
+	}{
+		{"Test no perfdata", func(cr *CheckResult) {
+			cr.SetResult(OK, "all good")
+		}, "0 My_Service - all good"},
+		{"Test perfdata", func(cr *CheckResult) {
+			cr.SetResult(Warning, "disk at 91%")
+			cr.AddPerformanceData("/var used", PerformanceMetric{Value: 91, Warn: 90, Crit: 95, Max: 100, UnitOM: UOMPercent})
+			cr.AddPerformanceData("files", PerformanceMetric{Value: 12, Integer: true})
+		}, "1 My_Service _var_used=91.00;90.00;95.00;0.00;100.00|files=12;0;0;0;0 disk at 91%"},
+		{"Test ranges and unknown values", func(cr *CheckResult) {
+			cr.SetResult(Critical, "bad")
+			cr.OmitZeroFields = true
+			cr.AddPerformanceData("temp", PerformanceMetric{Value: 40, WarnRange: &Range{Start: 10, End: 35}, CritRange: &Range{Start: 5, End: math.Inf(1)}})
+			cr.AddPerformanceData("load", PerformanceMetric{Unknown: true})
+		}, "2 My_Service temp=40.00;35.00;;; bad"},
+		{"Test long output", func(cr *CheckResult) {
+			cr.SetResult(Unknown, "2 errors")
+			cr.AddLongOutput("first\nsecond")
+			cr.AddLongOutput("third")
+		}, `3 My_Service - 2 errors\nfirst\nsecond\nthird`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NewCheckResult()
+			tc.setup(result)
+			if got := result.CheckmkLocal("My Service"); got != tc.want {
+				t.Errorf("CheckmkLocal got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
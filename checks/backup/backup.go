@@ -0,0 +1,240 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package backup checks that the latest backup is recent enough and large enough. It reads
+// restic snapshots, borg archives or plain dump files such as pg_dump output.
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Supported backup sources.
+const (
+	Restic = "restic"
+	Borg   = "borg"
+	Files  = "files"
+)
+
+// Options configures a backup freshness check.
+// - `Source` is Restic, Borg or Files.
+// - `Repository` is the restic or borg repository. If empty, restic and borg fall back to their RESTIC_REPOSITORY or BORG_REPO environment variables.
+// - `Pattern` is a filepath.Match glob for the Files source, such as "/var/backups/pg/*.dump". The newest matching file is checked.
+// - `Command` is the restic or borg program, with any leading arguments. It defaults to the Source name.
+// - `Env` holds extra environment variables for the command, such as RESTIC_PASSWORD_FILE.
+// - `Warn` and `Crit` are backup ages above which the check is Warning or Critical. 0 disables a threshold.
+// - `WarnSize` and `CritSize` are sizes in bytes below which the check is Warning or Critical. 0 disables a threshold.
+// - `Timeout` bounds the restic or borg command. 0 means 60 seconds.
+// - `Clock` tells the time backup ages are measured at. nil means gomonitor.SystemClock.
+type Options struct {
+	Source     string
+	Repository string
+	Pattern    string
+	Command    []string
+	Env        []string
+	Warn       time.Duration
+	Crit       time.Duration
+	WarnSize   int64
+	CritSize   int64
+	Timeout    time.Duration
+	Clock      gomonitor.Clock
+}
+
+// latest describes the most recent backup found.
+// - `name` identifies it: a snapshot ID, archive name or file path.
+// - `size` is -1 when the source does not report one.
+type latest struct {
+	name string
+	time time.Time
+	size int64
+}
+
+// Check finds the latest backup and compares its age and size with the thresholds. Not
+// finding any backup is Critical; failing to list backups is Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if len(opts.Command) == 0 {
+		opts.Command = []string{opts.Source}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 60 * time.Second
+	}
+
+	var backup *latest
+	var err error
+	switch opts.Source {
+	case Restic:
+		backup, err = latestRestic(ctx, opts)
+	case Borg:
+		backup, err = latestBorg(ctx, opts)
+	case Files:
+		backup, err = latestFile(opts.Pattern)
+	default:
+		err = fmt.Errorf("unsupported backup source %q", opts.Source)
+	}
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	if backup == nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("no %s backup found", opts.Source))
+		return result
+	}
+
+	age := gomonitor.ClockOrSystem(opts.Clock).Now().Sub(backup.time)
+	result.AddPerformanceData("age", gomonitor.PerformanceMetric{
+		Value:  age.Seconds(),
+		Warn:   opts.Warn.Seconds(),
+		Crit:   opts.Crit.Seconds(),
+		UnitOM: "s",
+	})
+	msg := fmt.Sprintf("latest %s backup %s is %s old", opts.Source, backup.name, age.Round(time.Second))
+	if backup.size >= 0 {
+		result.AddPerformanceData("size", gomonitor.PerformanceMetric{
+			Value:     float64(backup.size),
+			WarnRange: gomonitor.LowerBound(float64(opts.WarnSize)),
+			CritRange: gomonitor.LowerBound(float64(opts.CritSize)),
+			UnitOM:    "B",
+		})
+		msg += fmt.Sprintf(", %d bytes", backup.size)
+	}
+
+	result.Evaluate()
+	result.SetResult(result.ExitCode, msg)
+	return result
+}
+
+// latestRestic reads "restic snapshots --json --latest 1", which lists the latest snapshot of
+// every host and path group, and returns the newest one.
+func latestRestic(ctx context.Context, opts Options) (*latest, error) {
+	args := []string{"snapshots", "--json", "--latest", "1"}
+	if opts.Repository != "" {
+		args = append(args, "--repo", opts.Repository)
+	}
+	out, err := run(ctx, opts, args...)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []struct {
+		ShortID string    `json:"short_id"`
+		Time    time.Time `json:"time"`
+		Summary *struct {
+			TotalBytesProcessed int64 `json:"total_bytes_processed"`
+		} `json:"summary"`
+	}
+	if err := json.Unmarshal(out, &snapshots); err != nil {
+		return nil, fmt.Errorf("parsing restic output: %v", err)
+	}
+	var newest *latest
+	for _, s := range snapshots {
+		if newest != nil && !s.Time.After(newest.time) {
+			continue
+		}
+		newest = &latest{name: s.ShortID, time: s.Time, size: -1}
+		if s.Summary != nil {
+			newest.size = s.Summary.TotalBytesProcessed
+		}
+	}
+	return newest, nil
+}
+
+// borgTimeLayout is the layout of borg's timestamps, which are in local time without a zone.
+const borgTimeLayout = "2006-01-02T15:04:05.999999"
+
+// latestBorg reads "borg info --json --last 1", which includes the archive's statistics.
+func latestBorg(ctx context.Context, opts Options) (*latest, error) {
+	args := []string{"info", "--json", "--last", "1"}
+	if opts.Repository != "" {
+		args = append(args, opts.Repository)
+	}
+	out, err := run(ctx, opts, args...)
+	if err != nil {
+		return nil, err
+	}
+	var info struct {
+		Archives []struct {
+			Name  string `json:"name"`
+			Start string `json:"start"`
+			Stats struct {
+				OriginalSize int64 `json:"original_size"`
+			} `json:"stats"`
+		} `json:"archives"`
+	}
+	if err := json.Unmarshal(out, &info); err != nil {
+		return nil, fmt.Errorf("parsing borg output: %v", err)
+	}
+	if len(info.Archives) == 0 {
+		return nil, nil
+	}
+	archive := info.Archives[len(info.Archives)-1]
+	start, err := time.ParseInLocation(borgTimeLayout, archive.Start, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("parsing borg archive time %q", archive.Start)
+	}
+	return &latest{name: archive.Name, time: start, size: archive.Stats.OriginalSize}, nil
+}
+
+// latestFile returns the most recently modified regular file matching pattern.
+func latestFile(pattern string) (*latest, error) {
+	if pattern == "" {
+		return nil, errors.New("no file pattern given")
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file pattern %q: %v", pattern, err)
+	}
+	var newest *latest
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() || newest != nil && !info.ModTime().After(newest.time) {
+			continue
+		}
+		newest = &latest{name: path, time: info.ModTime(), size: info.Size()}
+	}
+	return newest, nil
+}
+
+// run executes the backup tool with args appended to Command and returns its standard output.
+func run(ctx context.Context, opts Options, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, opts.Command[0], append(opts.Command[1:len(opts.Command):len(opts.Command)], args...)...)
+	cmd.Env = append(os.Environ(), opts.Env...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running %s: %v: %s", opts.Source, err, msg)
+		}
+		return nil, fmt.Errorf("running %s: %v", opts.Source, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,185 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// TestHelperProcess stands in for restic and borg when run by the tests below. It prints
+// BACKUP_HELPER_OUTPUT if it was called with BACKUP_HELPER_ARGS and fails otherwise.
+func TestHelperProcess(t *testing.T) {
+	output, ok := os.LookupEnv("BACKUP_HELPER_OUTPUT")
+	if !ok {
+		return
+	}
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	if got, want := strings.Join(args, " "), os.Getenv("BACKUP_HELPER_ARGS"); got != want {
+		fmt.Fprintf(os.Stderr, "got args %q, want %q", got, want)
+		os.Exit(2)
+	}
+	fmt.Print(output)
+	os.Exit(0)
+}
+
+// helperCommand returns a Command that expects args and prints output.
+func helperCommand(t *testing.T, args, output string) []string {
+	t.Setenv("BACKUP_HELPER_ARGS", args)
+	t.Setenv("BACKUP_HELPER_OUTPUT", output)
+	return []string{os.Args[0], "-test.run=^TestHelperProcess$", "--"}
+}
+
+func TestCheckRestic(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-2 * time.Hour).UTC().Format(time.RFC3339Nano)
+	older := now.Add(-30 * time.Hour).UTC().Format(time.RFC3339Nano)
+	output := fmt.Sprintf(`[
+{"time":%q,"hostname":"db","paths":["/etc"],"short_id":"aaaa1111"},
+{"time":%q,"hostname":"db","paths":["/var/lib"],"short_id":"bbbb2222","summary":{"total_bytes_processed":2048}}
+]`, older, recent)
+
+	testCases := []struct {
+		name      string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", Options{Warn: 24 * time.Hour}, gomonitor.OK, "latest restic backup bbbb2222 is 2h0m0s old, 2048 bytes"},
+		{"Test Old", Options{Warn: time.Hour, Crit: 3 * time.Hour}, gomonitor.Warning, "latest restic backup bbbb2222"},
+		{"Test Too Old", Options{Warn: time.Hour, Crit: 90 * time.Minute}, gomonitor.Critical, "latest restic backup bbbb2222"},
+		{"Test Small", Options{WarnSize: 4096}, gomonitor.Warning, "latest restic backup bbbb2222"},
+		{"Test Too Small", Options{WarnSize: 8192, CritSize: 4096}, gomonitor.Critical, "latest restic backup bbbb2222"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Source = Restic
+			tc.opts.Repository = "/srv/restic"
+			tc.opts.Command = helperCommand(t, "snapshots --json --latest 1 --repo /srv/restic", output)
+			tc.opts.Clock = gomonitor.NewManualClock(now)
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.HasPrefix(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want prefix %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckResticWithoutSummary(t *testing.T) {
+	output := fmt.Sprintf(`[{"time":%q,"short_id":"aaaa1111"}]`, time.Now().UTC().Format(time.RFC3339Nano))
+	result := Check(context.Background(), Options{
+		Source:   Restic,
+		Command:  helperCommand(t, "snapshots --json --latest 1", output),
+		CritSize: 1,
+	})
+	if result.ExitCode != gomonitor.OK {
+		t.Errorf("got exitCode %s (%s), want OK", result.ExitCode, result.Message)
+	}
+	if _, ok := result.PerformanceData["size"]; ok {
+		t.Error("got size perfdata for a snapshot without a summary")
+	}
+}
+
+func TestCheckBorg(t *testing.T) {
+	// borg writes times with microseconds
+	now := time.Now().Truncate(time.Microsecond)
+	start := now.Add(-26 * time.Hour).Format(borgTimeLayout)
+	output := fmt.Sprintf(`{"archives":[{"name":"host-2024-01-01","start":%q,"stats":{"original_size":1000000}}],
+"repository":{"location":"/srv/borg"}}`, start)
+
+	result := Check(context.Background(), Options{
+		Source:     Borg,
+		Repository: "/srv/borg",
+		Command:    helperCommand(t, "info --json --last 1 /srv/borg", output),
+		Warn:       25 * time.Hour,
+		Crit:       48 * time.Hour,
+		Clock:      gomonitor.NewManualClock(now),
+	})
+	if result.ExitCode != gomonitor.Warning {
+		t.Errorf("got exitCode %s (%s), want Warning", result.ExitCode, result.Message)
+	}
+	if want := "latest borg backup host-2024-01-01 is 26h0m0s old, 1000000 bytes"; result.Message != want {
+		t.Errorf("got message %q, want %q", result.Message, want)
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	for i, name := range []string{"db-1.dump", "db-2.dump", "db-3.dump"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, 100*(i+1)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(-time.Duration(3-i) * time.Hour)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		pattern   string
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Newest", filepath.Join(dir, "*.dump"), gomonitor.OK,
+			"latest files backup " + filepath.Join(dir, "db-3.dump") + " is 1h0m0s old, 300 bytes"},
+		{"Test No Match", filepath.Join(dir, "*.sql"), gomonitor.Critical, "no files backup found"},
+		{"Test Bad Pattern", "[", gomonitor.Unknown, `invalid file pattern "["`},
+		{"Test No Pattern", "", gomonitor.Unknown, "no file pattern given"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), Options{Source: Files, Pattern: tc.pattern, Crit: 2 * time.Hour,
+				Clock: gomonitor.NewManualClock(now)})
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.HasPrefix(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want prefix %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    func(t *testing.T) Options
+		wantMsg string
+	}{
+		{"Test Command Fails", func(t *testing.T) Options {
+			return Options{Source: Restic, Command: helperCommand(t, "unexpected", "")}
+		}, "running restic: exit status 2"},
+		{"Test Bad Output", func(t *testing.T) Options {
+			return Options{Source: Borg, Command: helperCommand(t, "info --json --last 1", "not json")}
+		}, "parsing borg output"},
+		{"Test Unsupported Source", func(t *testing.T) Options {
+			return Options{Source: "tape"}
+		}, `unsupported backup source "tape"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), tc.opts(t))
+			if result.ExitCode != gomonitor.Unknown {
+				t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+			}
+			if !strings.HasPrefix(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want prefix %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
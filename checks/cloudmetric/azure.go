@@ -0,0 +1,98 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cloudmetric
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultAzureURL is the Azure Resource Manager API.
+const DefaultAzureURL = "https://management.azure.com"
+
+// AzureConfig configures the Azure Monitor source.
+// - `ResourceID` is the full ID of the resource, such as "/subscriptions/.../resourceGroups/.../providers/Microsoft.Compute/virtualMachines/web1".
+// - `Metric` is the metric name, such as "Percentage CPU".
+// - `Aggregation` is one of "Average", "Minimum", "Maximum", "Total" or "Count". "" means "Average".
+// - `Token` is an Azure AD access token for the management API.
+// - `URL` is the Resource Manager API. "" means DefaultAzureURL.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+type AzureConfig struct {
+	ResourceID  string
+	Metric      string
+	Aggregation string
+	Token       string
+	URL         string
+	Client      *http.Client
+}
+
+// AzureMonitor returns a Source that reads the newest one-minute aggregate of a resource
+// metric from Azure Monitor.
+func AzureMonitor(cfg AzureConfig) Source {
+	return func(ctx context.Context) (float64, error) {
+		base := cfg.URL
+		if base == "" {
+			base = DefaultAzureURL
+		}
+		aggregation := cfg.Aggregation
+		if aggregation == "" {
+			aggregation = "Average"
+		}
+		now := time.Now().UTC()
+		query := url.Values{
+			"api-version": {"2018-01-01"},
+			"metricnames": {cfg.Metric},
+			"aggregation": {aggregation},
+			"interval":    {"PT1M"},
+			"timespan":    {now.Add(-window).Format(time.RFC3339) + "/" + now.Format(time.RFC3339)},
+		}
+		u := strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(cfg.ResourceID, "/") +
+			"/providers/Microsoft.Insights/metrics?" + query.Encode()
+		raw, err := fetch(ctx, cfg.Client, u, cfg.Token)
+		if err != nil {
+			return 0, err
+		}
+		var resp struct {
+			Value []struct {
+				Timeseries []struct {
+					Data []map[string]any `json:"data"`
+				} `json:"timeseries"`
+			} `json:"value"`
+		}
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return 0, err
+		}
+		// Data points are returned oldest first, and the newest minutes have no aggregate until
+		// Azure has processed them
+		key := strings.ToLower(aggregation[:1]) + aggregation[1:]
+		for _, metric := range resp.Value {
+			for _, series := range metric.Timeseries {
+				for i := len(series.Data) - 1; i >= 0; i-- {
+					if v, ok := series.Data[i][key].(float64); ok {
+						return v, nil
+					}
+				}
+			}
+		}
+		return 0, errors.New("no recent samples for metric " + cfg.Metric)
+	}
+}
@@ -0,0 +1,68 @@
+package cloudmetric
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAzureMonitor(t *testing.T) {
+	const resource = "/subscriptions/s1/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/web1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != resource+"/providers/Microsoft.Insights/metrics" || r.Header.Get("Authorization") != "Bearer eyJ.token" ||
+			query.Get("api-version") == "" || !strings.Contains(query.Get("timespan"), "/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if query.Get("metricnames") != "Percentage CPU" {
+			w.Write([]byte(`{"value":[{"timeseries":[]}]}`))
+			return
+		}
+		// The newest minute has not been aggregated yet
+		w.Write([]byte(`{"value":[{"timeseries":[{"data":[
+			{"timeStamp":"2026-10-15T10:00:00Z","average":12.5,"maximum":40},
+			{"timeStamp":"2026-10-15T10:01:00Z","average":37.25,"maximum":91},
+			{"timeStamp":"2026-10-15T10:02:00Z"}]}]}]}`))
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		name        string
+		metric      string
+		aggregation string
+		want        float64
+		wantErr     string
+	}{
+		{"Test Default Average", "Percentage CPU", "", 37.25, ""},
+		{"Test Maximum", "Percentage CPU", "Maximum", 91, ""},
+		{"Test Missing Aggregation", "Percentage CPU", "Total", 0, "no recent samples for metric Percentage CPU"},
+		{"Test No Series", "Disk Read Bytes", "", 0, "no recent samples for metric Disk Read Bytes"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := AzureConfig{ResourceID: resource, Metric: tc.metric, Aggregation: tc.aggregation, Token: "eyJ.token", URL: srv.URL}
+			got, err := AzureMonitor(cfg)(context.Background())
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	cfg := AzureConfig{ResourceID: resource, Metric: "Percentage CPU", Token: "expired", URL: srv.URL}
+	if _, err := AzureMonitor(cfg)(context.Background()); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("got error %v, want 403", err)
+	}
+}
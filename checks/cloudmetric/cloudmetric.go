@@ -0,0 +1,120 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package cloudmetric checks a metric from a cloud provider's monitoring API, so hybrid
+// environments can alert on cloud metrics from their existing Nagios pipeline. Values come from
+// a Source, so the same thresholds and output work for Azure Monitor and Google Cloud
+// Monitoring.
+package cloudmetric
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// maxResponseSize bounds each API response a source will read.
+const maxResponseSize = 1 << 20
+
+// window is how far back the sources look for samples. Cloud metrics are typically sampled
+// every minute and can take a few more to become visible.
+const window = 10 * time.Minute
+
+// Source returns the newest value of a metric.
+type Source func(ctx context.Context) (float64, error)
+
+// Options configures a cloud metric check.
+// - `Name` identifies the metric in the message and perfdata.
+// - `Source` reads the metric.
+// - `Warn` and `Crit` are values above which the check is Warning or Critical. 0 disables a threshold.
+// - `UnitOM` is the unit of measure of the metric, such as "%".
+// - `Timeout` bounds the source. 0 means 10 seconds.
+type Options struct {
+	Name    string
+	Source  Source
+	Warn    float64
+	Crit    float64
+	UnitOM  string
+	Timeout time.Duration
+}
+
+// Check reads the newest value of the metric and compares it with the thresholds. Source
+// errors are Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Source == nil {
+		result.SetResult(gomonitor.Unknown, "no metric source given")
+		return result
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	value, err := opts.Source(ctx)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("%s: %v", opts.Name, err))
+		return result
+	}
+	metric := gomonitor.PerformanceMetric{
+		Value:  value,
+		Warn:   opts.Warn,
+		Crit:   opts.Crit,
+		UnitOM: opts.UnitOM,
+	}
+	result.AddPerformanceData(opts.Name, metric)
+	result.SetResult(metric.State(), fmt.Sprintf("%s is %s%s", opts.Name, strconv.FormatFloat(value, 'f', -1, 64), opts.UnitOM))
+	return result
+}
+
+// fetch sends a GET for u with the bearer token using client, nil meaning http.DefaultClient,
+// and returns the body of a 200 response. Error responses are reported with the start of their
+// body, where the APIs explain the problem.
+func fetch(ctx context.Context, client *http.Client, u, token string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := tracing.Do(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		detail := strings.TrimSpace(string(raw))
+		if len(detail) > 200 {
+			detail = detail[:200]
+		}
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, detail)
+	}
+	return raw, nil
+}
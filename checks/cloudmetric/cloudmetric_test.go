@@ -0,0 +1,59 @@
+package cloudmetric
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fixed returns a Source that always reports value and err.
+func fixed(value float64, err error) Source {
+	return func(context.Context) (float64, error) {
+		return value, err
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		source    Source
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", fixed(42.5, nil), gomonitor.OK, "cpu is 42.5%"},
+		{"Test At Warning", fixed(80, nil), gomonitor.OK, "cpu is 80%"},
+		{"Test Warning", fixed(80.5, nil), gomonitor.Warning, "cpu is 80.5%"},
+		{"Test Critical", fixed(97.25, nil), gomonitor.Critical, "cpu is 97.25%"},
+		{"Test Source Error", fixed(0, errors.New("access denied")), gomonitor.Unknown, "cpu: access denied"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), Options{Name: "cpu", Source: tc.source, Warn: 80, Crit: 95, UnitOM: "%"})
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPerfdata(t *testing.T) {
+	result := Check(context.Background(), Options{Name: "cpu", Source: fixed(42.5, nil), Warn: 80, Crit: 95, UnitOM: "%"})
+	want := "'cpu'=42.50%;80.00;95.00"
+	if got := result.FormatResult(); !strings.Contains(got, want) {
+		t.Errorf("got output %q, want it to contain %q", got, want)
+	}
+}
+
+func TestCheckNoSource(t *testing.T) {
+	result := Check(context.Background(), Options{Name: "cpu"})
+	if result.ExitCode != gomonitor.Unknown {
+		t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cloudmetric
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMonitoringURL is the Google Cloud Monitoring API.
+const DefaultMonitoringURL = "https://monitoring.googleapis.com"
+
+// CloudMonitoringConfig configures the Google Cloud Monitoring source.
+// - `Project` is the ID of the project the metric belongs to.
+// - `Filter` selects a single time series, such as `metric.type = "compute.googleapis.com/instance/cpu/utilization" AND resource.labels.instance_id = "123"`.
+// - `Token` is an OAuth 2.0 access token with the monitoring.read scope.
+// - `URL` is the Cloud Monitoring API. "" means DefaultMonitoringURL.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+type CloudMonitoringConfig struct {
+	Project string
+	Filter  string
+	Token   string
+	URL     string
+	Client  *http.Client
+}
+
+// CloudMonitoring returns a Source that reads the newest value of the first time series
+// matching the filter from Cloud Monitoring. Double and integer metrics are supported.
+func CloudMonitoring(cfg CloudMonitoringConfig) Source {
+	return func(ctx context.Context) (float64, error) {
+		base := cfg.URL
+		if base == "" {
+			base = DefaultMonitoringURL
+		}
+		now := time.Now().UTC()
+		query := url.Values{
+			"filter":             {cfg.Filter},
+			"interval.startTime": {now.Add(-window).Format(time.RFC3339)},
+			"interval.endTime":   {now.Format(time.RFC3339)},
+		}
+		u := strings.TrimSuffix(base, "/") + "/v3/projects/" + url.PathEscape(cfg.Project) + "/timeSeries?" + query.Encode()
+		raw, err := fetch(ctx, cfg.Client, u, cfg.Token)
+		if err != nil {
+			return 0, err
+		}
+		var resp struct {
+			TimeSeries []struct {
+				Points []struct {
+					Value struct {
+						DoubleValue *float64 `json:"doubleValue"`
+						Int64Value  *string  `json:"int64Value"`
+					} `json:"value"`
+				} `json:"points"`
+			} `json:"timeSeries"`
+		}
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return 0, err
+		}
+		// Points are returned newest first
+		if len(resp.TimeSeries) == 0 || len(resp.TimeSeries[0].Points) == 0 {
+			return 0, errors.New("no recent samples for " + cfg.Filter)
+		}
+		value := resp.TimeSeries[0].Points[0].Value
+		switch {
+		case value.DoubleValue != nil:
+			return *value.DoubleValue, nil
+		case value.Int64Value != nil:
+			v, err := strconv.ParseInt(*value.Int64Value, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid sample %q", *value.Int64Value)
+			}
+			return float64(v), nil
+		default:
+			return 0, errors.New("unsupported metric value type")
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package cloudmetric
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCloudMonitoring(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/projects/acme/timeSeries" || r.Header.Get("Authorization") != "Bearer ya29.token" ||
+			r.URL.Query().Get("interval.startTime") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Query().Get("filter") {
+		case "cpu":
+			w.Write([]byte(`{"timeSeries":[{"points":[{"value":{"doubleValue":0.42}},{"value":{"doubleValue":0.1}}]}]}`))
+		case "connections":
+			w.Write([]byte(`{"timeSeries":[{"points":[{"value":{"int64Value":"250"}}]}]}`))
+		case "up":
+			w.Write([]byte(`{"timeSeries":[{"points":[{"value":{"boolValue":true}}]}]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		name    string
+		filter  string
+		want    float64
+		wantErr string
+	}{
+		{"Test Double", "cpu", 0.42, ""},
+		{"Test Int64", "connections", 250, ""},
+		{"Test Unsupported Type", "up", 0, "unsupported metric value type"},
+		{"Test No Series", "idle", 0, "no recent samples for idle"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := CloudMonitoringConfig{Project: "acme", Filter: tc.filter, Token: "ya29.token", URL: srv.URL}
+			got, err := CloudMonitoring(cfg)(context.Background())
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	cfg := CloudMonitoringConfig{Project: "acme", Filter: "cpu", Token: "expired", URL: srv.URL}
+	if _, err := CloudMonitoring(cfg)(context.Background()); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("got error %v, want 403", err)
+	}
+}
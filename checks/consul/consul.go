@@ -0,0 +1,113 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package consul checks the Raft leader and peer status of a Consul cluster.
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// maxResponseSize bounds each response the check will read.
+const maxResponseSize = 1 << 20
+
+// Options configures a Consul check.
+// - `URL` is the address of the HTTP API, such as "http://127.0.0.1:8500".
+// - `Token` is the ACL token sent with each request. It may be empty.
+// - `WarnPeers` and `CritPeers` are Raft peer counts below which the check is Warning or Critical. 0 disables a threshold.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+// - `Timeout` bounds all requests. 0 means 10 seconds.
+type Options struct {
+	URL       string
+	Token     string
+	WarnPeers int
+	CritPeers int
+	Client    *http.Client
+	Timeout   time.Duration
+}
+
+// Check asks the agent for the current Raft leader and peer set. A cluster without a leader is
+// Critical. Failing to reach the agent is Critical.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	base := strings.TrimSuffix(opts.URL, "/")
+
+	var leader string
+	if err := get(ctx, opts, base+"/v1/status/leader", &leader); err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("leader request failed: %v", err))
+		return result
+	}
+	var peers []string
+	if err := get(ctx, opts, base+"/v1/status/peers", &peers); err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("peers request failed: %v", err))
+		return result
+	}
+	metric := gomonitor.PerformanceMetric{
+		Value:     float64(len(peers)),
+		WarnRange: gomonitor.LowerBound(float64(opts.WarnPeers)),
+		CritRange: gomonitor.LowerBound(float64(opts.CritPeers)),
+		Integer:   true,
+	}
+	result.AddPerformanceData("peers", metric)
+
+	if leader == "" {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("no leader, %d peer(s)", len(peers)))
+		return result
+	}
+	result.SetResult(metric.State(), fmt.Sprintf("leader %s, %d peer(s)", leader, len(peers)))
+	return result
+}
+
+// get requests url and decodes the JSON body of a 200 response into v.
+func get(ctx context.Context, opts Options, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if opts.Token != "" {
+		req.Header.Set("X-Consul-Token", opts.Token)
+	}
+	resp, err := tracing.Do(opts.Client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,76 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		leader    string
+		peers     string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", `"10.0.0.1:8300"`, `["10.0.0.1:8300","10.0.0.2:8300","10.0.0.3:8300"]`,
+			Options{WarnPeers: 3, CritPeers: 2}, gomonitor.OK, "leader 10.0.0.1:8300, 3 peer(s)"},
+		{"Test Peers Warning", `"10.0.0.1:8300"`, `["10.0.0.1:8300","10.0.0.2:8300"]`,
+			Options{WarnPeers: 3, CritPeers: 2}, gomonitor.Warning, ""},
+		{"Test Peers Critical", `"10.0.0.1:8300"`, `["10.0.0.1:8300"]`,
+			Options{WarnPeers: 3, CritPeers: 2}, gomonitor.Critical, ""},
+		{"Test No Leader", `""`, `[]`, Options{}, gomonitor.Critical, "no leader, 0 peer(s)"},
+		{"Test Bad Token", `""`, `[]`, Options{Token: "wrong"}, gomonitor.Critical, "leader request failed: unexpected status 403 Forbidden"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if token := r.Header.Get("X-Consul-Token"); token != "" && token != "secret" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				switch r.URL.Path {
+				case "/v1/status/leader":
+					w.Write([]byte(tc.leader))
+				case "/v1/status/peers":
+					w.Write([]byte(tc.peers))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			tc.opts.URL = srv.URL
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if tc.wantMsg != "" && result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPerfdata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/status/leader" {
+			w.Write([]byte(`"10.0.0.1:8300"`))
+			return
+		}
+		w.Write([]byte(`["10.0.0.1:8300","10.0.0.2:8300"]`))
+	}))
+	defer srv.Close()
+
+	result := Check(context.Background(), Options{URL: srv.URL, WarnPeers: 3})
+	if want := "'peers'=2;3:;"; !strings.Contains(result.FormatResult(), want) {
+		t.Errorf("got output %q, want it to contain %q", result.FormatResult(), want)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package container checks the images of running Docker containers for age and digest drift,
+// using the Docker Engine API.
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// DefaultHost is the Docker daemon's default socket.
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// maxResponseSize bounds each Docker API response the check will read.
+const maxResponseSize = 10 << 20
+
+// Options configures a container image check.
+// - `Host` is the Docker daemon address in DOCKER_HOST form: a unix:// socket, a tcp:// address or an http(s):// URL. "" means DefaultHost.
+// - `Warn` and `Crit` are image ages above which a container is Warning or Critical. 0 disables a threshold.
+// - `AllowedDigests` lists the image digests ("sha256:...") containers may run. If set, a container whose image matches none of them is Critical.
+// - `Timeout` bounds all API requests. 0 means 10 seconds.
+type Options struct {
+	Host           string
+	Warn           time.Duration
+	Crit           time.Duration
+	AllowedDigests []string
+	Timeout        time.Duration
+}
+
+// containerInfo is the part of a /containers/json entry the check uses.
+type containerInfo struct {
+	Names   []string `json:"Names"`
+	Image   string   `json:"Image"`
+	ImageID string   `json:"ImageID"`
+}
+
+// imageInfo is the part of a /images/{id}/json response the check uses.
+type imageInfo struct {
+	Created     time.Time `json:"Created"`
+	RepoDigests []string  `json:"RepoDigests"`
+}
+
+// Check lists the running containers, inspects each image and compares its age and digest with
+// the options. The oldest image age is recorded as perfdata. Failing to reach the daemon is
+// Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Host == "" {
+		opts.Host = DefaultHost
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	api, err := newClient(opts.Host)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+
+	var containers []containerInfo
+	if err := api.get(ctx, "/containers/json", &containers); err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("listing containers: %v", err))
+		return result
+	}
+
+	images := make(map[string]imageInfo)
+	state := gomonitor.OK
+	ages := gomonitor.PerformanceMetric{Warn: opts.Warn.Seconds(), Crit: opts.Crit.Seconds(), UnitOM: "s"}
+	var problems []string
+	var oldest time.Duration
+	for _, c := range containers {
+		img, ok := images[c.ImageID]
+		if !ok {
+			if err := api.get(ctx, "/images/"+url.PathEscape(c.ImageID)+"/json", &img); err != nil {
+				result.SetResult(gomonitor.Unknown, fmt.Sprintf("inspecting image %s: %v", c.Image, err))
+				return result
+			}
+			images[c.ImageID] = img
+		}
+		name := c.ImageID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+
+		age := time.Since(img.Created)
+		oldest = max(oldest, age)
+		days := int(age.Hours() / 24)
+		ages.Value = age.Seconds()
+		switch {
+		case len(opts.AllowedDigests) > 0 && !allowed(img, c.ImageID, opts.AllowedDigests):
+			state = gomonitor.Critical
+			problems = append(problems, fmt.Sprintf("%s runs unapproved image %s", name, c.Image))
+		case ages.State() != gomonitor.OK:
+			state = max(state, ages.State())
+			problems = append(problems, fmt.Sprintf("%s image %s is %d day(s) old", name, c.Image, days))
+		}
+	}
+
+	result.AddPerformanceData("containers", gomonitor.PerformanceMetric{Value: float64(len(containers))})
+	ages.Value = oldest.Seconds()
+	result.AddPerformanceData("oldest_image", ages)
+	msg := fmt.Sprintf("%d container(s) running current images", len(containers))
+	if len(problems) > 0 {
+		msg = fmt.Sprintf("%d of %d container(s) drifted: %s", len(problems), len(containers), strings.Join(problems, ", "))
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// allowed reports whether the image ID or any of the image's repository digests is in digests.
+// Repository digests look like "repo@sha256:...", so only the part after "@" is compared.
+func allowed(img imageInfo, imageID string, digests []string) bool {
+	if slices.Contains(digests, imageID) {
+		return true
+	}
+	for _, repoDigest := range img.RepoDigests {
+		_, digest, _ := strings.Cut(repoDigest, "@")
+		if slices.Contains(digests, digest) {
+			return true
+		}
+	}
+	return false
+}
+
+// client talks to the Docker Engine API over a unix socket or HTTP.
+type client struct {
+	http *http.Client
+	base string
+}
+
+// newClient returns a client for host, a unix:// socket path, a plain tcp:// address or an
+// http(s):// URL.
+func newClient(host string) (*client, error) {
+	if path, ok := strings.CutPrefix(host, "unix://"); ok {
+		transport := &http.Transport{
+			// Each check builds its own transport, so don't leave idle connections behind
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+		// The host name is ignored when dialing the socket
+		return &client{http: &http.Client{Transport: transport}, base: "http://docker"}, nil
+	}
+	if addr, ok := strings.CutPrefix(host, "tcp://"); ok {
+		host = "http://" + addr
+	}
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+		return &client{http: http.DefaultClient, base: strings.TrimSuffix(host, "/")}, nil
+	}
+	return nil, fmt.Errorf("unsupported Docker host %q", host)
+}
+
+// get requests path and decodes the JSON response into v.
+func (c *client) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := tracing.Do(c.http, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
@@ -0,0 +1,132 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeDocker serves the containers and images endpoints of the Docker Engine API.
+func fakeDocker(containers []containerInfo, images map[string]imageInfo) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /containers/json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(containers)
+	})
+	mux.HandleFunc("GET /images/{id}/json", func(w http.ResponseWriter, r *http.Request) {
+		img, ok := images[r.PathValue("id")]
+		if !ok {
+			http.Error(w, `{"message":"no such image"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(img)
+	})
+	return mux
+}
+
+func TestCheck(t *testing.T) {
+	day := 24 * time.Hour
+	images := map[string]imageInfo{
+		"sha256:new":     {Created: time.Now().Add(-2 * day), RepoDigests: []string{"nginx@sha256:aaa"}},
+		"sha256:old":     {Created: time.Now().Add(-40 * day), RepoDigests: []string{"redis@sha256:bbb"}},
+		"sha256:ancient": {Created: time.Now().Add(-200 * day)},
+	}
+	containers := []containerInfo{
+		{Names: []string{"/web"}, Image: "nginx:1.27", ImageID: "sha256:new"},
+		{Names: []string{"/web2"}, Image: "nginx:1.27", ImageID: "sha256:new"},
+		{Names: []string{"/cache"}, Image: "redis:7", ImageID: "sha256:old"},
+	}
+	srv := httptest.NewServer(fakeDocker(containers, images))
+	defer srv.Close()
+
+	testCases := []struct {
+		name      string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test TCP Host", Options{Host: "tcp://" + strings.TrimPrefix(srv.URL, "http://")}, gomonitor.OK, "3 container(s) running current images"},
+		{"Test OK", Options{Warn: 90 * day}, gomonitor.OK, "3 container(s) running current images"},
+		{"Test Warning", Options{Warn: 30 * day, Crit: 90 * day}, gomonitor.Warning,
+			"1 of 3 container(s) drifted: cache image redis:7 is 40 day(s) old"},
+		{"Test Critical", Options{Warn: time.Hour, Crit: 30 * day}, gomonitor.Critical,
+			"3 of 3 container(s) drifted: web image nginx:1.27 is 2 day(s) old, web2 image nginx:1.27 is 2 day(s) old, cache image redis:7 is 40 day(s) old"},
+		{"Test Allowed Digests", Options{AllowedDigests: []string{"sha256:aaa", "sha256:old"}}, gomonitor.OK,
+			"3 container(s) running current images"},
+		{"Test Unapproved Digest", Options{AllowedDigests: []string{"sha256:aaa"}}, gomonitor.Critical,
+			"1 of 3 container(s) drifted: cache runs unapproved image redis:7"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.opts.Host == "" {
+				tc.opts.Host = srv.URL
+			}
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(fakeDocker(
+		[]containerInfo{{Names: []string{"/db"}, Image: "postgres:16", ImageID: "sha256:pg"}},
+		map[string]imageInfo{"sha256:pg": {Created: time.Now().Add(-time.Hour)}},
+	))
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+
+	result := Check(context.Background(), Options{Host: "unix://" + path, Warn: 24 * time.Hour})
+	if result.ExitCode != gomonitor.OK {
+		t.Errorf("got exitCode %s (%s), want OK", result.ExitCode, result.Message)
+	}
+	if got := result.PerformanceData["oldest_image"].Value; got < 3600 || got > 3700 {
+		t.Errorf("got oldest_image %v, want about 3600", got)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	srv := httptest.NewServer(fakeDocker(
+		[]containerInfo{{Names: []string{"/db"}, Image: "postgres:16", ImageID: "sha256:gone"}}, nil))
+	defer srv.Close()
+
+	testCases := []struct {
+		name    string
+		host    string
+		wantMsg string
+	}{
+		{"Test Missing Image", srv.URL, "inspecting image postgres:16: unexpected status 404 Not Found"},
+		{"Test No Daemon", "unix://" + filepath.Join(t.TempDir(), "missing.sock"), "listing containers"},
+		{"Test Bad Host", "ssh://docker@host", `unsupported Docker host "ssh://docker@host"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), Options{Host: tc.host})
+			if result.ExitCode != gomonitor.Unknown {
+				t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+			}
+			if !strings.HasPrefix(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want prefix %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package crash checks for new core dumps and OOM-killer events since the previous run, using
+// coredumpctl and the kernel log in the systemd journal.
+package crash
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// maxSamples is how many events of each kind are described in the long output.
+const maxSamples = 3
+
+// oomPattern matches the kernel's OOM-killer report, in both the "Killed process" wording of
+// current kernels and the "Kill process" wording of older ones.
+var oomPattern = regexp.MustCompile(`[Oo]ut of memory: Kill(?:ed)? process (\d+) \(([^)]*)\)`)
+
+// Options configures a crash check.
+// - `StateFile` records when the previous run scanned up to. The check needs write access to it.
+// - `Lookback` is how far back the first run, without a state file, looks. 0 means 24 hours.
+// - `WarnCoredumps` and `CritCoredumps` are numbers of new core dumps above which the check is Warning or Critical. 0 disables a threshold.
+// - `WarnOOMKills` and `CritOOMKills` are numbers of new OOM kills above which the check is Warning or Critical. 0 disables a threshold.
+// - `Coredumpctl` and `Journalctl` are the programs to run, with any leading arguments. They default to "coredumpctl" and "journalctl".
+// - `Timeout` bounds each command. 0 means 30 seconds.
+type Options struct {
+	StateFile     string
+	Lookback      time.Duration
+	WarnCoredumps int
+	CritCoredumps int
+	WarnOOMKills  int
+	CritOOMKills  int
+	Coredumpctl   []string
+	Journalctl    []string
+	Timeout       time.Duration
+}
+
+// coredump is the part of a coredumpctl JSON entry the check uses.
+type coredump struct {
+	Time int64  `json:"time"`
+	PID  int    `json:"pid"`
+	Sig  int    `json:"sig"`
+	Exe  string `json:"exe"`
+}
+
+// Check lists the core dumps and OOM kills since the time in StateFile and compares their
+// numbers with the thresholds. The first few events of each kind are described in the long
+// output. The state file is only advanced after both sources were read,
+// so events are never skipped. Failing to run either command or to update the state file is
+// Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Lookback == 0 {
+		opts.Lookback = 24 * time.Hour
+	}
+	if len(opts.Coredumpctl) == 0 {
+		opts.Coredumpctl = []string{"coredumpctl"}
+	}
+	if len(opts.Journalctl) == 0 {
+		opts.Journalctl = []string{"journalctl"}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.StateFile == "" {
+		result.SetResult(gomonitor.Unknown, "no state file given")
+		return result
+	}
+
+	now := time.Now()
+	since, err := readState(opts.StateFile, now.Add(-opts.Lookback))
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	dumps, err := coredumps(ctx, opts, since)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	kills, err := oomKills(ctx, opts, since)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	if err := os.WriteFile(opts.StateFile, []byte(strconv.FormatInt(now.Unix(), 10)+"\n"), 0o644); err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("updating state: %v", err))
+		return result
+	}
+
+	result.AddPerformanceData("coredumps", gomonitor.PerformanceMetric{
+		Value:   float64(len(dumps)),
+		Warn:    float64(opts.WarnCoredumps),
+		Crit:    float64(opts.CritCoredumps),
+		Integer: true,
+	})
+	result.AddPerformanceData("oom_kills", gomonitor.PerformanceMetric{
+		Value:   float64(len(kills)),
+		Warn:    float64(opts.WarnOOMKills),
+		Crit:    float64(opts.CritOOMKills),
+		Integer: true,
+	})
+
+	for _, d := range dumps[:min(len(dumps), maxSamples)] {
+		result.AddLongOutput(fmt.Sprintf("%s (PID %d) dumped core on signal %d", d.Exe, d.PID, d.Sig))
+	}
+	for _, kill := range kills[:min(len(kills), maxSamples)] {
+		result.AddLongOutput(kill)
+	}
+
+	result.Evaluate()
+	result.SetResult(result.ExitCode, fmt.Sprintf("%d core dump(s) and %d OOM kill(s) since %s", len(dumps), len(kills), since.UTC().Format(time.RFC3339)))
+	return result
+}
+
+// readState returns the time stored in the state file, or fallback if there is none yet.
+func readState(path string, fallback time.Time) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fallback, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading state: %v", err)
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid state in %s: %q", path, strings.TrimSpace(string(data)))
+	}
+	return time.Unix(sec, 0), nil
+}
+
+// coredumps lists the core dumps since the given time, oldest first.
+func coredumps(ctx context.Context, opts Options, since time.Time) ([]coredump, error) {
+	out, stderr, err := run(ctx, opts, opts.Coredumpctl, "--no-pager", "--json=short", sinceArg(since), "list")
+	// coredumpctl fails when there is nothing to list
+	if err != nil && strings.Contains(stderr, "No coredumps found") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dumps []coredump
+	if err := json.Unmarshal(out, &dumps); err != nil {
+		return nil, fmt.Errorf("parsing coredumpctl output: %v", err)
+	}
+	return dumps, nil
+}
+
+// oomKills describes the OOM kills in the kernel log since the given time, oldest first.
+func oomKills(ctx context.Context, opts Options, since time.Time) ([]string, error) {
+	out, _, err := run(ctx, opts, opts.Journalctl, "--no-pager", "--quiet", "--dmesg", "--output=cat", sinceArg(since))
+	if err != nil {
+		return nil, err
+	}
+	var kills []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if m := oomPattern.FindStringSubmatch(scanner.Text()); m != nil {
+			kills = append(kills, fmt.Sprintf("%s (PID %s) killed by the OOM killer", m[2], m[1]))
+		}
+	}
+	return kills, scanner.Err()
+}
+
+// sinceArg returns the --since argument for t, which both coredumpctl and journalctl accept.
+func sinceArg(t time.Time) string {
+	return "--since=@" + strconv.FormatInt(t.Unix(), 10)
+}
+
+// run runs command with args appended and returns its standard output and error.
+func run(ctx context.Context, opts Options, command []string, args ...string) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], append(slices.Clone(command[1:]), args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	msg := strings.TrimSpace(stderr.String())
+	if err != nil {
+		if msg != "" {
+			return nil, msg, fmt.Errorf("running %s: %v: %s", command[0], err, msg)
+		}
+		return nil, msg, fmt.Errorf("running %s: %v", command[0], err)
+	}
+	return out, msg, nil
+}
@@ -0,0 +1,194 @@
+package crash
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+const dumpsJSON = `[
+{"time":1700000000000000,"pid":4242,"uid":0,"gid":0,"sig":11,"corefile":"present","exe":"/usr/bin/foo","size":1024},
+{"time":1700000100000000,"pid":4343,"uid":0,"gid":0,"sig":6,"corefile":"missing","exe":"/usr/sbin/bar","size":null}
+]`
+
+const kernelLog = `eth0: link up
+Out of memory: Killed process 1234 (java) total-vm:1000kB, anon-rss:900kB, file-rss:0kB
+oom_reaper: reaped process 1234 (java), now anon-rss:0kB
+Out of memory: Kill process 99 (postgres) score 900 or sacrifice child
+`
+
+// TestHelperProcess stands in for coredumpctl and journalctl when run by the tests below. The
+// program name after "--" selects which CRASH_HELPER_<NAME>_* variables describe its behavior.
+func TestHelperProcess(t *testing.T) {
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	if len(args) == 0 {
+		return
+	}
+	prefix := "CRASH_HELPER_" + strings.ToUpper(args[0]) + "_"
+	output, ok := os.LookupEnv(prefix + "OUTPUT")
+	if !ok {
+		return
+	}
+	if got, want := strings.Join(args[1:], " "), os.Getenv(prefix+"ARGS"); got != want {
+		fmt.Fprintf(os.Stderr, "got args %q, want %q", got, want)
+		os.Exit(2)
+	}
+	fmt.Print(output)
+	if status := os.Getenv(prefix + "STATUS"); status != "" {
+		fmt.Fprint(os.Stderr, os.Getenv(prefix+"STDERR"))
+		code, _ := strconv.Atoi(status)
+		os.Exit(code)
+	}
+	os.Exit(0)
+}
+
+// helperCommand returns a command standing in for name that expects args, prints output and
+// exits with status, writing stderr if status is not 0.
+func helperCommand(t *testing.T, name, args, output string, status int, stderr string) []string {
+	prefix := "CRASH_HELPER_" + strings.ToUpper(name) + "_"
+	t.Setenv(prefix+"ARGS", args)
+	t.Setenv(prefix+"OUTPUT", output)
+	if status != 0 {
+		t.Setenv(prefix+"STATUS", strconv.Itoa(status))
+		t.Setenv(prefix+"STDERR", stderr)
+	}
+	return []string{os.Args[0], "-test.run=^TestHelperProcess$", "--", name}
+}
+
+// stateFile returns the path of a state file recording since.
+func stateFile(t *testing.T, since int64) string {
+	path := filepath.Join(t.TempDir(), "crash.state")
+	if err := os.WriteFile(path, []byte(strconv.FormatInt(since, 10)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheck(t *testing.T) {
+	const since = 1700000000
+	coredumpArgs := "--no-pager --json=short --since=@1700000000 list"
+	journalArgs := "--no-pager --quiet --dmesg --output=cat --since=@1700000000"
+	noDumps := "No coredumps found.\n"
+
+	testCases := []struct {
+		name       string
+		dumps      string
+		dumpsExit  int
+		journal    string
+		opts       Options
+		wantState  gomonitor.ExitCode
+		wantOutput string
+	}{
+		{"Test quiet", "", 1, "eth0: link up\n", Options{WarnCoredumps: 1, WarnOOMKills: 1}, gomonitor.OK, "0 core dump(s) and 0 OOM kill(s)"},
+		{"Test core dumps warning", dumpsJSON, 0, "", Options{WarnCoredumps: 1, CritCoredumps: 5}, gomonitor.Warning, "/usr/bin/foo (PID 4242) dumped core on signal 11"},
+		{"Test core dumps at critical", dumpsJSON, 0, "", Options{WarnCoredumps: 1, CritCoredumps: 2}, gomonitor.Warning, "2 core dump(s)"},
+		{"Test core dumps critical", dumpsJSON, 0, "", Options{WarnCoredumps: 1, CritCoredumps: 1}, gomonitor.Critical, "2 core dump(s)"},
+		{"Test OOM kills critical", "", 1, kernelLog, Options{CritOOMKills: 1}, gomonitor.Critical, "java (PID 1234) killed by the OOM killer\npostgres (PID 99)"},
+		{"Test thresholds disabled", dumpsJSON, 0, kernelLog, Options{}, gomonitor.OK, "2 core dump(s) and 2 OOM kill(s)"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts
+			opts.StateFile = stateFile(t, since)
+			opts.Coredumpctl = helperCommand(t, "coredumpctl", coredumpArgs, tc.dumps, tc.dumpsExit, noDumps)
+			opts.Journalctl = helperCommand(t, "journalctl", journalArgs, tc.journal, 0, "")
+			result := Check(context.Background(), opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if got := result.FormatResult(); !strings.Contains(got, tc.wantOutput) {
+				t.Errorf("got output %q, want it to contain %q", got, tc.wantOutput)
+			}
+		})
+	}
+}
+
+func TestCheckUpdatesState(t *testing.T) {
+	path := stateFile(t, 1700000000)
+	opts := Options{
+		StateFile:   path,
+		Coredumpctl: helperCommand(t, "coredumpctl", "--no-pager --json=short --since=@1700000000 list", "[]", 0, ""),
+		Journalctl:  helperCommand(t, "journalctl", "--no-pager --quiet --dmesg --output=cat --since=@1700000000", "", 0, ""),
+	}
+	before := time.Now().Unix()
+	result := Check(context.Background(), opts)
+	if result.ExitCode != gomonitor.OK {
+		t.Fatalf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, gomonitor.OK)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || got < before {
+		t.Errorf("got state %q, want a time at or after %d", data, before)
+	}
+}
+
+func TestCheckPerformanceData(t *testing.T) {
+	opts := Options{
+		StateFile:     stateFile(t, 1700000000),
+		WarnCoredumps: 1,
+		CritOOMKills:  3,
+		Coredumpctl:   helperCommand(t, "coredumpctl", "--no-pager --json=short --since=@1700000000 list", dumpsJSON, 0, ""),
+		Journalctl:    helperCommand(t, "journalctl", "--no-pager --quiet --dmesg --output=cat --since=@1700000000", kernelLog, 0, ""),
+	}
+	result := Check(context.Background(), opts)
+	want := "'coredumps'=2;1;0;0;0 'oom_kills'=2;0;3;0;0"
+	if got := result.FormatResult(); !strings.Contains(got, want) {
+		t.Errorf("got %q, want perfdata %q", got, want)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		setup func(t *testing.T) Options
+	}{
+		{"Test no state file", func(t *testing.T) Options {
+			return Options{}
+		}},
+		{"Test invalid state", func(t *testing.T) Options {
+			path := filepath.Join(t.TempDir(), "crash.state")
+			os.WriteFile(path, []byte("yesterday"), 0o644)
+			return Options{StateFile: path}
+		}},
+		{"Test coredumpctl fails", func(t *testing.T) Options {
+			return Options{
+				StateFile:   stateFile(t, 1700000000),
+				Coredumpctl: helperCommand(t, "coredumpctl", "--no-pager --json=short --since=@1700000000 list", "", 1, "permission denied"),
+			}
+		}},
+		{"Test invalid coredumpctl output", func(t *testing.T) Options {
+			return Options{
+				StateFile:   stateFile(t, 1700000000),
+				Coredumpctl: helperCommand(t, "coredumpctl", "--no-pager --json=short --since=@1700000000 list", "not json", 0, ""),
+			}
+		}},
+		{"Test journalctl fails", func(t *testing.T) Options {
+			return Options{
+				StateFile:   stateFile(t, 1700000000),
+				Coredumpctl: helperCommand(t, "coredumpctl", "--no-pager --json=short --since=@1700000000 list", "[]", 0, ""),
+				Journalctl:  helperCommand(t, "journalctl", "--no-pager --quiet --dmesg --output=cat --since=@1700000000", "", 1, "No journal files were found."),
+			}
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), tc.setup(t))
+			if result.ExitCode != gomonitor.Unknown {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, gomonitor.Unknown)
+			}
+		})
+	}
+}
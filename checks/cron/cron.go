@@ -0,0 +1,153 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package cron checks scheduled jobs through sentinel files. A job records each run with Write
+// or Run, and Check alerts when the last run failed or is older than the schedule allows.
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Sentinel records a single run of a job.
+// - `Job` names the job.
+// - `Start` and `End` are when the run started and finished.
+// - `ExitCode` is the job's exit status. 0 is success.
+// - `Message` is optional detail about the run, such as an error.
+type Sentinel struct {
+	Job      string    `json:"job"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	ExitCode int       `json:"exit_code"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// Write atomically replaces the sentinel file at path, so Check never sees a partial write.
+func Write(path string, s Sentinel) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Run runs cmd and records the outcome in the sentinel file at path. It returns the error from
+// running cmd, or the error from writing the sentinel if the command succeeded.
+func Run(path, job string, cmd *exec.Cmd) error {
+	s := Sentinel{Job: job, Start: time.Now()}
+	runErr := cmd.Run()
+	s.End = time.Now()
+	var exitErr *exec.ExitError
+	switch {
+	case runErr == nil:
+	case errors.As(runErr, &exitErr) && exitErr.ExitCode() > 0:
+		s.ExitCode = exitErr.ExitCode()
+		s.Message = runErr.Error()
+	default:
+		// The command could not be started or was killed by a signal
+		s.ExitCode = -1
+		s.Message = runErr.Error()
+	}
+	if err := Write(path, s); runErr == nil {
+		return err
+	}
+	return runErr
+}
+
+// Options configures a sentinel check.
+// - `Path` is the sentinel file the job writes.
+// - `Warn` and `Crit` are ages of the last run above which the check is Warning or Critical. 0 disables a threshold.
+// - `Clock` tells the time the last run's age is measured at. nil means gomonitor.SystemClock.
+type Options struct {
+	Path  string
+	Warn  time.Duration
+	Crit  time.Duration
+	Clock gomonitor.Clock
+}
+
+// Check reads the sentinel at Path. A failed last run, or a missing sentinel, is Critical. A
+// sentinel that cannot be read or parsed is Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	data, err := os.ReadFile(opts.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("no sentinel at %s, job has never run", opts.Path))
+		return result
+	}
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	var s Sentinel
+	if err := json.Unmarshal(data, &s); err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("parsing sentinel %s: %v", opts.Path, err))
+		return result
+	}
+	job := s.Job
+	if job == "" {
+		job = filepath.Base(opts.Path)
+	}
+
+	age := gomonitor.ClockOrSystem(opts.Clock).Now().Sub(s.End)
+	metric := gomonitor.PerformanceMetric{
+		Value:  age.Seconds(),
+		Warn:   opts.Warn.Seconds(),
+		Crit:   opts.Crit.Seconds(),
+		UnitOM: "s",
+	}
+	result.AddPerformanceData("age", metric)
+	result.AddPerformanceData("duration", gomonitor.PerformanceMetric{
+		Value:  s.End.Sub(s.Start).Seconds(),
+		UnitOM: "s",
+	})
+	if s.ExitCode != 0 {
+		msg := fmt.Sprintf("%s failed with exit code %d %s ago", job, s.ExitCode, age.Round(time.Second))
+		if s.Message != "" {
+			msg += ": " + s.Message
+		}
+		result.SetResult(gomonitor.Critical, msg)
+		return result
+	}
+
+	result.SetResult(metric.State(), fmt.Sprintf("%s last succeeded %s ago", job, age.Round(time.Second)))
+	return result
+}
@@ -0,0 +1,128 @@
+package cron
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// TestHelperProcess stands in for a cron job, exiting with CRON_HELPER_EXIT.
+func TestHelperProcess(t *testing.T) {
+	code, ok := os.LookupEnv("CRON_HELPER_EXIT")
+	if !ok {
+		return
+	}
+	n, _ := strconv.Atoi(code)
+	os.Exit(n)
+}
+
+func helperCommand(exitCode int) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), "CRON_HELPER_EXIT="+strconv.Itoa(exitCode))
+	return cmd
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	sentinels := map[string]Sentinel{
+		"fresh":   {Job: "backup", Start: now.Add(-time.Hour - time.Minute), End: now.Add(-time.Hour)},
+		"stale":   {Job: "backup", Start: now.Add(-26 * time.Hour), End: now.Add(-25 * time.Hour)},
+		"failed":  {Job: "backup", Start: now.Add(-time.Hour), End: now.Add(-time.Hour), ExitCode: 2, Message: "disk full"},
+		"unnamed": {Start: now.Add(-time.Hour), End: now.Add(-time.Hour)},
+	}
+	for name, s := range sentinels {
+		if err := Write(filepath.Join(dir, name), s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "garbage"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		file      string
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Fresh", "fresh", gomonitor.OK, "backup last succeeded 1h0m0s ago"},
+		{"Test Warning", "stale", gomonitor.Warning, "backup last succeeded 25h0m0s ago"},
+		{"Test Failed", "failed", gomonitor.Critical, "backup failed with exit code 2 1h0m0s ago: disk full"},
+		{"Test Unnamed", "unnamed", gomonitor.OK, "unnamed last succeeded 1h0m0s ago"},
+		{"Test Missing", "missing", gomonitor.Critical, "no sentinel at"},
+		{"Test Garbage", "garbage", gomonitor.Unknown, "parsing sentinel"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), Options{
+				Path:  filepath.Join(dir, tc.file),
+				Warn:  24 * time.Hour,
+				Crit:  48 * time.Hour,
+				Clock: gomonitor.NewManualClock(now),
+			})
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.HasPrefix(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want prefix %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cmd       *exec.Cmd
+		wantCode  int
+		wantErr   bool
+		wantState gomonitor.ExitCode
+	}{
+		{"Test Success", helperCommand(0), 0, false, gomonitor.OK},
+		{"Test Failure", helperCommand(3), 3, true, gomonitor.Critical},
+		{"Test Not Started", exec.Command(filepath.Join(t.TempDir(), "missing")), -1, true, gomonitor.Critical},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "job.json")
+			err := Run(path, "job", tc.cmd)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+			result := Check(context.Background(), Options{Path: path})
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if tc.wantCode != 0 && !strings.Contains(result.Message, "exit code "+strconv.Itoa(tc.wantCode)) {
+				t.Errorf("got message %q, want exit code %d", result.Message, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestWriteLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "job.json")
+	for range 3 {
+		if err := Write(path, Sentinel{Job: "job", End: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files in the sentinel directory, want 1", len(entries))
+	}
+}
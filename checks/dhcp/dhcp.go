@@ -0,0 +1,215 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package dhcp checks that a DHCP server answers a DHCPDISCOVER with a DHCPOFFER.
+package dhcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// DHCP message types and option codes used by the check.
+const (
+	msgDiscover = 1
+	msgOffer    = 2
+
+	optPad           = 0
+	optMessageType   = 53
+	optServerID      = 54
+	optParameterList = 55
+	optEnd           = 255
+)
+
+// magicCookie marks the start of the options field.
+var magicCookie = []byte{99, 130, 83, 99}
+
+// Options configures a DHCP check.
+// - `Server` is the host:port to send the DISCOVER to. It defaults to broadcasting to 255.255.255.255:67.
+// - `LocalAddr` is the address to receive the OFFER on. It defaults to 0.0.0.0:68, which usually requires root.
+// - `HardwareAddr` is the client MAC address to use. It defaults to a random locally administered address.
+// - `RequiredOptions` lists option codes the OFFER must carry, such as 3 (router) or 6 (DNS servers).
+// - `Warn` and `Crit` are response times above which the check is Warning or Critical. 0 disables a threshold.
+// - `Timeout` is how long to wait for an OFFER. 0 means 5 seconds.
+type Options struct {
+	Server          string
+	LocalAddr       string
+	HardwareAddr    net.HardwareAddr
+	RequiredOptions []byte
+	Warn            time.Duration
+	Crit            time.Duration
+	Timeout         time.Duration
+}
+
+// offer is the part of a DHCPOFFER the check reports on.
+type offer struct {
+	yourIP   net.IP
+	serverID net.IP
+	options  map[byte][]byte
+}
+
+// Check broadcasts (or unicasts) a DHCPDISCOVER and waits for a matching DHCPOFFER. No offer
+// within the timeout, or an offer lacking a required option, is Critical. Socket errors, such
+// as lacking permission to bind port 68, are Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Server == "" {
+		opts.Server = "255.255.255.255:67"
+	}
+	if opts.LocalAddr == "" {
+		opts.LocalAddr = "0.0.0.0:68"
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.HardwareAddr == nil {
+		opts.HardwareAddr = make(net.HardwareAddr, 6)
+		_, _ = rand.Read(opts.HardwareAddr)
+		// Locally administered, unicast
+		opts.HardwareAddr[0] = opts.HardwareAddr[0]&0xFC | 0x02
+	}
+	if len(opts.HardwareAddr) > 16 {
+		result.SetResult(gomonitor.Unknown, "hardware address too long")
+		return result
+	}
+
+	var lc net.ListenConfig
+	conn, err := lc.ListenPacket(ctx, "udp4", opts.LocalAddr)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("cannot listen on %s: %v", opts.LocalAddr, err))
+		return result
+	}
+	defer conn.Close()
+	server, err := net.ResolveUDPAddr("udp4", opts.Server)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+
+	xid := make([]byte, 4)
+	_, _ = rand.Read(xid)
+	start := time.Now()
+	if _, err := conn.WriteTo(discover(xid, opts.HardwareAddr), server); err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("sending DHCPDISCOVER failed: %v", err))
+		return result
+	}
+	deadline := start.Add(opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			result.SetResult(gomonitor.Critical, fmt.Sprintf("no DHCPOFFER received from %s within %s", opts.Server, opts.Timeout))
+			return result
+		}
+		o, err := parseOffer(buf[:n], xid, opts.HardwareAddr)
+		if err != nil {
+			continue
+		}
+		elapsed := time.Since(start)
+		metric := gomonitor.PerformanceMetric{
+			Value:  elapsed.Seconds(),
+			Warn:   opts.Warn.Seconds(),
+			Crit:   opts.Crit.Seconds(),
+			UnitOM: "s",
+		}
+		result.AddPerformanceData("time", metric)
+		var missing []string
+		for _, code := range opts.RequiredOptions {
+			if _, ok := o.options[code]; !ok {
+				missing = append(missing, fmt.Sprint(code))
+			}
+		}
+		state := metric.State()
+		message := fmt.Sprintf("offer of %s from %s in %.3fs", o.yourIP, o.serverID, elapsed.Seconds())
+		if len(missing) > 0 {
+			state = gomonitor.Critical
+			message += fmt.Sprintf(", missing option(s) %s", strings.Join(missing, ", "))
+		}
+		result.SetResult(state, message)
+		return result
+	}
+}
+
+// discover builds a DHCPDISCOVER message asking the server to broadcast its reply.
+func discover(xid []byte, hw net.HardwareAddr) []byte {
+	msg := make([]byte, 236, 300)
+	msg[0] = 1 // BOOTREQUEST
+	msg[1] = 1 // Ethernet
+	msg[2] = byte(len(hw))
+	copy(msg[4:8], xid)
+	binary.BigEndian.PutUint16(msg[10:], 0x8000) // broadcast flag
+	copy(msg[28:44], hw)
+	msg = append(msg, magicCookie...)
+	msg = append(msg, optMessageType, 1, msgDiscover)
+	// Subnet mask, router, DNS servers, domain name, lease time, server identifier
+	msg = append(msg, optParameterList, 6, 1, 3, 6, 15, 51, 54)
+	msg = append(msg, optEnd)
+	// Some servers ignore BOOTP messages shorter than 300 bytes
+	for len(msg) < 300 {
+		msg = append(msg, optPad)
+	}
+	return msg
+}
+
+// parseOffer validates that msg is a DHCPOFFER for our transaction and decodes it.
+func parseOffer(msg, xid []byte, hw net.HardwareAddr) (offer, error) {
+	var o offer
+	if len(msg) < 240 || msg[0] != 2 || !bytes.Equal(msg[4:8], xid) || !bytes.Equal(msg[28:28+len(hw)], hw) {
+		return o, errors.New("not a reply to our request")
+	}
+	if !bytes.Equal(msg[236:240], magicCookie) {
+		return o, errors.New("missing magic cookie")
+	}
+	o.yourIP = net.IP(msg[16:20])
+	o.serverID = net.IP(msg[20:24])
+	o.options = make(map[byte][]byte)
+	for opts := msg[240:]; len(opts) > 0; {
+		code := opts[0]
+		if code == optEnd {
+			break
+		}
+		if code == optPad {
+			opts = opts[1:]
+			continue
+		}
+		if len(opts) < 2 || len(opts) < 2+int(opts[1]) {
+			return o, errors.New("truncated option")
+		}
+		o.options[code] = opts[2 : 2+int(opts[1])]
+		opts = opts[2+int(opts[1]):]
+	}
+	if t := o.options[optMessageType]; len(t) != 1 || t[0] != msgOffer {
+		return o, errors.New("not a DHCPOFFER")
+	}
+	if id := o.options[optServerID]; len(id) == 4 {
+		o.serverID = net.IP(id)
+	}
+	return o, nil
+}
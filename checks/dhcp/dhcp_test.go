@@ -0,0 +1,93 @@
+package dhcp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeServer answers every DISCOVER it receives with an OFFER of 192.0.2.50 carrying the given
+// extra options, sent back to the requester's address.
+func fakeServer(t *testing.T, extra []byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 240 || buf[0] != 1 {
+				continue
+			}
+			reply := make([]byte, 240)
+			copy(reply, buf[:240])
+			reply[0] = 2
+			copy(reply[16:20], net.IPv4(192, 0, 2, 50).To4())
+			reply = append(reply, optMessageType, 1, msgOffer, optServerID, 4, 192, 0, 2, 1)
+			reply = append(reply, extra...)
+			reply = append(reply, optEnd)
+			// A reply for some other transaction first, which must be ignored
+			other := append([]byte{}, reply...)
+			other[4]++
+			conn.WriteTo(other, addr)
+			conn.WriteTo(reply, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		extra     []byte
+		required  []byte
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Offer", []byte{3, 4, 192, 0, 2, 1}, []byte{3}, gomonitor.OK, "offer of 192.0.2.50 from 192.0.2.1"},
+		{"Test Missing Option", nil, []byte{3, 6}, gomonitor.Critical, "missing option(s) 3, 6"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), Options{
+				Server:          fakeServer(t, tc.extra),
+				LocalAddr:       "127.0.0.1:0",
+				RequiredOptions: tc.required,
+			})
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckNoOffer(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	result := Check(context.Background(), Options{
+		Server:    conn.LocalAddr().String(),
+		LocalAddr: "127.0.0.1:0",
+		Timeout:   50 * time.Millisecond,
+	})
+	if result.ExitCode != gomonitor.Critical {
+		t.Errorf("got exitCode %s (%s), want Critical", result.ExitCode, result.Message)
+	}
+}
@@ -0,0 +1,178 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package dnsbl checks whether an IP address or domain is listed on DNS blocklists.
+package dnsbl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// Options configures a DNSBL check.
+// - `Target` is the IP address or domain to look up. IP addresses are queried in reversed form, domains as-is.
+// - `Lists` are the blocklist zones to query, such as "zen.spamhaus.org".
+// - `Warn` and `Crit` are numbers of listings above which the check is Warning or Critical. If both are 0, any listing is Critical.
+// - `Resolver` is the resolver to use. nil means net.DefaultResolver.
+// - `Timeout` bounds all lookups. 0 means 10 seconds.
+type Options struct {
+	Target   string
+	Lists    []string
+	Warn     int
+	Crit     int
+	Resolver *net.Resolver
+	Timeout  time.Duration
+}
+
+// listResult is the outcome of querying a single blocklist.
+type listResult struct {
+	listed bool
+	codes  []string
+	err    error
+}
+
+// Check queries every list concurrently. Each list gets a perfdata entry that is 1 when the
+// target is listed and 0 otherwise. Lists that fail to answer, or answer outside 127.0.0.0/8 or
+// with a 127.255.255.x refusal code, are reported in the message and make the check Unknown
+// unless the listings already make it Warning or Critical.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if len(opts.Lists) == 0 {
+		result.SetResult(gomonitor.Unknown, "no blocklists given")
+		return result
+	}
+	query, err := queryName(opts.Target)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	results := make([]listResult, len(opts.Lists))
+	var wg sync.WaitGroup
+	for i, list := range opts.Lists {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = lookup(ctx, opts.Resolver, query+"."+strings.Trim(list, ".")+".")
+		}()
+	}
+	wg.Wait()
+
+	var listed, failed []string
+	for i, list := range opts.Lists {
+		r := results[i]
+		value := 0.0
+		switch {
+		case r.err != nil:
+			failed = append(failed, fmt.Sprintf("%s (%v)", list, r.err))
+		case r.listed:
+			value = 1
+			listed = append(listed, fmt.Sprintf("%s (%s)", list, strings.Join(r.codes, ",")))
+		}
+		result.AddPerformanceData(list, gomonitor.PerformanceMetric{Value: value, Max: 1})
+	}
+	metric := gomonitor.PerformanceMetric{
+		Value: float64(len(listed)),
+		Warn:  float64(opts.Warn),
+		Crit:  float64(opts.Crit),
+		Max:   float64(len(opts.Lists)),
+		// Without thresholds any listing is Critical
+		CritSet: opts.Warn == 0 && opts.Crit == 0,
+	}
+	result.AddPerformanceData("listed", metric)
+
+	state := metric.State()
+	if state == gomonitor.OK && len(failed) > 0 {
+		state = gomonitor.Unknown
+	}
+	var msg string
+	if len(listed) == 0 {
+		msg = fmt.Sprintf("%s not listed on %d of %d list(s)", opts.Target, len(opts.Lists)-len(failed), len(opts.Lists))
+	} else {
+		msg = fmt.Sprintf("%s listed on %d of %d list(s): %s", opts.Target, len(listed), len(opts.Lists), strings.Join(listed, ", "))
+	}
+	if len(failed) > 0 {
+		msg += fmt.Sprintf("; lookup failed for %s", strings.Join(failed, ", "))
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// queryName returns the name to prepend to each list zone: the reversed octets or nibbles of an
+// IP address, or the domain itself.
+func queryName(target string) (string, error) {
+	if target == "" {
+		return "", errors.New("no target given")
+	}
+	ip := net.ParseIP(target)
+	if ip == nil {
+		return strings.ToLower(strings.Trim(target, ".")), nil
+	}
+	var labels []string
+	if v4 := ip.To4(); v4 != nil {
+		for i := len(v4) - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprint(v4[i]))
+		}
+	} else {
+		const hex = "0123456789abcdef"
+		for i := len(ip) - 1; i >= 0; i-- {
+			labels = append(labels, string(hex[ip[i]&0x0f]), string(hex[ip[i]>>4]))
+		}
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// lookup queries a single blocklist name. NXDOMAIN means not listed.
+func lookup(ctx context.Context, resolver *net.Resolver, name string) listResult {
+	ips, err := tracing.LookupIP(ctx, resolver, "ip4", name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return listResult{}
+		}
+		return listResult{err: err}
+	}
+	var r listResult
+	for _, ip := range ips {
+		v4 := ip.To4()
+		switch {
+		case v4 == nil || v4[0] != 127:
+			return listResult{err: fmt.Errorf("unexpected answer %s", ip)}
+		case v4[1] == 255 && v4[2] == 255:
+			// Spamhaus and others answer 127.255.255.x when they refuse the query
+			return listResult{err: fmt.Errorf("query refused with %s", ip)}
+		}
+		r.listed = true
+		r.codes = append(r.codes, ip.String())
+	}
+	return r
+}
@@ -0,0 +1,133 @@
+package dnsbl
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/gomonitortest/probes"
+)
+
+func testResolver(addr string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", addr)
+		},
+	}
+}
+
+func TestQueryName(t *testing.T) {
+	testCases := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"Test IPv4", "192.0.2.10", "10.2.0.192"},
+		{"Test IPv6", "2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2"},
+		{"Test Domain", "Example.COM.", "example.com"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := queryName(tc.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	srv, err := probes.NewDNSServer(probes.Options{}, map[string][]net.IP{
+		"10.2.0.192.bl.test":     {net.ParseIP("127.0.0.2")},
+		"10.2.0.192.multi.test":  {net.ParseIP("127.0.0.4"), net.ParseIP("127.0.0.10")},
+		"10.2.0.192.refuse.test": {net.ParseIP("127.255.255.254")},
+		"spam.example.dbl.test":  {net.ParseIP("127.0.1.2")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	testCases := []struct {
+		name      string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Not Listed", Options{Target: "192.0.2.20", Lists: []string{"bl.test", "multi.test"}},
+			gomonitor.OK, "192.0.2.20 not listed on 2 of 2 list(s)"},
+		{"Test Listed", Options{Target: "192.0.2.10", Lists: []string{"bl.test", "clean.test"}},
+			gomonitor.Critical, "192.0.2.10 listed on 1 of 2 list(s): bl.test (127.0.0.2)"},
+		{"Test Multiple Codes", Options{Target: "192.0.2.10", Lists: []string{"multi.test"}},
+			gomonitor.Critical, "192.0.2.10 listed on 1 of 1 list(s): multi.test (127.0.0.4,127.0.0.10)"},
+		{"Test Warning", Options{Target: "192.0.2.10", Lists: []string{"bl.test", "multi.test", "clean.test"}, Warn: 1, Crit: 3},
+			gomonitor.Warning, "192.0.2.10 listed on 2 of 3 list(s): bl.test (127.0.0.2), multi.test (127.0.0.4,127.0.0.10)"},
+		{"Test Domain", Options{Target: "spam.example", Lists: []string{"dbl.test"}},
+			gomonitor.Critical, "spam.example listed on 1 of 1 list(s): dbl.test (127.0.1.2)"},
+		{"Test Refused", Options{Target: "192.0.2.10", Lists: []string{"refuse.test", "clean.test"}},
+			gomonitor.Unknown, "192.0.2.10 not listed on 1 of 2 list(s); lookup failed for refuse.test (query refused with 127.255.255.254)"},
+		{"Test Listed Despite Failure", Options{Target: "192.0.2.10", Lists: []string{"refuse.test", "bl.test"}},
+			gomonitor.Critical, "192.0.2.10 listed on 1 of 2 list(s): bl.test (127.0.0.2); lookup failed for refuse.test (query refused with 127.255.255.254)"},
+		{"Test No Lists", Options{Target: "192.0.2.10"}, gomonitor.Unknown, "no blocklists given"},
+		{"Test No Target", Options{Lists: []string{"bl.test"}}, gomonitor.Unknown, "no target given"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Resolver = testResolver(srv.Addr())
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPerformanceData(t *testing.T) {
+	srv, err := probes.NewDNSServer(probes.Options{}, map[string][]net.IP{
+		"10.2.0.192.bl.test": {net.ParseIP("127.0.0.2")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	result := Check(context.Background(), Options{
+		Target:   "192.0.2.10",
+		Lists:    []string{"bl.test", "clean.test"},
+		Resolver: testResolver(srv.Addr()),
+	})
+	want := map[string]float64{"bl.test": 1, "clean.test": 0, "listed": 1}
+	for name, value := range want {
+		if got := result.PerformanceData[name].Value; got != value {
+			t.Errorf("got %s=%v, want %v", name, got, value)
+		}
+	}
+}
+
+func TestCheckServerFailure(t *testing.T) {
+	srv, err := probes.NewDNSServer(probes.Options{Mode: probes.Reset}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	result := Check(context.Background(), Options{
+		Target:   "192.0.2.10",
+		Lists:    []string{"bl.test"},
+		Resolver: testResolver(srv.Addr()),
+	})
+	if result.ExitCode != gomonitor.Unknown {
+		t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+	}
+}
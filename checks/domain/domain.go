@@ -0,0 +1,180 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package domain checks when a domain's registration expires, using RDAP (RFC 9083).
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// DefaultBootstrapURL is the IANA registry mapping top-level domains to RDAP servers (RFC 9224).
+const DefaultBootstrapURL = "https://data.iana.org/rdap/dns.json"
+
+// maxResponseSize bounds the RDAP and bootstrap responses the check will read.
+const maxResponseSize = 10 << 20
+
+// Options configures a domain expiry check.
+// - `Domain` is the registered domain to look up, such as "example.com".
+// - `Server` is the base URL of the RDAP server to ask. If empty it is looked up in the bootstrap registry.
+// - `BootstrapURL` is the RDAP bootstrap registry. "" means DefaultBootstrapURL.
+// - `Warn` and `Crit` are the remaining times until expiry below which the check is Warning or Critical. 0 disables a threshold.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+// - `Timeout` bounds each request. 0 means 10 seconds.
+type Options struct {
+	Domain       string
+	Server       string
+	BootstrapURL string
+	Warn         time.Duration
+	Crit         time.Duration
+	Client       *http.Client
+	Timeout      time.Duration
+}
+
+// bootstrap is an RDAP bootstrap registry. Each service is a list of TLDs and a list of base URLs.
+type bootstrap struct {
+	Services [][][]string `json:"services"`
+}
+
+// domainResponse is the part of an RDAP domain object the check uses.
+type domainResponse struct {
+	LDHName string `json:"ldhName"`
+	Events  []struct {
+		Action string    `json:"eventAction"`
+		Date   time.Time `json:"eventDate"`
+	} `json:"events"`
+}
+
+// Check looks up the domain's expiration event and compares the time left with the thresholds.
+// An expired domain is Critical. Lookup failures and registries that don't publish an
+// expiration date are Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.BootstrapURL == "" {
+		opts.BootstrapURL = DefaultBootstrapURL
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	name := strings.ToLower(strings.TrimSuffix(opts.Domain, "."))
+	if name == "" {
+		result.SetResult(gomonitor.Unknown, "no domain given")
+		return result
+	}
+
+	if opts.Server == "" {
+		server, err := lookupServer(ctx, opts, name)
+		if err != nil {
+			result.SetResult(gomonitor.Unknown, fmt.Sprintf("finding RDAP server for %s: %v", name, err))
+			return result
+		}
+		opts.Server = server
+	}
+
+	var dom domainResponse
+	if err := getJSON(ctx, opts, strings.TrimSuffix(opts.Server, "/")+"/domain/"+name, &dom); err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("querying RDAP for %s: %v", name, err))
+		return result
+	}
+	var expires time.Time
+	for _, event := range dom.Events {
+		if event.Action == "expiration" {
+			expires = event.Date
+			break
+		}
+	}
+	if expires.IsZero() {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("registry publishes no expiration date for %s", name))
+		return result
+	}
+
+	remaining := time.Until(expires)
+	metric := gomonitor.PerformanceMetric{
+		Value:     remaining.Seconds(),
+		WarnRange: gomonitor.LowerBound(opts.Warn.Seconds()),
+		CritRange: gomonitor.LowerBound(opts.Crit.Seconds()),
+		UnitOM:    "s",
+	}
+	result.AddPerformanceData("expiry", metric)
+	when := expires.UTC().Format(time.RFC3339)
+	if remaining <= 0 {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("%s expired on %s", name, when))
+		return result
+	}
+	result.SetResult(metric.State(), fmt.Sprintf("%s expires in %d day(s) on %s", name, int(remaining.Hours()/24), when))
+	return result
+}
+
+// lookupServer finds the RDAP base URL responsible for name in the bootstrap registry, using
+// the longest matching label suffix.
+func lookupServer(ctx context.Context, opts Options, name string) (string, error) {
+	var reg bootstrap
+	if err := getJSON(ctx, opts, opts.BootstrapURL, &reg); err != nil {
+		return "", err
+	}
+	best, bestLen := "", 0
+	for _, service := range reg.Services {
+		if len(service) < 2 || len(service[1]) == 0 {
+			continue
+		}
+		for _, suffix := range service[0] {
+			suffix = strings.ToLower(suffix)
+			if (name == suffix || strings.HasSuffix(name, "."+suffix)) && len(suffix) > bestLen {
+				best, bestLen = service[1][0], len(suffix)
+			}
+		}
+	}
+	if best == "" {
+		return "", errors.New("no RDAP service in the bootstrap registry")
+	}
+	return best, nil
+}
+
+// getJSON fetches url and decodes the JSON response into v.
+func getJSON(ctx context.Context, opts Options, url string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/rdap+json, application/json")
+	resp, err := tracing.Do(opts.Client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.New("not found")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+func TestCheck(t *testing.T) {
+	expiries := map[string]time.Time{
+		"example.com":   time.Now().Add(365 * 24 * time.Hour),
+		"soon.com":      time.Now().Add(20 * 24 * time.Hour),
+		"urgent.com":    time.Now().Add(3 * 24 * time.Hour),
+		"lapsed.com":    time.Now().Add(-24 * time.Hour),
+		"example.co.uk": time.Now().Add(365 * 24 * time.Hour),
+		"noexpiry.com":  {},
+	}
+	var srvURL string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/dns.json":
+			fmt.Fprintf(w, `{"version":"1.0","services":[[["com"],["%[1]s/com/"]],[["co.uk"],["%[1]s/couk"]]]}`, srvURL)
+		case strings.HasPrefix(r.URL.Path, "/com/domain/"), strings.HasPrefix(r.URL.Path, "/couk/domain/"):
+			name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			expires, ok := expiries[name]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			events := `{"eventAction":"registration","eventDate":"2000-01-01T00:00:00Z"}`
+			if !expires.IsZero() {
+				events += fmt.Sprintf(`,{"eventAction":"expiration","eventDate":%q}`, expires.UTC().Format(time.RFC3339))
+			}
+			fmt.Fprintf(w, `{"objectClassName":"domain","ldhName":%q,"events":[%s]}`, name, events)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	testCases := []struct {
+		name      string
+		domain    string
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", "Example.com.", gomonitor.OK, "example.com expires in 364 day(s)"},
+		{"Test Warning", "soon.com", gomonitor.Warning, "soon.com expires in 19 day(s)"},
+		{"Test Critical", "urgent.com", gomonitor.Critical, "urgent.com expires in 2 day(s)"},
+		{"Test Expired", "lapsed.com", gomonitor.Critical, "lapsed.com expired on"},
+		{"Test Longest Suffix", "example.co.uk", gomonitor.OK, "example.co.uk expires in"},
+		{"Test No Expiration", "noexpiry.com", gomonitor.Unknown, "registry publishes no expiration date"},
+		{"Test Not Registered", "missing.com", gomonitor.Unknown, "querying RDAP for missing.com: not found"},
+		{"Test Unknown TLD", "example.test", gomonitor.Unknown, "finding RDAP server"},
+		{"Test No Domain", "", gomonitor.Unknown, "no domain given"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), Options{
+				Domain:       tc.domain,
+				BootstrapURL: srv.URL + "/dns.json",
+				Warn:         30 * 24 * time.Hour,
+				Crit:         7 * 24 * time.Hour,
+			})
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.HasPrefix(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want prefix %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckServer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rdap/domain/example.net" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"ldhName":"example.net","events":[{"eventAction":"expiration","eventDate":"2999-01-01T00:00:00Z"}]}`))
+	}))
+	defer srv.Close()
+
+	result := Check(context.Background(), Options{Domain: "example.net", Server: srv.URL + "/rdap/"})
+	if result.ExitCode != gomonitor.OK {
+		t.Errorf("got exitCode %s (%s), want OK", result.ExitCode, result.Message)
+	}
+	if _, ok := result.PerformanceData["expiry"]; !ok {
+		t.Error("expiry performance data missing")
+	}
+}
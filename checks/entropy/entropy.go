@@ -0,0 +1,133 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package entropy checks the kernel entropy pool and the rngd daemon that feeds it on Linux.
+package entropy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Options configures an entropy check.
+// - `Warn` and `Crit` are amounts of available entropy in bits below which the check is Warning or Critical. 0 disables a threshold.
+// - `RequireRngd` makes the check Critical when no rngd process is running.
+// - `ProcPath` and `SysPath` are where procfs and sysfs are mounted. "" means "/proc" and "/sys".
+type Options struct {
+	Warn        int
+	Crit        int
+	RequireRngd bool
+	ProcPath    string
+	SysPath     string
+}
+
+// Check reads the available entropy and pool size from procfs, the active hardware RNG from
+// sysfs and looks for a running rngd. Since Linux 5.18 the pool always reports 256 bits, so
+// the thresholds only matter on older kernels. Failing to read the entropy is Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.ProcPath == "" {
+		opts.ProcPath = "/proc"
+	}
+	if opts.SysPath == "" {
+		opts.SysPath = "/sys"
+	}
+
+	random := filepath.Join(opts.ProcPath, "sys", "kernel", "random")
+	avail, err := readInt(filepath.Join(random, "entropy_avail"))
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	poolSize, err := readInt(filepath.Join(random, "poolsize"))
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	metric := gomonitor.PerformanceMetric{
+		Value:     float64(avail),
+		WarnRange: gomonitor.LowerBound(float64(opts.Warn)),
+		CritRange: gomonitor.LowerBound(float64(opts.Crit)),
+		Max:       float64(poolSize),
+		Integer:   true,
+	}
+	result.AddPerformanceData("entropy", metric)
+
+	msg := fmt.Sprintf("%d of %d bits of entropy available", avail, poolSize)
+	// rng_current is "none" when the kernel has no hardware RNG
+	if rng, err := os.ReadFile(filepath.Join(opts.SysPath, "class", "misc", "hw_random", "rng_current")); err == nil {
+		if name := strings.TrimSpace(string(rng)); name != "" && name != "none" {
+			msg += ", hardware RNG " + name
+		}
+	}
+	rngd, err := rngdRunning(opts.ProcPath)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("looking for rngd: %v", err))
+		return result
+	}
+	if rngd {
+		msg += ", rngd running"
+	}
+
+	state := metric.State()
+	if opts.RequireRngd && !rngd {
+		state = gomonitor.Critical
+		msg += ", rngd not running"
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// readInt reads a file holding a single integer.
+func readInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid value in %s: %q", path, strings.TrimSpace(string(data)))
+	}
+	return v, nil
+}
+
+// rngdRunning reports whether any process in procfs is named rngd.
+func rngdRunning(procPath string) (bool, error) {
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(procPath, entry.Name(), "comm"))
+		// Processes can exit while we look
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(comm)) == "rngd" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,82 @@
+package entropy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeRoot builds procfs and sysfs trees with the given available entropy, hardware RNG and
+// process names, and returns their paths.
+func fakeRoot(t *testing.T, avail, rng string, processes ...string) (string, string) {
+	t.Helper()
+	root := t.TempDir()
+	proc, sys := filepath.Join(root, "proc"), filepath.Join(root, "sys")
+	files := map[string]string{
+		filepath.Join(proc, "sys", "kernel", "random", "entropy_avail"): avail + "\n",
+		filepath.Join(proc, "sys", "kernel", "random", "poolsize"):      "4096\n",
+		filepath.Join(proc, "self-is-not-a-pid"):                        "",
+	}
+	if rng != "" {
+		files[filepath.Join(sys, "class", "misc", "hw_random", "rng_current")] = rng + "\n"
+	}
+	for i, name := range processes {
+		files[filepath.Join(proc, strings.Repeat("1", i+1), "comm")] = name + "\n"
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return proc, sys
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		avail     string
+		rng       string
+		processes []string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", "3500", "tpm-rng-0", []string{"systemd", "rngd"}, Options{Warn: 1000, Crit: 200, RequireRngd: true},
+			gomonitor.OK, "3500 of 4096 bits of entropy available, hardware RNG tpm-rng-0, rngd running"},
+		{"Test No Hardware RNG", "3500", "none", []string{"systemd"}, Options{},
+			gomonitor.OK, "3500 of 4096 bits of entropy available"},
+		{"Test At Warning", "1000", "", []string{"rngd"}, Options{Warn: 1000, Crit: 200}, gomonitor.OK, ""},
+		{"Test Low Warning", "800", "", []string{"rngd"}, Options{Warn: 1000, Crit: 200}, gomonitor.Warning, ""},
+		{"Test Low Critical", "150", "", []string{"rngd"}, Options{Warn: 1000, Crit: 200}, gomonitor.Critical, ""},
+		{"Test Rngd Missing", "3500", "", []string{"systemd", "sshd"}, Options{RequireRngd: true},
+			gomonitor.Critical, "3500 of 4096 bits of entropy available, rngd not running"},
+		{"Test Invalid Value", "lots", "", nil, Options{}, gomonitor.Unknown, "invalid value in"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.ProcPath, tc.opts.SysPath = fakeRoot(t, tc.avail, tc.rng, tc.processes...)
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckMissingProc(t *testing.T) {
+	result := Check(context.Background(), Options{ProcPath: filepath.Join(t.TempDir(), "missing")})
+	if result.ExitCode != gomonitor.Unknown {
+		t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package etcd checks the health and backend database size of an etcd member.
+package etcd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// maxResponseSize bounds each response the check will read.
+const maxResponseSize = 10 << 20
+
+// Options configures an etcd check.
+// - `URL` is the client URL of the member, such as "https://10.0.0.1:2379".
+// - `WarnDBSize` and `CritDBSize` are backend database sizes in bytes above which the check is Warning or Critical. 0 disables a threshold.
+// - `Client` is the HTTP client to use, configured with client certificates if the member requires them. nil means http.DefaultClient.
+// - `Timeout` bounds all requests. 0 means 10 seconds.
+type Options struct {
+	URL        string
+	WarnDBSize int64
+	CritDBSize int64
+	Client     *http.Client
+	Timeout    time.Duration
+}
+
+// Check queries the member's /health endpoint and reads its database size and leader status
+// from /metrics. An unhealthy member, or one without a leader, is Critical. Failing to reach
+// the member is Critical and failing to read its metrics is Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	base := strings.TrimSuffix(opts.URL, "/")
+
+	start := time.Now()
+	raw, err := get(ctx, opts.Client, base+"/health")
+	elapsed := time.Since(start)
+	if err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("health request failed: %v", err))
+		return result
+	}
+	var health struct {
+		Health string `json:"health"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(raw, &health); err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("invalid health response: %v", err))
+		return result
+	}
+	result.AddPerformanceData("time", gomonitor.PerformanceMetric{
+		Value:  elapsed.Seconds(),
+		UnitOM: "s",
+	})
+	if health.Health != "true" {
+		message := "member is unhealthy"
+		if health.Reason != "" {
+			message += ": " + health.Reason
+		}
+		result.SetResult(gomonitor.Critical, message)
+		return result
+	}
+
+	raw, err = get(ctx, opts.Client, base+"/metrics")
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("metrics request failed: %v", err))
+		return result
+	}
+	metrics := parseMetrics(raw)
+	dbSize, ok := metrics["etcd_mvcc_db_total_size_in_bytes"]
+	if !ok {
+		result.SetResult(gomonitor.Unknown, "etcd_mvcc_db_total_size_in_bytes missing from metrics")
+		return result
+	}
+	metric := gomonitor.PerformanceMetric{
+		Value:   dbSize,
+		Warn:    float64(opts.WarnDBSize),
+		Crit:    float64(opts.CritDBSize),
+		UnitOM:  "B",
+		Integer: true,
+	}
+	result.AddPerformanceData("db_size", metric)
+	if inUse, ok := metrics["etcd_mvcc_db_total_size_in_use_in_bytes"]; ok {
+		result.AddPerformanceData("db_size_in_use", gomonitor.PerformanceMetric{
+			Value:   inUse,
+			UnitOM:  "B",
+			Integer: true,
+		})
+	}
+
+	status := "member is healthy"
+	state := metric.State()
+	if hasLeader, ok := metrics["etcd_server_has_leader"]; ok && hasLeader == 0 {
+		status = "member has no leader"
+		state = gomonitor.Critical
+	}
+	result.SetResult(state, fmt.Sprintf("%s, database %.0f bytes", status, dbSize))
+	return result
+}
+
+// get requests url and returns the body of a 200 response.
+func get(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := tracing.Do(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	// An unhealthy member answers /health with 503 and a JSON body worth reporting
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return raw, nil
+}
+
+// parseMetrics reads the unlabelled samples from a Prometheus text exposition. Labelled
+// samples and comments are skipped.
+func parseMetrics(raw []byte) map[string]float64 {
+	metrics := make(map[string]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.Contains(fields[0], "{") {
+			continue
+		}
+		if v, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			metrics[fields[0]] = v
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,103 @@
+package etcd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/gomonitortest/probes"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		health    string
+		metrics   string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", `{"health":"true","reason":""}`,
+			"# TYPE etcd_mvcc_db_total_size_in_bytes gauge\netcd_mvcc_db_total_size_in_bytes 2.097152e+06\n" +
+				"etcd_mvcc_db_total_size_in_use_in_bytes 1.048576e+06\netcd_server_has_leader 1\n",
+			Options{WarnDBSize: 4 << 20}, gomonitor.OK, "member is healthy, database 2097152 bytes"},
+		{"Test DB Size At Warning", `{"health":"true"}`,
+			"etcd_mvcc_db_total_size_in_bytes 4194304\netcd_server_has_leader 1\n",
+			Options{WarnDBSize: 4 << 20, CritDBSize: 8 << 20}, gomonitor.OK, "member is healthy, database 4194304 bytes"},
+		{"Test DB Size Warning", `{"health":"true"}`,
+			"etcd_mvcc_db_total_size_in_bytes 5e+06\netcd_server_has_leader 1\n",
+			Options{WarnDBSize: 4 << 20, CritDBSize: 8 << 20}, gomonitor.Warning, ""},
+		{"Test DB Size Critical", `{"health":"true"}`,
+			"etcd_mvcc_db_total_size_in_bytes 9e+06\netcd_server_has_leader 1\n",
+			Options{WarnDBSize: 4 << 20, CritDBSize: 8 << 20}, gomonitor.Critical, ""},
+		{"Test No Leader", `{"health":"true"}`,
+			"etcd_mvcc_db_total_size_in_bytes 1024\netcd_server_has_leader 0\n",
+			Options{}, gomonitor.Critical, "member has no leader, database 1024 bytes"},
+		{"Test Unhealthy", `{"health":"false","reason":"RAFT NO LEADER"}`, "",
+			Options{}, gomonitor.Critical, "member is unhealthy: RAFT NO LEADER"},
+		{"Test Missing Metric", `{"health":"true"}`, "etcd_server_has_leader 1\n",
+			Options{}, gomonitor.Unknown, "etcd_mvcc_db_total_size_in_bytes missing from metrics"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/health":
+					if strings.Contains(tc.health, `"false"`) {
+						w.WriteHeader(http.StatusServiceUnavailable)
+					}
+					w.Write([]byte(tc.health))
+				case "/metrics":
+					w.Write([]byte(tc.metrics))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			tc.opts.URL = srv.URL + "/"
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if tc.wantMsg != "" && result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	testCases := []struct {
+		name   string
+		probe  probes.Options
+		closed bool
+	}{
+		{"Test closed server", probes.Options{}, true},
+		{"Test connection reset", probes.Options{Mode: probes.Reset}, false},
+		{"Test slow server", probes.Options{Mode: probes.Slow, Delay: 300 * time.Millisecond}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, err := probes.NewHTTPServer(tc.probe, http.NotFoundHandler())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Close()
+			if tc.closed {
+				srv.Close()
+			}
+
+			result := Check(context.Background(), Options{URL: srv.URL, Timeout: 100 * time.Millisecond})
+			if result.ExitCode != gomonitor.Critical {
+				t.Errorf("got exitCode %s (%s), want Critical", result.ExitCode, result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package expiry checks expiry dates, such as those of software licenses and API tokens.
+// Dates come from a Collector, so the same thresholds and output work whether the date is read
+// from a file, an HTTP API or a command.
+package expiry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// maxResponseSize bounds the HTTP response a JSON collector will read.
+const maxResponseSize = 10 << 20
+
+// Collector returns the expiry date being checked.
+type Collector func(ctx context.Context) (time.Time, error)
+
+// File returns a Collector that reads a date from the file at path. See ParseDate for layout.
+func File(path, layout string) Collector {
+	return func(ctx context.Context) (time.Time, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return ParseDate(string(data), layout)
+	}
+}
+
+// JSON returns a Collector that fetches url and reads the date at field, a dot-separated path
+// into the JSON response such as "license.expires". See ParseDate for layout. A nil client
+// means http.DefaultClient.
+func JSON(client *http.Client, url, field, layout string) Collector {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context) (time.Time, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return time.Time{}, err
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := tracing.Do(client, req)
+		if err != nil {
+			return time.Time{}, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return time.Time{}, fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+		}
+		var data any
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&data); err != nil {
+			return time.Time{}, fmt.Errorf("parsing response from %s: %v", url, err)
+		}
+		value, ok := lookup(data, field)
+		if !ok {
+			return time.Time{}, fmt.Errorf("field %s missing from response", field)
+		}
+		return ParseDate(value, layout)
+	}
+}
+
+// Command returns a Collector that runs name with args and reads a date from its output. See
+// ParseDate for layout.
+func Command(layout, name string, args ...string) Collector {
+	return func(ctx context.Context) (time.Time, error) {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return time.Time{}, fmt.Errorf("running %s: %v: %s", name, err, msg)
+			}
+			return time.Time{}, fmt.Errorf("running %s: %v", name, err)
+		}
+		return ParseDate(string(out), layout)
+	}
+}
+
+// ParseDate parses s, ignoring surrounding whitespace, with the given time layout. The layout
+// "unix" reads seconds since the epoch. An empty layout accepts RFC 3339 timestamps and plain
+// dates such as 2025-12-31, which are taken as the start of that day in UTC.
+func ParseDate(s, layout string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	switch layout {
+	case "unix":
+		secs, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid unix timestamp %q", s)
+		}
+		return time.Unix(secs, 0), nil
+	case "":
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, nil
+		}
+		layout = time.DateOnly
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return t, nil
+}
+
+// Options configures an expiry check.
+// - `Name` describes what expires, such as "license" or "API token". It defaults to "expiry".
+// - `Collect` gathers the expiry date.
+// - `Warn` and `Crit` are the remaining times below which the check is Warning or Critical. 0 disables a threshold.
+// - `Timeout` bounds the collector. 0 means 30 seconds.
+type Options struct {
+	Name    string
+	Collect Collector
+	Warn    time.Duration
+	Crit    time.Duration
+	Timeout time.Duration
+}
+
+// Check collects the expiry date and compares the time left with the thresholds. Something that
+// has already expired is Critical; failing to collect the date is Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Name == "" {
+		opts.Name = "expiry"
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.Collect == nil {
+		result.SetResult(gomonitor.Unknown, "no collector given")
+		return result
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	expires, err := opts.Collect(ctx)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("collecting %s date: %v", opts.Name, err))
+		return result
+	}
+	remaining := time.Until(expires)
+	metric := gomonitor.PerformanceMetric{
+		Value:     remaining.Seconds(),
+		WarnRange: gomonitor.LowerBound(opts.Warn.Seconds()),
+		CritRange: gomonitor.LowerBound(opts.Crit.Seconds()),
+		UnitOM:    "s",
+	}
+	result.AddPerformanceData("expiry", metric)
+	when := expires.UTC().Format(time.RFC3339)
+	if remaining <= 0 {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("%s expired on %s", opts.Name, when))
+		return result
+	}
+	result.SetResult(metric.State(), fmt.Sprintf("%s expires in %d day(s) on %s", opts.Name, int(remaining.Hours()/24), when))
+	return result
+}
+
+// lookup follows a dot-separated path through decoded JSON and returns the value found there
+// as a string. Numeric path elements index into arrays.
+func lookup(data any, path string) (string, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := data.(type) {
+		case map[string]any:
+			next, ok := v[key]
+			if !ok {
+				return "", false
+			}
+			data = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", false
+			}
+			data = v[i]
+		default:
+			return "", false
+		}
+	}
+	switch v := data.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,141 @@
+package expiry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// TestHelperProcess stands in for a command that prints an expiry date.
+func TestHelperProcess(t *testing.T) {
+	output, ok := os.LookupEnv("EXPIRY_HELPER_OUTPUT")
+	if !ok {
+		return
+	}
+	fmt.Println(output)
+	os.Exit(0)
+}
+
+func TestParseDate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		layout  string
+		want    time.Time
+		wantErr bool
+	}{
+		{"Test RFC 3339", "2030-06-01T12:00:00Z\n", "", time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC), false},
+		{"Test Date Only", " 2030-06-01 ", "", time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC), false},
+		{"Test Layout", "01/06/2030", "02/01/2006", time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC), false},
+		{"Test Unix", "1906545600", "unix", time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC), false},
+		{"Test Bad Date", "next year", "", time.Time{}, true},
+		{"Test Bad Unix", "soon", "unix", time.Time{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseDate(tc.input, tc.layout)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCollectors(t *testing.T) {
+	want := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	path := filepath.Join(t.TempDir(), "license.txt")
+	if err := os.WriteFile(path, []byte("2030-06-01\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"license":{"seats":10,"terms":[{"expires":"2030-06-01T00:00:00Z"}]},"token":{"exp":1906502400}}`))
+	}))
+	defer srv.Close()
+	t.Setenv("EXPIRY_HELPER_OUTPUT", "2030-06-01")
+
+	testCases := []struct {
+		name    string
+		collect Collector
+		wantErr bool
+	}{
+		{"Test File", File(path, ""), false},
+		{"Test Missing File", File(path+".missing", ""), true},
+		{"Test JSON", JSON(nil, srv.URL, "license.terms.0.expires", ""), false},
+		{"Test JSON Unix", JSON(nil, srv.URL, "token.exp", "unix"), false},
+		{"Test JSON Missing Field", JSON(nil, srv.URL, "license.expires", ""), true},
+		{"Test JSON Object Field", JSON(nil, srv.URL, "license", ""), true},
+		{"Test Command", Command("", os.Args[0], "-test.run=^TestHelperProcess$"), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.collect(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+			if !tc.wantErr && !got.Equal(want) {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	in := func(d time.Duration) Collector {
+		return func(context.Context) (time.Time, error) { return time.Now().Add(d), nil }
+	}
+	day := 24 * time.Hour
+
+	testCases := []struct {
+		name      string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", Options{Name: "license", Collect: in(100 * day)}, gomonitor.OK, "license expires in 99 day(s)"},
+		{"Test Warning", Options{Name: "license", Collect: in(20 * day)}, gomonitor.Warning, "license expires in 19 day(s)"},
+		{"Test Critical", Options{Name: "license", Collect: in(5 * day)}, gomonitor.Critical, "license expires in 4 day(s)"},
+		{"Test Expired", Options{Collect: in(-day)}, gomonitor.Critical, "expiry expired on"},
+		{"Test Collector Error", Options{Name: "token", Collect: func(context.Context) (time.Time, error) {
+			return time.Time{}, errors.New("permission denied")
+		}}, gomonitor.Unknown, "collecting token date: permission denied"},
+		{"Test No Collector", Options{}, gomonitor.Unknown, "no collector given"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Warn = 30 * day
+			tc.opts.Crit = 7 * day
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.HasPrefix(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want prefix %q", result.Message, tc.wantMsg)
+			}
+			// The perfdata thresholds are lower bounds, so Evaluate agrees with the check
+			if metric, ok := result.PerformanceData["expiry"]; ok {
+				if got := metric.State(); got != tc.wantState {
+					t.Errorf("got metric state %s, want %s", got, tc.wantState)
+				}
+				if got, want := result.PerfdataEntries()[0], ";2592000:;604800:;"; !strings.Contains(got, want) {
+					t.Errorf("got perfdata %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package gpu checks the health of NVIDIA GPUs using nvidia-smi.
+package gpu
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// queryFields are the nvidia-smi --query-gpu fields the check reads, in order.
+var queryFields = []string{
+	"index",
+	"name",
+	"temperature.gpu",
+	"utilization.gpu",
+	"memory.used",
+	"memory.total",
+	"ecc.errors.uncorrected.volatile.total",
+}
+
+// Options configures a GPU check.
+// - `Command` is the nvidia-smi program, with any leading arguments. It defaults to "nvidia-smi".
+// - `WarnTemp` and `CritTemp` are temperatures in degrees Celsius above which a GPU is Warning or Critical.
+// - `WarnMemory` and `CritMemory` are memory usage percentages above which a GPU is Warning or Critical.
+// - `WarnUtil` and `CritUtil` are utilization percentages above which a GPU is Warning or Critical.
+// - `WarnECC` and `CritECC` are uncorrected ECC error counts above which a GPU is Warning or Critical.
+// - `Timeout` bounds the nvidia-smi command. 0 means 30 seconds.
+//
+// A threshold of 0 disables it.
+type Options struct {
+	Command    []string
+	WarnTemp   float64
+	CritTemp   float64
+	WarnMemory float64
+	CritMemory float64
+	WarnUtil   float64
+	CritUtil   float64
+	WarnECC    float64
+	CritECC    float64
+	Timeout    time.Duration
+}
+
+// gpu holds the readings of a single GPU. Readings nvidia-smi reports as not available or not
+// supported are marked with -1.
+type gpu struct {
+	index  string
+	name   string
+	temp   float64
+	util   float64
+	memory float64
+	ecc    float64
+}
+
+// Check queries every GPU and compares its readings with the thresholds. Each GPU gets
+// "gpu<index>_temp", "_util", "_memory" and "_ecc" perfdata. The state is that of the worst
+// reading. Failing to run nvidia-smi, or finding no GPUs, is Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if len(opts.Command) == 0 {
+		opts.Command = []string{"nvidia-smi"}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	gpus, err := query(ctx, opts)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	if len(gpus) == 0 {
+		result.SetResult(gomonitor.Unknown, "nvidia-smi reported no GPUs")
+		return result
+	}
+
+	state := gomonitor.OK
+	var problems []string
+	for _, g := range gpus {
+		readings := []struct {
+			metric, uom string
+			value       float64
+			warn, crit  float64
+			format      string
+		}{
+			{"temp", "", g.temp, opts.WarnTemp, opts.CritTemp, "temperature %.0fC"},
+			{"util", "%", g.util, opts.WarnUtil, opts.CritUtil, "utilization %.0f%%"},
+			{"memory", "%", g.memory, opts.WarnMemory, opts.CritMemory, "memory %.1f%%"},
+			{"ecc", "c", g.ecc, opts.WarnECC, opts.CritECC, "%.0f uncorrected ECC error(s)"},
+		}
+		for _, r := range readings {
+			if r.value < 0 {
+				continue
+			}
+			metric := gomonitor.PerformanceMetric{Value: r.value, Warn: r.warn, Crit: r.crit, UnitOM: r.uom}
+			if r.uom == "%" {
+				metric.Max = 100
+			}
+			result.AddPerformanceData("gpu"+g.index+"_"+r.metric, metric)
+			if s := metric.State(); s != gomonitor.OK {
+				state = max(state, s)
+				problems = append(problems, fmt.Sprintf("gpu%s (%s) ", g.index, g.name)+fmt.Sprintf(r.format, r.value))
+			}
+		}
+	}
+
+	msg := fmt.Sprintf("%d GPU(s) healthy", len(gpus))
+	if len(problems) > 0 {
+		msg = fmt.Sprintf("%d GPU(s): %s", len(gpus), strings.Join(problems, ", "))
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// query runs nvidia-smi and parses its CSV output.
+func query(ctx context.Context, opts Options) ([]gpu, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	args := append(slices.Clone(opts.Command[1:]),
+		"--query-gpu="+strings.Join(queryFields, ","), "--format=csv,noheader,nounits")
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, opts.Command[0], args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running nvidia-smi: %v: %s", err, msg)
+		}
+		return nil, fmt.Errorf("running nvidia-smi: %v", err)
+	}
+	return parseQuery(out)
+}
+
+// parseQuery parses the output of nvidia-smi --query-gpu with the queryFields.
+func parseQuery(data []byte) ([]gpu, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = len(queryFields)
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing nvidia-smi output: %v", err)
+	}
+	var gpus []gpu
+	for _, rec := range records {
+		g := gpu{index: rec[0], name: rec[1]}
+		var memUsed, memTotal float64
+		for i, dst := range []*float64{&g.temp, &g.util, &memUsed, &memTotal, &g.ecc} {
+			if *dst, err = parseReading(rec[i+2]); err != nil {
+				return nil, fmt.Errorf("parsing nvidia-smi output: %s of gpu%s: %v", queryFields[i+2], g.index, err)
+			}
+		}
+		g.memory = -1
+		if memUsed >= 0 && memTotal > 0 {
+			g.memory = memUsed / memTotal * 100
+		}
+		gpus = append(gpus, g)
+	}
+	return gpus, nil
+}
+
+// parseReading parses a numeric nvidia-smi value. "[N/A]" and "[Not Supported]" return -1.
+func parseReading(s string) (float64, error) {
+	if strings.HasPrefix(s, "[") {
+		return -1, nil
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
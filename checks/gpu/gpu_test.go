@@ -0,0 +1,112 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+const testOutput = `0, NVIDIA A100-SXM4-40GB, 65, 97, 30000, 40960, 0
+1, NVIDIA A100-SXM4-40GB, 88, 12, 40000, 40960, 2
+2, Tesla T4, 40, 0, 0, 15360, [N/A]
+`
+
+// TestHelperProcess stands in for nvidia-smi when run by the tests below.
+func TestHelperProcess(t *testing.T) {
+	output, ok := os.LookupEnv("GPU_HELPER_OUTPUT")
+	if !ok {
+		return
+	}
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	if len(args) != 2 || args[1] != "--format=csv,noheader,nounits" {
+		fmt.Fprintf(os.Stderr, "unexpected args %q", args)
+		os.Exit(2)
+	}
+	fmt.Print(output)
+	os.Exit(0)
+}
+
+// helperCommand returns a Command that prints output.
+func helperCommand(t *testing.T, output string) []string {
+	t.Setenv("GPU_HELPER_OUTPUT", output)
+	return []string{os.Args[0], "-test.run=^TestHelperProcess$", "--"}
+}
+
+func TestParseQuery(t *testing.T) {
+	gpus, err := parseQuery([]byte(testOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gpu{
+		{"0", "NVIDIA A100-SXM4-40GB", 65, 97, 30000.0 / 40960 * 100, 0},
+		{"1", "NVIDIA A100-SXM4-40GB", 88, 12, 40000.0 / 40960 * 100, 2},
+		{"2", "Tesla T4", 40, 0, 0, -1},
+	}
+	if !slices.Equal(gpus, want) {
+		t.Errorf("got %+v, want %+v", gpus, want)
+	}
+
+	for _, bad := range []string{"0, GPU, 65\n", "0, GPU, hot, 1, 1, 1, 0\n"} {
+		if _, err := parseQuery([]byte(bad)); err == nil {
+			t.Errorf("parseQuery(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		opts      Options
+		output    string
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Healthy", Options{WarnTemp: 90, CritECC: 5}, testOutput, gomonitor.OK, "3 GPU(s) healthy"},
+		{"Test Warning", Options{WarnTemp: 80, CritTemp: 95, WarnUtil: 95}, testOutput, gomonitor.Warning,
+			"3 GPU(s): gpu0 (NVIDIA A100-SXM4-40GB) utilization 97%, gpu1 (NVIDIA A100-SXM4-40GB) temperature 88C"},
+		{"Test Critical", Options{WarnMemory: 70, CritMemory: 95, CritECC: 1}, testOutput, gomonitor.Critical,
+			"3 GPU(s): gpu0 (NVIDIA A100-SXM4-40GB) memory 73.2%, gpu1 (NVIDIA A100-SXM4-40GB) memory 97.7%, gpu1 (NVIDIA A100-SXM4-40GB) 2 uncorrected ECC error(s)"},
+		{"Test No GPUs", Options{}, "", gomonitor.Unknown, "nvidia-smi reported no GPUs"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Command = helperCommand(t, tc.output)
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPerformanceData(t *testing.T) {
+	result := Check(context.Background(), Options{Command: helperCommand(t, testOutput)})
+	if got := len(result.PerfOrder); got != 11 {
+		t.Errorf("got %d metrics, want 11", got)
+	}
+	if _, ok := result.PerformanceData["gpu2_ecc"]; ok {
+		t.Error("got perfdata for an unsupported reading")
+	}
+	if got := result.PerformanceData["gpu1_temp"].Value; got != 88 {
+		t.Errorf("got gpu1_temp=%v, want 88", got)
+	}
+}
+
+func TestCheckCommandFails(t *testing.T) {
+	result := Check(context.Background(), Options{Command: []string{os.Args[0] + ".missing"}})
+	if result.ExitCode != gomonitor.Unknown {
+		t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+	}
+	if !strings.HasPrefix(result.Message, "running nvidia-smi") {
+		t.Errorf("got message %q, want prefix %q", result.Message, "running nvidia-smi")
+	}
+}
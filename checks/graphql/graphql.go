@@ -0,0 +1,190 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package graphql checks a GraphQL endpoint by posting a query and asserting on the response.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// maxResponseSize bounds the response body the check will read.
+const maxResponseSize = 10 << 20
+
+// Options configures a GraphQL check.
+// - `URL` is the GraphQL endpoint.
+// - `Query` is the GraphQL document to post.
+// - `Variables` are passed along with the query. It may be nil.
+// - `Header` holds extra request headers, such as Authorization. It may be nil.
+// - `Expect` maps dot-separated paths into the response "data" object (e.g. "health.status") to the value they must have.
+// - `Warn` and `Crit` are response times above which the check is Warning or Critical. 0 disables a threshold.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+// - `Timeout` bounds the request. 0 means 10 seconds.
+type Options struct {
+	URL       string
+	Query     string
+	Variables map[string]any
+	Header    http.Header
+	Expect    map[string]string
+	Warn      time.Duration
+	Crit      time.Duration
+	Client    *http.Client
+	Timeout   time.Duration
+}
+
+// response is a GraphQL response body.
+type response struct {
+	Data   any `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Check posts the query and validates that the response has no errors, that every expected
+// field has its expected value and that the response time is within the thresholds. Failed
+// requests, GraphQL errors and mismatched fields are Critical.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	body, err := json.Marshal(map[string]any{"query": opts.Query, "variables": opts.Variables})
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("encoding request: %v", err))
+		return result
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.URL, bytes.NewReader(body))
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	for k, v := range opts.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	start := time.Now()
+	resp, err := tracing.Do(opts.Client, req)
+	if err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("request failed: %v", err))
+		return result
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	elapsed := time.Since(start)
+	if err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("reading response failed: %v", err))
+		return result
+	}
+	metric := gomonitor.PerformanceMetric{
+		Value:  elapsed.Seconds(),
+		Warn:   opts.Warn.Seconds(),
+		Crit:   opts.Crit.Seconds(),
+		UnitOM: "s",
+	}
+	result.AddPerformanceData("time", metric)
+	result.AddPerformanceData("size", gomonitor.PerformanceMetric{
+		Value:  float64(len(raw)),
+		UnitOM: "B",
+	})
+
+	var gqlResp response
+	if err := json.Unmarshal(raw, &gqlResp); err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("HTTP %d with invalid GraphQL response: %v", resp.StatusCode, err))
+		return result
+	}
+	if len(gqlResp.Errors) > 0 {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("%d error(s), first: %s", len(gqlResp.Errors), gqlResp.Errors[0].Message))
+		return result
+	}
+	if resp.StatusCode != http.StatusOK {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("unexpected status %s", resp.Status))
+		return result
+	}
+	// Check fields in a stable order so the message is deterministic
+	paths := make([]string, 0, len(opts.Expect))
+	for path := range opts.Expect {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		got, ok := lookup(gqlResp.Data, path)
+		if !ok {
+			result.SetResult(gomonitor.Critical, fmt.Sprintf("field %s missing from response", path))
+			return result
+		}
+		if got != opts.Expect[path] {
+			result.SetResult(gomonitor.Critical, fmt.Sprintf("field %s is %q, want %q", path, got, opts.Expect[path]))
+			return result
+		}
+	}
+
+	result.SetResult(metric.State(), fmt.Sprintf("query succeeded in %.3fs, %d field(s) matched", elapsed.Seconds(), len(paths)))
+	return result
+}
+
+// lookup follows a dot-separated path through decoded JSON and returns the value found there
+// as a string. Numeric path elements index into arrays.
+func lookup(data any, path string) (string, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := data.(type) {
+		case map[string]any:
+			next, ok := v[key]
+			if !ok {
+				return "", false
+			}
+			data = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", false
+			}
+			data = v[i]
+		default:
+			return "", false
+		}
+	}
+	switch v := data.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "null", true
+	case map[string]any, []any:
+		b, _ := json.Marshal(v)
+		return string(b), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
@@ -0,0 +1,102 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/gomonitortest/probes"
+)
+
+func TestCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch req.Query {
+		case "{ health { status replicas { name } } }":
+			w.Write([]byte(`{"data":{"health":{"status":"UP","replicas":[{"name":"a"},{"name":"b"}]}}}`))
+		case "slow":
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte(`{"data":{}}`))
+		default:
+			w.Write([]byte(`{"data":null,"errors":[{"message":"Cannot query field"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		name      string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", Options{
+			Query:  "{ health { status replicas { name } } }",
+			Expect: map[string]string{"health.status": "UP", "health.replicas.1.name": "b"},
+		}, gomonitor.OK, ""},
+		{"Test Field Mismatch", Options{
+			Query:  "{ health { status replicas { name } } }",
+			Expect: map[string]string{"health.status": "DOWN"},
+		}, gomonitor.Critical, `field health.status is "UP", want "DOWN"`},
+		{"Test Missing Field", Options{
+			Query:  "{ health { status replicas { name } } }",
+			Expect: map[string]string{"health.version": "1"},
+		}, gomonitor.Critical, "field health.version missing from response"},
+		{"Test Errors", Options{Query: "{ bogus }"}, gomonitor.Critical, "1 error(s), first: Cannot query field"},
+		{"Test Slow", Options{Query: "slow", Warn: 20 * time.Millisecond}, gomonitor.Warning, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.URL = srv.URL
+			tc.opts.Header = http.Header{"Authorization": {"Bearer token"}}
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if tc.wantMsg != "" && result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	testCases := []struct {
+		name   string
+		probe  probes.Options
+		closed bool
+	}{
+		{"Test closed server", probes.Options{}, true},
+		{"Test connection reset", probes.Options{Mode: probes.Reset}, false},
+		{"Test slow server", probes.Options{Mode: probes.Slow, Delay: 300 * time.Millisecond}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, err := probes.NewHTTPServer(tc.probe, http.NotFoundHandler())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Close()
+			if tc.closed {
+				srv.Close()
+			}
+
+			result := Check(context.Background(), Options{URL: srv.URL, Query: "{ a }", Timeout: 100 * time.Millisecond})
+			if result.ExitCode != gomonitor.Critical {
+				t.Errorf("got exitCode %s (%s), want Critical", result.ExitCode, result.Message)
+			}
+		})
+	}
+}
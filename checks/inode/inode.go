@@ -0,0 +1,115 @@
+//go:build unix
+
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package inode checks filesystem inode usage, which can run out while plenty of bytes are
+// still free, for example on volumes full of small cache or mail files.
+package inode
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Options configures an inode usage check.
+// - `Paths` are the mount points, or any path on the filesystems, to check.
+// - `WarnPercent` and `CritPercent` are percentages of inodes used above which the check is Warning or Critical. 0 disables a threshold.
+// - `WarnFree` and `CritFree` are numbers of free inodes below which the check is Warning or Critical. 0 disables a threshold.
+type Options struct {
+	Paths       []string
+	WarnPercent float64
+	CritPercent float64
+	WarnFree    uint64
+	CritFree    uint64
+}
+
+// statfs returns the total and free inodes of the filesystem containing path.
+var statfs = func(path string) (total, free uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(st.Files), uint64(st.Ffree), nil
+}
+
+// Check reads the inode counts of each path. Filesystems that allocate inodes dynamically,
+// such as btrfs, report no inode total and are skipped. Paths that cannot be read are Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if len(opts.Paths) == 0 {
+		result.SetResult(gomonitor.Unknown, "no paths given")
+		return result
+	}
+
+	state := gomonitor.OK
+	var statuses, problems []string
+	for _, path := range opts.Paths {
+		total, free, err := statfs(path)
+		if err != nil {
+			result.SetResult(gomonitor.Unknown, fmt.Sprintf("%s: %v", path, err))
+			return result
+		}
+		if total == 0 {
+			statuses = append(statuses, path+" has no fixed inode count")
+			continue
+		}
+		used := total - free
+		percent := float64(used) / float64(total) * 100
+		result.AddPerformanceData(path, gomonitor.PerformanceMetric{
+			Value:   float64(used),
+			Warn:    percentOf(opts.WarnPercent, total),
+			Crit:    percentOf(opts.CritPercent, total),
+			Max:     float64(total),
+			Integer: true,
+		})
+		usage := gomonitor.PerformanceMetric{
+			Value:  percent,
+			Warn:   opts.WarnPercent,
+			Crit:   opts.CritPercent,
+			Max:    100,
+			UnitOM: "%",
+		}
+		result.AddPerformanceData(path+" %", usage)
+		// The free inode thresholds have no perfdata of their own
+		available := gomonitor.PerformanceMetric{
+			Value:     float64(free),
+			WarnRange: gomonitor.LowerBound(float64(opts.WarnFree)),
+			CritRange: gomonitor.LowerBound(float64(opts.CritFree)),
+		}
+
+		status := fmt.Sprintf("%s %.1f%% inodes used (%d free)", path, percent, free)
+		if s := max(usage.State(), available.State()); s != gomonitor.OK {
+			state = max(state, s)
+			problems = append(problems, status)
+		} else {
+			statuses = append(statuses, status)
+		}
+	}
+
+	// List the filesystems in trouble first, so they survive truncation
+	result.SetResult(state, strings.Join(append(problems, statuses...), ", "))
+	return result
+}
+
+// percentOf returns percent of total as an inode count, or 0 if percent is 0.
+func percentOf(percent float64, total uint64) float64 {
+	return percent / 100 * float64(total)
+}
@@ -0,0 +1,77 @@
+//go:build unix
+
+package inode
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+func TestCheck(t *testing.T) {
+	// Inode counts by path as total and free
+	filesystems := map[string][2]uint64{
+		"/":          {1000000, 900000},
+		"/var/spool": {200000, 15000},
+		"/srv/cache": {100000, 500},
+		"/data":      {0, 0},
+	}
+	defer func(orig func(string) (uint64, uint64, error)) { statfs = orig }(statfs)
+	statfs = func(path string) (uint64, uint64, error) {
+		fs, ok := filesystems[path]
+		if !ok {
+			return 0, 0, errors.New("no such file or directory")
+		}
+		return fs[0], fs[1], nil
+	}
+
+	testCases := []struct {
+		name      string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", Options{Paths: []string{"/", "/data"}, WarnPercent: 80, CritPercent: 90}, gomonitor.OK,
+			"/ 10.0% inodes used (900000 free), /data has no fixed inode count"},
+		{"Test Percent Warning", Options{Paths: []string{"/", "/var/spool"}, WarnPercent: 90, CritPercent: 95}, gomonitor.Warning,
+			"/var/spool 92.5% inodes used (15000 free), / 10.0% inodes used (900000 free)"},
+		{"Test Percent Critical", Options{Paths: []string{"/var/spool", "/srv/cache"}, WarnPercent: 90, CritPercent: 95}, gomonitor.Critical, ""},
+		{"Test Free Warning", Options{Paths: []string{"/var/spool"}, WarnFree: 20000, CritFree: 1000}, gomonitor.Warning, ""},
+		{"Test Free Critical", Options{Paths: []string{"/srv/cache"}, WarnFree: 20000, CritFree: 1000}, gomonitor.Critical, ""},
+		{"Test Missing Path", Options{Paths: []string{"/nope"}}, gomonitor.Unknown, "/nope: no such file or directory"},
+		{"Test No Paths", Options{}, gomonitor.Unknown, "no paths given"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if tc.wantMsg != "" && result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPerfdata(t *testing.T) {
+	defer func(orig func(string) (uint64, uint64, error)) { statfs = orig }(statfs)
+	statfs = func(string) (uint64, uint64, error) { return 1000, 250, nil }
+
+	result := Check(context.Background(), Options{Paths: []string{"/var"}, WarnPercent: 80, CritPercent: 90})
+	want := "'/var'=750;800;900;0;1000 '/var %'=75.00%;80.00;90.00;0.00;100.00"
+	if got := result.FormatResult(); !strings.Contains(got, want) {
+		t.Errorf("got output %q, want it to contain %q", got, want)
+	}
+}
+
+func TestCheckRealFilesystem(t *testing.T) {
+	result := Check(context.Background(), Options{Paths: []string{t.TempDir()}})
+	if result.ExitCode != gomonitor.OK {
+		t.Errorf("got exitCode %s (%s), want OK", result.ExitCode, result.Message)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package mailq checks the length of a Postfix or Exim mail queue.
+package mailq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Supported mail transfer agents.
+const (
+	Postfix = "postfix"
+	Exim    = "exim"
+)
+
+// postfixQueues are the Postfix queues in the order they are reported.
+var postfixQueues = []string{"maildrop", "incoming", "active", "deferred", "hold"}
+
+// Options configures a mail queue check.
+// - `MTA` is Postfix or Exim. It defaults to Postfix.
+// - `QueueDir` is the queue directory to count messages in, such as /var/spool/postfix or /var/spool/exim4. If empty the MTA's queue listing command is run instead.
+// - `Command` overrides the queue listing command. It defaults to "postqueue -j" for Postfix and "exim -bpc" for Exim.
+// - `Warn` and `Crit` map queue names ("active", "deferred", ... or "total") to message counts above which the check is Warning or Critical. Missing or 0 entries disable a threshold.
+// - `Timeout` bounds the queue listing command. 0 means 30 seconds.
+type Options struct {
+	MTA      string
+	QueueDir string
+	Command  []string
+	Warn     map[string]int
+	Crit     map[string]int
+	Timeout  time.Duration
+}
+
+// Check counts the messages in each queue and compares the counts with the thresholds. Every
+// queue, and the total, is recorded as perfdata. Failing to read the queue is Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.MTA == "" {
+		opts.MTA = Postfix
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if len(opts.Command) == 0 {
+		switch opts.MTA {
+		case Postfix:
+			opts.Command = []string{"postqueue", "-j"}
+		case Exim:
+			opts.Command = []string{"exim", "-bpc"}
+		}
+	}
+
+	var queues []string
+	var counts map[string]int
+	var err error
+	switch {
+	case opts.MTA == Postfix && opts.QueueDir != "":
+		queues = postfixQueues
+		counts, err = countPostfixDir(opts.QueueDir)
+	case opts.MTA == Postfix:
+		queues = postfixQueues
+		counts, err = countPostqueue(ctx, opts)
+	case opts.MTA == Exim && opts.QueueDir != "":
+		counts, err = countEximDir(opts.QueueDir)
+	case opts.MTA == Exim:
+		counts, err = countEximCommand(ctx, opts)
+	default:
+		err = fmt.Errorf("unsupported MTA %q", opts.MTA)
+	}
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	// Exim only reports a total
+	if _, ok := counts["total"]; !ok {
+		total := 0
+		for _, n := range counts {
+			total += n
+		}
+		counts["total"] = total
+	}
+	queues = append(queues, "total")
+
+	state := gomonitor.OK
+	var parts, violations []string
+	for _, queue := range queues {
+		n := counts[queue]
+		warn, crit := opts.Warn[queue], opts.Crit[queue]
+		metric := gomonitor.PerformanceMetric{
+			Value: float64(n),
+			Warn:  float64(warn),
+			Crit:  float64(crit),
+		}
+		result.AddPerformanceData(queue, metric)
+		parts = append(parts, fmt.Sprintf("%d %s", n, queue))
+		switch metric.State() {
+		case gomonitor.Critical:
+			state = gomonitor.Critical
+			violations = append(violations, fmt.Sprintf("%s %d > %d", queue, n, crit))
+		case gomonitor.Warning:
+			state = max(state, gomonitor.Warning)
+			violations = append(violations, fmt.Sprintf("%s %d > %d", queue, n, warn))
+		}
+	}
+	msg := fmt.Sprintf("%s queue: %s", opts.MTA, strings.Join(parts, ", "))
+	if len(violations) > 0 {
+		msg += " (" + strings.Join(violations, ", ") + ")"
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// countPostfixDir counts the queue files under each Postfix queue directory. Deferred and
+// other hashed queues keep their files in nested subdirectories.
+func countPostfixDir(dir string) (map[string]int, error) {
+	counts := make(map[string]int, len(postfixQueues))
+	for _, queue := range postfixQueues {
+		n, err := countFiles(filepath.Join(dir, queue), func(string) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+		counts[queue] = n
+	}
+	return counts, nil
+}
+
+// countEximDir counts the message header files in Exim's input directory, including any
+// split spool subdirectories.
+func countEximDir(dir string) (map[string]int, error) {
+	n, err := countFiles(filepath.Join(dir, "input"), func(name string) bool {
+		return strings.HasSuffix(name, "-H")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"total": n}, nil
+}
+
+// countFiles counts the regular files below dir whose names match.
+func countFiles(dir string, match func(string) bool) (int, error) {
+	n := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && match(d.Name()) {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("counting queue files: %w", err)
+	}
+	return n, nil
+}
+
+// countPostqueue runs "postqueue -j", which prints one JSON object per queued message.
+func countPostqueue(ctx context.Context, opts Options) (map[string]int, error) {
+	out, err := run(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(postfixQueues))
+	for _, queue := range postfixQueues {
+		counts[queue] = 0
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg struct {
+			Queue string `json:"queue_name"`
+		}
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, fmt.Errorf("parsing postqueue output: %v", err)
+		}
+		counts[msg.Queue]++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading postqueue output: %v", err)
+	}
+	return counts, nil
+}
+
+// countEximCommand runs "exim -bpc", which prints the number of queued messages.
+func countEximCommand(ctx context.Context, opts Options) (map[string]int, error) {
+	out, err := run(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, fmt.Errorf("parsing exim output %q", strings.TrimSpace(string(out)))
+	}
+	return map[string]int{"total": n}, nil
+}
+
+// run executes the queue listing command and returns its standard output.
+func run(ctx context.Context, opts Options) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, opts.Command[0], opts.Command[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running %s: %v: %s", opts.Command[0], err, msg)
+		}
+		return nil, fmt.Errorf("running %s: %v", opts.Command[0], err)
+	}
+	return out, nil
+}
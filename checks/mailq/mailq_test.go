@@ -0,0 +1,167 @@
+package mailq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// TestHelperProcess stands in for postqueue and exim when run by the tests below.
+func TestHelperProcess(t *testing.T) {
+	output, ok := os.LookupEnv("MAILQ_HELPER_OUTPUT")
+	if !ok {
+		return
+	}
+	if strings.HasPrefix(output, "fail:") {
+		fmt.Fprintln(os.Stderr, strings.TrimPrefix(output, "fail:"))
+		os.Exit(1)
+	}
+	fmt.Print(output)
+	os.Exit(0)
+}
+
+// helperCommand returns a command that prints output, or fails with it when it starts with "fail:".
+func helperCommand(t *testing.T, output string) []string {
+	t.Setenv("MAILQ_HELPER_OUTPUT", output)
+	return []string{os.Args[0], "-test.run=^TestHelperProcess$"}
+}
+
+// writeFiles creates empty files at the given paths below dir.
+func writeFiles(t *testing.T, dir string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckPostfixDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "active/A1", "active/A2", "deferred/4/4B1", "deferred/C/C2", "deferred/C/C3", "hold/H1")
+	for _, queue := range []string{"maildrop", "incoming"} {
+		if err := os.Mkdir(filepath.Join(dir, queue), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		warn      map[string]int
+		crit      map[string]int
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", nil, nil, gomonitor.OK,
+			"postfix queue: 0 maildrop, 0 incoming, 2 active, 3 deferred, 1 hold, 6 total"},
+		{"Test At Warning", map[string]int{"deferred": 3}, map[string]int{"deferred": 10}, gomonitor.OK,
+			"postfix queue: 0 maildrop, 0 incoming, 2 active, 3 deferred, 1 hold, 6 total"},
+		{"Test Warning", map[string]int{"deferred": 2}, map[string]int{"deferred": 10}, gomonitor.Warning,
+			"postfix queue: 0 maildrop, 0 incoming, 2 active, 3 deferred, 1 hold, 6 total (deferred 3 > 2)"},
+		{"Test Critical", map[string]int{"deferred": 1}, map[string]int{"total": 5}, gomonitor.Critical,
+			"postfix queue: 0 maildrop, 0 incoming, 2 active, 3 deferred, 1 hold, 6 total (deferred 3 > 1, total 6 > 5)"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), Options{QueueDir: dir, Warn: tc.warn, Crit: tc.crit})
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPostfixCommand(t *testing.T) {
+	output := `{"queue_name": "deferred", "queue_id": "4B1"}
+{"queue_name": "deferred", "queue_id": "C2"}
+{"queue_name": "active", "queue_id": "A1"}
+`
+	result := Check(context.Background(), Options{
+		Command: helperCommand(t, output),
+		Crit:    map[string]int{"deferred": 1},
+	})
+	if result.ExitCode != gomonitor.Critical {
+		t.Errorf("got exitCode %s (%s), want Critical", result.ExitCode, result.Message)
+	}
+	want := map[string]float64{"active": 1, "deferred": 2, "hold": 0, "total": 3}
+	for name, value := range want {
+		if got := result.PerformanceData[name].Value; got != value {
+			t.Errorf("got %s=%v, want %v", name, got, value)
+		}
+	}
+}
+
+func TestCheckExim(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "input/1a-H", "input/1a-D", "input/b/2b-H", "input/b/2b-D", "input/c/3c-H", "input/c/3c-D")
+
+	testCases := []struct {
+		name    string
+		opts    Options
+		wantMsg string
+	}{
+		{"Test Dir", Options{MTA: Exim, QueueDir: dir}, "exim queue: 3 total"},
+		{"Test Command", Options{MTA: Exim, Command: helperCommand(t, "42\n")}, "exim queue: 42 total"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != gomonitor.OK {
+				t.Errorf("got exitCode %s (%s), want OK", result.ExitCode, result.Message)
+			}
+			if result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    func(t *testing.T) Options
+		wantMsg string
+	}{
+		{"Test Missing Dir", func(t *testing.T) Options {
+			return Options{QueueDir: filepath.Join(t.TempDir(), "missing")}
+		}, "counting queue files"},
+		{"Test Command Fails", func(t *testing.T) Options {
+			return Options{Command: helperCommand(t, "fail:postqueue: fatal: Queue report unavailable")}
+		}, "postqueue: fatal: Queue report unavailable"},
+		{"Test Bad JSON", func(t *testing.T) Options {
+			return Options{Command: helperCommand(t, "Mail queue is empty\n")}
+		}, "parsing postqueue output"},
+		{"Test Bad Count", func(t *testing.T) Options {
+			return Options{MTA: Exim, Command: helperCommand(t, "lots")}
+		}, `parsing exim output "lots"`},
+		{"Test Unsupported MTA", func(t *testing.T) Options {
+			return Options{MTA: "sendmail"}
+		}, `unsupported MTA "sendmail"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), tc.opts(t))
+			if result.ExitCode != gomonitor.Unknown {
+				t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package modbus checks values read from holding or input registers over Modbus TCP.
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// Modbus function codes used by the check.
+const (
+	readHoldingRegisters = 0x03
+	readInputRegisters   = 0x04
+)
+
+// Options configures a Modbus register check.
+// - `Address` is the host:port of the Modbus TCP server, usually on port 502.
+// - `UnitID` is the unit (slave) identifier to address.
+// - `Register` is the zero-based address of the first register to read.
+// - `Registers` is 1 for a 16-bit value or 2 for a 32-bit value (high word first). 0 means 1.
+// - `Input` reads input registers instead of holding registers.
+// - `Signed` interprets the raw value as two's complement.
+// - `Scale` and `Offset` convert the raw value: value = raw*Scale + Offset. A Scale of 0 means 1.
+// - `Label` names the metric in the perfdata. It defaults to "register_<Register>".
+// - `UnitOM` is the unit of measure of the scaled value.
+// - `Warn` and `Crit` are the values above which the check is Warning or Critical. 0 disables a threshold.
+// - `Timeout` bounds the whole exchange. 0 means 10 seconds.
+type Options struct {
+	Address   string
+	UnitID    byte
+	Register  uint16
+	Registers uint16
+	Input     bool
+	Signed    bool
+	Scale     float64
+	Offset    float64
+	Label     string
+	UnitOM    string
+	Warn      float64
+	Crit      float64
+	Timeout   time.Duration
+}
+
+// Check reads the configured register and evaluates the scaled value against the thresholds.
+// Connection and protocol errors produce an Unknown result.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Registers == 0 {
+		opts.Registers = 1
+	}
+	if opts.Scale == 0 {
+		opts.Scale = 1
+	}
+	if opts.Label == "" {
+		opts.Label = fmt.Sprintf("register_%d", opts.Register)
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Registers > 2 {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("unsupported register count %d", opts.Registers))
+		return result
+	}
+
+	start := time.Now()
+	raw, err := readRegisters(ctx, opts)
+	elapsed := time.Since(start)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("%s: %v", opts.Address, err))
+		return result
+	}
+	value := decode(raw, opts.Signed)*opts.Scale + opts.Offset
+
+	metric := gomonitor.PerformanceMetric{
+		Value:  value,
+		Warn:   opts.Warn,
+		Crit:   opts.Crit,
+		UnitOM: opts.UnitOM,
+	}
+	result.SetResult(metric.State(), fmt.Sprintf("%s = %g%s", opts.Label, value, opts.UnitOM))
+	result.AddPerformanceData(opts.Label, metric)
+	result.AddPerformanceData("time", gomonitor.PerformanceMetric{
+		Value:  elapsed.Seconds(),
+		UnitOM: "s",
+	})
+	return result
+}
+
+// readRegisters performs a single read request and returns the register contents.
+func readRegisters(ctx context.Context, opts Options) ([]uint16, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	conn, err := tracing.Dial(ctx, nil, "tcp", opts.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	function := byte(readHoldingRegisters)
+	if opts.Input {
+		function = readInputRegisters
+	}
+	// MBAP header followed by the PDU
+	req := make([]byte, 12)
+	binary.BigEndian.PutUint16(req[0:], 1)
+	binary.BigEndian.PutUint16(req[2:], 0)
+	binary.BigEndian.PutUint16(req[4:], 6)
+	req[6] = opts.UnitID
+	req[7] = function
+	binary.BigEndian.PutUint16(req[8:], opts.Register)
+	binary.BigEndian.PutUint16(req[10:], opts.Registers)
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint16(header[4:])
+	if binary.BigEndian.Uint16(header[0:]) != 1 || length < 3 || length > 254 {
+		return nil, errors.New("invalid response header")
+	}
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(conn, pdu); err != nil {
+		return nil, err
+	}
+	if pdu[0] == function|0x80 {
+		return nil, fmt.Errorf("exception %s", exceptionName(pdu[1]))
+	}
+	if pdu[0] != function || len(pdu) < 2 || int(pdu[1]) != 2*int(opts.Registers) || len(pdu) != 2+int(pdu[1]) {
+		return nil, errors.New("invalid response")
+	}
+	regs := make([]uint16, opts.Registers)
+	for i := range regs {
+		regs[i] = binary.BigEndian.Uint16(pdu[2+2*i:])
+	}
+	return regs, nil
+}
+
+// decode converts one or two registers into a number.
+func decode(regs []uint16, signed bool) float64 {
+	if len(regs) == 1 {
+		if signed {
+			return float64(int16(regs[0]))
+		}
+		return float64(regs[0])
+	}
+	v := uint32(regs[0])<<16 | uint32(regs[1])
+	if signed {
+		return float64(int32(v))
+	}
+	return float64(v)
+}
+
+// exceptionName returns a readable name for a Modbus exception code.
+func exceptionName(code byte) string {
+	switch code {
+	case 1:
+		return "illegal function"
+	case 2:
+		return "illegal data address"
+	case 3:
+		return "illegal data value"
+	case 4:
+		return "server device failure"
+	case 6:
+		return "server device busy"
+	case 10:
+		return "gateway path unavailable"
+	case 11:
+		return "gateway target failed to respond"
+	default:
+		return fmt.Sprintf("code %d", code)
+	}
+}
@@ -0,0 +1,146 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeServer answers Modbus read requests from a register table. Reads outside the table get
+// an illegal data address exception.
+func fakeServer(t *testing.T, registers map[uint16]uint16) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			req := make([]byte, 12)
+			if _, err := io.ReadFull(conn, req); err != nil {
+				conn.Close()
+				continue
+			}
+			start := binary.BigEndian.Uint16(req[8:])
+			count := binary.BigEndian.Uint16(req[10:])
+			pdu := []byte{req[7], byte(2 * count)}
+			for i := uint16(0); i < count; i++ {
+				v, ok := registers[start+i]
+				if !ok {
+					pdu = []byte{req[7] | 0x80, 2}
+					break
+				}
+				pdu = binary.BigEndian.AppendUint16(pdu, v)
+			}
+			resp := append([]byte{}, req[:4]...)
+			resp = binary.BigEndian.AppendUint16(resp, uint16(len(pdu)+1))
+			resp = append(resp, req[6])
+			resp = append(resp, pdu...)
+			conn.Write(resp)
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// rawServer answers every request with resp, whatever was asked.
+func rawServer(t *testing.T, resp []byte) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			io.ReadFull(conn, make([]byte, 12))
+			conn.Write(resp)
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestCheck(t *testing.T) {
+	addr := fakeServer(t, map[uint16]uint16{10: 235, 11: 0xFFFF, 20: 0x0001, 21: 0x0002})
+
+	testCases := []struct {
+		name      string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantValue float64
+	}{
+		{"Test OK", Options{Register: 10, Scale: 0.1, Warn: 30, Crit: 40}, gomonitor.OK, 23.5},
+		{"Test Warning", Options{Register: 10, Scale: 0.1, Warn: 20, Crit: 40}, gomonitor.Warning, 23.5},
+		{"Test Critical", Options{Register: 10, Scale: 0.1, Warn: 20, Crit: 23}, gomonitor.Critical, 23.5},
+		{"Test At Critical", Options{Register: 10, Scale: 1, Warn: 100, Crit: 235}, gomonitor.Warning, 235},
+		{"Test Signed", Options{Register: 11, Signed: true, Input: true}, gomonitor.OK, -1},
+		{"Test 32 Bit", Options{Register: 20, Registers: 2, Offset: -1}, gomonitor.OK, 65537},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Address = addr
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if got := result.PerformanceData[result.PerfOrder[0]].Value; got != tc.wantValue {
+				t.Errorf("got value %v, want %v", got, tc.wantValue)
+			}
+		})
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	addr := fakeServer(t, map[uint16]uint16{})
+	result := Check(context.Background(), Options{Address: addr, Register: 5})
+	if result.ExitCode != gomonitor.Unknown || !strings.Contains(result.Message, "illegal data address") {
+		t.Errorf("got %s %q, want Unknown illegal data address", result.ExitCode, result.Message)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	l.Close()
+	result = Check(context.Background(), Options{Address: closed})
+	if result.ExitCode != gomonitor.Unknown {
+		t.Errorf("got %s %q, want Unknown for refused connection", result.ExitCode, result.Message)
+	}
+}
+
+func TestCheckTruncatedResponse(t *testing.T) {
+	testCases := []struct {
+		name string
+		resp []byte
+	}{
+		{"Test exception without code", []byte{0, 1, 0, 0, 0, 2, 1, 0x83}},
+		{"Test header without function", []byte{0, 1, 0, 0, 0, 1, 1}},
+		{"Test short read", []byte{0, 1, 0, 0, 0, 3, 1, 0x83}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), Options{Address: rawServer(t, tc.resp)})
+			if result.ExitCode != gomonitor.Unknown {
+				t.Errorf("got %s %q, want Unknown", result.ExitCode, result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package pidfile checks lock and PID files for stale entries left behind by crashed or hung
+// jobs.
+package pidfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Options configures a lock and PID file check.
+// - `Patterns` are the files to check, as filepath.Glob patterns such as "/var/run/*.pid".
+// - `Warn` and `Crit` are file ages above which the check is Warning or Critical, catching jobs that hang while holding a lock. 0 disables a threshold.
+// - `Clock` tells the time file ages are measured at. nil means gomonitor.SystemClock.
+type Options struct {
+	Patterns []string
+	Warn     time.Duration
+	Crit     time.Duration
+	Clock    gomonitor.Clock
+}
+
+// Check looks at every file matching Patterns. A file holding the PID of a process that no
+// longer exists is Critical, as it usually blocks the next run of the job. Files that are empty
+// or hold something other than a PID, as many lock files do, are only checked for age. A PID
+// that was reused by an unrelated process cannot be told apart from the original. Invalid
+// patterns and unreadable files are Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	var paths []string
+	for _, pattern := range opts.Patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			result.SetResult(gomonitor.Unknown, fmt.Sprintf("invalid pattern %q: %v", pattern, err))
+			return result
+		}
+		paths = append(paths, matches...)
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	now := gomonitor.ClockOrSystem(opts.Clock).Now()
+	state := gomonitor.OK
+	ages := gomonitor.PerformanceMetric{Warn: opts.Warn.Seconds(), Crit: opts.Crit.Seconds(), UnitOM: "s"}
+	var problems []string
+	var oldest time.Duration
+	stale := 0
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			result.SetResult(gomonitor.Unknown, err.Error())
+			return result
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			result.SetResult(gomonitor.Unknown, err.Error())
+			return result
+		}
+
+		age := now.Sub(info.ModTime())
+		oldest = max(oldest, age)
+		first, _, _ := strings.Cut(string(data), "\n")
+		pid, err := strconv.Atoi(strings.TrimSpace(first))
+		ages.Value = age.Seconds()
+		switch {
+		case err == nil && pid > 0 && !processExists(pid):
+			state = gomonitor.Critical
+			stale++
+			problems = append(problems, fmt.Sprintf("%s names PID %d, which is not running", path, pid))
+		case ages.State() != gomonitor.OK:
+			state = max(state, ages.State())
+			problems = append(problems, fmt.Sprintf("%s is %s old", path, age.Round(time.Second)))
+		}
+	}
+
+	result.AddPerformanceData("files", gomonitor.PerformanceMetric{Value: float64(len(paths)), Integer: true})
+	result.AddPerformanceData("stale", gomonitor.PerformanceMetric{Value: float64(stale), Integer: true})
+	ages.Value = oldest.Seconds()
+	result.AddPerformanceData("oldest", ages)
+	msg := fmt.Sprintf("no stale lock files among %d", len(paths))
+	if len(problems) > 0 {
+		msg = fmt.Sprintf("%d of %d lock file(s) stale: %s", len(problems), len(paths), strings.Join(problems, ", "))
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// processExists reports whether a process with the given PID is running. A process owned by
+// another user, which we may not signal, still exists.
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
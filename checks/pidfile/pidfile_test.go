@@ -0,0 +1,86 @@
+package pidfile
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// deadPID returns the PID of a process that has exited.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+// writeFile creates a file in dir with the given content and modification time mtime.
+func writeFile(t *testing.T, dir, name, content string, mtime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	alive := strconv.Itoa(os.Getpid()) + "\n"
+	testCases := []struct {
+		name      string
+		files     map[string]string
+		age       time.Duration
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Running", map[string]string{"job.pid": alive, "job.lock": ""}, time.Minute,
+			Options{Warn: time.Hour}, gomonitor.OK, "no stale lock files among 2"},
+		{"Test Dead Process", map[string]string{"job.pid": strconv.Itoa(deadPID(t))}, time.Minute,
+			Options{Warn: time.Hour}, gomonitor.Critical, "which is not running"},
+		{"Test Old Lock Warning", map[string]string{"job.lock": "locked by backup"}, 2 * time.Hour,
+			Options{Warn: time.Hour, Crit: 4 * time.Hour}, gomonitor.Warning, "job.lock is 2h0m0s old"},
+		{"Test Old Lock Critical", map[string]string{"job.lock": ""}, 5 * time.Hour,
+			Options{Warn: time.Hour, Crit: 4 * time.Hour}, gomonitor.Critical, "job.lock is 5h0m0s old"},
+		{"Test Old Running", map[string]string{"job.pid": alive}, 5 * time.Hour,
+			Options{Crit: 4 * time.Hour}, gomonitor.Critical, "1 of 1 lock file(s) stale"},
+		{"Test No Files", map[string]string{}, 0, Options{Warn: time.Hour}, gomonitor.OK, "no stale lock files among 0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				writeFile(t, dir, name, content, now.Add(-tc.age))
+			}
+			tc.opts.Clock = gomonitor.NewManualClock(now)
+			tc.opts.Patterns = []string{filepath.Join(dir, "*.pid"), filepath.Join(dir, "*.lock"), filepath.Join(dir, "job.*")}
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckInvalidPattern(t *testing.T) {
+	result := Check(context.Background(), Options{Patterns: []string{"[unterminated"}})
+	if result.ExitCode != gomonitor.Unknown {
+		t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+	}
+}
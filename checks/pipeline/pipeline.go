@@ -0,0 +1,252 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package pipeline checks the latest CI pipeline of repository branches on GitHub Actions or
+// GitLab CI.
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// Supported providers
+const (
+	GitHub = "github"
+	GitLab = "gitlab"
+)
+
+// Default API base URLs of the hosted providers.
+const (
+	DefaultGitHubURL = "https://api.github.com"
+	DefaultGitLabURL = "https://gitlab.com/api/v4"
+)
+
+// maxResponseSize bounds each API response the check will read.
+const maxResponseSize = 10 << 20
+
+// Target is a repository branch whose latest pipeline is checked.
+// - `Repo` is the repository path, such as "owner/name" on GitHub or "group/subgroup/project" on GitLab.
+// - `Branch` is the branch the pipeline ran for.
+type Target struct {
+	Repo   string
+	Branch string
+}
+
+// Options configures a CI pipeline check.
+// - `Provider` is GitHub or GitLab.
+// - `URL` is the API base URL, for self-hosted instances. "" means DefaultGitHubURL or DefaultGitLabURL.
+// - `Token` authenticates the requests. It may be empty for public repositories.
+// - `Targets` are the branches to check.
+// - `Warn` and `Crit` are running times above which an unfinished pipeline is considered stuck and the check is Warning or Critical. 0 disables a threshold.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+// - `Timeout` bounds all requests. 0 means 10 seconds.
+type Options struct {
+	Provider string
+	URL      string
+	Token    string
+	Targets  []Target
+	Warn     time.Duration
+	Crit     time.Duration
+	Client   *http.Client
+	Timeout  time.Duration
+}
+
+// Pipeline statuses, normalized across providers.
+const (
+	statusSuccess  = "success"
+	statusFailed   = "failed"
+	statusCanceled = "canceled"
+	statusRunning  = "running"
+)
+
+// pipeline is the latest pipeline of a target.
+type pipeline struct {
+	status   string
+	duration time.Duration
+}
+
+// fetcher returns the latest pipeline of a target.
+type fetcher func(ctx context.Context, opts Options, target Target) (pipeline, error)
+
+// Check fetches the latest pipeline of each target. A failed pipeline is Critical and a
+// canceled one Warning; a pipeline still running past Warn or Crit is Warning or Critical.
+// The duration of each pipeline is recorded as perfdata. API errors and branches without
+// pipelines are Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	var fetch fetcher
+	switch opts.Provider {
+	case GitHub:
+		fetch = fetchGitHub
+		if opts.URL == "" {
+			opts.URL = DefaultGitHubURL
+		}
+	case GitLab:
+		fetch = fetchGitLab
+		if opts.URL == "" {
+			opts.URL = DefaultGitLabURL
+		}
+	default:
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("unsupported provider %q", opts.Provider))
+		return result
+	}
+	if len(opts.Targets) == 0 {
+		result.SetResult(gomonitor.Unknown, "no repositories given")
+		return result
+	}
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	state := gomonitor.OK
+	var problems []string
+	for _, target := range opts.Targets {
+		name := target.Repo + "@" + target.Branch
+		p, err := fetch(ctx, opts, target)
+		if err != nil {
+			result.SetResult(gomonitor.Unknown, fmt.Sprintf("%s: %v", name, err))
+			return result
+		}
+		metric := gomonitor.PerformanceMetric{
+			Value:  p.duration.Seconds(),
+			UnitOM: "s",
+		}
+		// Only an unfinished pipeline can be stuck
+		if p.status == statusRunning {
+			metric.Warn, metric.Crit = opts.Warn.Seconds(), opts.Crit.Seconds()
+		}
+		result.AddPerformanceData(name, metric)
+
+		switch {
+		case p.status == statusFailed:
+			state = gomonitor.Critical
+			problems = append(problems, name+" failed")
+		case p.status == statusCanceled:
+			state = max(state, gomonitor.Warning)
+			problems = append(problems, name+" was canceled")
+		case metric.State() != gomonitor.OK:
+			state = max(state, metric.State())
+			problems = append(problems, fmt.Sprintf("%s running for %s", name, p.duration.Round(time.Second)))
+		}
+	}
+
+	msg := fmt.Sprintf("%d pipeline(s) healthy", len(opts.Targets))
+	if len(problems) > 0 {
+		msg = fmt.Sprintf("%d of %d pipeline(s) unhealthy: %s", len(problems), len(opts.Targets), strings.Join(problems, ", "))
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// fetchGitHub returns the latest workflow run of a GitHub branch.
+func fetchGitHub(ctx context.Context, opts Options, target Target) (pipeline, error) {
+	var runs struct {
+		WorkflowRuns []struct {
+			Status     string    `json:"status"`
+			Conclusion string    `json:"conclusion"`
+			StartedAt  time.Time `json:"run_started_at"`
+			UpdatedAt  time.Time `json:"updated_at"`
+		} `json:"workflow_runs"`
+	}
+	u := fmt.Sprintf("%s/repos/%s/actions/runs?per_page=1&branch=%s", opts.URL, target.Repo, url.QueryEscape(target.Branch))
+	if err := getJSON(ctx, opts, u, "Authorization", "Bearer "+opts.Token, &runs); err != nil {
+		return pipeline{}, err
+	}
+	if len(runs.WorkflowRuns) == 0 {
+		return pipeline{}, errors.New("no workflow runs")
+	}
+	run := runs.WorkflowRuns[0]
+	if run.Status != "completed" {
+		return pipeline{status: statusRunning, duration: time.Since(run.StartedAt)}, nil
+	}
+	p := pipeline{status: statusSuccess, duration: run.UpdatedAt.Sub(run.StartedAt)}
+	switch run.Conclusion {
+	case "failure", "timed_out", "startup_failure":
+		p.status = statusFailed
+	case "cancelled":
+		p.status = statusCanceled
+	}
+	return p, nil
+}
+
+// fetchGitLab returns the latest pipeline of a GitLab branch.
+func fetchGitLab(ctx context.Context, opts Options, target Target) (pipeline, error) {
+	var pipelines []struct {
+		Status    string    `json:"status"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	u := fmt.Sprintf("%s/projects/%s/pipelines?per_page=1&ref=%s", opts.URL, url.PathEscape(target.Repo), url.QueryEscape(target.Branch))
+	if err := getJSON(ctx, opts, u, "PRIVATE-TOKEN", opts.Token, &pipelines); err != nil {
+		return pipeline{}, err
+	}
+	if len(pipelines) == 0 {
+		return pipeline{}, errors.New("no pipelines")
+	}
+	latest := pipelines[0]
+	switch latest.Status {
+	case "created", "waiting_for_resource", "preparing", "pending", "running", "scheduled":
+		return pipeline{status: statusRunning, duration: time.Since(latest.CreatedAt)}, nil
+	case "failed":
+		return pipeline{status: statusFailed, duration: latest.UpdatedAt.Sub(latest.CreatedAt)}, nil
+	case "canceled":
+		return pipeline{status: statusCanceled, duration: latest.UpdatedAt.Sub(latest.CreatedAt)}, nil
+	}
+	return pipeline{status: statusSuccess, duration: latest.UpdatedAt.Sub(latest.CreatedAt)}, nil
+}
+
+// getJSON requests url with the token in the named header, if there is a token, and decodes
+// the JSON response into v.
+func getJSON(ctx context.Context, opts Options, url, header, value string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if opts.Token != "" {
+		req.Header.Set(header, value)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := tracing.Do(opts.Client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
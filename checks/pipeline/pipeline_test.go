@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// ago formats the time d ago for API responses.
+func ago(d time.Duration) string {
+	return time.Now().Add(-d).UTC().Format(time.RFC3339)
+}
+
+func TestCheckGitHub(t *testing.T) {
+	// Runs by branch of the owner/app repository
+	runs := map[string]string{
+		"main":    fmt.Sprintf(`{"status":"completed","conclusion":"success","run_started_at":%q,"updated_at":%q}`, ago(time.Hour), ago(50*time.Minute)),
+		"broken":  fmt.Sprintf(`{"status":"completed","conclusion":"failure","run_started_at":%q,"updated_at":%q}`, ago(time.Hour), ago(55*time.Minute)),
+		"stopped": fmt.Sprintf(`{"status":"completed","conclusion":"cancelled","run_started_at":%q,"updated_at":%q}`, ago(time.Hour), ago(55*time.Minute)),
+		"slow":    fmt.Sprintf(`{"status":"in_progress","conclusion":null,"run_started_at":%q,"updated_at":%q}`, ago(2*time.Hour), ago(time.Minute)),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/app/actions/runs" || r.Header.Get("Authorization") != "Bearer ghtoken" {
+			http.NotFound(w, r)
+			return
+		}
+		run, ok := runs[r.URL.Query().Get("branch")]
+		if !ok {
+			w.Write([]byte(`{"workflow_runs":[]}`))
+			return
+		}
+		fmt.Fprintf(w, `{"workflow_runs":[%s]}`, run)
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		name      string
+		branches  []string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", []string{"main"}, Options{}, gomonitor.OK, "1 pipeline(s) healthy"},
+		{"Test Failed", []string{"main", "broken"}, Options{}, gomonitor.Critical, "1 of 2 pipeline(s) unhealthy: owner/app@broken failed"},
+		{"Test Canceled", []string{"stopped"}, Options{}, gomonitor.Warning, "owner/app@stopped was canceled"},
+		{"Test Stuck Warning", []string{"slow"}, Options{Warn: time.Hour, Crit: 3 * time.Hour}, gomonitor.Warning, "owner/app@slow running for 2h0m"},
+		{"Test Stuck Critical", []string{"slow"}, Options{Warn: 30 * time.Minute, Crit: time.Hour}, gomonitor.Critical, ""},
+		{"Test Running", []string{"slow"}, Options{}, gomonitor.OK, ""},
+		{"Test No Runs", []string{"new"}, Options{}, gomonitor.Unknown, "owner/app@new: no workflow runs"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Provider = GitHub
+			tc.opts.URL = srv.URL
+			tc.opts.Token = "ghtoken"
+			for _, branch := range tc.branches {
+				tc.opts.Targets = append(tc.opts.Targets, Target{Repo: "owner/app", Branch: branch})
+			}
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckGitLab(t *testing.T) {
+	statuses := map[string]string{"main": "success", "broken": "failed", "slow": "running"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The project path arrives escaped as a single path segment
+		if r.URL.EscapedPath() != "/projects/group%2Fapp/pipelines" || r.Header.Get("PRIVATE-TOKEN") != "gltoken" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `[{"status":%q,"created_at":%q,"updated_at":%q}]`,
+			statuses[r.URL.Query().Get("ref")], ago(90*time.Minute), ago(80*time.Minute))
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		name      string
+		branch    string
+		wantState gomonitor.ExitCode
+	}{
+		{"Test Success", "main", gomonitor.OK},
+		{"Test Failed", "broken", gomonitor.Critical},
+		{"Test Stuck", "slow", gomonitor.Warning},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), Options{
+				Provider: GitLab,
+				URL:      srv.URL + "/",
+				Token:    "gltoken",
+				Targets:  []Target{{Repo: "group/app", Branch: tc.branch}},
+				Warn:     time.Hour,
+			})
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+		})
+	}
+}
+
+func TestCheckInvalidOptions(t *testing.T) {
+	result := Check(context.Background(), Options{Provider: "jenkins"})
+	if result.ExitCode != gomonitor.Unknown {
+		t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+	}
+	result = Check(context.Background(), Options{Provider: GitHub})
+	if result.ExitCode != gomonitor.Unknown {
+		t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+	}
+}
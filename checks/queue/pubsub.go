@@ -0,0 +1,113 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMonitoringURL is the Google Cloud Monitoring API.
+const DefaultMonitoringURL = "https://monitoring.googleapis.com"
+
+// PubSubConfig configures the Google Pub/Sub backend.
+// - `Project` is the ID of the project the subscription belongs to.
+// - `Subscription` is the subscription ID.
+// - `Token` is an OAuth 2.0 access token with the monitoring.read scope.
+// - `URL` is the Cloud Monitoring API. "" means DefaultMonitoringURL.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+type PubSubConfig struct {
+	Project      string
+	Subscription string
+	Token        string
+	URL          string
+	Client       *http.Client
+}
+
+// pubSubWindow is how far back the backend looks for samples. Pub/Sub metrics are sampled
+// every minute and can take a few more to become visible.
+const pubSubWindow = 10 * time.Minute
+
+// PubSub returns a Backend that reads the undelivered message count and the age of the oldest
+// unacknowledged message of a Pub/Sub subscription from Cloud Monitoring.
+func PubSub(cfg PubSubConfig) Backend {
+	return func(ctx context.Context) (Stats, error) {
+		depth, err := latestSample(ctx, cfg, "pubsub.googleapis.com/subscription/num_undelivered_messages")
+		if err != nil {
+			return Stats{}, err
+		}
+		age, err := latestSample(ctx, cfg, "pubsub.googleapis.com/subscription/oldest_unacked_message_age")
+		if err != nil {
+			return Stats{}, err
+		}
+		return Stats{Depth: depth, OldestAge: time.Duration(age) * time.Second, HasAge: true}, nil
+	}
+}
+
+// latestSample returns the newest value of an integer subscription metric.
+func latestSample(ctx context.Context, cfg PubSubConfig, metric string) (int64, error) {
+	base := cfg.URL
+	if base == "" {
+		base = DefaultMonitoringURL
+	}
+	now := time.Now().UTC()
+	query := url.Values{
+		"filter":             {fmt.Sprintf(`metric.type = %q AND resource.labels.subscription_id = %q`, metric, cfg.Subscription)},
+		"interval.startTime": {now.Add(-pubSubWindow).Format(time.RFC3339)},
+		"interval.endTime":   {now.Format(time.RFC3339)},
+	}
+	u := strings.TrimSuffix(base, "/") + "/v3/projects/" + url.PathEscape(cfg.Project) + "/timeSeries?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+
+	raw, err := fetch(cfg.Client, req)
+	if err != nil {
+		return 0, err
+	}
+	var resp struct {
+		TimeSeries []struct {
+			Points []struct {
+				Value struct {
+					Int64Value string `json:"int64Value"`
+				} `json:"value"`
+			} `json:"points"`
+		} `json:"timeSeries"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return 0, err
+	}
+	// Points are returned newest first
+	if len(resp.TimeSeries) == 0 || len(resp.TimeSeries[0].Points) == 0 {
+		return 0, errors.New("no recent samples for subscription " + cfg.Subscription)
+	}
+	value := resp.TimeSeries[0].Points[0].Value.Int64Value
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s sample %q", metric, value)
+	}
+	return v, nil
+}
@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPubSub(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter := r.URL.Query().Get("filter")
+		if r.URL.Path != "/v3/projects/acme/timeSeries" || r.Header.Get("Authorization") != "Bearer ya29.token" ||
+			r.URL.Query().Get("interval.startTime") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if !strings.Contains(filter, `resource.labels.subscription_id = "workers"`) {
+			w.Write([]byte(`{}`))
+			return
+		}
+		value := "250"
+		if strings.Contains(filter, "oldest_unacked_message_age") {
+			value = "95"
+		}
+		fmt.Fprintf(w, `{"timeSeries":[{"points":[{"value":{"int64Value":%q}},{"value":{"int64Value":"1"}}]}]}`, value)
+	}))
+	defer srv.Close()
+
+	cfg := PubSubConfig{Project: "acme", Subscription: "workers", Token: "ya29.token", URL: srv.URL}
+	stats, err := PubSub(cfg)(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Stats{Depth: 250, OldestAge: 95 * time.Second, HasAge: true}); stats != want {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+
+	cfg.Subscription = "idle"
+	if _, err := PubSub(cfg)(context.Background()); err == nil || err.Error() != "no recent samples for subscription idle" {
+		t.Errorf("got error %v, want no recent samples", err)
+	}
+
+	cfg.Token = "expired"
+	if _, err := PubSub(cfg)(context.Background()); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("got error %v, want 403", err)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package queue checks the backlog of a message queue. Queue statistics come from a Backend, so
+// the same thresholds and output work for Amazon SQS, Azure Service Bus and Google Pub/Sub.
+package queue
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// maxResponseSize bounds each API response a backend will read.
+const maxResponseSize = 1 << 20
+
+// Stats is the state of a queue's backlog.
+// - `Depth` is the number of messages waiting to be delivered.
+// - `OldestAge` is the age of the oldest waiting message. It is only meaningful when `HasAge` is set, as not every service reports it.
+type Stats struct {
+	Depth     int64
+	OldestAge time.Duration
+	HasAge    bool
+}
+
+// Backend returns the current statistics of a queue.
+type Backend func(ctx context.Context) (Stats, error)
+
+// Options configures a queue check.
+// - `Name` identifies the queue in the message.
+// - `Backend` reads the queue statistics.
+// - `WarnDepth` and `CritDepth` are backlogs above which the check is Warning or Critical. 0 disables a threshold.
+// - `WarnAge` and `CritAge` are oldest message ages above which the check is Warning or Critical. 0 disables a threshold.
+// - `Timeout` bounds the backend. 0 means 10 seconds.
+type Options struct {
+	Name      string
+	Backend   Backend
+	WarnDepth int64
+	CritDepth int64
+	WarnAge   time.Duration
+	CritAge   time.Duration
+	Timeout   time.Duration
+}
+
+// Check reads the queue statistics and compares the backlog and, when the backend reports it,
+// the age of the oldest message with the thresholds. Backend errors are Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Backend == nil {
+		result.SetResult(gomonitor.Unknown, "no queue backend given")
+		return result
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	stats, err := opts.Backend(ctx)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("%s: %v", opts.Name, err))
+		return result
+	}
+	result.AddPerformanceData("depth", gomonitor.PerformanceMetric{
+		Value:   float64(stats.Depth),
+		Warn:    float64(opts.WarnDepth),
+		Crit:    float64(opts.CritDepth),
+		Integer: true,
+	})
+	msg := fmt.Sprintf("%s has %d message(s) waiting", opts.Name, stats.Depth)
+	if stats.HasAge {
+		result.AddPerformanceData("oldest_age", gomonitor.PerformanceMetric{
+			Value:  stats.OldestAge.Seconds(),
+			Warn:   opts.WarnAge.Seconds(),
+			Crit:   opts.CritAge.Seconds(),
+			UnitOM: "s",
+		})
+		msg += fmt.Sprintf(", oldest %s", stats.OldestAge.Round(time.Second))
+	}
+
+	result.Evaluate()
+	result.SetResult(result.ExitCode, msg)
+	return result
+}
+
+// fetch sends req with client, nil meaning http.DefaultClient, and returns the body of a 200
+// response. Error responses are reported with the start of their body, where the services
+// explain the problem.
+func fetch(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := tracing.Do(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		detail := strings.TrimSpace(string(raw))
+		if len(detail) > 200 {
+			detail = detail[:200]
+		}
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, detail)
+	}
+	return raw, nil
+}
@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fixed returns a Backend that always reports stats and err.
+func fixed(stats Stats, err error) Backend {
+	return func(context.Context) (Stats, error) {
+		return stats, err
+	}
+}
+
+func TestCheck(t *testing.T) {
+	thresholds := Options{WarnDepth: 100, CritDepth: 1000, WarnAge: time.Minute, CritAge: 10 * time.Minute}
+	testCases := []struct {
+		name      string
+		backend   Backend
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", fixed(Stats{Depth: 3, OldestAge: 5 * time.Second, HasAge: true}, nil), thresholds,
+			gomonitor.OK, "jobs has 3 message(s) waiting, oldest 5s"},
+		{"Test Depth At Warning", fixed(Stats{Depth: 100}, nil), thresholds, gomonitor.OK, "jobs has 100 message(s) waiting"},
+		{"Test Depth Warning", fixed(Stats{Depth: 150}, nil), thresholds, gomonitor.Warning, "jobs has 150 message(s) waiting"},
+		{"Test Depth Critical", fixed(Stats{Depth: 1500}, nil), thresholds, gomonitor.Critical, ""},
+		{"Test Age Warning", fixed(Stats{Depth: 3, OldestAge: 2 * time.Minute, HasAge: true}, nil), thresholds, gomonitor.Warning, ""},
+		{"Test Age Critical", fixed(Stats{Depth: 150, OldestAge: time.Hour, HasAge: true}, nil), thresholds, gomonitor.Critical, "jobs has 150 message(s) waiting, oldest 1h0m0s"},
+		{"Test Age Not Reported", fixed(Stats{Depth: 3}, nil), thresholds, gomonitor.OK, "jobs has 3 message(s) waiting"},
+		{"Test Backend Error", fixed(Stats{}, errors.New("access denied")), thresholds, gomonitor.Unknown, "jobs: access denied"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Name = "jobs"
+			tc.opts.Backend = tc.backend
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if tc.wantMsg != "" && result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPerfdata(t *testing.T) {
+	result := Check(context.Background(), Options{
+		Name:      "jobs",
+		Backend:   fixed(Stats{Depth: 7, OldestAge: 30 * time.Second, HasAge: true}, nil),
+		WarnDepth: 100,
+	})
+	want := "'depth'=7;100;0;0;0 'oldest_age'=30.00s;0.00;0.00;0.00;0.00"
+	if got := result.FormatResult(); !strings.Contains(got, want) {
+		t.Errorf("got output %q, want it to contain %q", got, want)
+	}
+
+	result = Check(context.Background(), Options{Name: "jobs", Backend: fixed(Stats{Depth: 7}, nil)})
+	if got := result.FormatResult(); strings.Contains(got, "oldest_age") {
+		t.Errorf("got output %q, want no oldest_age without an age", got)
+	}
+}
+
+func TestCheckNoBackend(t *testing.T) {
+	result := Check(context.Background(), Options{Name: "jobs"})
+	if result.ExitCode != gomonitor.Unknown {
+		t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+	}
+}
@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// entry is a Service Bus queue entity with 17 active messages.
+const entry = `<entry xmlns="http://www.w3.org/2005/Atom"><title type="text">orders</title>` +
+	`<content type="application/xml"><QueueDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect">` +
+	`<MessageCount>20</MessageCount><CountDetails xmlns:d2p1="http://schemas.microsoft.com/netservices/2011/06/servicebus">` +
+	`<d2p1:ActiveMessageCount>17</d2p1:ActiveMessageCount><d2p1:DeadLetterMessageCount>3</d2p1:DeadLetterMessageCount>` +
+	`</CountDetails></QueueDescription></content></entry>`
+
+func TestServiceBus(t *testing.T) {
+	var srvURL string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := url.ParseQuery(strings.TrimPrefix(r.Header.Get("Authorization"), "SharedAccessSignature "))
+		if err != nil || token.Get("skn") != "monitor" || token.Get("sr") != srvURL+r.URL.Path {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mac := hmac.New(sha256.New, []byte("sbkey"))
+		mac.Write([]byte(url.QueryEscape(token.Get("sr")) + "\n" + token.Get("se")))
+		if token.Get("sig") != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/orders" {
+			w.Write([]byte(`<feed xmlns="http://www.w3.org/2005/Atom"><title type="text">Publicly Listed Services</title></feed>`))
+			return
+		}
+		w.Write([]byte(entry))
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	cfg := ServiceBusConfig{Endpoint: srv.URL + "/", Queue: "orders", KeyName: "monitor", Key: "sbkey"}
+	stats, err := ServiceBus(cfg)(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (Stats{Depth: 17}) {
+		t.Errorf("got %+v, want depth 17 without age", stats)
+	}
+
+	cfg.Queue = "missing"
+	if _, err := ServiceBus(cfg)(context.Background()); err == nil || err.Error() != "queue missing not found" {
+		t.Errorf("got error %v, want queue missing not found", err)
+	}
+
+	cfg.Key = "wrong"
+	if _, err := ServiceBus(cfg)(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("got error %v, want 401", err)
+	}
+}
+
+func TestSASToken(t *testing.T) {
+	token := sasToken("https://example.servicebus.windows.net/orders", "monitor", "sbkey", time.Unix(1700000000, 0))
+	want := "SharedAccessSignature sr=https%3A%2F%2Fexample.servicebus.windows.net%2Forders&sig="
+	if !strings.HasPrefix(token, want) || !strings.HasSuffix(token, "&se=1700000000&skn=monitor") {
+		t.Errorf("got %q", token)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQSConfig configures the Amazon SQS backend.
+// - `QueueURL` is the queue URL, such as "https://sqs.us-east-1.amazonaws.com/123456789012/jobs".
+// - `Region` is the AWS region of the queue. "" means the region in QueueURL's host.
+// - `AccessKeyID`, `SecretAccessKey` and `SessionToken` are the credentials the request is signed with. SessionToken is only needed for temporary credentials.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+type SQSConfig struct {
+	QueueURL        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Client          *http.Client
+}
+
+// SQS returns a Backend that reads the ApproximateNumberOfMessages attribute of an SQS queue.
+// SQS only publishes the age of the oldest message to CloudWatch, so the backend does not
+// report it.
+func SQS(cfg SQSConfig) Backend {
+	return func(ctx context.Context) (Stats, error) {
+		queue, err := url.Parse(cfg.QueueURL)
+		if err != nil {
+			return Stats{}, err
+		}
+		region := cfg.Region
+		if region == "" {
+			// Queue hosts look like sqs.<region>.amazonaws.com
+			parts := strings.Split(queue.Hostname(), ".")
+			if len(parts) < 3 || parts[0] != "sqs" {
+				return Stats{}, fmt.Errorf("cannot tell the region of %s", cfg.QueueURL)
+			}
+			region = parts[1]
+		}
+
+		body, err := json.Marshal(map[string]any{
+			"QueueUrl":       cfg.QueueURL,
+			"AttributeNames": []string{"ApproximateNumberOfMessages"},
+		})
+		if err != nil {
+			return Stats{}, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, queue.Scheme+"://"+queue.Host+"/", bytes.NewReader(body))
+		if err != nil {
+			return Stats{}, err
+		}
+		req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+		req.Header.Set("X-Amz-Target", "AmazonSQS.GetQueueAttributes")
+		if cfg.SessionToken != "" {
+			req.Header.Set("X-Amz-Security-Token", cfg.SessionToken)
+		}
+		signV4(req, body, cfg.AccessKeyID, cfg.SecretAccessKey, region, "sqs", time.Now())
+
+		raw, err := fetch(cfg.Client, req)
+		if err != nil {
+			return Stats{}, err
+		}
+		var resp struct {
+			Attributes map[string]string `json:"Attributes"`
+		}
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return Stats{}, err
+		}
+		depth, err := strconv.ParseInt(resp.Attributes["ApproximateNumberOfMessages"], 10, 64)
+		if err != nil {
+			return Stats{}, fmt.Errorf("invalid ApproximateNumberOfMessages %q", resp.Attributes["ApproximateNumberOfMessages"])
+		}
+		return Stats{Depth: depth}, nil
+	}
+}
+
+// signV4 signs req with AWS Signature Version 4, covering every header already set on it and
+// the host.
+func signV4(req *http.Request, body []byte, accessKeyID, secretAccessKey, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
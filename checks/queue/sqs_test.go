@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignV4(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signV4(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSQS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			QueueURL       string   `json:"QueueUrl"`
+			AttributeNames []string `json:"AttributeNames"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil ||
+			r.Header.Get("X-Amz-Target") != "AmazonSQS.GetQueueAttributes" ||
+			!strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/") ||
+			!strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/sqs/aws4_request") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazon.coral.service#InvalidSignatureException"}`))
+			return
+		}
+		if strings.HasSuffix(req.QueueURL, "/missing") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.sqs#QueueDoesNotExist"}`))
+			return
+		}
+		w.Write([]byte(`{"Attributes":{"ApproximateNumberOfMessages":"42"}}`))
+	}))
+	defer srv.Close()
+
+	cfg := SQSConfig{QueueURL: srv.URL + "/123456789012/jobs", Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	stats, err := SQS(cfg)(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (Stats{Depth: 42}) {
+		t.Errorf("got %+v, want depth 42 without age", stats)
+	}
+
+	cfg.QueueURL = srv.URL + "/123456789012/missing"
+	if _, err := SQS(cfg)(context.Background()); err == nil || !strings.Contains(err.Error(), "QueueDoesNotExist") {
+		t.Errorf("got error %v, want QueueDoesNotExist", err)
+	}
+
+	// The region cannot be taken from a test server's host
+	cfg.Region = ""
+	if _, err := SQS(cfg)(context.Background()); err == nil {
+		t.Error("SQS without a region succeeded, want error")
+	}
+}
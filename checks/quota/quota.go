@@ -0,0 +1,225 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package quota checks filesystem quota usage per user, group or project.
+package quota
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Quota types.
+const (
+	User    = "user"
+	Group   = "group"
+	Project = "project"
+)
+
+// repquotaFlags maps quota types to the repquota flag selecting them.
+var repquotaFlags = map[string]string{User: "-u", Group: "-g", Project: "-P"}
+
+// Options configures a quota check.
+// - `Filesystem` is the mount point or device to report on.
+// - `Type` is User, Group or Project. It defaults to User.
+// - `Principals` limits the check to these users, groups or projects. Empty means all with a limit set.
+// - `Command` is the repquota program, with any leading arguments. It defaults to "repquota".
+// - `Warn` and `Crit` are usage percentages above which a principal is Warning or Critical. 0 disables a threshold.
+// - `Timeout` bounds the repquota command. 0 means 30 seconds.
+type Options struct {
+	Filesystem string
+	Type       string
+	Principals []string
+	Command    []string
+	Warn       float64
+	Crit       float64
+	Timeout    time.Duration
+}
+
+// usage is the quota usage of a single principal.
+// - `blocks` and `files` are percentages of the hard limit, or of the soft limit when there is no hard limit. -1 means no limit is set.
+type usage struct {
+	name   string
+	blocks float64
+	files  float64
+}
+
+// percent returns the higher of the block and file usage.
+func (u usage) percent() float64 {
+	return max(u.blocks, u.files)
+}
+
+// Check reads the quota report and compares every principal's usage with the thresholds. Each
+// principal with a limit gets a perfdata entry with its highest usage percentage. The state is
+// that of the worst principal. Failing to read the report is Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Type == "" {
+		opts.Type = User
+	}
+	if len(opts.Command) == 0 {
+		opts.Command = []string{"repquota"}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	flag, ok := repquotaFlags[opts.Type]
+	if !ok {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("unsupported quota type %q", opts.Type))
+		return result
+	}
+	if opts.Filesystem == "" {
+		result.SetResult(gomonitor.Unknown, "no filesystem given")
+		return result
+	}
+
+	usages, err := report(ctx, opts, flag)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	if len(opts.Principals) > 0 {
+		usages = slices.DeleteFunc(usages, func(u usage) bool {
+			return !slices.Contains(opts.Principals, u.name)
+		})
+	}
+
+	state := gomonitor.OK
+	var warn, crit []string
+	checked := 0
+	for _, u := range usages {
+		pct := u.percent()
+		if pct < 0 {
+			continue
+		}
+		checked++
+		metric := gomonitor.PerformanceMetric{
+			Value:  pct,
+			Warn:   opts.Warn,
+			Crit:   opts.Crit,
+			Max:    100,
+			UnitOM: "%",
+		}
+		result.AddPerformanceData(u.name, metric)
+		switch metric.State() {
+		case gomonitor.Critical:
+			state = gomonitor.Critical
+			crit = append(crit, fmt.Sprintf("%s %.1f%%", u.name, pct))
+		case gomonitor.Warning:
+			if state == gomonitor.OK {
+				state = gomonitor.Warning
+			}
+			warn = append(warn, fmt.Sprintf("%s %.1f%%", u.name, pct))
+		}
+	}
+
+	msg := fmt.Sprintf("%d %s quota(s) on %s within limits", checked, opts.Type, opts.Filesystem)
+	if len(crit)+len(warn) > 0 {
+		msg = fmt.Sprintf("%d of %d %s quota(s) on %s over threshold: %s", len(crit)+len(warn), checked,
+			opts.Type, opts.Filesystem, strings.Join(append(crit, warn...), ", "))
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// report runs "repquota -O csv" and returns the usage of every principal in it.
+func report(ctx context.Context, opts Options, flag string) ([]usage, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	args := append(slices.Clone(opts.Command[1:]), flag, "-O", "csv", opts.Filesystem)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, opts.Command[0], args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running repquota: %v: %s", err, msg)
+		}
+		return nil, fmt.Errorf("running repquota: %v", err)
+	}
+	return parseReport(out)
+}
+
+// reportColumns are the repquota CSV columns the check reads.
+var reportColumns = [...]string{"BlockUsed", "BlockSoftLimit", "BlockHardLimit", "FileUsed", "FileSoftLimit", "FileHardLimit"}
+
+// parseReport parses repquota's CSV output. The first column names the principal; the other
+// columns are located by their header names.
+func parseReport(data []byte) ([]usage, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing repquota output: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("parsing repquota output: no header")
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	for _, name := range reportColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("parsing repquota output: missing column %s", name)
+		}
+	}
+
+	var usages []usage
+	for _, record := range records[1:] {
+		field := func(name string) (float64, error) {
+			s := record[columns[name]]
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return 0, fmt.Errorf("parsing repquota output: invalid %s %q for %s", name, s, record[0])
+			}
+			return v, nil
+		}
+		var values [len(reportColumns)]float64
+		for i, name := range reportColumns {
+			if values[i], err = field(name); err != nil {
+				return nil, err
+			}
+		}
+		usages = append(usages, usage{
+			name:   record[0],
+			blocks: percent(values[0], values[1], values[2]),
+			files:  percent(values[3], values[4], values[5]),
+		})
+	}
+	return usages, nil
+}
+
+// percent returns used as a percentage of the hard limit, falling back to the soft limit. It
+// returns -1 if neither limit is set.
+func percent(used, soft, hard float64) float64 {
+	limit := hard
+	if limit == 0 {
+		limit = soft
+	}
+	if limit == 0 {
+		return -1
+	}
+	return used / limit * 100
+}
@@ -0,0 +1,141 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+const testReport = `User,BlockStatus,FileStatus,BlockUsed,BlockSoftLimit,BlockHardLimit,BlockGrace,FileUsed,FileSoftLimit,FileHardLimit,FileGrace
+root,ok,ok,2048,0,0,,100,0,0,
+alice,soft,ok,950,800,1000,6days,10,0,0,
+bob,ok,ok,850,0,1000,,10,0,0,
+carol,ok,soft,100,0,1000,,95,90,100,7days
+dave,ok,ok,10,1000,0,,1,0,0,
+`
+
+// TestHelperProcess stands in for repquota when run by the tests below.
+func TestHelperProcess(t *testing.T) {
+	output, ok := os.LookupEnv("QUOTA_HELPER_OUTPUT")
+	if !ok {
+		return
+	}
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	if got, want := strings.Join(args, " "), os.Getenv("QUOTA_HELPER_ARGS"); got != want {
+		fmt.Fprintf(os.Stderr, "got args %q, want %q", got, want)
+		os.Exit(2)
+	}
+	fmt.Print(output)
+	os.Exit(0)
+}
+
+// helperCommand returns a Command that expects args and prints output.
+func helperCommand(t *testing.T, args, output string) []string {
+	t.Setenv("QUOTA_HELPER_ARGS", args)
+	t.Setenv("QUOTA_HELPER_OUTPUT", output)
+	return []string{os.Args[0], "-test.run=^TestHelperProcess$", "--"}
+}
+
+func TestParseReport(t *testing.T) {
+	usages, err := parseReport([]byte(testReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []usage{
+		{"root", -1, -1},
+		{"alice", 95, -1},
+		{"bob", 85, -1},
+		{"carol", 10, 95},
+		{"dave", 1, -1},
+	}
+	if !slices.Equal(usages, want) {
+		t.Errorf("got %+v, want %+v", usages, want)
+	}
+
+	for _, bad := range []string{"", "User,BlockUsed\nroot,1\n", strings.Replace(testReport, "950", "lots", 1)} {
+		if _, err := parseReport([]byte(bad)); err == nil {
+			t.Errorf("parseReport(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		opts      Options
+		args      string
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Critical", Options{Warn: 80, Crit: 90}, "-u -O csv /home", gomonitor.Critical,
+			"3 of 4 user quota(s) on /home over threshold: alice 95.0%, carol 95.0%, bob 85.0%"},
+		{"Test Warning", Options{Warn: 80, Crit: 99}, "-u -O csv /home", gomonitor.Warning,
+			"3 of 4 user quota(s) on /home over threshold: alice 95.0%, bob 85.0%, carol 95.0%"},
+		{"Test OK", Options{Warn: 96}, "-u -O csv /home", gomonitor.OK,
+			"4 user quota(s) on /home within limits"},
+		{"Test Principals", Options{Warn: 80, Principals: []string{"dave", "bob"}}, "-u -O csv /home", gomonitor.Warning,
+			"1 of 2 user quota(s) on /home over threshold: bob 85.0%"},
+		{"Test Group", Options{Type: Group, Warn: 96}, "-g -O csv /home", gomonitor.OK,
+			"4 group quota(s) on /home within limits"},
+		{"Test Project", Options{Type: Project, Warn: 96}, "-P -O csv /home", gomonitor.OK,
+			"4 project quota(s) on /home within limits"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Filesystem = "/home"
+			tc.opts.Command = helperCommand(t, tc.args, testReport)
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if result.Message != tc.wantMsg {
+				t.Errorf("got message %q, want %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPerformanceData(t *testing.T) {
+	result := Check(context.Background(), Options{
+		Filesystem: "/home",
+		Command:    helperCommand(t, "-u -O csv /home", testReport),
+	})
+	if _, ok := result.PerformanceData["root"]; ok {
+		t.Error("got perfdata for a principal without limits")
+	}
+	if got := result.PerformanceData["carol"].Value; got != 95 {
+		t.Errorf("got carol=%v, want 95", got)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    func(t *testing.T) Options
+		wantMsg string
+	}{
+		{"Test No Filesystem", func(t *testing.T) Options { return Options{} }, "no filesystem given"},
+		{"Test Bad Type", func(t *testing.T) Options { return Options{Filesystem: "/", Type: "tree"} }, `unsupported quota type "tree"`},
+		{"Test Command Fails", func(t *testing.T) Options {
+			return Options{Filesystem: "/", Command: helperCommand(t, "unexpected", "")}
+		}, "running repquota: exit status 2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), tc.opts(t))
+			if result.ExitCode != gomonitor.Unknown {
+				t.Errorf("got exitCode %s (%s), want Unknown", result.ExitCode, result.Message)
+			}
+			if !strings.HasPrefix(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want prefix %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
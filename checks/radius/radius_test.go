@@ -0,0 +1,106 @@
+package radius
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeServer accepts user "alice" with password "s3cret-password!!" and rejects everyone
+// else. Requests signed with the wrong secret are dropped.
+func fakeServer(t *testing.T, secret string) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := append([]byte{}, buf[:n]...)
+			var user, hidden []byte
+			for attrs := req[20:]; len(attrs) >= 2; attrs = attrs[attrs[1]:] {
+				switch attrs[0] {
+				case attrUserName:
+					user = attrs[2:attrs[1]]
+				case attrUserPassword:
+					hidden = attrs[2:attrs[1]]
+				}
+			}
+			// Each block's pad depends on the previous hidden block
+			password := make([]byte, len(hidden))
+			prev := req[4:20]
+			for i := 0; i < len(hidden); i += 16 {
+				sum := md5.Sum(append([]byte(secret), prev...))
+				for j := range 16 {
+					password[i+j] = hidden[i+j] ^ sum[j]
+				}
+				prev = hidden[i : i+16]
+			}
+			code := byte(codeAccessReject)
+			if string(user) == "alice" && strings.TrimRight(string(password), "\x00") == "s3cret-password!!" {
+				code = codeAccessAccept
+			}
+			resp := []byte{code, req[1], 0, 0}
+			resp = append(resp, req[4:20]...)
+			resp = appendAttr(resp, attrReplyMessage, []byte("hello "+string(user)))
+			binary.BigEndian.PutUint16(resp[2:], uint16(len(resp)))
+			sum := md5.Sum(append(append([]byte{}, resp...), secret...))
+			copy(resp[4:20], sum[:])
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestCheck(t *testing.T) {
+	addr := fakeServer(t, "testing123")
+
+	testCases := []struct {
+		name      string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Accept", Options{Secret: "testing123", Username: "alice", Password: "s3cret-password!!"},
+			gomonitor.OK, "Access-Accept for alice"},
+		{"Test Reject", Options{Secret: "testing123", Username: "alice", Password: "wrong"},
+			gomonitor.Critical, "Access-Reject for alice"},
+		{"Test Wrong Secret", Options{Secret: "other", Username: "alice", Password: "x", Timeout: 100 * time.Millisecond},
+			gomonitor.Critical, "no response"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Address = addr
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestHidePassword(t *testing.T) {
+	authenticator := make([]byte, 16)
+	for _, password := range []string{"", "short", "exactly-16-bytes", "a password longer than one block"} {
+		hidden := hidePassword(password, "secret", authenticator)
+		if len(hidden)%16 != 0 || len(hidden) < len(password) {
+			t.Errorf("hidePassword(%q) got %d bytes, want a multiple of 16 covering the password", password, len(hidden))
+		}
+	}
+}
@@ -0,0 +1,289 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// OIDs used in OCSP requests and responses (RFC 6960).
+var (
+	oidSHA1              = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+)
+
+// signatureAlgorithms maps the signature OIDs responders commonly use to x509 algorithms.
+var signatureAlgorithms = []signatureAlgorithm{
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}, x509.SHA1WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, x509.SHA256WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}, x509.SHA384WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}, x509.SHA512WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}, x509.ECDSAWithSHA1},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}, x509.ECDSAWithSHA256},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}, x509.ECDSAWithSHA384},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}, x509.ECDSAWithSHA512},
+	{asn1.ObjectIdentifier{1, 3, 101, 112}, x509.PureEd25519},
+}
+
+type signatureAlgorithm struct {
+	oid  asn1.ObjectIdentifier
+	algo x509.SignatureAlgorithm
+}
+
+// OCSP response statuses (RFC 6960 section 4.2.1).
+var responseStatuses = map[asn1.Enumerated]string{
+	1: "malformedRequest",
+	2: "internalError",
+	3: "tryLater",
+	5: "sigRequired",
+	6: "unauthorized",
+}
+
+type certID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest struct {
+		RequestList []struct {
+			Cert certID
+		}
+	}
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	} `asn1:"explicit,tag:0,optional"`
+}
+
+type basicResponse struct {
+	TBSResponseData    responseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Raw            asn1.RawContent
+	Version        int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID asn1.RawValue
+	ProducedAt     time.Time `asn1:"generalized"`
+	Responses      []singleResponse
+}
+
+type singleResponse struct {
+	CertID     certID
+	Good       asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked    revokedInfo      `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	Extensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// OCSPOptions configures an OCSP check.
+// - `Certificate` is the certificate to ask about.
+// - `Issuer` is the certificate of the CA that issued it.
+// - `URL` is the OCSP responder. It defaults to the first OCSP server listed in Certificate.
+// - `Warn` and `Crit` are the remaining times until the response's nextUpdate below which the check is Warning or Critical. 0 disables a threshold.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+// - `Timeout` bounds the request. 0 means 10 seconds.
+type OCSPOptions struct {
+	Certificate *x509.Certificate
+	Issuer      *x509.Certificate
+	URL         string
+	Warn        time.Duration
+	Crit        time.Duration
+	Client      *http.Client
+	Timeout     time.Duration
+}
+
+// CheckOCSP queries the responder about Certificate. A good, fresh, correctly signed answer is
+// OK. A revoked certificate, an unknown certificate, an error status, a bad signature or a
+// stale answer is Critical. Failing to reach the responder is Unknown.
+func CheckOCSP(ctx context.Context, opts OCSPOptions) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Certificate == nil || opts.Issuer == nil {
+		result.SetResult(gomonitor.Unknown, "both the certificate and its issuer are required")
+		return result
+	}
+	if opts.URL == "" && len(opts.Certificate.OCSPServer) > 0 {
+		opts.URL = opts.Certificate.OCSPServer[0]
+	}
+	if opts.URL == "" {
+		result.SetResult(gomonitor.Unknown, "no OCSP URL given or found in the certificate")
+		return result
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	id, err := newCertID(opts.Certificate, opts.Issuer)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	var req ocspRequest
+	req.TBSRequest.RequestList = append(req.TBSRequest.RequestList, struct{ Cert certID }{id})
+	body, err := asn1.Marshal(req)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("encoding OCSP request: %v", err))
+		return result
+	}
+
+	start := time.Now()
+	der, err := fetch(ctx, opts.Client, opts.Timeout, http.MethodPost, opts.URL, "application/ocsp-request", bytes.NewReader(body))
+	elapsed := time.Since(start)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("querying OCSP responder: %v", err))
+		return result
+	}
+	result.AddPerformanceData("time", gomonitor.PerformanceMetric{
+		Value:  elapsed.Seconds(),
+		UnitOM: "s",
+	})
+
+	single, err := parseResponse(der, id, opts.Issuer)
+	if err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("OCSP response from %s: %v", opts.URL, err))
+		return result
+	}
+	serial := opts.Certificate.SerialNumber
+	switch {
+	case !single.Revoked.RevocationTime.IsZero():
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("certificate %s revoked at %s",
+			serial, single.Revoked.RevocationTime.UTC().Format(time.RFC3339)))
+		return result
+	case bool(single.Unknown):
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("responder does not know certificate %s", serial))
+		return result
+	}
+	if single.NextUpdate.IsZero() {
+		result.SetResult(gomonitor.OK, fmt.Sprintf("certificate %s is good, response has no nextUpdate", serial))
+		return result
+	}
+	state, message := freshness("OCSP response", single.NextUpdate, opts.Warn, opts.Crit, result)
+	result.SetResult(state, fmt.Sprintf("certificate %s is good, %s", serial, message))
+	return result
+}
+
+// newCertID builds the SHA-1 CertID identifying cert to the responder.
+func newCertID(cert, issuer *x509.Certificate) (certID, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return certID{}, fmt.Errorf("parsing issuer public key: %v", err)
+	}
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign())
+	return certID{
+		HashAlgorithm:  pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+		IssuerNameHash: nameHash[:],
+		IssuerKeyHash:  keyHash[:],
+		SerialNumber:   cert.SerialNumber,
+	}, nil
+}
+
+// parseResponse decodes and verifies an OCSP response and returns the answer for id.
+func parseResponse(der []byte, id certID, issuer *x509.Certificate) (singleResponse, error) {
+	var resp ocspResponse
+	if rest, err := asn1.Unmarshal(der, &resp); err != nil || len(rest) > 0 {
+		return singleResponse{}, errors.New("malformed response")
+	}
+	if resp.Status != 0 {
+		name, ok := responseStatuses[resp.Status]
+		if !ok {
+			name = fmt.Sprintf("status %d", resp.Status)
+		}
+		return singleResponse{}, fmt.Errorf("responder returned %s", name)
+	}
+	if !resp.Response.ResponseType.Equal(oidOCSPBasicResponse) {
+		return singleResponse{}, errors.New("unsupported response type")
+	}
+	var basic basicResponse
+	if rest, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil || len(rest) > 0 {
+		return singleResponse{}, errors.New("malformed basic response")
+	}
+	if err := verifySignature(basic, issuer); err != nil {
+		return singleResponse{}, err
+	}
+	for _, single := range basic.TBSResponseData.Responses {
+		if single.CertID.SerialNumber.Cmp(id.SerialNumber) == 0 &&
+			bytes.Equal(single.CertID.IssuerNameHash, id.IssuerNameHash) &&
+			bytes.Equal(single.CertID.IssuerKeyHash, id.IssuerKeyHash) {
+			return single, nil
+		}
+	}
+	return singleResponse{}, errors.New("response does not cover the certificate")
+}
+
+// verifySignature checks that the response was signed by the issuer, or by a responder
+// certificate the issuer delegated OCSP signing to.
+func verifySignature(basic basicResponse, issuer *x509.Certificate) error {
+	i := slices.IndexFunc(signatureAlgorithms, func(s signatureAlgorithm) bool {
+		return s.oid.Equal(basic.SignatureAlgorithm.Algorithm)
+	})
+	if i == -1 {
+		return fmt.Errorf("unsupported signature algorithm %s", basic.SignatureAlgorithm.Algorithm)
+	}
+	algo := signatureAlgorithms[i].algo
+	signed, sig := basic.TBSResponseData.Raw, basic.Signature.RightAlign()
+
+	if issuer.CheckSignature(algo, signed, sig) == nil {
+		return nil
+	}
+	for _, raw := range basic.Certificates {
+		responder, err := x509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(responder.ExtKeyUsage, x509.ExtKeyUsageOCSPSigning) ||
+			responder.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		if responder.CheckSignature(algo, signed, sig) == nil {
+			return nil
+		}
+	}
+	return errors.New("signature not made by the issuer or an authorized responder")
+}
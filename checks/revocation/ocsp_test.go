@@ -0,0 +1,176 @@
+package revocation
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// ocspAnswer describes what the fake responder says about the requested certificate.
+type ocspAnswer struct {
+	status     asn1.Enumerated
+	good       bool
+	revoked    bool
+	nextUpdate time.Time
+	signer     crypto.Signer
+	signerCert *x509.Certificate
+}
+
+// ocspResponder answers every request it receives with answer, echoing the requested CertID.
+func ocspResponder(t *testing.T, answer *ocspAnswer) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req ocspRequest
+		if _, err := asn1.Unmarshal(body, &req); err != nil || r.Header.Get("Content-Type") != "application/ocsp-request" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(buildResponse(t, answer, req.TBSRequest.RequestList[0].Cert))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func buildResponse(t *testing.T, answer *ocspAnswer, id certID) []byte {
+	t.Helper()
+	var resp ocspResponse
+	resp.Status = answer.status
+	if answer.status != 0 {
+		der, err := asn1.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+
+	single := singleResponse{
+		CertID:     id,
+		Good:       asn1.Flag(answer.good),
+		Unknown:    asn1.Flag(!answer.good && !answer.revoked),
+		ThisUpdate: time.Now().Add(-time.Minute).UTC().Truncate(time.Second),
+		NextUpdate: answer.nextUpdate.UTC().Truncate(time.Second),
+	}
+	if answer.revoked {
+		single.Revoked.RevocationTime = time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	}
+	keyHash, _ := asn1.Marshal(id.IssuerKeyHash)
+	data := responseData{
+		RawResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: keyHash},
+		ProducedAt:     time.Now().UTC().Truncate(time.Second),
+		Responses:      []singleResponse{single},
+	}
+	tbs, err := asn1.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(tbs)
+	sig, err := answer.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic := basicResponse{
+		TBSResponseData:    responseData{Raw: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)},
+	}
+	if answer.signerCert != nil {
+		basic.Certificates = []asn1.RawValue{{FullBytes: answer.signerCert.Raw}}
+	}
+	resp.Response.ResponseType = oidOCSPBasicResponse
+	if resp.Response.Response, err = asn1.Marshal(basic); err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestCheckOCSP(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	other := newTestCA(t, "other CA")
+	delegate, delegateKey := ca.issue(t, 10, &x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+	})
+	undelegated, undelegatedKey := ca.issue(t, 11, &x509.Certificate{})
+	fresh := time.Now().Add(24 * time.Hour)
+
+	testCases := []struct {
+		name      string
+		answer    ocspAnswer
+		warn      time.Duration
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Good", ocspAnswer{good: true, nextUpdate: fresh, signer: ca.key}, 0, gomonitor.OK, "certificate 2 is good, OCSP response is fresh"},
+		{"Test No Next Update", ocspAnswer{good: true, signer: ca.key}, 0, gomonitor.OK, "certificate 2 is good, response has no nextUpdate"},
+		{"Test Warning", ocspAnswer{good: true, nextUpdate: fresh, signer: ca.key}, 48 * time.Hour, gomonitor.Warning, "certificate 2 is good, OCSP response nextUpdate"},
+		{"Test Stale", ocspAnswer{good: true, nextUpdate: time.Now().Add(-time.Minute), signer: ca.key}, 0, gomonitor.Critical, "certificate 2 is good, OCSP response expired"},
+		{"Test Revoked", ocspAnswer{revoked: true, nextUpdate: fresh, signer: ca.key}, 0, gomonitor.Critical, "certificate 2 revoked at"},
+		{"Test Unknown Certificate", ocspAnswer{nextUpdate: fresh, signer: ca.key}, 0, gomonitor.Critical, "responder does not know certificate 2"},
+		{"Test Try Later", ocspAnswer{status: 3}, 0, gomonitor.Critical, "responder returned tryLater"},
+		{"Test Delegated Responder", ocspAnswer{good: true, nextUpdate: fresh, signer: delegateKey, signerCert: delegate}, 0, gomonitor.OK, "certificate 2 is good"},
+		{"Test Undelegated Responder", ocspAnswer{good: true, nextUpdate: fresh, signer: undelegatedKey, signerCert: undelegated}, 0, gomonitor.Critical, "signature not made by the issuer"},
+		{"Test Wrong Signer", ocspAnswer{good: true, nextUpdate: fresh, signer: other.key}, 0, gomonitor.Critical, "signature not made by the issuer"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := ocspResponder(t, &tc.answer)
+			leaf, _ := ca.issue(t, 2, &x509.Certificate{OCSPServer: []string{srv.URL}})
+			result := CheckOCSP(context.Background(), OCSPOptions{Certificate: leaf, Issuer: ca.cert, Warn: tc.warn})
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckOCSPOptions(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	leaf, _ := ca.issue(t, 2, &x509.Certificate{})
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	testCases := []struct {
+		name      string
+		opts      OCSPOptions
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Missing Issuer", OCSPOptions{Certificate: leaf}, gomonitor.Unknown, "both the certificate and its issuer are required"},
+		{"Test No URL", OCSPOptions{Certificate: leaf, Issuer: ca.cert}, gomonitor.Unknown, "no OCSP URL"},
+		{"Test Not Found", OCSPOptions{Certificate: leaf, Issuer: ca.cert, URL: srv.URL}, gomonitor.Unknown, "querying OCSP responder"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := CheckOCSP(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.HasPrefix(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want prefix %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
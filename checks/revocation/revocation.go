@@ -0,0 +1,157 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package revocation checks certificate revocation infrastructure: that a CRL is being
+// published on time and that an OCSP responder gives fresh, good answers.
+package revocation
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// maxResponseSize bounds the CRL or OCSP response the check will read.
+const maxResponseSize = 50 << 20
+
+// CRLOptions configures a CRL check.
+// - `URL` is where the CRL is published. It defaults to the first CRL distribution point of Certificate.
+// - `Issuer` is the CA certificate. If set, the CRL signature is verified against it.
+// - `Certificate` is an optional certificate to look up in the CRL. If it is revoked the check is Critical.
+// - `Warn` and `Crit` are the remaining times until the CRL's nextUpdate below which the check is Warning or Critical. 0 disables a threshold.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+// - `Timeout` bounds the download. 0 means 30 seconds.
+type CRLOptions struct {
+	URL         string
+	Issuer      *x509.Certificate
+	Certificate *x509.Certificate
+	Warn        time.Duration
+	Crit        time.Duration
+	Client      *http.Client
+	Timeout     time.Duration
+}
+
+// CheckCRL downloads and validates a CRL. Download and parse failures are Unknown; a bad
+// signature, an expired CRL or a revoked Certificate is Critical.
+func CheckCRL(ctx context.Context, opts CRLOptions) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.URL == "" && opts.Certificate != nil && len(opts.Certificate.CRLDistributionPoints) > 0 {
+		opts.URL = opts.Certificate.CRLDistributionPoints[0]
+	}
+	if opts.URL == "" {
+		result.SetResult(gomonitor.Unknown, "no CRL URL given or found in the certificate")
+		return result
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	der, err := fetch(ctx, opts.Client, opts.Timeout, http.MethodGet, opts.URL, "", nil)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("fetching CRL: %v", err))
+		return result
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("parsing CRL from %s: %v", opts.URL, err))
+		return result
+	}
+	if opts.Issuer != nil {
+		if err := crl.CheckSignatureFrom(opts.Issuer); err != nil {
+			result.SetResult(gomonitor.Critical, fmt.Sprintf("CRL signature invalid: %v", err))
+			return result
+		}
+	}
+	result.AddPerformanceData("entries", gomonitor.PerformanceMetric{
+		Value: float64(len(crl.RevokedCertificateEntries)),
+	})
+
+	if opts.Certificate != nil {
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(opts.Certificate.SerialNumber) == 0 {
+				result.SetResult(gomonitor.Critical, fmt.Sprintf("certificate %s revoked at %s",
+					opts.Certificate.SerialNumber, entry.RevocationTime.UTC().Format(time.RFC3339)))
+				return result
+			}
+		}
+	}
+	if crl.NextUpdate.IsZero() {
+		result.SetResult(gomonitor.OK, fmt.Sprintf("CRL issued %s has no nextUpdate", crl.ThisUpdate.UTC().Format(time.RFC3339)))
+		return result
+	}
+	state, message := freshness("CRL", crl.NextUpdate, opts.Warn, opts.Crit, result)
+	result.SetResult(state, message)
+	return result
+}
+
+// freshness evaluates how long is left until nextUpdate and records it as perfdata.
+func freshness(what string, nextUpdate time.Time, warn, crit time.Duration, result *gomonitor.CheckResult) (gomonitor.ExitCode, string) {
+	remaining := time.Until(nextUpdate)
+	metric := gomonitor.PerformanceMetric{
+		Value:     remaining.Seconds(),
+		WarnRange: gomonitor.LowerBound(warn.Seconds()),
+		CritRange: gomonitor.LowerBound(crit.Seconds()),
+		UnitOM:    "s",
+	}
+	result.AddPerformanceData("next_update", metric)
+	when := nextUpdate.UTC().Format(time.RFC3339)
+	if remaining <= 0 {
+		return gomonitor.Critical, fmt.Sprintf("%s expired, nextUpdate was %s", what, when)
+	}
+	if state := metric.State(); state != gomonitor.OK {
+		return state, fmt.Sprintf("%s nextUpdate %s is in %s", what, when, remaining.Round(time.Second))
+	}
+	return gomonitor.OK, fmt.Sprintf("%s is fresh, nextUpdate %s", what, when)
+}
+
+// fetch performs an HTTP request and returns the response body, failing on non-2xx statuses.
+func fetch(ctx context.Context, client *http.Client, timeout time.Duration, method, url, contentType string, body io.Reader) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := tracing.Do(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxResponseSize {
+		return nil, errors.New("response too large")
+	}
+	return data, nil
+}
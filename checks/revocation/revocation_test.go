@@ -0,0 +1,163 @@
+package revocation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// testCA is a throwaway certificate authority.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCA{cert, key}
+}
+
+// issue signs a certificate from tmpl, filling in the fields every test needs.
+func (ca testCA) issue(t *testing.T, serial int64, tmpl *x509.Certificate) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(serial)
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(24 * time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// crl builds a CRL revoking serials that is valid until nextUpdate.
+func (ca testCA) crl(t *testing.T, nextUpdate time.Time, serials ...int64) []byte {
+	t.Helper()
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	for _, serial := range serials {
+		tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now().Add(-time.Minute),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, tmpl, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestCheckCRL(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	other := newTestCA(t, "other CA")
+	crls := map[string][]byte{
+		"/fresh.crl":   ca.crl(t, time.Now().Add(7*24*time.Hour), 5),
+		"/soon.crl":    ca.crl(t, time.Now().Add(2*time.Hour)),
+		"/expired.crl": ca.crl(t, time.Now().Add(-time.Minute)),
+		"/garbage.crl": []byte("not a CRL"),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		der, ok := crls[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(der)
+	}))
+	defer srv.Close()
+
+	good, _ := ca.issue(t, 4, &x509.Certificate{CRLDistributionPoints: []string{srv.URL + "/fresh.crl"}})
+	revoked, _ := ca.issue(t, 5, &x509.Certificate{})
+
+	testCases := []struct {
+		name      string
+		opts      CRLOptions
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Fresh", CRLOptions{URL: srv.URL + "/fresh.crl", Issuer: ca.cert, Warn: 24 * time.Hour}, gomonitor.OK, "CRL is fresh"},
+		{"Test URL From Certificate", CRLOptions{Certificate: good, Issuer: ca.cert}, gomonitor.OK, "CRL is fresh"},
+		{"Test Warning", CRLOptions{URL: srv.URL + "/soon.crl", Warn: 24 * time.Hour, Crit: time.Hour}, gomonitor.Warning, "CRL nextUpdate"},
+		{"Test Critical", CRLOptions{URL: srv.URL + "/soon.crl", Warn: 24 * time.Hour, Crit: 3 * time.Hour}, gomonitor.Critical, "CRL nextUpdate"},
+		{"Test Expired", CRLOptions{URL: srv.URL + "/expired.crl"}, gomonitor.Critical, "CRL expired"},
+		{"Test Revoked", CRLOptions{URL: srv.URL + "/fresh.crl", Certificate: revoked}, gomonitor.Critical, "certificate 5 revoked"},
+		{"Test Bad Signature", CRLOptions{URL: srv.URL + "/fresh.crl", Issuer: other.cert}, gomonitor.Critical, "CRL signature invalid"},
+		{"Test Garbage", CRLOptions{URL: srv.URL + "/garbage.crl"}, gomonitor.Unknown, "parsing CRL"},
+		{"Test Not Found", CRLOptions{URL: srv.URL + "/missing.crl"}, gomonitor.Unknown, "fetching CRL"},
+		{"Test No URL", CRLOptions{}, gomonitor.Unknown, "no CRL URL"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := CheckCRL(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.HasPrefix(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want prefix %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckCRLPerformanceData(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	der := ca.crl(t, time.Now().Add(time.Hour), 1, 2, 3)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(der)
+	}))
+	defer srv.Close()
+
+	result := CheckCRL(context.Background(), CRLOptions{URL: srv.URL})
+	if got := result.PerformanceData["entries"].Value; got != 3 {
+		t.Errorf("got entries %v, want 3", got)
+	}
+	if got := result.PerformanceData["next_update"].Value; got <= 3500 || got > 3600 {
+		t.Errorf("got next_update %v, want about 3600", got)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package sip checks a SIP proxy or registrar by sending it an OPTIONS request.
+package sip
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// Options configures a SIP OPTIONS check.
+// - `Address` is the host:port of the SIP server, usually on port 5060.
+// - `URI` is the Request-URI. It defaults to "sip:<host>".
+// - `Transport` is "udp" or "tcp". It defaults to "udp".
+// - `ExpectCodes` lists the acceptable final response codes. It defaults to 200.
+// - `Warn` and `Crit` are response times above which the check is Warning or Critical. 0 disables a threshold.
+// - `Timeout` bounds the whole exchange. 0 means 10 seconds.
+type Options struct {
+	Address     string
+	URI         string
+	Transport   string
+	ExpectCodes []int
+	Warn        time.Duration
+	Crit        time.Duration
+	Timeout     time.Duration
+}
+
+// Check sends an OPTIONS request and validates the final response code and response time.
+// An unexpected response code is Critical; no response at all is Critical as well, since an
+// unreachable registrar is the failure this check exists to catch.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Transport == "" {
+		opts.Transport = "udp"
+	}
+	if len(opts.ExpectCodes) == 0 {
+		opts.ExpectCodes = []int{200}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Transport != "udp" && opts.Transport != "tcp" {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("unsupported transport %q", opts.Transport))
+		return result
+	}
+	if opts.URI == "" {
+		host, _, err := net.SplitHostPort(opts.Address)
+		if err != nil {
+			result.SetResult(gomonitor.Unknown, err.Error())
+			return result
+		}
+		opts.URI = "sip:" + host
+	}
+
+	start := time.Now()
+	code, reason, err := options(ctx, opts)
+	elapsed := time.Since(start)
+	if err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("%s: %v", opts.Address, err))
+		return result
+	}
+
+	metric := gomonitor.PerformanceMetric{
+		Value:  elapsed.Seconds(),
+		Warn:   opts.Warn.Seconds(),
+		Crit:   opts.Crit.Seconds(),
+		UnitOM: "s",
+	}
+	state := metric.State()
+	if !slices.Contains(opts.ExpectCodes, code) {
+		state = gomonitor.Critical
+	}
+	result.SetResult(state, fmt.Sprintf("%d %s from %s in %.3fs", code, reason, opts.Address, elapsed.Seconds()))
+	result.AddPerformanceData("time", metric)
+	return result
+}
+
+// options sends the request and returns the status of the first final response.
+func options(ctx context.Context, opts Options) (int, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	conn, err := tracing.Dial(ctx, nil, opts.Transport, opts.Address)
+	if err != nil {
+		return 0, "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	callID := randomToken() + "@gomonitor"
+	local := conn.LocalAddr().String()
+	req := strings.Join([]string{
+		"OPTIONS " + opts.URI + " SIP/2.0",
+		fmt.Sprintf("Via: SIP/2.0/%s %s;branch=z9hG4bK%s;rport", strings.ToUpper(opts.Transport), local, randomToken()),
+		"Max-Forwards: 70",
+		"From: <sip:gomonitor@" + local + ">;tag=" + randomToken(),
+		"To: <" + opts.URI + ">",
+		"Call-ID: " + callID,
+		"CSeq: 1 OPTIONS",
+		"Contact: <sip:gomonitor@" + local + ">",
+		"Accept: application/sdp",
+		"User-Agent: gomonitor",
+		"Content-Length: 0",
+		"", "",
+	}, "\r\n")
+	if _, err := conn.Write([]byte(req)); err != nil {
+		return 0, "", err
+	}
+
+	// UDP delivers one message per read; TCP needs to be framed by Content-Length.
+	var reader *bufio.Reader
+	if opts.Transport == "tcp" {
+		reader = bufio.NewReader(conn)
+	}
+	buf := make([]byte, 65535)
+	for {
+		var msg *bufio.Reader
+		if reader != nil {
+			msg = reader
+		} else {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return 0, "", err
+			}
+			msg = bufio.NewReader(bytes.NewReader(buf[:n]))
+		}
+		code, reason, header, err := readResponse(msg)
+		if err != nil {
+			return 0, "", err
+		}
+		if header.Get("Call-ID") != callID {
+			continue
+		}
+		// Provisional responses are followed by a final one
+		if code >= 200 {
+			return code, reason, nil
+		}
+	}
+}
+
+// readResponse parses a SIP status line and headers, consuming any body.
+func readResponse(r *bufio.Reader) (int, string, textproto.MIMEHeader, error) {
+	tp := textproto.NewReader(r)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return 0, "", nil, err
+	}
+	proto, status, ok := strings.Cut(line, " ")
+	if !ok || proto != "SIP/2.0" {
+		return 0, "", nil, fmt.Errorf("invalid status line %q", line)
+	}
+	codeStr, reason, _ := strings.Cut(status, " ")
+	code, err := strconv.Atoi(codeStr)
+	if err != nil || code < 100 || code > 699 {
+		return 0, "", nil, fmt.Errorf("invalid status code %q", codeStr)
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return 0, "", nil, err
+	}
+	if header.Get("Call-ID") == "" {
+		// Compact form of Call-ID
+		header.Set("Call-ID", header.Get("i"))
+	}
+	length := header.Get("Content-Length")
+	if length == "" {
+		length = header.Get("l")
+	}
+	if n, err := strconv.Atoi(length); err == nil && n > 0 {
+		if _, err := r.Discard(n); err != nil {
+			return 0, "", nil, err
+		}
+	} else if err != nil && length != "" {
+		return 0, "", nil, errors.New("invalid Content-Length")
+	}
+	return code, reason, header, nil
+}
+
+// randomToken returns a random hex string for tags, branches and Call-IDs.
+func randomToken() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
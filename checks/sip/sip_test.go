@@ -0,0 +1,99 @@
+package sip
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeServer answers every OPTIONS request over UDP with a 100 Trying followed by the given
+// final status, after an optional delay.
+func fakeServer(t *testing.T, status string, delay time.Duration) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			method, header, err := readRequest(string(buf[:n]))
+			if err != nil || method != "OPTIONS" {
+				continue
+			}
+			time.Sleep(delay)
+			for _, s := range []string{"100 Trying", status} {
+				resp := "SIP/2.0 " + s + "\r\n" +
+					"Via: " + header.Get("Via") + "\r\n" +
+					"Call-ID: " + header.Get("Call-ID") + "\r\n" +
+					"CSeq: 1 OPTIONS\r\nContent-Length: 0\r\n\r\n"
+				conn.WriteTo([]byte(resp), addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// readRequest returns the method and headers of a SIP request.
+func readRequest(s string) (string, textproto.MIMEHeader, error) {
+	tp := textproto.NewReader(bufio.NewReader(strings.NewReader(s)))
+	line, err := tp.ReadLine()
+	if err != nil {
+		return "", nil, err
+	}
+	method, _, _ := strings.Cut(line, " ")
+	header, err := tp.ReadMIMEHeader()
+	return method, header, err
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		status    string
+		delay     time.Duration
+		opts      Options
+		wantState gomonitor.ExitCode
+	}{
+		{"Test OK", "200 OK", 0, Options{}, gomonitor.OK},
+		{"Test Unexpected Code", "404 Not Found", 0, Options{}, gomonitor.Critical},
+		{"Test Accepted Code", "404 Not Found", 0, Options{ExpectCodes: []int{200, 404}}, gomonitor.OK},
+		{"Test Slow", "200 OK", 50 * time.Millisecond, Options{Warn: 20 * time.Millisecond, Crit: time.Second}, gomonitor.Warning},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Address = fakeServer(t, tc.status, tc.delay)
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+		})
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	result := Check(context.Background(), Options{Address: conn.LocalAddr().String(), Timeout: 50 * time.Millisecond})
+	if result.ExitCode != gomonitor.Critical {
+		t.Errorf("got exitCode %s (%s), want Critical", result.ExitCode, result.Message)
+	}
+	if result := Check(context.Background(), Options{Address: "127.0.0.1:5060", Transport: "sctp"}); result.ExitCode != gomonitor.Unknown {
+		t.Errorf("got exitCode %s for unsupported transport, want Unknown", result.ExitCode)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package tacacs checks a TACACS+ server by performing a PAP test authentication.
+package tacacs
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// TACACS+ protocol constants used by the check (RFC 8907).
+const (
+	versionPAP    = 0xC1 // major version 0xC, minor version 1
+	typeAuthen    = 0x01
+	flagUnencrypt = 0x01
+
+	actionLogin   = 0x01
+	privLvlUser   = 0x01
+	authenTypePAP = 0x02
+	serviceLogin  = 0x01
+
+	statusPass  = 0x01
+	statusFail  = 0x02
+	statusError = 0x07
+)
+
+// Options configures a TACACS+ authentication check.
+// - `Address` is the host:port of the TACACS+ server, usually on port 49.
+// - `Key` is the shared secret. If empty, the exchange is sent unobfuscated.
+// - `Username` and `Password` are the test credentials.
+// - `Port` and `RemoteAddr` are reported to the server as the user's port and address. They default to "gomonitor" and empty.
+// - `Warn` and `Crit` are response times above which the check is Warning or Critical. 0 disables a threshold.
+// - `Timeout` bounds the whole exchange. 0 means 5 seconds.
+type Options struct {
+	Address    string
+	Key        string
+	Username   string
+	Password   string
+	Port       string
+	RemoteAddr string
+	Warn       time.Duration
+	Crit       time.Duration
+	Timeout    time.Duration
+}
+
+// Check performs a PAP authentication. A PASS reply is OK; FAIL, ERROR, anything else, or no
+// reply at all is Critical.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Port == "" {
+		opts.Port = "gomonitor"
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	for _, field := range []string{opts.Username, opts.Password, opts.Port, opts.RemoteAddr} {
+		if len(field) > 255 {
+			result.SetResult(gomonitor.Unknown, "credentials and port must be at most 255 bytes")
+			return result
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	status, serverMsg, err := authenticate(ctx, opts)
+	elapsed := time.Since(start)
+	if err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("%s: %v", opts.Address, err))
+		return result
+	}
+	metric := gomonitor.PerformanceMetric{
+		Value:  elapsed.Seconds(),
+		Warn:   opts.Warn.Seconds(),
+		Crit:   opts.Crit.Seconds(),
+		UnitOM: "s",
+	}
+	result.AddPerformanceData("time", metric)
+
+	message := fmt.Sprintf("%s for %s from %s in %.3fs", statusName(status), opts.Username, opts.Address, elapsed.Seconds())
+	if serverMsg != "" {
+		message += ": " + serverMsg
+	}
+	state := metric.State()
+	if status != statusPass {
+		state = gomonitor.Critical
+	}
+	result.SetResult(state, message)
+	return result
+}
+
+// authenticate sends an authentication START and returns the status and server message of
+// the REPLY.
+func authenticate(ctx context.Context, opts Options) (byte, string, error) {
+	conn, err := tracing.Dial(ctx, nil, "tcp", opts.Address)
+	if err != nil {
+		return 0, "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	body := []byte{
+		actionLogin, privLvlUser, authenTypePAP, serviceLogin,
+		byte(len(opts.Username)), byte(len(opts.Port)), byte(len(opts.RemoteAddr)), byte(len(opts.Password)),
+	}
+	body = append(body, opts.Username...)
+	body = append(body, opts.Port...)
+	body = append(body, opts.RemoteAddr...)
+	body = append(body, opts.Password...)
+
+	header := make([]byte, 12)
+	header[0], header[1], header[2] = versionPAP, typeAuthen, 1
+	if opts.Key == "" {
+		header[3] = flagUnencrypt
+	}
+	if _, err := rand.Read(header[4:8]); err != nil {
+		return 0, "", err
+	}
+	binary.BigEndian.PutUint32(header[8:], uint32(len(body)))
+	crypt(header, body, opts.Key)
+	if _, err := conn.Write(append(header, body...)); err != nil {
+		return 0, "", err
+	}
+
+	reply := make([]byte, 12)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return 0, "", err
+	}
+	if reply[1] != typeAuthen || reply[2] != 2 || string(reply[4:8]) != string(header[4:8]) {
+		return 0, "", errors.New("reply does not match request")
+	}
+	length := binary.BigEndian.Uint32(reply[8:])
+	if length < 6 || length > 65535 {
+		return 0, "", errors.New("invalid reply length")
+	}
+	replyBody := make([]byte, length)
+	if _, err := io.ReadFull(conn, replyBody); err != nil {
+		return 0, "", err
+	}
+	crypt(reply, replyBody, opts.Key)
+	msgLen := int(binary.BigEndian.Uint16(replyBody[2:]))
+	dataLen := int(binary.BigEndian.Uint16(replyBody[4:]))
+	if 6+msgLen+dataLen != len(replyBody) {
+		// A wrong key garbles the body, which shows up here
+		return 0, "", errors.New("malformed reply, check the shared key")
+	}
+	return replyBody[0], string(replyBody[6 : 6+msgLen]), nil
+}
+
+// crypt obfuscates or restores body in place using the MD5 pad from RFC 8907 section 4.5.
+// It does nothing for packets flagged as unencrypted.
+func crypt(header, body []byte, key string) {
+	if header[3]&flagUnencrypt != 0 {
+		return
+	}
+	seed := make([]byte, 0, 4+len(key)+2)
+	seed = append(seed, header[4:8]...)
+	seed = append(seed, key...)
+	seed = append(seed, header[0], header[2])
+	var prev []byte
+	for i := 0; i < len(body); i += md5.Size {
+		sum := md5.Sum(append(seed, prev...))
+		prev = sum[:]
+		for j := 0; j < md5.Size && i+j < len(body); j++ {
+			body[i+j] ^= sum[j]
+		}
+	}
+}
+
+// statusName returns the name of an authentication reply status.
+func statusName(status byte) string {
+	switch status {
+	case statusPass:
+		return "PASS"
+	case statusFail:
+		return "FAIL"
+	case statusError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("status %d", status)
+	}
+}
@@ -0,0 +1,92 @@
+package tacacs
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeServer passes user "bob" with password "hunter2" and fails everyone else.
+func fakeServer(t *testing.T, key string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			header := make([]byte, 12)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				conn.Close()
+				continue
+			}
+			body := make([]byte, binary.BigEndian.Uint32(header[8:]))
+			if _, err := io.ReadFull(conn, body); err != nil {
+				conn.Close()
+				continue
+			}
+			crypt(header, body, key)
+			// A client using the wrong key sends garbage lengths
+			var user, password string
+			userLen, portLen, remLen, dataLen := int(body[4]), int(body[5]), int(body[6]), int(body[7])
+			if 8+userLen+portLen+remLen+dataLen == len(body) {
+				user = string(body[8 : 8+userLen])
+				password = string(body[8+userLen+portLen+remLen:])
+			}
+
+			status, msg := byte(statusFail), "denied"
+			if user == "bob" && password == "hunter2" {
+				status, msg = statusPass, "welcome"
+			}
+			replyBody := []byte{status, 0}
+			replyBody = binary.BigEndian.AppendUint16(replyBody, uint16(len(msg)))
+			replyBody = binary.BigEndian.AppendUint16(replyBody, 0)
+			replyBody = append(replyBody, msg...)
+			reply := append([]byte{}, header...)
+			reply[2] = 2
+			binary.BigEndian.PutUint32(reply[8:], uint32(len(replyBody)))
+			crypt(reply, replyBody, key)
+			conn.Write(append(reply, replyBody...))
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		serverKey string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Pass", "tackey", Options{Key: "tackey", Username: "bob", Password: "hunter2"}, gomonitor.OK, "PASS for bob"},
+		{"Test Fail", "tackey", Options{Key: "tackey", Username: "bob", Password: "nope"}, gomonitor.Critical, "FAIL for bob"},
+		{"Test Unencrypted", "", Options{Username: "bob", Password: "hunter2"}, gomonitor.OK, ": welcome"},
+		{"Test Wrong Key", "tackey", Options{Key: "other-key", Username: "bob", Password: "hunter2"}, gomonitor.Critical, "check the shared key"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Address = fakeServer(t, tc.serverKey)
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
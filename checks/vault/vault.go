@@ -0,0 +1,202 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package vault checks the seal status, HA role and TTLs of a HashiCorp Vault server.
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// maxResponseSize bounds each response the check will read.
+const maxResponseSize = 1 << 20
+
+// healthStatuses are the status codes /v1/sys/health answers with. Each carries the same JSON
+// body, so the check reads the state from the body rather than the code.
+var healthStatuses = []int{200, 429, 472, 473, 501, 503}
+
+// Options configures a Vault check.
+// - `URL` is the address of the server, such as "https://vault.example.com:8200".
+// - `Role` is the HA role the server should have: "active", "standby" or "" for either. A performance standby counts as a standby.
+// - `Token` is a Vault token whose remaining TTL is checked. If empty, no token is checked.
+// - `WarnCertTTL` and `CritCertTTL` are the remaining lifetimes of the listener certificate below which the check is Warning or Critical. 0 disables a threshold.
+// - `WarnTokenTTL` and `CritTokenTTL` are the remaining TTLs of Token below which the check is Warning or Critical. 0 disables a threshold.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+// - `Timeout` bounds all requests. 0 means 10 seconds.
+type Options struct {
+	URL          string
+	Role         string
+	Token        string
+	WarnCertTTL  time.Duration
+	CritCertTTL  time.Duration
+	WarnTokenTTL time.Duration
+	CritTokenTTL time.Duration
+	Client       *http.Client
+	Timeout      time.Duration
+}
+
+// healthResponse is the /v1/sys/health response body.
+type healthResponse struct {
+	Initialized        bool   `json:"initialized"`
+	Sealed             bool   `json:"sealed"`
+	Standby            bool   `json:"standby"`
+	PerformanceStandby bool   `json:"performance_standby"`
+	Version            string `json:"version"`
+}
+
+// Check queries /v1/sys/health and, if Token is set, looks the token up. A sealed or
+// uninitialized server is Critical and a server in the wrong role is Warning. The listener
+// certificate and token TTLs are compared with their thresholds. Failing to reach the server
+// or to look up the token is Critical.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Role != "" && opts.Role != "active" && opts.Role != "standby" {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("invalid role %q", opts.Role))
+		return result
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	base := strings.TrimSuffix(opts.URL, "/")
+
+	var health healthResponse
+	start := time.Now()
+	resp, err := get(ctx, opts, base+"/v1/sys/health", "", &health)
+	elapsed := time.Since(start)
+	if err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("health request failed: %v", err))
+		return result
+	}
+	result.AddPerformanceData("time", gomonitor.PerformanceMetric{
+		Value:  elapsed.Seconds(),
+		UnitOM: "s",
+	})
+	switch {
+	case !health.Initialized:
+		result.SetResult(gomonitor.Critical, "Vault is not initialized")
+		return result
+	case health.Sealed:
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("Vault %s is sealed", health.Version))
+		return result
+	}
+
+	role := "active"
+	if health.Standby || health.PerformanceStandby {
+		role = "standby"
+	}
+	state := gomonitor.OK
+	var problems []string
+	if opts.Role != "" && role != opts.Role {
+		state = gomonitor.Warning
+		problems = append(problems, fmt.Sprintf("%s, want %s", role, opts.Role))
+	}
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		remaining := time.Until(resp.TLS.PeerCertificates[0].NotAfter)
+		metric := ttlMetric(remaining, opts.WarnCertTTL, opts.CritCertTTL)
+		result.AddPerformanceData("cert_ttl", metric)
+		if s := ttlState(metric); s != gomonitor.OK {
+			state = max(state, s)
+			problems = append(problems, fmt.Sprintf("certificate expires in %s", remaining.Round(time.Second)))
+		}
+	}
+
+	if opts.Token != "" {
+		var lookup struct {
+			Data struct {
+				TTL int64 `json:"ttl"`
+			} `json:"data"`
+		}
+		if _, err := get(ctx, opts, base+"/v1/auth/token/lookup-self", opts.Token, &lookup); err != nil {
+			result.SetResult(gomonitor.Critical, fmt.Sprintf("token lookup failed: %v", err))
+			return result
+		}
+		// Tokens without an expiry, such as root tokens, have a TTL of 0
+		if lookup.Data.TTL > 0 {
+			remaining := time.Duration(lookup.Data.TTL) * time.Second
+			metric := ttlMetric(remaining, opts.WarnTokenTTL, opts.CritTokenTTL)
+			result.AddPerformanceData("token_ttl", metric)
+			if s := ttlState(metric); s != gomonitor.OK {
+				state = max(state, s)
+				problems = append(problems, fmt.Sprintf("token expires in %s", remaining))
+			}
+		}
+	}
+
+	msg := fmt.Sprintf("Vault %s is unsealed and %s", health.Version, role)
+	if len(problems) > 0 {
+		msg += ": " + strings.Join(problems, ", ")
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// ttlMetric records a remaining lifetime, which violates its thresholds when it falls below them.
+func ttlMetric(remaining, warn, crit time.Duration) gomonitor.PerformanceMetric {
+	return gomonitor.PerformanceMetric{
+		Value:     remaining.Seconds(),
+		WarnRange: gomonitor.LowerBound(warn.Seconds()),
+		CritRange: gomonitor.LowerBound(crit.Seconds()),
+		UnitOM:    "s",
+	}
+}
+
+// ttlState returns the state for a lifetime metric. An expired lifetime is Critical.
+func ttlState(metric gomonitor.PerformanceMetric) gomonitor.ExitCode {
+	if metric.Value <= 0 {
+		return gomonitor.Critical
+	}
+	return metric.State()
+}
+
+// get requests url with token, if set, and decodes the JSON response into v.
+func get(ctx context.Context, opts Options, url, token string, v any) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	resp, err := tracing.Do(opts.Client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && (token != "" || !slices.Contains(healthStatuses, resp.StatusCode)) {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	return resp, json.Unmarshal(raw, v)
+}
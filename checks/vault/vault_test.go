@@ -0,0 +1,82 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeVault answers /v1/sys/health with health and status, and looks up token "s.valid" with
+// a TTL of one hour.
+func fakeVault(t *testing.T, status int, health string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sys/health":
+			w.WriteHeader(status)
+			w.Write([]byte(health))
+		case "/v1/auth/token/lookup-self":
+			if r.Header.Get("X-Vault-Token") != "s.valid" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			w.Write([]byte(`{"data":{"ttl":3600}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCheck(t *testing.T) {
+	const (
+		active  = `{"initialized":true,"sealed":false,"standby":false,"version":"1.15.2"}`
+		standby = `{"initialized":true,"sealed":false,"standby":true,"version":"1.15.2"}`
+		sealed  = `{"initialized":true,"sealed":true,"standby":true,"version":"1.15.2"}`
+	)
+	testCases := []struct {
+		name      string
+		status    int
+		health    string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test Active", 200, active, Options{Role: "active"}, gomonitor.OK, "Vault 1.15.2 is unsealed and active"},
+		{"Test Standby", 429, standby, Options{Role: "standby"}, gomonitor.OK, "Vault 1.15.2 is unsealed and standby"},
+		{"Test Unexpected Standby", 429, standby, Options{Role: "active"}, gomonitor.Warning, "standby, want active"},
+		{"Test Sealed", 503, sealed, Options{}, gomonitor.Critical, "Vault 1.15.2 is sealed"},
+		{"Test Uninitialized", 501, `{"initialized":false,"sealed":true}`, Options{}, gomonitor.Critical, "Vault is not initialized"},
+		{"Test Bad Status", 500, `{}`, Options{}, gomonitor.Critical, "unexpected status 500"},
+		{"Test Cert TTL Warning", 200, active, Options{WarnCertTTL: 100 * 365 * 24 * time.Hour}, gomonitor.Warning, "certificate expires in"},
+		{"Test Token OK", 200, active, Options{Token: "s.valid", WarnTokenTTL: time.Minute}, gomonitor.OK, ""},
+		{"Test Token TTL At Critical", 200, active, Options{Token: "s.valid", WarnTokenTTL: 2 * time.Hour, CritTokenTTL: time.Hour},
+			gomonitor.Warning, "token expires in 1h0m0s"},
+		{"Test Token TTL Critical", 200, active, Options{Token: "s.valid", WarnTokenTTL: 2 * time.Hour, CritTokenTTL: 90 * time.Minute},
+			gomonitor.Critical, "token expires in 1h0m0s"},
+		{"Test Token Denied", 200, active, Options{Token: "s.expired"}, gomonitor.Critical, "token lookup failed: unexpected status 403"},
+		{"Test Invalid Role", 200, active, Options{Role: "leader"}, gomonitor.Unknown, `invalid role "leader"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := fakeVault(t, tc.status, tc.health)
+			tc.opts.URL = srv.URL
+			tc.opts.Client = srv.Client()
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package vsphere checks the health of a VMware vCenter inventory through the vSphere Automation
+// REST API: datastore capacity, host connection state and VM power state, each reported as a
+// sub-result.
+package vsphere
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// maxResponseSize bounds each response the check will read.
+const maxResponseSize = 4 << 20
+
+// Options configures a vSphere check.
+// - `URL` is the address of vCenter, such as "https://vcenter.example.com".
+// - `Username` and `Password` are the credentials used to create the API session.
+// - `WarnDatastore` and `CritDatastore` are datastore usage percentages above which the check is Warning or Critical. 0 disables a threshold.
+// - `VMs` are the names of VMs that must be powered on. Other VMs are only counted.
+// - `Client` is the HTTP client to use. nil means http.DefaultClient.
+// - `Timeout` bounds all requests. 0 means 10 seconds.
+type Options struct {
+	URL           string
+	Username      string
+	Password      string
+	WarnDatastore float64
+	CritDatastore float64
+	VMs           []string
+	Client        *http.Client
+	Timeout       time.Duration
+}
+
+// session is an authenticated vSphere API session.
+type session struct {
+	ctx   context.Context
+	opts  Options
+	base  string
+	token string
+}
+
+// Check logs in to vCenter and runs the datastore, host and VM checks as a gomonitor.CheckGroup,
+// so the combined state is the worst of the three and each metric is prefixed with its check's
+// name. A datastore above a threshold, a host that is not connected or a listed VM that
+// is missing or not powered on fails its check. Failing to log in or to list an inventory is
+// Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	group := gomonitor.CheckGroup[*session]{
+		Setup:    func() (*session, error) { return login(ctx, opts) },
+		Teardown: (*session).logout,
+		Checks: []gomonitor.SubCheck[*session]{
+			{Name: "datastores", Run: checkDatastores},
+			{Name: "hosts", Run: checkHosts},
+			{Name: "vms", Run: checkVMs},
+		},
+	}
+	return group.Run()
+}
+
+// checkDatastores compares the usage of every datastore with the thresholds.
+func checkDatastores(s *session) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	var datastores []struct {
+		Name      string `json:"name"`
+		FreeSpace int64  `json:"free_space"`
+		Capacity  int64  `json:"capacity"`
+	}
+	if err := s.do(http.MethodGet, "/api/vcenter/datastore", &datastores); err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+
+	state := gomonitor.OK
+	var problems []string
+	for _, ds := range datastores {
+		// Inaccessible datastores report no capacity
+		if ds.Capacity <= 0 {
+			continue
+		}
+		used := float64(ds.Capacity-ds.FreeSpace) / float64(ds.Capacity) * 100
+		metric := gomonitor.PerformanceMetric{
+			Value:  used,
+			Warn:   s.opts.WarnDatastore,
+			Crit:   s.opts.CritDatastore,
+			UnitOM: "%",
+		}
+		result.AddPerformanceData(ds.Name, metric)
+		if ms := metric.State(); ms != gomonitor.OK {
+			state = max(state, ms)
+			problems = append(problems, fmt.Sprintf("%s %.1f%% used", ds.Name, used))
+		}
+	}
+
+	msg := fmt.Sprintf("%d datastore(s) within limits", len(datastores))
+	if len(problems) > 0 {
+		msg = strings.Join(problems, ", ")
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// checkHosts reports every host that is not connected to vCenter as Critical.
+func checkHosts(s *session) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	var hosts []struct {
+		Name            string `json:"name"`
+		ConnectionState string `json:"connection_state"`
+	}
+	if err := s.do(http.MethodGet, "/api/vcenter/host", &hosts); err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+
+	var problems []string
+	for _, host := range hosts {
+		if host.ConnectionState != "CONNECTED" {
+			problems = append(problems, fmt.Sprintf("%s %s", host.Name, strings.ToLower(host.ConnectionState)))
+		}
+	}
+	result.AddPerformanceData("connected", gomonitor.PerformanceMetric{
+		Value:   float64(len(hosts) - len(problems)),
+		Integer: true,
+	})
+	if len(problems) > 0 {
+		result.SetResult(gomonitor.Critical, strings.Join(problems, ", "))
+		return result
+	}
+	result.SetResult(gomonitor.OK, fmt.Sprintf("%d host(s) connected", len(hosts)))
+	return result
+}
+
+// checkVMs counts the powered on VMs and reports every VM in Options.VMs that is missing or not
+// powered on as Critical.
+func checkVMs(s *session) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	var vms []struct {
+		Name       string `json:"name"`
+		PowerState string `json:"power_state"`
+	}
+	if err := s.do(http.MethodGet, "/api/vcenter/vm", &vms); err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+
+	powerStates := make(map[string]string, len(vms))
+	poweredOn := 0
+	for _, vm := range vms {
+		powerStates[vm.Name] = vm.PowerState
+		if vm.PowerState == "POWERED_ON" {
+			poweredOn++
+		}
+	}
+	result.AddPerformanceData("powered_on", gomonitor.PerformanceMetric{
+		Value:   float64(poweredOn),
+		Integer: true,
+	})
+
+	var problems []string
+	for _, name := range s.opts.VMs {
+		switch state, ok := powerStates[name]; {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s not found", name))
+		case state != "POWERED_ON":
+			problems = append(problems, fmt.Sprintf("%s %s", name, strings.ToLower(state)))
+		}
+	}
+	if len(problems) > 0 {
+		result.SetResult(gomonitor.Critical, strings.Join(problems, ", "))
+		return result
+	}
+	result.SetResult(gomonitor.OK, fmt.Sprintf("%d of %d VM(s) powered on", poweredOn, len(vms)))
+	return result
+}
+
+// login creates an API session with the configured credentials.
+func login(ctx context.Context, opts Options) (*session, error) {
+	s := &session{ctx: ctx, opts: opts, base: strings.TrimSuffix(opts.URL, "/")}
+	var token string
+	if err := s.do(http.MethodPost, "/api/session", &token); err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+	s.token = token
+	return s, nil
+}
+
+// logout deletes the API session, so sessions don't pile up on vCenter between runs.
+func (s *session) logout() error {
+	return s.do(http.MethodDelete, "/api/session", nil)
+}
+
+// do sends a request for path, authenticated with the session token or, before login, the
+// credentials, and decodes the JSON response into v unless v is nil.
+func (s *session) do(method, path string, v any) error {
+	req, err := http.NewRequestWithContext(s.ctx, method, s.base+path, nil)
+	if err != nil {
+		return err
+	}
+	if s.token != "" {
+		req.Header.Set("vmware-api-session-id", s.token)
+	} else {
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+	}
+	resp, err := tracing.Do(s.opts.Client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if !slices.Contains([]int{http.StatusOK, http.StatusCreated, http.StatusNoContent}, resp.StatusCode) {
+		return fmt.Errorf("%s %s: unexpected status %s", method, path, resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,120 @@
+package vsphere
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeVCenter issues session "s1" to admin/secret and answers the inventory endpoints with the
+// given bodies. It counts the sessions still open.
+func fakeVCenter(t *testing.T, datastores, hosts, vms string, open *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/session" && r.Method == http.MethodPost {
+			if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			open.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`"s1"`))
+			return
+		}
+		if r.Header.Get("vmware-api-session-id") != "s1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/session":
+			open.Add(-1)
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/vcenter/datastore":
+			w.Write([]byte(datastores))
+		case "/api/vcenter/host":
+			w.Write([]byte(hosts))
+		case "/api/vcenter/vm":
+			w.Write([]byte(vms))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCheck(t *testing.T) {
+	const (
+		datastores = `[{"name":"ds1","free_space":600,"capacity":1000},{"name":"ds2","free_space":150,"capacity":1000}]`
+		full       = `[{"name":"ds1","free_space":20,"capacity":1000}]`
+		hosts      = `[{"name":"esx1","connection_state":"CONNECTED"},{"name":"esx2","connection_state":"CONNECTED"}]`
+		down       = `[{"name":"esx1","connection_state":"CONNECTED"},{"name":"esx2","connection_state":"NOT_RESPONDING"}]`
+		vms        = `[{"name":"web1","power_state":"POWERED_ON"},{"name":"db1","power_state":"POWERED_OFF"}]`
+	)
+	testCases := []struct {
+		name       string
+		datastores string
+		hosts      string
+		vms        string
+		opts       Options
+		wantState  gomonitor.ExitCode
+		wantMsg    string
+	}{
+		{"Test OK", datastores, hosts, vms, Options{WarnDatastore: 90, CritDatastore: 95, VMs: []string{"web1"}}, gomonitor.OK,
+			"datastores: 2 datastore(s) within limits, hosts: 2 host(s) connected, vms: 1 of 2 VM(s) powered on"},
+		{"Test Datastore Warning", datastores, hosts, vms, Options{WarnDatastore: 80, CritDatastore: 95}, gomonitor.Warning,
+			"datastores: ds2 85.0% used,"},
+		{"Test Datastore Critical", full, hosts, vms, Options{WarnDatastore: 80, CritDatastore: 95}, gomonitor.Critical,
+			"datastores: ds1 98.0% used,"},
+		{"Test Host Not Responding", datastores, down, vms, Options{}, gomonitor.Critical, "hosts: esx2 not_responding,"},
+		{"Test VM Powered Off", datastores, hosts, vms, Options{VMs: []string{"web1", "db1"}}, gomonitor.Critical, "vms: db1 powered_off"},
+		{"Test VM Missing", datastores, hosts, vms, Options{VMs: []string{"mail1"}}, gomonitor.Critical, "vms: mail1 not found"},
+		{"Test Bad Inventory", datastores, hosts, `{`, Options{}, gomonitor.Unknown, "vms: unexpected end of JSON input"},
+		{"Test Bad Login", datastores, hosts, vms, Options{Password: "wrong"}, gomonitor.Unknown,
+			"setup failed: login failed: POST /api/session: unexpected status 401 Unauthorized"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var open atomic.Int32
+			srv := fakeVCenter(t, tc.datastores, tc.hosts, tc.vms, &open)
+			tc.opts.URL = srv.URL
+			tc.opts.Username = "admin"
+			if tc.opts.Password == "" {
+				tc.opts.Password = "secret"
+			}
+			tc.opts.Client = srv.Client()
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+			if n := open.Load(); n != 0 {
+				t.Errorf("got %d session(s) left open, want 0", n)
+			}
+		})
+	}
+}
+
+func TestCheckPerfdata(t *testing.T) {
+	var open atomic.Int32
+	srv := fakeVCenter(t, `[{"name":"ds1","free_space":250,"capacity":1000},{"name":"offline","free_space":0,"capacity":0}]`,
+		`[{"name":"esx1","connection_state":"CONNECTED"}]`, `[{"name":"web1","power_state":"POWERED_ON"}]`, &open)
+	result := Check(context.Background(), Options{URL: srv.URL, Username: "admin", Password: "secret", WarnDatastore: 80, Client: srv.Client()})
+	got := result.FormatResult()
+	for _, want := range []string{"'datastores_ds1'=75.00%;80.00", "'hosts_connected'=1", "'vms_powered_on'=1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got output %q, want it to contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "offline") {
+		t.Errorf("got output %q, want no metric for an inaccessible datastore", got)
+	}
+}
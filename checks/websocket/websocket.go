@@ -0,0 +1,282 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package websocket checks a WebSocket endpoint by completing the opening handshake and,
+// optionally, exchanging a message.
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// acceptGUID is the fixed GUID from RFC 6455 used to derive Sec-WebSocket-Accept.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize bounds the response message the check will read.
+const maxMessageSize = 1 << 20
+
+// WebSocket opcodes used by the check.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Options configures a WebSocket check.
+// - `URL` is the ws:// or wss:// endpoint.
+// - `Send` is a text message to send once connected. If empty, only the handshake is checked.
+// - `Expect` must be contained in the response to Send. If empty, any response is accepted.
+// - `Warn` and `Crit` are the round trip (or handshake, without Send) times above which the check is Warning or Critical. 0 disables a threshold.
+// - `TLSConfig` is used for wss:// connections.
+// - `Timeout` bounds the whole check. 0 means 10 seconds.
+type Options struct {
+	URL       string
+	Send      string
+	Expect    string
+	Warn      time.Duration
+	Crit      time.Duration
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+}
+
+// Check connects to the endpoint and, if configured, sends a message and validates the
+// response. Failed handshakes, timeouts and unexpected responses are Critical.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := dial(ctx, opts)
+	connect := time.Since(start)
+	if err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("handshake with %s failed: %v", opts.URL, err))
+		return result
+	}
+	defer conn.Close()
+	// The upgraded connection has no deadlines, so enforce the timeout by closing it
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	result.AddPerformanceData("connect", gomonitor.PerformanceMetric{
+		Value:  connect.Seconds(),
+		UnitOM: "s",
+	})
+
+	message := fmt.Sprintf("connected to %s in %.3fs", opts.URL, connect.Seconds())
+	if opts.Send != "" {
+		start = time.Now()
+		if err := writeFrame(conn, opText, []byte(opts.Send)); err != nil {
+			result.SetResult(gomonitor.Critical, fmt.Sprintf("sending to %s failed: %v", opts.URL, err))
+			return result
+		}
+		resp, err := readMessage(conn)
+		measured := time.Since(start)
+		if err != nil {
+			result.SetResult(gomonitor.Critical, fmt.Sprintf("no response from %s: %v", opts.URL, err))
+			return result
+		}
+		if !strings.Contains(string(resp), opts.Expect) {
+			result.SetResult(gomonitor.Critical, fmt.Sprintf("response from %s does not contain %q", opts.URL, opts.Expect))
+			return result
+		}
+		result.AddPerformanceData("rtt", gomonitor.PerformanceMetric{
+			Value:  measured.Seconds(),
+			UnitOM: "s",
+		})
+		message += fmt.Sprintf(", round trip %.3fs", measured.Seconds())
+	}
+
+	// Thresholds belong to whichever metric they were applied to
+	name := result.PerfOrder[len(result.PerfOrder)-1]
+	metric := result.PerformanceData[name]
+	metric.Warn, metric.Crit = opts.Warn.Seconds(), opts.Crit.Seconds()
+	result.UpdatePerformanceData(name, metric)
+	_ = writeFrame(conn, opClose, []byte{0x03, 0xE8})
+	result.SetResult(metric.State(), message)
+	return result
+}
+
+// dial performs the opening handshake and returns the upgraded connection.
+func dial(ctx context.Context, opts Options) (io.ReadWriteCloser, error) {
+	url := opts.URL
+	switch {
+	case strings.HasPrefix(url, "ws://"):
+		url = "http://" + strings.TrimPrefix(url, "ws://")
+	case strings.HasPrefix(url, "wss://"):
+		url = "https://" + strings.TrimPrefix(url, "wss://")
+	default:
+		return nil, errors.New("URL must start with ws:// or wss://")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	keyBytes := make([]byte, 16)
+	_, _ = rand.Read(keyBytes)
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: opts.TLSConfig}}
+	resp, err := tracing.Do(client, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, errors.New("connection cannot be upgraded")
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, errors.New("invalid Sec-WebSocket-Accept")
+	}
+	return conn, nil
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value for a Sec-WebSocket-Key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// writeFrame writes a single masked frame, as clients are required to.
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	mask := make([]byte, 4)
+	_, _ = rand.Read(mask)
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// readMessage reads the next text or binary message, reassembling fragments and answering
+// pings along the way.
+func readMessage(rw io.ReadWriter) ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := readFrame(rw)
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case opPing:
+			if err := writeFrame(rw, opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return nil, errors.New("connection closed by server")
+		case opText, opBinary, opContinuation:
+		default:
+			return nil, fmt.Errorf("unexpected opcode %d", opcode)
+		}
+		if len(message)+len(payload) > maxMessageSize {
+			return nil, errors.New("message too large")
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// readFrame reads a single frame.
+func readFrame(r io.Reader) (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode := header[0]&0x80 != 0, header[0]&0x0F
+	masked, length := header[1]&0x80 != 0, uint64(header[1]&0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, errors.New("frame too large")
+	}
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(r, mask); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		if masked {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
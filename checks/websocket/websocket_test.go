@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// echoServer upgrades every request and echoes text messages back, prefixed with "echo: ".
+// With pingFirst set it sends a ping before each reply and splits the reply in two fragments.
+func echoServer(t *testing.T, pingFirst bool, delay time.Duration) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "not a websocket request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Sec-WebSocket-Accept", acceptKey(r.Header.Get("Sec-WebSocket-Key")))
+		w.WriteHeader(http.StatusSwitchingProtocols)
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, opcode, payload, err := readFrame(buf)
+			if err != nil || opcode == opClose {
+				return
+			}
+			if opcode == opPong {
+				continue
+			}
+			time.Sleep(delay)
+			reply := "echo: " + string(payload)
+			if pingFirst {
+				conn.Write([]byte{0x80 | opPing, 0})
+				conn.Write(append([]byte{opText, 3}, reply[:3]...))
+				conn.Write(append([]byte{0x80 | opContinuation, byte(len(reply) - 3)}, reply[3:]...))
+				continue
+			}
+			conn.Write(append([]byte{0x80 | opText, byte(len(reply))}, reply...))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		pingFirst bool
+		delay     time.Duration
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantPerf  []string
+	}{
+		{"Test Handshake Only", false, 0, Options{}, gomonitor.OK, []string{"connect"}},
+		{"Test Echo", false, 0, Options{Send: "hello", Expect: "echo: hello"}, gomonitor.OK, []string{"connect", "rtt"}},
+		{"Test Fragmented With Ping", true, 0, Options{Send: "hello", Expect: "echo: hello"}, gomonitor.OK, []string{"connect", "rtt"}},
+		{"Test Unexpected Response", false, 0, Options{Send: "hello", Expect: "pong"}, gomonitor.Critical, []string{"connect"}},
+		{"Test Slow", false, 50 * time.Millisecond, Options{Send: "x", Warn: 20 * time.Millisecond}, gomonitor.Warning, []string{"connect", "rtt"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := echoServer(t, tc.pingFirst, tc.delay)
+			tc.opts.URL = "ws://" + strings.TrimPrefix(srv.URL, "http://")
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if strings.Join(result.PerfOrder, ",") != strings.Join(tc.wantPerf, ",") {
+				t.Errorf("got perfdata %v, want %v", result.PerfOrder, tc.wantPerf)
+			}
+		})
+	}
+}
+
+func TestCheckHandshakeFailure(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	testCases := []struct {
+		name string
+		url  string
+	}{
+		{"Test Not Found", "ws://" + strings.TrimPrefix(srv.URL, "http://")},
+		{"Test Bad Scheme", srv.URL},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), Options{URL: tc.url})
+			if result.ExitCode != gomonitor.Critical {
+				t.Errorf("got exitCode %s (%s), want Critical", result.ExitCode, result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package winperf checks Windows performance counters, read with PowerShell's Get-Counter
+// either locally or over WinRM, so Windows hosts can be monitored without an agent.
+package winperf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/winrm"
+)
+
+// Options configures a performance counter check.
+// - `Counters` are the counter paths to read, such as `\Processor(_Total)\% Processor Time`. Wildcard instances are expanded into one sample each.
+// - `Warn` and `Crit` are values above which a sample is Warning or Critical. 0 disables a threshold.
+// - `WinRM` reads the counters on a remote host. nil runs PowerShell locally.
+// - `Command` is the local PowerShell program with any leading arguments. It defaults to "powershell -NoProfile -NonInteractive".
+// - `Timeout` bounds the collection. 0 means 30 seconds.
+type Options struct {
+	Counters []string
+	Warn     float64
+	Crit     float64
+	WinRM    *winrm.Client
+	Command  []string
+	Timeout  time.Duration
+}
+
+// sample is a single counter value.
+type sample struct {
+	path  string
+	value float64
+}
+
+// Check reads the counters and compares every sample with the thresholds. Each sample is
+// recorded as perfdata under its path without the computer name. Failing to read the counters
+// is Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if len(opts.Command) == 0 {
+		opts.Command = []string{"powershell", "-NoProfile", "-NonInteractive"}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if len(opts.Counters) == 0 {
+		result.SetResult(gomonitor.Unknown, "no counters given")
+		return result
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	out, err := collect(ctx, opts)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	samples, err := parseSamples(out)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	if len(samples) == 0 {
+		result.SetResult(gomonitor.Unknown, "Get-Counter returned no samples")
+		return result
+	}
+
+	state := gomonitor.OK
+	var problems []string
+	for _, s := range samples {
+		metric := gomonitor.PerformanceMetric{
+			Value: s.value,
+			Warn:  opts.Warn,
+			Crit:  opts.Crit,
+		}
+		if sampleState := metric.State(); sampleState != gomonitor.OK {
+			state = max(state, sampleState)
+			problems = append(problems, fmt.Sprintf("%s is %s", s.path, strconv.FormatFloat(s.value, 'f', -1, 64)))
+		}
+		result.AddPerformanceData(s.path, metric)
+	}
+	msg := fmt.Sprintf("%d counter sample(s) within thresholds", len(samples))
+	if len(problems) > 0 {
+		msg = fmt.Sprintf("%d of %d counter sample(s) over threshold: %s", len(problems), len(samples), strings.Join(problems, ", "))
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// script returns the PowerShell script that prints each sample of counters as a tab-separated
+// path and value, with the value formatted independently of the host's culture.
+func script(counters []string) string {
+	quoted := make([]string, len(counters))
+	for i, c := range counters {
+		quoted[i] = "'" + strings.ReplaceAll(c, "'", "''") + "'"
+	}
+	return "$ErrorActionPreference = 'Stop'\n" +
+		"(Get-Counter -Counter @(" + strings.Join(quoted, ", ") + ")).CounterSamples | ForEach-Object {\n" +
+		"  $_.Path + \"`t\" + $_.CookedValue.ToString([Globalization.CultureInfo]::InvariantCulture)\n" +
+		"}\n"
+}
+
+// collect runs the Get-Counter script locally or over WinRM and returns its output.
+func collect(ctx context.Context, opts Options) ([]byte, error) {
+	if opts.WinRM != nil {
+		out, err := opts.WinRM.RunPowerShell(ctx, script(opts.Counters))
+		if err != nil {
+			return nil, err
+		}
+		if out.ExitCode != 0 {
+			return nil, fmt.Errorf("Get-Counter failed with exit code %d: %s", out.ExitCode, strings.TrimSpace(string(out.Stderr)))
+		}
+		return out.Stdout, nil
+	}
+
+	var stderr bytes.Buffer
+	args := append(slices.Clone(opts.Command[1:]), "-EncodedCommand", winrm.EncodePowerShell(script(opts.Counters)))
+	cmd := exec.CommandContext(ctx, opts.Command[0], args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running %s: %v: %s", opts.Command[0], err, msg)
+		}
+		return nil, fmt.Errorf("running %s: %v", opts.Command[0], err)
+	}
+	return out, nil
+}
+
+// parseSamples parses the script output. Paths start with the computer name, as in
+// `\\web01\processor(_total)\% processor time`, which is removed.
+func parseSamples(out []byte) ([]sample, error) {
+	var samples []sample
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		path, value, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("unexpected Get-Counter output %q", line)
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q", path, value)
+		}
+		if rest, ok := strings.CutPrefix(path, `\\`); ok {
+			if i := strings.IndexByte(rest, '\\'); i != -1 {
+				path = rest[i:]
+			}
+		}
+		samples = append(samples, sample{path: path, value: v})
+	}
+	return samples, scanner.Err()
+}
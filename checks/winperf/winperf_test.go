@@ -0,0 +1,166 @@
+package winperf
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/winrm"
+)
+
+const testOutput = "\\\\web01\\processor(_total)\\% processor time\t85.5\r\n" +
+	"\\\\web01\\logicaldisk(c:)\\% free space\t12.25\r\n"
+
+// TestHelperProcess stands in for PowerShell when run by the tests below.
+func TestHelperProcess(t *testing.T) {
+	output, ok := os.LookupEnv("WINPERF_HELPER_OUTPUT")
+	if !ok {
+		return
+	}
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	if got, want := strings.Join(args, " "), os.Getenv("WINPERF_HELPER_ARGS"); got != want {
+		fmt.Fprintf(os.Stderr, "got args %q, want %q", got, want)
+		os.Exit(2)
+	}
+	fmt.Print(output)
+	os.Exit(0)
+}
+
+// helperCommand returns a Command that expects args and prints output.
+func helperCommand(t *testing.T, args, output string) []string {
+	t.Setenv("WINPERF_HELPER_ARGS", args)
+	t.Setenv("WINPERF_HELPER_OUTPUT", output)
+	return []string{os.Args[0], "-test.run=^TestHelperProcess$", "--"}
+}
+
+func TestCheck(t *testing.T) {
+	counters := []string{`\Processor(_Total)\% Processor Time`, `\LogicalDisk(C:)\% Free Space`}
+	args := "-EncodedCommand " + winrm.EncodePowerShell(script(counters))
+
+	testCases := []struct {
+		name      string
+		warn      float64
+		crit      float64
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", 90, 95, gomonitor.OK, "2 counter sample(s) within thresholds"},
+		{"Test warning", 80, 90, gomonitor.Warning, `1 of 2 counter sample(s) over threshold: \processor(_total)\% processor time is 85.5`},
+		{"Test critical", 10, 80, gomonitor.Critical, "2 of 2 counter sample(s) over threshold"},
+		{"Test thresholds disabled", 0, 0, gomonitor.OK, "2 counter sample(s) within thresholds"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), Options{
+				Counters: counters,
+				Warn:     tc.warn,
+				Crit:     tc.crit,
+				Command:  helperCommand(t, args, testOutput),
+			})
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPerformanceData(t *testing.T) {
+	counters := []string{`\Processor(_Total)\% Processor Time`, `\LogicalDisk(C:)\% Free Space`}
+	result := Check(context.Background(), Options{
+		Counters: counters,
+		Warn:     90,
+		Command:  helperCommand(t, "-EncodedCommand "+winrm.EncodePowerShell(script(counters)), testOutput),
+	})
+	want := `'\processor(_total)\% processor time'=85.50;90.00;0.00;0.00;0.00 '\logicaldisk(c:)\% free space'=12.25;90.00;0.00;0.00;0.00 `
+	if got := result.FormatResult(); !strings.HasSuffix(got, want) {
+		t.Errorf("got %q, want perfdata %q", got, want)
+	}
+}
+
+func TestCheckWinRM(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body := string(raw)
+		var resp string
+		switch {
+		case strings.Contains(body, "transfer/Create"):
+			resp = `<rsp:Shell><rsp:ShellId>S1</rsp:ShellId></rsp:Shell>`
+		case strings.Contains(body, "shell/Command<"):
+			if !strings.Contains(body, "<rsp:Command>powershell</rsp:Command>") {
+				t.Errorf("command request %s does not run powershell", body)
+			}
+			resp = `<rsp:CommandResponse><rsp:CommandId>C1</rsp:CommandId></rsp:CommandResponse>`
+		case strings.Contains(body, "shell/Receive"):
+			resp = `<rsp:ReceiveResponse><rsp:Stream Name="stdout" CommandId="C1">` +
+				base64.StdEncoding.EncodeToString([]byte(testOutput)) + `</rsp:Stream>` +
+				`<rsp:CommandState CommandId="C1" State="http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done">` +
+				`<rsp:ExitCode>0</rsp:ExitCode></rsp:CommandState></rsp:ReceiveResponse>`
+		}
+		fmt.Fprintf(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell"><s:Body>%s</s:Body></s:Envelope>`, resp)
+	}))
+	defer srv.Close()
+
+	result := Check(context.Background(), Options{
+		Counters: []string{`\Processor(_Total)\% Processor Time`, `\LogicalDisk(C:)\% Free Space`},
+		Crit:     80,
+		WinRM:    &winrm.Client{Endpoint: srv.URL + "/wsman", Username: "monitor", Password: "secret"},
+	})
+	if result.ExitCode != gomonitor.Critical {
+		t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, gomonitor.Critical)
+	}
+}
+
+func TestScript(t *testing.T) {
+	got := script([]string{`\Process(it's)\Working Set`, `\Memory\Available MBytes`})
+	want := `(Get-Counter -Counter @('\Process(it''s)\Working Set', '\Memory\Available MBytes'))`
+	if !strings.Contains(got, want) {
+		t.Errorf("got script %q, want it to contain %q", got, want)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	counters := []string{`\Memory\Available MBytes`}
+	args := "-EncodedCommand " + winrm.EncodePowerShell(script(counters))
+
+	testCases := []struct {
+		name string
+		opts func(t *testing.T) Options
+	}{
+		{"Test no counters", func(t *testing.T) Options {
+			return Options{}
+		}},
+		{"Test command fails", func(t *testing.T) Options {
+			return Options{Counters: counters, Command: helperCommand(t, "unexpected", "")}
+		}},
+		{"Test no samples", func(t *testing.T) Options {
+			return Options{Counters: counters, Command: helperCommand(t, args, "")}
+		}},
+		{"Test malformed output", func(t *testing.T) Options {
+			return Options{Counters: counters, Command: helperCommand(t, args, "Get-Counter : The specified object was not found\n")}
+		}},
+		{"Test invalid value", func(t *testing.T) Options {
+			return Options{Counters: counters, Command: helperCommand(t, args, "\\\\web01\\memory\\available mbytes\tlots\n")}
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Check(context.Background(), tc.opts(t))
+			if result.ExitCode != gomonitor.Unknown {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, gomonitor.Unknown)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package wmi checks the result of a WMI/CIM query, read with PowerShell's Get-CimInstance
+// either locally or over WinRM. It thresholds numeric properties of the returned instances or
+// asserts how many instances there are.
+package wmi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/winrm"
+)
+
+// Options configures a WMI query check.
+// - `Query` is the WQL query, such as "SELECT * FROM Win32_LogicalDisk WHERE DriveType = 3".
+// - `Namespace` is the CIM namespace to query. "" means "root/cimv2".
+// - `Properties` are the numeric properties of each instance to record and compare with `Warn` and `Crit`.
+// - `Label` is the property that names each instance in the output, such as "DeviceID". "" numbers the instances.
+// - `Warn` and `Crit` are property values above which the check is Warning or Critical. 0 disables a threshold.
+// - `WarnRows` and `CritRows` are ranges the number of instances must lie within, such as "1:" to require at least one. nil disables a threshold.
+// - `WinRM` runs the query on a remote host. nil runs PowerShell locally.
+// - `Command` is the local PowerShell program with any leading arguments. It defaults to "powershell -NoProfile -NonInteractive".
+// - `Timeout` bounds the query. 0 means 30 seconds.
+type Options struct {
+	Query      string
+	Namespace  string
+	Properties []string
+	Label      string
+	Warn       float64
+	Crit       float64
+	WarnRows   *gomonitor.Range
+	CritRows   *gomonitor.Range
+	WinRM      *winrm.Client
+	Command    []string
+	Timeout    time.Duration
+}
+
+// Check runs the query, compares the instance count with WarnRows and CritRows and every
+// selected property with Warn and Crit. Each property is recorded as perfdata, named after the
+// instance's Label when there is more than one instance. Failing to run the query, or a
+// selected property that is missing or not a number, is Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Namespace == "" {
+		opts.Namespace = "root/cimv2"
+	}
+	if len(opts.Command) == 0 {
+		opts.Command = []string{"powershell", "-NoProfile", "-NonInteractive"}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.Query == "" {
+		result.SetResult(gomonitor.Unknown, "no query given")
+		return result
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	out, err := collect(ctx, opts)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, err.Error())
+		return result
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(out), &rows); err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("parsing query result: %v", err))
+		return result
+	}
+
+	instances := gomonitor.PerformanceMetric{
+		Value:     float64(len(rows)),
+		WarnRange: opts.WarnRows,
+		CritRange: opts.CritRows,
+		Integer:   true,
+	}
+	result.AddPerformanceData("instances", instances)
+	state := instances.State()
+	var problems []string
+	switch state {
+	case gomonitor.Critical:
+		problems = append(problems, fmt.Sprintf("%d instance(s) outside %s", len(rows), opts.CritRows))
+	case gomonitor.Warning:
+		problems = append(problems, fmt.Sprintf("%d instance(s) outside %s", len(rows), opts.WarnRows))
+	}
+
+	for i, row := range rows {
+		name := strconv.Itoa(i)
+		if opts.Label != "" {
+			name = fmt.Sprint(row[opts.Label])
+		}
+		for _, prop := range opts.Properties {
+			v, err := number(row[prop])
+			if err != nil {
+				result.SetResult(gomonitor.Unknown, fmt.Sprintf("instance %s property %s: %v", name, prop, err))
+				return result
+			}
+			label := prop
+			if len(rows) > 1 {
+				label = name + " " + prop
+			}
+			metric := gomonitor.PerformanceMetric{Value: v, Warn: opts.Warn, Crit: opts.Crit}
+			if s := metric.State(); s != gomonitor.OK {
+				state = max(state, s)
+				problems = append(problems, fmt.Sprintf("%s is %s", label, strconv.FormatFloat(v, 'f', -1, 64)))
+			}
+			result.AddPerformanceData(label, metric)
+		}
+	}
+
+	msg := fmt.Sprintf("%d instance(s) returned", len(rows))
+	if len(problems) > 0 {
+		msg += ": " + strings.Join(problems, ", ")
+	}
+	result.SetResult(state, msg)
+	return result
+}
+
+// number converts a property value to a float64. CIM 64-bit integers may arrive as strings.
+func number(v any) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("not a number: %q", v)
+		}
+		return f, nil
+	case nil:
+		return 0, fmt.Errorf("missing")
+	default:
+		return 0, fmt.Errorf("not a number: %v", v)
+	}
+}
+
+// quote returns s as a PowerShell single-quoted string.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// script returns the PowerShell script that prints the query result as a JSON array.
+func script(opts Options) string {
+	return "$ErrorActionPreference = 'Stop'\n" +
+		"$rows = @(Get-CimInstance -Namespace " + quote(opts.Namespace) + " -Query " + quote(opts.Query) + ")\n" +
+		"ConvertTo-Json -InputObject @($rows | Select-Object -Property * -ExcludeProperty Cim*) -Compress -Depth 2\n"
+}
+
+// collect runs the query script locally or over WinRM and returns its output.
+func collect(ctx context.Context, opts Options) ([]byte, error) {
+	if opts.WinRM != nil {
+		out, err := opts.WinRM.RunPowerShell(ctx, script(opts))
+		if err != nil {
+			return nil, err
+		}
+		if out.ExitCode != 0 {
+			return nil, fmt.Errorf("query failed with exit code %d: %s", out.ExitCode, strings.TrimSpace(string(out.Stderr)))
+		}
+		return out.Stdout, nil
+	}
+
+	var stderr bytes.Buffer
+	args := append(slices.Clone(opts.Command[1:]), "-EncodedCommand", winrm.EncodePowerShell(script(opts)))
+	cmd := exec.CommandContext(ctx, opts.Command[0], args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running %s: %v: %s", opts.Command[0], err, msg)
+		}
+		return nil, fmt.Errorf("running %s: %v", opts.Command[0], err)
+	}
+	return out, nil
+}
@@ -0,0 +1,141 @@
+package wmi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/winrm"
+)
+
+const testRows = `[{"DeviceID":"C:","PercentUsed":91,"Size":"107374182400"},{"DeviceID":"D:","PercentUsed":40,"Size":"53687091200"}]`
+
+// TestHelperProcess stands in for PowerShell when run by the tests below.
+func TestHelperProcess(t *testing.T) {
+	output, ok := os.LookupEnv("WMI_HELPER_OUTPUT")
+	if !ok {
+		return
+	}
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	if got, want := strings.Join(args, " "), os.Getenv("WMI_HELPER_ARGS"); got != want {
+		fmt.Fprintf(os.Stderr, "got args %q, want %q", got, want)
+		os.Exit(2)
+	}
+	fmt.Print(output)
+	os.Exit(0)
+}
+
+// helperCommand returns a Command that expects the script for opts and prints output.
+func helperCommand(t *testing.T, opts Options, output string) []string {
+	if opts.Namespace == "" {
+		opts.Namespace = "root/cimv2"
+	}
+	t.Setenv("WMI_HELPER_ARGS", "-EncodedCommand "+winrm.EncodePowerShell(script(opts)))
+	t.Setenv("WMI_HELPER_OUTPUT", output)
+	return []string{os.Args[0], "-test.run=^TestHelperProcess$", "--"}
+}
+
+// mustRange parses a range for a test case.
+func mustRange(t *testing.T, s string) *gomonitor.Range {
+	r, err := gomonitor.ParseThreshold(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		output    string
+		opts      func(t *testing.T) Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", testRows, func(t *testing.T) Options {
+			return Options{Properties: []string{"PercentUsed"}, Label: "DeviceID", Warn: 95, Crit: 98}
+		}, gomonitor.OK, "2 instance(s) returned"},
+		{"Test property warning", testRows, func(t *testing.T) Options {
+			return Options{Properties: []string{"PercentUsed"}, Label: "DeviceID", Warn: 90, Crit: 95}
+		}, gomonitor.Warning, "2 instance(s) returned: C: PercentUsed is 91"},
+		{"Test string property critical", testRows, func(t *testing.T) Options {
+			return Options{Properties: []string{"Size"}, Label: "DeviceID", Crit: 99 << 30}
+		}, gomonitor.Critical, "C: Size is 107374182400"},
+		{"Test required instance missing", "[]", func(t *testing.T) Options {
+			return Options{CritRows: mustRange(t, "1:")}
+		}, gomonitor.Critical, "0 instance(s) outside 1:"},
+		{"Test unexpected instances", testRows, func(t *testing.T) Options {
+			return Options{WarnRows: mustRange(t, "0"), CritRows: mustRange(t, "5")}
+		}, gomonitor.Warning, "2 instance(s) outside 0"},
+		{"Test single instance", `[{"PercentUsed":12}]`, func(t *testing.T) Options {
+			return Options{Properties: []string{"PercentUsed"}, Warn: 10}
+		}, gomonitor.Warning, "PercentUsed is 12"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts(t)
+			opts.Query = "SELECT * FROM Win32_LogicalDisk WHERE DriveType = 3"
+			opts.Command = helperCommand(t, opts, tc.output)
+			result := Check(context.Background(), opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPerformanceData(t *testing.T) {
+	opts := Options{
+		Query:      "SELECT * FROM Win32_LogicalDisk",
+		Properties: []string{"PercentUsed"},
+		Label:      "DeviceID",
+		Warn:       95,
+		CritRows:   &gomonitor.Range{Start: 1, End: 10},
+	}
+	opts.Command = helperCommand(t, opts, testRows)
+	result := Check(context.Background(), opts)
+	want := "'instances'=2;0;1:10;0;0 'C: PercentUsed'=91.00;95.00;0.00;0.00;0.00 'D: PercentUsed'=40.00;95.00;0.00;0.00;0.00 "
+	if got := result.FormatResult(); !strings.HasSuffix(got, want) {
+		t.Errorf("got %q, want perfdata %q", got, want)
+	}
+}
+
+func TestScript(t *testing.T) {
+	got := script(Options{Namespace: "root/cimv2", Query: "SELECT * FROM Win32_Service WHERE Name = 'Spooler'"})
+	want := "-Query 'SELECT * FROM Win32_Service WHERE Name = ''Spooler'''"
+	if !strings.Contains(got, want) {
+		t.Errorf("got script %q, want it to contain %q", got, want)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		opts   Options
+	}{
+		{"Test no query", "", Options{}},
+		{"Test invalid JSON", "Get-CimInstance : Invalid query", Options{Query: "SELECT"}},
+		{"Test missing property", testRows, Options{Query: "SELECT", Properties: []string{"FreeSpace"}}},
+		{"Test non-numeric property", testRows, Options{Query: "SELECT", Properties: []string{"DeviceID"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts
+			opts.Command = helperCommand(t, opts, tc.output)
+			result := Check(context.Background(), opts)
+			if result.ExitCode != gomonitor.Unknown {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, gomonitor.Unknown)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package zookeeper checks a ZooKeeper server with the ruok and mntr four-letter-word commands.
+package zookeeper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+	"github.com/dmabry/gomonitor/tracing"
+)
+
+// maxResponseSize bounds each command response the check will read.
+const maxResponseSize = 1 << 20
+
+// Options configures a ZooKeeper check. Both commands must be allowed by the server's
+// 4lw.commands.whitelist.
+// - `Address` is the host:port of the server, usually on port 2181.
+// - `WarnLatency` and `CritLatency` are average request latencies above which the check is Warning or Critical. 0 disables a threshold.
+// - `WarnOutstanding` and `CritOutstanding` are queued request counts above which the check is Warning or Critical. 0 disables a threshold.
+// - `Timeout` bounds each command. 0 means 10 seconds.
+type Options struct {
+	Address         string
+	WarnLatency     time.Duration
+	CritLatency     time.Duration
+	WarnOutstanding int
+	CritOutstanding int
+	Timeout         time.Duration
+}
+
+// Check sends ruok and expects imok back, then reads the server statistics from mntr. A server
+// that does not answer imok is Critical; mntr output the check cannot use is Unknown.
+func Check(ctx context.Context, opts Options) *gomonitor.CheckResult {
+	result := gomonitor.NewCheckResult()
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	reply, err := command(ctx, opts, "ruok")
+	if err != nil {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("%s: %v", opts.Address, err))
+		return result
+	}
+	if reply := strings.TrimSpace(string(reply)); reply != "imok" {
+		result.SetResult(gomonitor.Critical, fmt.Sprintf("%s: ruok answered %q", opts.Address, reply))
+		return result
+	}
+
+	reply, err = command(ctx, opts, "mntr")
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("%s: mntr failed: %v", opts.Address, err))
+		return result
+	}
+	stats := parseMntr(reply)
+	serverState, ok := stats["zk_server_state"]
+	if !ok {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("%s: mntr answered %q", opts.Address, strings.TrimSpace(string(reply))))
+		return result
+	}
+	latency, err := strconv.ParseFloat(stats["zk_avg_latency"], 64)
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("invalid zk_avg_latency %q", stats["zk_avg_latency"]))
+		return result
+	}
+	outstanding, err := strconv.Atoi(stats["zk_outstanding_requests"])
+	if err != nil {
+		result.SetResult(gomonitor.Unknown, fmt.Sprintf("invalid zk_outstanding_requests %q", stats["zk_outstanding_requests"]))
+		return result
+	}
+	// mntr reports latencies in milliseconds
+	avgLatency := time.Duration(latency * float64(time.Millisecond))
+
+	result.AddPerformanceData("avg_latency", gomonitor.PerformanceMetric{
+		Value:  avgLatency.Seconds(),
+		Warn:   opts.WarnLatency.Seconds(),
+		Crit:   opts.CritLatency.Seconds(),
+		UnitOM: "s",
+		// Latencies are usually well under a millisecond
+		Precision: 6,
+	})
+	result.AddPerformanceData("outstanding_requests", gomonitor.PerformanceMetric{
+		Value:   float64(outstanding),
+		Warn:    float64(opts.WarnOutstanding),
+		Crit:    float64(opts.CritOutstanding),
+		Integer: true,
+	})
+	for _, key := range []string{"zk_num_alive_connections", "zk_znode_count", "zk_synced_followers"} {
+		if v, err := strconv.ParseFloat(stats[key], 64); err == nil {
+			result.AddPerformanceData(strings.TrimPrefix(key, "zk_"), gomonitor.PerformanceMetric{Value: v, Integer: true})
+		}
+	}
+
+	result.Evaluate()
+	result.SetResult(result.ExitCode, fmt.Sprintf("%s, average latency %.1fms, %d outstanding request(s)", serverState, latency, outstanding))
+	return result
+}
+
+// command sends a four-letter word on a new connection and returns everything the server
+// writes before closing it.
+func command(ctx context.Context, opts Options, word string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	conn, err := tracing.Dial(ctx, nil, "tcp", opts.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write([]byte(word)); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(conn, maxResponseSize))
+}
+
+// parseMntr parses the tab-separated key and value lines of a mntr response.
+func parseMntr(reply []byte) map[string]string {
+	stats := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(reply))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "\t"); ok {
+			stats[key] = strings.TrimSpace(value)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,102 @@
+package zookeeper
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeServer answers each four-letter word with its entry in replies and closes the
+// connection, like a ZooKeeper server does.
+func fakeServer(t *testing.T, replies map[string]string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			word := make([]byte, 4)
+			if _, err := io.ReadFull(conn, word); err == nil {
+				reply, ok := replies[string(word)]
+				if !ok {
+					reply = string(word) + " is not executed because it is not in the whitelist.\n"
+				}
+				conn.Write([]byte(reply))
+			}
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// mntr returns a mntr response from a leader with the given latency and queue length.
+func mntr(latency, outstanding string) string {
+	return strings.Join([]string{
+		"zk_version\t3.8.4-9316c2a7a97e1666d8f4593f34dd6fc36ecc436c, built on 2024-02-12 22:16 UTC",
+		"zk_server_state\tleader",
+		"zk_avg_latency\t" + latency,
+		"zk_outstanding_requests\t" + outstanding,
+		"zk_num_alive_connections\t12",
+		"zk_znode_count\t4310",
+		"zk_synced_followers\t2",
+	}, "\n") + "\n"
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		replies   map[string]string
+		opts      Options
+		wantState gomonitor.ExitCode
+		wantMsg   string
+	}{
+		{"Test OK", map[string]string{"ruok": "imok", "mntr": mntr("0.4", "0")},
+			Options{WarnLatency: 10 * time.Millisecond}, gomonitor.OK, "leader, average latency 0.4ms, 0 outstanding request(s)"},
+		{"Test Latency Warning", map[string]string{"ruok": "imok", "mntr": mntr("25", "0")},
+			Options{WarnLatency: 10 * time.Millisecond, CritLatency: 50 * time.Millisecond}, gomonitor.Warning, ""},
+		{"Test Latency Critical", map[string]string{"ruok": "imok", "mntr": mntr("60.5", "0")},
+			Options{WarnLatency: 10 * time.Millisecond, CritLatency: 50 * time.Millisecond}, gomonitor.Critical, ""},
+		{"Test Outstanding Warning", map[string]string{"ruok": "imok", "mntr": mntr("1", "15")},
+			Options{WarnOutstanding: 10, CritOutstanding: 100}, gomonitor.Warning, ""},
+		{"Test Outstanding Critical", map[string]string{"ruok": "imok", "mntr": mntr("1", "150")},
+			Options{WarnOutstanding: 10, CritOutstanding: 100}, gomonitor.Critical, ""},
+		{"Test Not OK", map[string]string{}, Options{}, gomonitor.Critical, "not in the whitelist"},
+		{"Test Mntr Not Allowed", map[string]string{"ruok": "imok"}, Options{}, gomonitor.Unknown, "not in the whitelist"},
+		{"Test Bad Latency", map[string]string{"ruok": "imok", "mntr": mntr("fast", "0")},
+			Options{}, gomonitor.Unknown, `invalid zk_avg_latency "fast"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Address = fakeServer(t, tc.replies)
+			result := Check(context.Background(), tc.opts)
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s (%s), want %s", result.ExitCode, result.Message, tc.wantState)
+			}
+			if !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", result.Message, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPerfdata(t *testing.T) {
+	addr := fakeServer(t, map[string]string{"ruok": "imok", "mntr": mntr("2", "3")})
+	result := Check(context.Background(), Options{Address: addr})
+	for _, want := range []string{"'avg_latency'=0.002000s", "'outstanding_requests'=3;", "'num_alive_connections'=12;", "'znode_count'=4310;", "'synced_followers'=2;"} {
+		if !strings.Contains(result.FormatResult(), want) {
+			t.Errorf("got output %q, want it to contain %q", result.FormatResult(), want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package gomonitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	if got := clock.Now(); !got.Equal(start) {
+		t.Errorf("got %v, want %v", got, start)
+	}
+	clock.Advance(time.Minute)
+	clock.Sleep(time.Hour)
+	if got, want := clock.Now(), start.Add(time.Hour+time.Minute); !got.Equal(want) {
+		t.Errorf("got %v after Advance and Sleep, want %v", got, want)
+	}
+	clock.Set(start)
+	if got := clock.Now(); !got.Equal(start) {
+		t.Errorf("got %v after Set, want %v", got, start)
+	}
+}
+
+func TestClockOrSystem(t *testing.T) {
+	if ClockOrSystem(nil) != SystemClock {
+		t.Error("ClockOrSystem(nil) did not return SystemClock")
+	}
+	clock := NewManualClock(time.Time{})
+	if ClockOrSystem(clock) != clock {
+		t.Error("ClockOrSystem did not return the given clock")
+	}
+}
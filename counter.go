@@ -0,0 +1,70 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// CounterWidth is the number of bits a counter wraps around at, such as the 32-bit and 64-bit
+// SNMP Counter32 and Counter64 types.
+type CounterWidth uint
+
+// Counter widths
+const (
+	Counter32 CounterWidth = 32
+	Counter64 CounterWidth = 64
+)
+
+// CounterMetric returns a metric for the raw value of a continuous counter, with the "c" unit
+// of measure and no decimals.
+func CounterMetric(value uint64) PerformanceMetric {
+	return PerformanceMetric{Value: float64(value), UnitOM: UOMCounter, Integer: true}
+}
+
+// CounterRate returns the per-second rate of a counter that went from prev to cur over elapsed.
+// A cur lower than prev is taken to be a single wraparound at width bits. A counter that was
+// reset, for example by a device reboot, cannot be told apart from one that wrapped, so callers
+// that can detect resets (such as through sysUpTime) should discard those samples.
+func CounterRate(prev, cur uint64, elapsed time.Duration, width CounterWidth) (float64, error) {
+	if width != Counter32 && width != Counter64 {
+		return 0, fmt.Errorf("gomonitor: unsupported counter width %d", width)
+	}
+	if elapsed <= 0 {
+		return 0, fmt.Errorf("gomonitor: counter samples must be taken over a positive interval, got %s", elapsed)
+	}
+	if width == Counter32 && max(prev, cur) > 1<<32-1 {
+		return 0, fmt.Errorf("gomonitor: counter value %d does not fit in 32 bits", max(prev, cur))
+	}
+	// Unsigned subtraction wraps modulo 2^64, which is the 64-bit wraparound
+	delta := cur - prev
+	if width == Counter32 {
+		delta &= 1<<32 - 1
+	}
+	return float64(delta) / elapsed.Seconds(), nil
+}
+
+// CounterRateMetric is like CounterRate but returns the rate as a metric that can be added with
+// AddPerformanceData. The rate is a derived value, so it has no unit of measure.
+func CounterRateMetric(prev, cur uint64, elapsed time.Duration, width CounterWidth) (PerformanceMetric, error) {
+	rate, err := CounterRate(prev, cur, elapsed, width)
+	if err != nil {
+		return PerformanceMetric{}, err
+	}
+	return PerformanceMetric{Value: rate}, nil
+}
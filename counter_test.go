@@ -0,0 +1,61 @@
+package gomonitor
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCounterRate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		prev    uint64
+		cur     uint64
+		elapsed time.Duration
+		width   CounterWidth
+		want    float64
+		wantErr bool
+	}{
+		{"Test Increase", 1000, 4000, 10 * time.Second, Counter64, 300, false},
+		{"Test Unchanged", 5, 5, time.Second, Counter32, 0, false},
+		{"Test Sub Second", 0, 50, 500 * time.Millisecond, Counter64, 100, false},
+		{"Test 32 Bit Wrap", math.MaxUint32 - 99, 100, 20 * time.Second, Counter32, 10, false},
+		{"Test 64 Bit Wrap", math.MaxUint64 - 9, 10, 4 * time.Second, Counter64, 5, false},
+		{"Test 32 Bit Overflow", 0, math.MaxUint32 + 1, time.Second, Counter32, 0, true},
+		{"Test Zero Interval", 1, 2, 0, Counter64, 0, true},
+		{"Test Negative Interval", 1, 2, -time.Second, Counter64, 0, true},
+		{"Test Bad Width", 1, 2, time.Second, 16, 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := CounterRate(tc.prev, tc.cur, tc.elapsed, tc.width)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCounterMetrics(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(OK, "eth0")
+	result.AddPerformanceData("in_octets", CounterMetric(123456789))
+	rate, err := CounterRateMetric(1000, 1600, time.Minute, Counter32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.AddPerformanceData("in_rate", rate)
+
+	want := "OK - eth0 | 'in_octets'=123456789c;0;0;0;0 'in_rate'=10.00;0.00;0.00;0.00;0.00 "
+	if got := result.FormatResult(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := CounterRateMetric(1, 2, 0, Counter64); err == nil {
+		t.Error("CounterRateMetric with a zero interval succeeded, want error")
+	}
+}
@@ -17,8 +17,19 @@
 package gomonitor
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"math"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode"
 )
 
 // ExitCode represents a Nagios exit code
@@ -81,43 +92,197 @@ func (ec ExitCode) Int() int {
 // PerformanceMetric represents a performance metric with various attributes.
 // - `Value` is the actual value of the metric.
 // - `Warn` and `Crit` are threshold values for warning and critical states respectively.
+// - `WarnRange` and `CritRange` are optional threshold ranges that take precedence over Warn and Crit when set.
 // - `Min` and `Max` represent the minimum and maximum expected values of the metric.
 // - `UnitOM` is the unit of measure for the metric.
+// - `Unknown` marks a value that could not be collected. It is output as "U" instead of Value.
+// - `Precision` is the number of decimals the metric's numbers are output with. 0 uses the CheckResult's Precision.
+// - `Integer` outputs the metric's numbers without decimals, for counters. It overrides Precision.
+// - `WarnSet`, `CritSet`, `MinSet` and `MaxSet` mark Warn, Crit, Min and Max as set, so a bound of 0 is still output when the CheckResult's OmitZeroFields is set.
+// - `Labels` tell the metric apart from others of the same Series, such as the host it came from. Outputs with labels, like OpenMetrics and InfluxDB, write them; plugin perfdata does not.
+// - `Series` is the name outputs with labels write the metric under. Empty means its perfdata name.
 type PerformanceMetric struct {
-	Value  float64
-	Warn   float64
-	Crit   float64
-	Min    float64
-	Max    float64
-	UnitOM string
+	Value     float64
+	Warn      float64
+	Crit      float64
+	WarnRange *Range
+	CritRange *Range
+	Min       float64
+	Max       float64
+	UnitOM    string
+	Unknown   bool
+	Precision int
+	Integer   bool
+	WarnSet   bool
+	CritSet   bool
+	MinSet    bool
+	MaxSet    bool
+	Labels    map[string]string
+	Series    string
 }
 
 // CheckResult represents the result of a Monitoring check.
 // - `ExitCode` is the exit code of the check, indicating the status of the check.
 // - `Message` is a descriptive message associated with the check result.
+// - `LongOutput` holds the detail lines written after the summary line, as the plugin API's long output.
+// - `Links` point to remediation context such as runbooks and dashboards. They are written as the first long output lines.
+// - `Tags` label the result with key/value pairs, such as the host's environment or team, for every output to carry. They are written as sorted "key=value" long output lines after the Links.
+// - `Verbosity` selects which lines added with AddVerboseOutput are written, from VerbosityQuiet to VerbosityDebug.
 // - `PerformanceData` is a map containing performance metrics associated with the check result.
-// - `Format` is the format string used to generate the output message.
+// - `Format` is the template of the summary line. Its first two %s or %v verbs are replaced with the state and Message, and %% with a percent sign.
+// - `Profile` adjusts the output for the quirks of a particular monitoring core.
+// - `StderrFallback` makes SendResult write the output to stderr if stdout cannot be written.
+// - `OutputStats` appends a line reporting the metric count, output size and truncation status. It is left out if the Profile's MaxOutputLength is too small to hold it.
+// - `StrictFormat` formats perfdata byte-for-byte like the monitoring-plugins reference plugins.
+// - `OmitZeroFields` leaves the warn, crit, min and max perfdata fields empty when they are 0 and not marked set with the metric's WarnSet, CritSet, MinSet or MaxSet.
+// - `Precision` is the number of decimals perfdata numbers are output with. 0 means 2, PrecisionShortest trims trailing zeros.
+// - `Serializer` renders each perfdata entry. nil means the CheckResult's own Serialize method.
+// - `NonFinite` decides what happens to metrics whose Value is NaN or infinite. The default writes them as unknown.
 type CheckResult struct {
 	ExitCode
 	Message         string
+	LongOutput      []string
+	Links           []Link
+	Tags            map[string]string
+	Verbosity       Verbosity
+	verboseOutput   []verboseLine
+	beforeExit      []func(*CheckResult)
 	PerfOrder       []string
 	PerformanceData map[string]PerformanceMetric
 	Format          string
+	Profile         Profile
+	StderrFallback  bool
+	OutputStats     bool
+	StrictFormat    bool
+	OmitZeroFields  bool
+	Precision       int
+	Serializer      PerfdataSerializer
+	NonFinite       NonFinitePolicy
 }
 
+// Link points from a result to related documentation.
+// - `Name` describes the link, such as "runbook" or "dashboard".
+// - `URL` is the address it points to.
+type Link struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// String renders the link as a "name: URL" line.
+func (l Link) String() string {
+	return l.Name + ": " + l.URL
+}
+
+// Verbosity is the amount of detail a plugin writes, following the plugin development
+// guidelines' -v, -vv and -vvv levels. Levels above VerbosityDebug behave like VerbosityDebug.
+type Verbosity int
+
+const (
+	// VerbosityQuiet writes only the summary and long output
+	VerbosityQuiet Verbosity = iota
+	// VerbosityDetail (-v) adds details such as which items failed
+	VerbosityDetail
+	// VerbosityConfig (-vv) adds configuration debug output such as the commands run
+	VerbosityConfig
+	// VerbosityDebug (-vvv) adds everything useful for diagnosing the plugin itself
+	VerbosityDebug
+)
+
+// verboseLine is a long output line that is only written at or above its level.
+type verboseLine struct {
+	level Verbosity
+	text  string
+}
+
+// NonFinitePolicy controls how metrics with a NaN or infinite Value, which no monitoring core
+// can parse, are written to the perfdata.
+type NonFinitePolicy int
+
+const (
+	// NonFiniteUnknown writes the value as "U", the unknown marker
+	NonFiniteUnknown NonFinitePolicy = iota
+	// NonFiniteDrop leaves the metric out of the output
+	NonFiniteDrop
+)
+
+// PerfdataSerializer renders a performance metric as a single perfdata entry, such as
+// "'load'=1.50;2;3;0;10". An empty string leaves the metric out of the output.
+type PerfdataSerializer interface {
+	Serialize(name string, m PerformanceMetric) string
+}
+
+// PrecisionShortest is a Precision that outputs each number with the fewest decimals that
+// represent it exactly, so counters print as integers and small latencies keep their digits.
+const PrecisionShortest = -1
+
 // SetResult sets the ExitCode and Message fields of the CheckResult to the provided values.
 func (cr *CheckResult) SetResult(ec ExitCode, msg string) {
 	cr.ExitCode = ec
 	cr.Message = msg
 }
 
+// AddLongOutput appends a detail line to the long output, which is written below the summary
+// line. Monitoring cores treat "|" as the start of the perfdata, so lines should not contain it.
+func (cr *CheckResult) AddLongOutput(line string) {
+	cr.LongOutput = append(cr.LongOutput, line)
+}
+
+// AddLink attaches a link, such as a runbook or dashboard URL, to the result.
+func (cr *CheckResult) AddLink(name, url string) {
+	cr.Links = append(cr.Links, Link{Name: name, URL: url})
+}
+
+// SetTag sets the tag key to value, initializing the Tags map if it is nil.
+func (cr *CheckResult) SetTag(key, value string) {
+	if cr.Tags == nil {
+		cr.Tags = make(map[string]string)
+	}
+	cr.Tags[key] = value
+}
+
+// AddVerboseOutput appends a detail line that is only written when Verbosity is at least
+// level. The line is kept either way, so Verbosity may be set after the check has run. Verbose
+// lines are written after LongOutput, in the order they were added.
+func (cr *CheckResult) AddVerboseOutput(level Verbosity, line string) {
+	cr.verboseOutput = append(cr.verboseOutput, verboseLine{level: level, text: line})
+}
+
+// Verbose reports whether Verbosity is at least level, so plugins can skip gathering detail
+// that would not be written.
+func (cr *CheckResult) Verbose(level Verbosity) bool {
+	return cr.Verbosity >= level
+}
+
+// longOutput returns the long output lines to write at the current Verbosity. Links and tags
+// come first so they are the last lines dropped when the output is shortened.
+func (cr *CheckResult) longOutput() []string {
+	var lines []string
+	for _, link := range cr.Links {
+		lines = append(lines, link.String())
+	}
+	for _, key := range slices.Sorted(maps.Keys(cr.Tags)) {
+		lines = append(lines, key+"="+cr.Tags[key])
+	}
+	lines = append(lines, cr.LongOutput...)
+	for _, line := range cr.verboseOutput {
+		if cr.Verbose(line.level) {
+			lines = append(lines, line.text)
+		}
+	}
+	return lines
+}
+
 // AddPerformanceData adds a performance metric to the CheckResult's PerformanceData map.
-// If the PerformanceData map is nil, it is initialized before adding the metric.
+// If the PerformanceData map is nil, it is initialized before adding the metric. Adding a name
+// that is already present replaces its metric and keeps its position. Metrics whose name fails
+// ValidateLabel are left out of the output.
 func (cr *CheckResult) AddPerformanceData(metricName string, metric PerformanceMetric) {
 	if cr.PerformanceData == nil {
 		cr.PerformanceData = make(map[string]PerformanceMetric)
 	}
-	cr.PerfOrder = append(cr.PerfOrder, metricName)
+	if _, ok := cr.PerformanceData[metricName]; !ok || !slices.Contains(cr.PerfOrder, metricName) {
+		cr.PerfOrder = append(cr.PerfOrder, metricName)
+	}
 	cr.PerformanceData[metricName] = metric
 }
 
@@ -143,24 +308,366 @@ func (cr *CheckResult) DeletePerformanceData(metricName string) {
 	}
 }
 
-// SendResult will output the formatted message and exit with the appropriate exit code
-func (cr *CheckResult) SendResult() {
-	output := fmt.Sprintf(cr.Format, cr.ExitCode.String(), cr.Message)
-	// Check if there is performance data to return
-	if len(cr.PerformanceData) > 0 {
-		performanceDataStr := ""
-		for _, key := range cr.PerfOrder {
-			metric := cr.PerformanceData[key]
-			metricStr := fmt.Sprintf("'%s'=%.2f%s;%.2f;%.2f;%.2f;%.2f ",
-				key, metric.Value, metric.UnitOM, metric.Warn, metric.Crit, metric.Min, metric.Max)
-			performanceDataStr += metricStr
+// Metrics returns an iterator over the performance metrics in the order they were added.
+func (cr *CheckResult) Metrics() iter.Seq2[string, PerformanceMetric] {
+	return func(yield func(string, PerformanceMetric) bool) {
+		for _, name := range cr.PerfOrder {
+			metric, ok := cr.PerformanceData[name]
+			if !ok {
+				continue
+			}
+			if !yield(name, metric) {
+				return
+			}
+		}
+	}
+}
+
+// Evaluate compares every performance metric's Value with its thresholds and raises the
+// ExitCode to the worst violation found. CritRange and WarnRange are checked with
+// Range.Violates when set. Otherwise a metric violates Crit or Warn when its Value is above it,
+// and a threshold of 0 is treated as unset unless CritSet or WarnSet marks it set. A metric marked Unknown makes an otherwise OK
+// result Unknown. Evaluate never lowers an ExitCode that was already set.
+func (cr *CheckResult) Evaluate() {
+	state := cr.ExitCode
+	for _, metric := range cr.Metrics() {
+		state = worse(state, metric.State())
+	}
+	cr.ExitCode = state
+}
+
+// EvaluateMetric returns the state of a single performance metric using the same rules as
+// Evaluate, so callers can report which metric tripped which threshold. It does not change the
+// CheckResult and returns an error if there is no metric with that name.
+func (cr *CheckResult) EvaluateMetric(name string) (ExitCode, error) {
+	metric, ok := cr.PerformanceData[name]
+	if !ok {
+		return Unknown, fmt.Errorf("gomonitor: no performance metric %q", name)
+	}
+	return metric.State(), nil
+}
+
+// State returns Critical or Warning if the metric violates that threshold, Unknown if its
+// value could not be collected, otherwise OK. It is the rule Evaluate applies to every metric,
+// so a check that derives its state from State agrees with what its perfdata says.
+func (m PerformanceMetric) State() ExitCode {
+	switch {
+	case m.Unknown:
+		return Unknown
+	case violates(m.Value, m.Crit, m.CritSet, m.CritRange):
+		return Critical
+	case violates(m.Value, m.Warn, m.WarnSet, m.WarnRange):
+		return Warning
+	default:
+		return OK
+	}
+}
+
+// violates reports whether value breaches a threshold given as a range or, when r is nil, as
+// a plain upper limit where 0 means unset unless set is true.
+func violates(value, limit float64, set bool, r *Range) bool {
+	if r != nil {
+		return r.Violates(value)
+	}
+	return (limit != 0 || set) && value > limit
+}
+
+// FormatResult returns the formatted message followed by the performance data, if any,
+// exactly as SendResult prints it. Long output lines follow the summary line and the
+// performance data is appended to the last of them, as the plugin API specifies.
+// Output longer than the Profile's MaxOutputLength is shortened as described by fitOutput.
+func (cr *CheckResult) FormatResult() string {
+	summary := toASCII(formatSummary(cr.Format, cr.Profile.stateWord(cr.ExitCode), cr.Message), cr.Profile.ASCII)
+	lines := cr.longOutput()
+	for i, line := range lines {
+		lines[i] = toASCII(line, cr.Profile.ASCII)
+	}
+	entries := cr.perfdataEntries()
+	total := len(entries)
+	text, truncated := joinLines(summary, lines), false
+	stats := cr.OutputStats
+	if limit := cr.Profile.MaxOutputLength; limit > 0 {
+		room := limit
+		if stats {
+			// Leave room for the stats line of a truncated output. It is left out when even
+			// an empty output would not leave room for it.
+			reserve := len(outputStats(total, total, limit, true))
+			stats = reserve < limit
+			if stats {
+				room = limit - reserve
+			}
+		}
+		text, entries, truncated = fitOutput(summary, lines, entries, room, cr.joinOutput)
+		if stats && !truncated {
+			// An intact output's "truncated=false" can be one byte longer than reserved for
+			output := cr.joinOutput(text, entries)
+			if len(output)+len(outputStats(total, total, len(output), false)) > limit {
+				text, entries, truncated = fitOutput(summary, lines, entries, room-1, cr.joinOutput)
+			}
 		}
+	}
+	output := cr.joinOutput(text, entries)
+	if stats {
+		output += outputStats(len(entries), total, len(output), truncated)
+	}
+	return output
+}
+
+// PluginOutput returns the summary line followed by the long output lines, without the
+// performance data, for outputs that carry the two separately. It is not shortened to the
+// Profile's MaxOutputLength.
+func (cr *CheckResult) PluginOutput() string {
+	lines := cr.longOutput()
+	for i, line := range lines {
+		lines[i] = toASCII(line, cr.Profile.ASCII)
+	}
+	return joinLines(toASCII(formatSummary(cr.Format, cr.Profile.stateWord(cr.ExitCode), cr.Message), cr.Profile.ASCII), lines)
+}
+
+// PerfdataEntries returns the performance metrics in PerfOrder, each rendered as a single
+// perfdata entry exactly as FormatResult writes it.
+func (cr *CheckResult) PerfdataEntries() []string {
+	return cr.perfdataEntries()
+}
 
+// joinLines joins the summary line and the long output lines.
+func joinLines(summary string, lines []string) string {
+	return strings.Join(append([]string{summary}, lines...), "\n")
+}
+
+// formatSummary fills in the Format template with the state and message. Unlike fmt.Sprintf
+// it never reports a verb error in the output: verbs other than %s and %v, and any beyond the
+// second, are written as they appear. The arguments are inserted verbatim, so a message such as
+// "95% used" is never interpreted.
+func formatSummary(format string, args ...string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(format, '%')
+		if i == -1 || i == len(format)-1 {
+			b.WriteString(format)
+			return b.String()
+		}
+		b.WriteString(format[:i])
+		verb := format[i+1]
+		switch {
+		case verb == '%':
+			b.WriteByte('%')
+		case (verb == 's' || verb == 'v') && len(args) > 0:
+			b.WriteString(args[0])
+			args = args[1:]
+		default:
+			b.WriteString(format[i : i+2])
+		}
+		format = format[i+2:]
+	}
+}
+
+// joinOutput combines the summary and the perfdata entries into a single output line.
+// StrictFormat uses the reference plugins' "summary|perf1 perf2" layout.
+func (cr *CheckResult) joinOutput(summary string, entries []string) string {
+	var output strings.Builder
+	output.WriteString(summary)
+	// Check if there is performance data to return
+	if len(entries) > 0 {
 		// Append performance data to the message
-		output = fmt.Sprintf("%s | %s", output, performanceDataStr)
+		if cr.StrictFormat {
+			output.WriteByte('|')
+			output.WriteString(strings.Join(entries, " "))
+		} else {
+			output.WriteString(" | ")
+			output.WriteString(joinPerfdata(entries))
+		}
+	}
+	return output.String()
+}
+
+// outputStats renders the OutputStats line, including its leading newline.
+func outputStats(metrics, total, bytes int, truncated bool) string {
+	return fmt.Sprintf("\n[output] metrics=%d/%d bytes=%d truncated=%t", metrics, total, bytes, truncated)
+}
+
+// formatPerformanceData renders the performance metrics in PerfOrder as a perfdata string.
+func (cr *CheckResult) formatPerformanceData() string {
+	return joinPerfdata(cr.perfdataEntries())
+}
+
+// perfdataEntries renders each performance metric in PerfOrder as a single perfdata entry.
+func (cr *CheckResult) perfdataEntries() []string {
+	entries := make([]string, 0, len(cr.PerfOrder))
+	var serializer PerfdataSerializer = cr
+	if cr.Serializer != nil {
+		serializer = cr.Serializer
 	}
-	fmt.Println(output)
-	os.Exit(cr.ExitCode.Int())
+	for key, metric := range cr.Metrics() {
+		key = toASCII(key, cr.Profile.ASCII)
+		// A label that cannot be represented would corrupt every entry after it
+		if ValidateLabel(key) != nil {
+			continue
+		}
+		if math.IsNaN(metric.Value) || math.IsInf(metric.Value, 0) {
+			if cr.NonFinite == NonFiniteDrop {
+				continue
+			}
+			metric.Unknown = true
+		}
+		if entry := toASCII(serializer.Serialize(key, metric), cr.Profile.ASCII); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Serialize renders a single perfdata entry in the plugin API format, following the
+// CheckResult's StrictFormat, OmitZeroFields, Precision and Profile settings. It is the
+// serializer FormatResult uses when Serializer is nil, so custom serializers can delegate to it.
+func (cr *CheckResult) Serialize(name string, metric PerformanceMetric) string {
+	uom := metric.UnitOM
+	if cr.Profile.StrictUOM && UOM(uom).Validate() != nil {
+		uom = ""
+	}
+	prec := cr.MetricPrecision(metric)
+	value := formatNumber(metric.Value, prec) + uom
+	if metric.Unknown {
+		value = "U"
+	}
+	return fmt.Sprintf("%s=%s;%s;%s;%s;%s",
+		cr.formatLabel(name), value, cr.thresholdField(metric.Warn, metric.WarnSet, metric.WarnRange, prec),
+		cr.thresholdField(metric.Crit, metric.CritSet, metric.CritRange, prec), cr.numberField(metric.Min, metric.MinSet, prec),
+		cr.numberField(metric.Max, metric.MaxSet, prec))
+}
+
+// thresholdField renders the warn or crit field of a perfdata entry, preferring the range.
+func (cr *CheckResult) thresholdField(limit float64, set bool, r *Range, prec int) string {
+	if r != nil {
+		return r.String()
+	}
+	return cr.numberField(limit, set, prec)
+}
+
+// numberField renders a numeric perfdata field, leaving it empty for an unset 0 when
+// OmitZeroFields is set and for NaN or infinite values, which have no perfdata representation.
+func (cr *CheckResult) numberField(v float64, set bool, prec int) string {
+	if cr.OmitZeroFields && v == 0 && !set || math.IsNaN(v) || math.IsInf(v, 0) {
+		return ""
+	}
+	return formatNumber(v, prec)
+}
+
+// MetricPrecision returns the number of decimals metric is written with in perfdata, taking
+// Integer, the metric's Precision and the CheckResult's Precision into account. It may return
+// PrecisionShortest. Code that formats a metric's numbers elsewhere, such as in a message, can
+// use it to match the perfdata.
+func (cr *CheckResult) MetricPrecision(metric PerformanceMetric) int {
+	switch {
+	case metric.Integer:
+		return 0
+	case metric.Precision != 0:
+		return metric.Precision
+	case cr.Precision != 0:
+		return cr.Precision
+	default:
+		return 2
+	}
+}
+
+// formatLabel renders a metric name as a perfdata label. Labels are always quoted unless
+// StrictFormat is set, in which case they are only quoted when they contain whitespace, a quote
+// or an equals sign, as the reference plugins do. Single quotes are escaped by doubling them.
+// A Profile with PlainLabels replaces those characters with underscores and never quotes.
+func (cr *CheckResult) formatLabel(name string) string {
+	needsQuotes := func(r rune) bool {
+		return r == '\'' || r == '=' || unicode.IsSpace(r)
+	}
+	if cr.Profile.PlainLabels {
+		return strings.Map(func(r rune) rune {
+			if needsQuotes(r) {
+				return '_'
+			}
+			return r
+		}, name)
+	}
+	if cr.StrictFormat && !strings.ContainsFunc(name, needsQuotes) {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+// ValidateLabel returns an error if name cannot be written as a perfdata label. Labels must not
+// be empty and must not contain "|", which separates the perfdata from the message, or control
+// characters such as newlines, which would split the output. Every other character can be
+// represented by quoting.
+func ValidateLabel(name string) error {
+	if name == "" {
+		return errors.New("gomonitor: empty perfdata label")
+	}
+	if strings.ContainsFunc(name, func(r rune) bool { return r == '|' || unicode.IsControl(r) }) {
+		return fmt.Errorf("gomonitor: perfdata label %q contains \"|\" or a control character", name)
+	}
+	return nil
+}
+
+// joinPerfdata joins perfdata entries into the perfdata section of the output.
+func joinPerfdata(entries []string) string {
+	var performanceDataStr strings.Builder
+	for _, entry := range entries {
+		performanceDataStr.WriteString(entry)
+		performanceDataStr.WriteByte(' ')
+	}
+	return performanceDataStr.String()
+}
+
+// ignoreSIGPIPE makes SendResult ignore SIGPIPE at most once per process.
+var ignoreSIGPIPE sync.Once
+
+// SendResult will output the formatted message and exit with the appropriate exit code.
+// If stdout cannot be written, for example because the reader closed the pipe, the output is
+// written to stderr when StderrFallback is set and the plugin exits with Unknown. Since the
+// process is about to exit, SendResult ignores SIGPIPE for the rest of it, so a closed stdout
+// is reported as a write error instead of killing the plugin before it can exit with Unknown.
+func (cr *CheckResult) SendResult() {
+	ignoreSIGPIPE.Do(func() { signal.Ignore(syscall.SIGPIPE) })
+	cr.SendResultWith(os.Exit)
+}
+
+// SendResultWith outputs the result like SendResult but passes the exit code to exit instead
+// of calling os.Exit, so programs embedding checks in a long-running process can intercept it.
+// SendResultWith returns if exit does. Unlike SendResult it leaves the process's signal
+// handling alone.
+func (cr *CheckResult) SendResultWith(exit func(code int)) {
+	code := cr.ExitCode.Int()
+	if err := cr.WriteResult(os.Stdout); err != nil {
+		if cr.StderrFallback {
+			_ = cr.WriteResult(os.Stderr)
+		}
+		code = Unknown.Int()
+	}
+	for _, fn := range cr.beforeExit {
+		fn(cr)
+	}
+	exit(code)
+}
+
+// OnBeforeExit registers fn to be called by SendResult and SendResultWith after the output is
+// written and before exiting, so integrations can flush sinks, write state or emit traces.
+// Hooks run in the order they were registered.
+func (cr *CheckResult) OnBeforeExit(fn func(*CheckResult)) {
+	cr.beforeExit = append(cr.beforeExit, fn)
+}
+
+// WriteResult writes the formatted result and a trailing newline to w, as SendResult does for
+// stdout, without exiting. The output is written with a single Write call.
+func (cr *CheckResult) WriteResult(w io.Writer) error {
+	return writeOutput(w, cr.FormatResult())
+}
+
+// writeOutput writes output and its trailing newline to w with a single Write call, so the
+// result cannot be interleaved with anything else printing to the same stream.
+func writeOutput(w io.Writer, output string) error {
+	buf := make([]byte, 0, len(output)+1)
+	buf = append(buf, output...)
+	buf = append(buf, '\n')
+	_, err := w.Write(buf)
+	return err
 }
 
 // NewCheckResult initializes a new check result
@@ -2,8 +2,15 @@ package gomonitor
 
 import (
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"os/exec"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -114,6 +121,636 @@ func TestPerformanceData(t *testing.T) {
 	}
 }
 
+func TestAddPerformanceDataTwice(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(OK, "fine")
+	result.AddPerformanceData("a", PerformanceMetric{Value: 1})
+	result.AddPerformanceData("b", PerformanceMetric{Value: 2})
+	result.AddPerformanceData("a", PerformanceMetric{Value: 6})
+
+	if want := []string{"a", "b"}; !slices.Equal(result.PerfOrder, want) {
+		t.Errorf("got PerfOrder %v, want %v", result.PerfOrder, want)
+	}
+	perfdata := result.formatPerformanceData()
+	if want := "'a'=6.00;0.00;0.00;0.00;0.00 'b'=2.00;0.00;0.00;0.00;0.00 "; perfdata != want {
+		t.Errorf("got perfdata %q, want %q", perfdata, want)
+	}
+	if _, _, err := ParsePerformanceData(perfdata); err != nil {
+		t.Errorf("ParsePerformanceData(%q) got error %v", perfdata, err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	result := NewCheckResult()
+	for _, name := range []string{"c", "a", "b"} {
+		result.AddPerformanceData(name, PerformanceMetric{Value: float64(len(result.PerfOrder))})
+	}
+	result.DeletePerformanceData("a")
+
+	var names []string
+	for name, metric := range result.Metrics() {
+		names = append(names, name)
+		if !reflect.DeepEqual(metric, result.PerformanceData[name]) {
+			t.Errorf("Metrics got %+v for %q, want %+v", metric, name, result.PerformanceData[name])
+		}
+	}
+	if strings.Join(names, ",") != "c,b" {
+		t.Errorf("Metrics got order %v, want [c b]", names)
+	}
+
+	for name := range result.Metrics() {
+		if name != "c" {
+			t.Errorf("Metrics yielded %q after break", name)
+		}
+		break
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		initial ExitCode
+		metrics []PerformanceMetric
+		want    ExitCode
+	}{
+		{"Test No Metrics", OK, nil, OK},
+		{"Test Within Thresholds", OK, []PerformanceMetric{{Value: 5, Warn: 10, Crit: 20}}, OK},
+		{"Test At Threshold", OK, []PerformanceMetric{{Value: 10, Warn: 10, Crit: 20}}, OK},
+		{"Test Warning", OK, []PerformanceMetric{{Value: 15, Warn: 10, Crit: 20}}, Warning},
+		{"Test Critical", OK, []PerformanceMetric{{Value: 25, Warn: 10, Crit: 20}}, Critical},
+		{"Test Unset Thresholds", OK, []PerformanceMetric{{Value: 100}}, OK},
+		{"Test Crit Only", OK, []PerformanceMetric{{Value: 15, Crit: 10}}, Critical},
+		{"Test Worst Wins", OK, []PerformanceMetric{
+			{Value: 15, Warn: 10, Crit: 20},
+			{Value: 25, Warn: 10, Crit: 20},
+			{Value: 1, Warn: 10, Crit: 20},
+		}, Critical},
+		{"Test Never Lowers", Critical, []PerformanceMetric{{Value: 15, Warn: 10, Crit: 20}}, Critical},
+		{"Test Raises Unknown", Unknown, []PerformanceMetric{{Value: 15, Warn: 10}}, Warning},
+		{"Test Unknown Value", OK, []PerformanceMetric{{Value: 15, Warn: 10, Unknown: true}}, Unknown},
+		{"Test Unknown Value With Violation", OK, []PerformanceMetric{{Unknown: true}, {Value: 15, Warn: 10}}, Warning},
+		{"Test Range Outside", OK, []PerformanceMetric{{Value: 5, WarnRange: &Range{Start: 10, End: 20}}}, Warning},
+		{"Test Range Inside", OK, []PerformanceMetric{{Value: 15, WarnRange: &Range{Start: 10, End: 20}}}, OK},
+		{"Test Inverted Range", OK, []PerformanceMetric{{Value: 15, CritRange: &Range{Start: 10, End: 20, Invert: true}}}, Critical},
+		{"Test Range Overrides Float", OK, []PerformanceMetric{{Value: 15, Crit: 10, CritRange: &Range{Start: 0, End: 20}}}, OK},
+		{"Test Zero Warn Set", OK, []PerformanceMetric{{Value: 5, WarnSet: true}}, Warning},
+		{"Test Zero Crit Set", OK, []PerformanceMetric{{Value: 5, Warn: 10, CritSet: true}}, Critical},
+		{"Test Zero Set At Threshold", OK, []PerformanceMetric{{Value: 0, WarnSet: true, CritSet: true}}, OK},
+		{"Test Zero Set Below Threshold", OK, []PerformanceMetric{{Value: -1, WarnSet: true}}, OK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NewCheckResult()
+			result.ExitCode = tc.initial
+			for i, metric := range tc.metrics {
+				result.AddPerformanceData(fmt.Sprintf("m%d", i), metric)
+			}
+			result.Evaluate()
+			if result.ExitCode != tc.want {
+				t.Errorf("got %s, want %s", result.ExitCode, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatResult(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(Critical, "Test message")
+	if got, want := result.FormatResult(), "Critical - Test message"; got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+
+	result.AddPerformanceData("test", PerformanceMetric{Value: 1.5, Warn: 1, Crit: 2, Max: 10, UnitOM: "ms"})
+	want := "Critical - Test message | 'test'=1.50ms;1.00;2.00;0.00;10.00 "
+	if got := result.FormatResult(); got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+}
+
+func TestFormatResultLongOutput(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(Warning, "2 of 3 disks degraded")
+	result.AddLongOutput("sda: OK")
+	result.AddLongOutput("sdb: degraded")
+	result.AddLongOutput("sdc: degraded")
+	if got, want := result.FormatResult(), "Warning - 2 of 3 disks degraded\nsda: OK\nsdb: degraded\nsdc: degraded"; got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+
+	result.AddPerformanceData("degraded", PerformanceMetric{Value: 2, Integer: true})
+	want := "Warning - 2 of 3 disks degraded\nsda: OK\nsdb: degraded\nsdc: degraded | 'degraded'=2;0;0;0;0 "
+	if got := result.FormatResult(); got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+}
+
+func TestFormatResultLinks(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(Critical, "disk full")
+	result.AddLongOutput("/var: 100% used")
+	result.AddLink("runbook", "https://wiki.example.com/runbooks/disk-full")
+	result.AddLink("dashboard", "https://grafana.example.com/d/disk")
+	want := "Critical - disk full\n" +
+		"runbook: https://wiki.example.com/runbooks/disk-full\n" +
+		"dashboard: https://grafana.example.com/d/disk\n" +
+		"/var: 100% used"
+	if got := result.FormatResult(); got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+}
+
+func TestFormatResultTags(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(OK, "fine")
+	result.AddLink("runbook", "https://wiki.example.com/runbooks/fine")
+	result.SetTag("team", "storage")
+	result.SetTag("env", "prod")
+	result.AddPerformanceData("a", PerformanceMetric{Value: 1, Integer: true})
+	want := "OK - fine\nrunbook: https://wiki.example.com/runbooks/fine\nenv=prod\nteam=storage | 'a'=1;0;0;0;0 "
+	if got := result.FormatResult(); got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+}
+
+func TestFormatResultVerbosity(t *testing.T) {
+	testCases := []struct {
+		name      string
+		verbosity Verbosity
+		want      string
+	}{
+		{"Test quiet", VerbosityQuiet, "OK - done\nalways"},
+		{"Test detail", VerbosityDetail, "OK - done\nalways\n1 of 1 item checked"},
+		{"Test config", VerbosityConfig, "OK - done\nalways\n1 of 1 item checked\nran: true"},
+		{"Test debug", VerbosityDebug, "OK - done\nalways\n1 of 1 item checked\nran: true\nelapsed 1ms"},
+		{"Test above debug", VerbosityDebug + 1, "OK - done\nalways\n1 of 1 item checked\nran: true\nelapsed 1ms"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NewCheckResult()
+			result.SetResult(OK, "done")
+			result.AddVerboseOutput(VerbosityDetail, "1 of 1 item checked")
+			result.AddVerboseOutput(VerbosityConfig, "ran: true")
+			result.AddVerboseOutput(VerbosityDebug, "elapsed 1ms")
+			result.AddLongOutput("always")
+			// Verbosity may be set after the lines were added
+			result.Verbosity = tc.verbosity
+			if got := result.FormatResult(); got != tc.want {
+				t.Errorf("FormatResult got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatSummary(t *testing.T) {
+	testCases := []struct {
+		name    string
+		format  string
+		message string
+		want    string
+	}{
+		{"Test Default", "%s - %s", "disk 95% used", "OK - disk 95% used"},
+		{"Test Verbs In Message", "%s - %s", "%s %d %!x(MISSING)", "OK - %s %d %!x(MISSING)"},
+		{"Test Value Verb", "[%v] %v", "fine", "[OK] fine"},
+		{"Test Escaped Percent", "%s: %s (100%%)", "fine", "OK: fine (100%)"},
+		{"Test Missing Verb", "%s", "fine", "OK"},
+		{"Test Extra Verb", "%s - %s - %s", "fine", "OK - fine - %s"},
+		{"Test Other Verb", "%d %s %s", "fine", "%d OK fine"},
+		{"Test Trailing Percent", "%s - %s %", "fine", "OK - fine %"},
+		{"Test No Verbs", "static", "fine", "static"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NewCheckResult()
+			result.Format = tc.format
+			result.SetResult(OK, tc.message)
+			if got := result.FormatResult(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateMetric(t *testing.T) {
+	result := NewCheckResult()
+	result.AddPerformanceData("ok", PerformanceMetric{Value: 5, Warn: 10, Crit: 20})
+	result.AddPerformanceData("warn", PerformanceMetric{Value: 15, Warn: 10, Crit: 20})
+	result.AddPerformanceData("crit", PerformanceMetric{Value: 25, Warn: 10, Crit: 20})
+	result.AddPerformanceData("range", PerformanceMetric{Value: 5, CritRange: &Range{Start: 10, End: 20}})
+	result.AddPerformanceData("zero", PerformanceMetric{Value: 5, Crit: 0, CritSet: true})
+
+	testCases := []struct {
+		name    string
+		metric  string
+		want    ExitCode
+		wantErr bool
+	}{
+		{"Test OK", "ok", OK, false},
+		{"Test Warning", "warn", Warning, false},
+		{"Test Critical", "crit", Critical, false},
+		{"Test Range", "range", Critical, false},
+		{"Test Zero Threshold Set", "zero", Critical, false},
+		{"Test Missing", "missing", Unknown, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := result.EvaluateMetric(tc.metric)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+	if result.ExitCode != OK {
+		t.Errorf("EvaluateMetric changed ExitCode to %s", result.ExitCode)
+	}
+}
+
+func TestFormatResultRanges(t *testing.T) {
+	testCases := []struct {
+		name string
+		warn string
+		crit string
+		want string
+	}{
+		{"Test Bounded", "15:25", "10:30", "'temp'=21.50;15:25;10:30;0.00;0.00 "},
+		{"Test Inverted", "@10:20", "@~:5", "'temp'=21.50;@10:20;@~:5;0.00;0.00 "},
+		{"Test Open", "~:5", "30:", "'temp'=21.50;~:5;30:;0.00;0.00 "},
+		{"Test Bare Upper Bound", "25", "@30", "'temp'=21.50;0:25;@0:30;0.00;0.00 "},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			warn, err := ParseThreshold(tc.warn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			crit, err := ParseThreshold(tc.crit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := NewCheckResult()
+			result.SetResult(OK, "fine")
+			result.AddPerformanceData("temp", PerformanceMetric{Value: 21.5, WarnRange: warn, CritRange: crit})
+			if got, want := result.FormatResult(), "OK - fine | "+tc.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			assertConformance(t, result)
+
+			// Parsing the perfdata back and formatting it again gives the same entry
+			order, metrics, err := ParsePerformanceData(tc.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed := NewCheckResult()
+			parsed.AddPerformanceData(order[0], metrics[order[0]])
+			if got := parsed.formatPerformanceData(); got != tc.want {
+				t.Errorf("round trip got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatResultOmitZeroFields(t *testing.T) {
+	result := NewCheckResult()
+	result.OmitZeroFields = true
+	result.SetResult(OK, "fine")
+	result.AddPerformanceData("load", PerformanceMetric{Value: 1.5})
+	result.AddPerformanceData("disk", PerformanceMetric{Value: 0, Warn: 80, Max: 100, UnitOM: "%"})
+	result.AddPerformanceData("temp", PerformanceMetric{Value: 20, WarnRange: &Range{Start: 10, End: 30}})
+	result.AddPerformanceData("offset", PerformanceMetric{Value: -1, Crit: 0, CritSet: true, Min: 0, MinSet: true})
+	want := "OK - fine | 'load'=1.50;;;; 'disk'=0.00%;80.00;;;100.00 'temp'=20.00;10:30;;; 'offset'=-1.00;;0.00;0.00; "
+	if got := result.FormatResult(); got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+	assertConformance(t, result)
+}
+
+func TestFormatResultUnknownValue(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(OK, "fine")
+	result.AddPerformanceData("latency", PerformanceMetric{Value: 3, Warn: 100, UnitOM: "ms", Unknown: true})
+	want := "OK - fine | 'latency'=U;100.00;0.00;0.00;0.00 "
+	if got := result.FormatResult(); got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+}
+
+func TestMetricPrecision(t *testing.T) {
+	testCases := []struct {
+		name            string
+		resultPrecision int
+		metric          PerformanceMetric
+		want            int
+	}{
+		{"Test Default", 0, PerformanceMetric{}, 2},
+		{"Test Result", 4, PerformanceMetric{}, 4},
+		{"Test Metric Overrides Result", 4, PerformanceMetric{Precision: 1}, 1},
+		{"Test Shortest", PrecisionShortest, PerformanceMetric{}, PrecisionShortest},
+		{"Test Integer Overrides All", 4, PerformanceMetric{Precision: 1, Integer: true}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NewCheckResult()
+			result.Precision = tc.resultPrecision
+			if got := result.MetricPrecision(tc.metric); got != tc.want {
+				t.Errorf("MetricPrecision got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatResultPrecision(t *testing.T) {
+	testCases := []struct {
+		name            string
+		resultPrecision int
+		metricPrecision int
+		want            string
+	}{
+		{"Test Default", 0, 0, "'rtt'=0.00;0.00;0.00;0.00;0.00 'rx'=123456.00;0.00;0.00;0.00;0.00 "},
+		{"Test Result", 4, 0, "'rtt'=0.0003;0.0010;0.0000;0.0000;0.0000 'rx'=123456.0000;0.0000;0.0000;0.0000;0.0000 "},
+		{"Test Shortest", PrecisionShortest, 0, "'rtt'=0.00025;0.001;0;0;0 'rx'=123456;0;0;0;0 "},
+		{"Test Metric Overrides Result", PrecisionShortest, 6, "'rtt'=0.000250;0.001000;0.000000;0.000000;0.000000 'rx'=123456;0;0;0;0 "},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NewCheckResult()
+			result.SetResult(OK, "fine")
+			result.Precision = tc.resultPrecision
+			result.AddPerformanceData("rtt", PerformanceMetric{Value: 0.00025, Warn: 0.001, Precision: tc.metricPrecision})
+			result.AddPerformanceData("rx", PerformanceMetric{Value: 123456})
+			if got, want := result.FormatResult(), "OK - fine | "+tc.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFormatResultInteger(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(OK, "fine")
+	result.Precision = 3
+	result.AddPerformanceData("processes", PerformanceMetric{Value: 42, Warn: 100, Crit: 200.6, Max: 1024, Integer: true, Precision: 5})
+	result.AddPerformanceData("load", PerformanceMetric{Value: 0.5})
+	want := "OK - fine | 'processes'=42;100;201;0;1024 'load'=0.500;0.000;0.000;0.000;0.000 "
+	if got := result.FormatResult(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatResultPrecisionShortestRoundTrip(t *testing.T) {
+	result := NewCheckResult()
+	result.Precision = PrecisionShortest
+	want := PerformanceMetric{Value: 0.000123456, Warn: 1.5, Crit: 2.25, Min: -7, Max: 1e12}
+	result.AddPerformanceData("m", want)
+	_, metrics, err := ParsePerformanceData(result.formatPerformanceData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := metrics["m"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestFormatResultStrictFormat(t *testing.T) {
+	result := NewCheckResult()
+	result.StrictFormat = true
+	result.SetResult(OK, "fine")
+	if got, want := result.FormatResult(), "OK - fine"; got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+
+	result.AddPerformanceData("rta", PerformanceMetric{Value: 0.05, Warn: 100, Crit: 500, UnitOM: "ms"})
+	result.AddPerformanceData("packet loss", PerformanceMetric{Value: 0, Warn: 20, Crit: 60, UnitOM: "%"})
+	want := "OK - fine|rta=0.05ms;100.00;500.00;0.00;0.00 'packet loss'=0.00%;20.00;60.00;0.00;0.00"
+	if got := result.FormatResult(); got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+}
+
+func TestFormatResultLabels(t *testing.T) {
+	testCases := []struct {
+		name   string
+		label  string
+		strict bool
+		want   string
+	}{
+		{"Test Plain", "load", false, "'load'=1;0;0;0;0"},
+		{"Test Plain Strict", "load", true, "load=1;0;0;0;0"},
+		{"Test Space Strict", "disk /var", true, "'disk /var'=1;0;0;0;0"},
+		{"Test Equals Strict", "a=b", true, "'a=b'=1;0;0;0;0"},
+		{"Test Quote", "it's", false, "'it''s'=1;0;0;0;0"},
+		{"Test Quote Strict", "'quoted'", true, "'''quoted'''=1;0;0;0;0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NewCheckResult()
+			result.StrictFormat = tc.strict
+			result.Precision = PrecisionShortest
+			result.AddPerformanceData(tc.label, PerformanceMetric{Value: 1})
+			if got := strings.TrimSpace(result.formatPerformanceData()); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			assertConformance(t, result)
+		})
+	}
+}
+
+func TestFormatResultInvalidLabels(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(OK, "fine")
+	result.AddPerformanceData("", PerformanceMetric{Value: 1})
+	result.AddPerformanceData("a|b", PerformanceMetric{Value: 2})
+	result.AddPerformanceData("line\nbreak", PerformanceMetric{Value: 3})
+	result.AddPerformanceData("ok", PerformanceMetric{Value: 4, Integer: true})
+	want := "OK - fine | 'ok'=4;0;0;0;0 "
+	if got := result.FormatResult(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateLabel(t *testing.T) {
+	testCases := []struct {
+		name    string
+		label   string
+		wantErr bool
+	}{
+		{"Test Plain", "load1", false},
+		{"Test Space And Quote", "it's a = b", false},
+		{"Test Unicode", "température", false},
+		{"Test Empty", "", true},
+		{"Test Pipe", "a|b", true},
+		{"Test Newline", "a\nb", true},
+		{"Test Tab", "a\tb", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateLabel(tc.label); (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// uppercaseSerializer upper-cases labels, drops metrics named "skip" and delegates the rest to
+// the CheckResult's default serialization.
+type uppercaseSerializer struct {
+	cr *CheckResult
+}
+
+func (s uppercaseSerializer) Serialize(name string, m PerformanceMetric) string {
+	if name == "skip" {
+		return ""
+	}
+	return s.cr.Serialize(strings.ToUpper(name), m)
+}
+
+func TestFormatResultSerializer(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(OK, "fine")
+	result.Precision = PrecisionShortest
+	result.AddPerformanceData("load", PerformanceMetric{Value: 1.5, Warn: 2})
+	result.AddPerformanceData("skip", PerformanceMetric{Value: 1})
+	result.AddPerformanceData("users", PerformanceMetric{Value: 3})
+	result.Serializer = uppercaseSerializer{result}
+	want := "OK - fine | 'LOAD'=1.5;2;0;0;0 'USERS'=3;0;0;0;0 "
+	if got := result.FormatResult(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatResultNonFinite(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy NonFinitePolicy
+		want   string
+	}{
+		{"Test Unknown", NonFiniteUnknown, "OK - fine | 'rate'=U;10;;0; 'ratio'=U;;0;0;0 'load'=1;;0;0; "},
+		{"Test Drop", NonFiniteDrop, "OK - fine | 'load'=1;;0;0; "},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NewCheckResult()
+			result.SetResult(OK, "fine")
+			result.Precision = PrecisionShortest
+			result.NonFinite = tc.policy
+			result.AddPerformanceData("rate", PerformanceMetric{Value: math.NaN(), Warn: 10, Crit: math.NaN(), Max: math.Inf(1)})
+			result.AddPerformanceData("ratio", PerformanceMetric{Value: math.Inf(-1), Warn: math.Inf(1), UnitOM: "%"})
+			result.AddPerformanceData("load", PerformanceMetric{Value: 1, Warn: math.NaN(), Max: math.Inf(1)})
+			if got := result.FormatResult(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if strings.Contains(result.FormatResult(), "NaN") || strings.Contains(result.FormatResult(), "Inf") {
+				t.Errorf("output %q contains a non-finite number", result.FormatResult())
+			}
+		})
+	}
+}
+
+func TestFormatResultOutputStats(t *testing.T) {
+	result := NewCheckResult()
+	result.OutputStats = true
+	result.SetResult(OK, "fine")
+	result.AddPerformanceData("a", PerformanceMetric{Value: 1})
+	result.AddPerformanceData("b", PerformanceMetric{Value: 2})
+
+	want := "OK - fine | 'a'=1.00;0.00;0.00;0.00;0.00 'b'=2.00;0.00;0.00;0.00;0.00 \n" +
+		"[output] metrics=2/2 bytes=70 truncated=false"
+	if got := result.FormatResult(); got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+
+	result.Profile = Profile{MaxOutputLength: 110}
+	got := result.FormatResult()
+	if len(got) > 110 {
+		t.Errorf("FormatResult got %d bytes, want at most 110", len(got))
+	}
+	if !strings.HasSuffix(got, "[output] metrics=1/2 bytes=60 truncated=true") {
+		t.Errorf("FormatResult got %q, want stats reporting truncation", got)
+	}
+
+	// Exactly enough room for the intact output and its stats line
+	result.Profile = Profile{MaxOutputLength: len(want)}
+	if got := result.FormatResult(); got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+
+	// Every limit must hold, including those too small for the stats line itself
+	for limit := 1; limit <= 130; limit++ {
+		result.Profile = Profile{MaxOutputLength: limit}
+		got := result.FormatResult()
+		if len(got) > limit {
+			t.Errorf("FormatResult with limit %d got %d bytes: %q", limit, len(got), got)
+		}
+		if limit < 45 && strings.Contains(got, "[output]") {
+			t.Errorf("FormatResult with limit %d got %q, want the stats line left out", limit, got)
+		}
+	}
+}
+
+// countingWriter records every Write call it receives.
+type countingWriter struct {
+	writes []string
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestWriteOutputSingleWrite(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(Warning, "Test message")
+	result.AddPerformanceData("a", PerformanceMetric{Value: 1})
+	result.AddPerformanceData("b", PerformanceMetric{Value: 2})
+
+	w := &countingWriter{}
+	if err := writeOutput(w, result.FormatResult()); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.writes) != 1 {
+		t.Fatalf("got %d writes, want 1", len(w.writes))
+	}
+	if want := result.FormatResult() + "\n"; w.writes[0] != want {
+		t.Errorf("got %q, want %q", w.writes[0], want)
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(Critical, "Test message")
+	result.AddLongOutput("detail")
+	result.AddPerformanceData("a", PerformanceMetric{Value: 1, Integer: true})
+
+	var buf strings.Builder
+	if err := result.WriteResult(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "Critical - Test message\ndetail | 'a'=1;0;0;0;0 \n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	defer w.Close()
+	if err := result.WriteResult(w); err == nil {
+		t.Error("WriteResult to a closed pipe returned no error")
+	}
+}
+
 func TestSendResult(t *testing.T) {
 	if os.Getenv("BE_CRASHER") == "1" {
 		result := NewCheckResult()
@@ -137,35 +774,130 @@ func TestSendResult(t *testing.T) {
 	}
 }
 
-type ExitGetter interface {
-	GetExitCode() int
-}
+func TestSendResultClosedStdout(t *testing.T) {
+	if os.Getenv("BE_CRASHER") == "1" {
+		result := NewCheckResult()
+		result.StderrFallback = true
+		result.SetResult(Critical, "Test Message")
+		result.SendResult()
+		return
+	}
 
-type exitStatus struct {
-	Code int
-}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Close the reading end so every write to stdout fails
+	r.Close()
+	defer w.Close()
+
+	var stderr strings.Builder
+	cmd := exec.Command(os.Args[0], "-test.run=TestSendResultClosedStdout")
+	cmd.Env = append(os.Environ(), "BE_CRASHER=1")
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	err = cmd.Run()
 
-func (e *exitStatus) GetExitCode() int {
-	return e.Code
+	exitError, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("cmd.Run() got %v, want exit status %d", err, Unknown.Int())
+	}
+	if status := exitError.ExitCode(); status != Unknown.Int() {
+		t.Fatalf("process ran with err %v, want exit status %d", err, Unknown.Int())
+	}
+	if !strings.Contains(stderr.String(), "Critical - Test Message") {
+		t.Errorf("stderr got %q, want the result output", stderr.String())
+	}
 }
 
-var exiters = make(map[int]ExitGetter)
+func TestSendResultWith(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	result := NewCheckResult()
+	result.SetResult(Warning, "Test message")
+	var codes []int
+	result.SendResultWith(func(code int) { codes = append(codes, code) })
+	w.Close()
+	output, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-func mockExit(code int) {
-	exiters[code] = &exitStatus{code}
-	panic(fmt.Sprintf("exit %v", code))
+	if len(codes) != 1 || codes[0] != Warning.Int() {
+		t.Errorf("got exit codes %v, want [%d]", codes, Warning.Int())
+	}
+	if got, want := string(output), "Warning - Test message\n"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
 }
 
-func init() {
-	osExit = mockExit
+func TestSendResultWithBeforeExit(t *testing.T) {
+	_, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	defer w.Close()
+
+	var calls []string
+	result := NewCheckResult()
+	result.SetResult(Critical, "Test message")
+	result.OnBeforeExit(func(cr *CheckResult) { calls = append(calls, "first "+cr.ExitCode.String()) })
+	result.OnBeforeExit(func(cr *CheckResult) { calls = append(calls, "second") })
+	result.SendResultWith(func(code int) { calls = append(calls, fmt.Sprintf("exit %d", code)) })
+
+	want := []string{"first Critical", "second", "exit 2"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("got calls %q, want %q", calls, want)
+	}
 }
 
-// Mock os.Exit for testing
-var osExit = func(code int) {
-	os.Exit(code)
+func TestSendResultWithClosedStdout(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	defer w.Close()
+
+	result := NewCheckResult()
+	result.SetResult(OK, "Test message")
+	var codes []int
+	result.SendResultWith(func(code int) { codes = append(codes, code) })
+	if len(codes) != 1 || codes[0] != Unknown.Int() {
+		t.Errorf("got exit codes %v, want [%d]", codes, Unknown.Int())
+	}
+	// Only SendResult, which exits the process, changes its signal handling
+	if signal.Ignored(syscall.SIGPIPE) {
+		t.Error("SendResultWith ignored SIGPIPE")
+	}
 }
 
 // Mock fmt.Printf for testing
 var fmtPrintf = func(format string, a ...interface{}) (n int, err error) {
 	return fmt.Printf(format, a...)
 }
+
+func TestPluginOutput(t *testing.T) {
+	cr := NewCheckResult()
+	cr.SetResult(Warning, "load high")
+	cr.AddLongOutput("load1 5.2")
+	cr.AddPerformanceData("load1", PerformanceMetric{Value: 5.2})
+	if got, want := cr.PluginOutput(), "Warning - load high\nload1 5.2"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+	if got, want := cr.PerfdataEntries(), []string{"'load1'=5.20;0.00;0.00;0.00;0.00"}; !slices.Equal(got, want) {
+		t.Errorf("got entries %q, want %q", got, want)
+	}
+}
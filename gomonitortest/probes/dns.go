@@ -0,0 +1,176 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package probes
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+// DNS record types and response codes understood by DNSServer.
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+
+	dnsRcodeServFail = 2
+	dnsRcodeNXDomain = 3
+)
+
+// DNSServer is a minimal authoritative DNS server over UDP that answers A and AAAA queries from a
+// fixed record table.
+type DNSServer struct {
+	conn    net.PacketConn
+	opts    Options
+	records map[string][]net.IP
+	wg      sync.WaitGroup
+}
+
+// NewDNSServer starts a DNS server answering from records, keyed by host name with or without the
+// trailing dot. Names that are not in records get NXDOMAIN; in Reset mode every query gets
+// SERVFAIL.
+func NewDNSServer(opts Options, records map[string][]net.IP) (*DNSServer, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &DNSServer{
+		conn:    conn,
+		opts:    opts,
+		records: make(map[string][]net.IP, len(records)),
+	}
+	for name, ips := range records {
+		s.records[canonicalName(name)] = ips
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the host:port the server is listening on.
+func (s *DNSServer) Addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+// Close stops the server.
+func (s *DNSServer) Close() {
+	_ = s.conn.Close()
+	s.wg.Wait()
+}
+
+func (s *DNSServer) serve() {
+	defer s.wg.Done()
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		resp, err := s.answer(buf[:n])
+		if err != nil {
+			continue
+		}
+		s.opts.wait()
+		_, _ = s.conn.WriteTo(resp, addr)
+	}
+}
+
+// answer builds the response message for a single query.
+func (s *DNSServer) answer(query []byte) ([]byte, error) {
+	if len(query) < 12 {
+		return nil, errors.New("short DNS header")
+	}
+	name, end, err := readName(query, 12)
+	if err != nil {
+		return nil, err
+	}
+	if end+4 > len(query) {
+		return nil, errors.New("short DNS question")
+	}
+	qtype := binary.BigEndian.Uint16(query[end:])
+	question := query[12 : end+4]
+
+	var rcode uint16
+	var answers []net.IP
+	switch {
+	case s.opts.Mode == Reset:
+		rcode = dnsRcodeServFail
+	default:
+		ips, ok := s.records[canonicalName(name)]
+		if !ok {
+			rcode = dnsRcodeNXDomain
+		}
+		for _, ip := range ips {
+			if qtype == dnsTypeA && ip.To4() != nil || qtype == dnsTypeAAAA && ip.To4() == nil {
+				answers = append(answers, ip)
+			}
+		}
+	}
+
+	resp := make([]byte, 12, 12+len(question)+len(answers)*28)
+	copy(resp, query[:2])
+	// QR, copy RD, RA, rcode
+	flags := uint16(0x8000) | binary.BigEndian.Uint16(query[2:])&0x0100 | 0x0080 | rcode
+	binary.BigEndian.PutUint16(resp[2:], flags)
+	binary.BigEndian.PutUint16(resp[4:], 1)
+	binary.BigEndian.PutUint16(resp[6:], uint16(len(answers)))
+	resp = append(resp, question...)
+	for _, ip := range answers {
+		data := ip.To4()
+		if data == nil {
+			data = ip.To16()
+		}
+		rr := make([]byte, 12, 12+len(data))
+		// Pointer to the name in the question section
+		binary.BigEndian.PutUint16(rr[0:], 0xC00C)
+		binary.BigEndian.PutUint16(rr[2:], qtype)
+		binary.BigEndian.PutUint16(rr[4:], 1)
+		binary.BigEndian.PutUint32(rr[6:], 60)
+		binary.BigEndian.PutUint16(rr[10:], uint16(len(data)))
+		resp = append(resp, append(rr, data...)...)
+	}
+	return resp, nil
+}
+
+// readName decodes the uncompressed name starting at off and returns it along with the offset just
+// past it.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	for {
+		if off >= len(msg) {
+			return "", 0, errors.New("truncated DNS name")
+		}
+		l := int(msg[off])
+		off++
+		if l == 0 {
+			break
+		}
+		if l&0xC0 != 0 || off+l > len(msg) {
+			return "", 0, errors.New("invalid DNS label")
+		}
+		labels = append(labels, string(msg[off:off+l]))
+		off += l
+	}
+	return strings.Join(labels, "."), off, nil
+}
+
+// canonicalName lower-cases name and strips any trailing dot.
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
@@ -0,0 +1,78 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func testResolver(addr string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", addr)
+		},
+	}
+}
+
+func TestDNSServer(t *testing.T) {
+	srv, err := NewDNSServer(Options{}, map[string][]net.IP{
+		"example.test": {net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	r := testResolver(srv.Addr())
+	ips, err := r.LookupIP(context.Background(), "ip4", "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("got %v, want [192.0.2.1]", ips)
+	}
+
+	ips, err = r.LookupIP(context.Background(), "ip6", "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("got %v, want [2001:db8::1]", ips)
+	}
+}
+
+func TestDNSServerFailures(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mode         Mode
+		host         string
+		wantNotFound bool
+	}{
+		{"Test NXDOMAIN", Normal, "missing.test", true},
+		{"Test SERVFAIL", Reset, "example.test", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, err := NewDNSServer(Options{Mode: tc.mode}, map[string][]net.IP{
+				"example.test": {net.ParseIP("192.0.2.1")},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Close()
+
+			_, err = testResolver(srv.Addr()).LookupIP(context.Background(), "ip4", tc.host)
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) {
+				t.Fatalf("got error %v, want *net.DNSError", err)
+			}
+			if dnsErr.IsNotFound != tc.wantNotFound {
+				t.Errorf("got IsNotFound %t, want %t", dnsErr.IsNotFound, tc.wantNotFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package probes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// HTTPServer is an httptest.Server whose handler is wrapped with the configured failure behavior.
+type HTTPServer struct {
+	*httptest.Server
+}
+
+// NewHTTPServer starts an HTTP server that serves handler according to opts. A nil handler answers
+// every request with 200 OK. BadCert mode starts a TLS server, every other mode a plain HTTP
+// server.
+func NewHTTPServer(opts Options, handler http.Handler) (*HTTPServer, error) {
+	if handler == nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	wrapped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if opts.Mode == Reset {
+			hj, ok := w.(http.Hijacker)
+			if !ok {
+				panic("probes: ResponseWriter does not support hijacking")
+			}
+			conn, _, err := hj.Hijack()
+			if err != nil {
+				return
+			}
+			reset(conn)
+			return
+		}
+		opts.wait()
+		handler.ServeHTTP(w, r)
+	})
+
+	if opts.Mode != BadCert {
+		return &HTTPServer{httptest.NewServer(wrapped)}, nil
+	}
+
+	cert, err := expiredCertificate()
+	if err != nil {
+		return nil, err
+	}
+	srv := httptest.NewUnstartedServer(wrapped)
+	// Clients are expected to reject the certificate; don't log every failed handshake.
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	return &HTTPServer{srv}, nil
+}
+
+// expiredCertificate generates a self-signed loopback certificate whose validity period ended a day
+// ago.
+func expiredCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gomonitor probes"},
+		NotBefore:    now.Add(-48 * time.Hour),
+		NotAfter:     now.Add(-24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,68 @@
+package probes
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHTTPServer(t *testing.T) {
+	srv, err := NewHTTPServer(Options{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusTeapot)
+	}
+}
+
+func TestHTTPServerSlow(t *testing.T) {
+	srv, err := NewHTTPServer(Options{Mode: Slow, Delay: 200 * time.Millisecond}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Error("request to slow server succeeded, want timeout")
+	}
+}
+
+func TestHTTPServerReset(t *testing.T) {
+	srv, err := NewHTTPServer(Options{Mode: Reset}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	if _, err := http.Get(srv.URL); err == nil {
+		t.Error("request to resetting server succeeded, want error")
+	}
+}
+
+func TestHTTPServerBadCert(t *testing.T) {
+	srv, err := NewHTTPServer(Options{Mode: BadCert}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	// The server's own client trusts the certificate, so any failure is down to the expiry.
+	_, err = srv.Client().Get(srv.URL)
+	var certErr x509.CertificateInvalidError
+	if !errors.As(err, &certErr) || certErr.Reason != x509.Expired {
+		t.Errorf("got error %v, want expired certificate", err)
+	}
+}
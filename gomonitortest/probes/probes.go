@@ -0,0 +1,172 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package probes provides in-process servers that checks can be pointed at during tests. Every
+// server listens on a random loopback port and can be configured to misbehave in the ways real
+// services do, so checks can be integration-tested without touching the network.
+package probes
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Mode selects the behavior of a test server.
+type Mode int
+
+const (
+	// Normal answers every request correctly and immediately
+	Normal Mode = iota
+	// Slow waits Options.Delay before answering
+	Slow
+	// Reset aborts every connection with a TCP RST. Servers without connections (DNS over UDP)
+	// answer with a server failure instead.
+	Reset
+	// BadCert serves TLS with an expired, self-signed certificate (HTTP only)
+	BadCert
+)
+
+// String returns a string representation of a Mode
+func (m Mode) String() string {
+	switch m {
+	case Normal:
+		return "Normal"
+	case Slow:
+		return "Slow"
+	case Reset:
+		return "Reset"
+	case BadCert:
+		return "BadCert"
+	default:
+		return fmt.Sprintf("Mode(%d)", m)
+	}
+}
+
+// Options configures the failure behavior of a test server.
+// - `Mode` selects how the server behaves.
+// - `Delay` is how long a Slow server waits before answering.
+type Options struct {
+	Mode  Mode
+	Delay time.Duration
+}
+
+// wait sleeps for the configured delay when the server is in Slow mode.
+func (o Options) wait() {
+	if o.Mode == Slow {
+		time.Sleep(o.Delay)
+	}
+}
+
+// reset closes conn so the peer sees a connection reset rather than an orderly shutdown.
+func reset(conn net.Conn) {
+	if tc, ok := conn.(*net.TCPConn); ok {
+		_ = tc.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+// tcpServer is the accept loop shared by the connection oriented servers.
+type tcpServer struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+}
+
+// start begins accepting connections on a loopback port and hands each one to handle in its own
+// goroutine.
+func (s *tcpServer) start(handle func(net.Conn)) error {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	s.conns = make(map[net.Conn]struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				handle(conn)
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+			}()
+		}
+	}()
+	return nil
+}
+
+// Addr returns the host:port the server is listening on.
+func (s *tcpServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server, drops any open connections and waits for their handlers to return.
+func (s *tcpServer) Close() {
+	_ = s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// TCPEchoServer is a TCP server that writes back everything it receives.
+type TCPEchoServer struct {
+	tcpServer
+	opts Options
+}
+
+// NewTCPEchoServer starts a TCP echo server with the given options.
+func NewTCPEchoServer(opts Options) (*TCPEchoServer, error) {
+	s := &TCPEchoServer{opts: opts}
+	if err := s.start(s.handle); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *TCPEchoServer) handle(conn net.Conn) {
+	if s.opts.Mode == Reset {
+		reset(conn)
+		return
+	}
+	defer conn.Close()
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		s.opts.wait()
+		if _, err := conn.Write(buf[:n]); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package probes
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestModeString(t *testing.T) {
+	testCases := []struct {
+		name string
+		mode Mode
+		want string
+	}{
+		{"Test Normal", Normal, "Normal"},
+		{"Test Slow", Slow, "Slow"},
+		{"Test Reset", Reset, "Reset"},
+		{"Test BadCert", BadCert, "BadCert"},
+		{"Test Non-Exist", Mode(100), "Mode(100)"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.mode.String()
+			if got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTCPEchoServer(t *testing.T) {
+	srv, err := NewTCPEchoServer(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "ping" {
+		t.Errorf("got %q, want %q", buf, "ping")
+	}
+}
+
+func TestTCPEchoServerSlow(t *testing.T) {
+	delay := 50 * time.Millisecond
+	srv, err := NewTCPEchoServer(Options{Mode: Slow, Delay: delay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if _, err := conn.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("echo took %v, want at least %v", elapsed, delay)
+	}
+}
+
+func TestTCPEchoServerReset(t *testing.T) {
+	srv, err := NewTCPEchoServer(Options{Mode: Reset})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	// The reset can race the end of the handshake and surface from Dial.
+	conn, err := net.Dial("tcp", srv.Addr())
+	if err == nil {
+		defer conn.Close()
+		_, err = conn.Read(make([]byte, 1))
+	}
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("got error %v, want connection reset", err)
+	}
+}
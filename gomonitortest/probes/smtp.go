@@ -0,0 +1,128 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package probes
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Message is a mail message accepted by SMTPServer.
+type Message struct {
+	From string
+	To   []string
+	Data string
+}
+
+// SMTPServer is a minimal SMTP server that accepts and records every message it is sent.
+type SMTPServer struct {
+	tcpServer
+	opts     Options
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewSMTPServer starts an SMTP server with the given options. A Slow server delays its greeting,
+// which is where real servers under load stall.
+func NewSMTPServer(opts Options) (*SMTPServer, error) {
+	s := &SMTPServer{opts: opts}
+	if err := s.start(s.handle); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Messages returns the messages received so far.
+func (s *SMTPServer) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *SMTPServer) handle(conn net.Conn) {
+	if s.opts.Mode == Reset {
+		reset(conn)
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+
+	s.opts.wait()
+	if tp.PrintfLine("220 localhost gomonitor probes ESMTP") != nil {
+		return
+	}
+
+	var msg Message
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			err = tp.PrintfLine("250 localhost")
+		case "EHLO":
+			err = tp.PrintfLine("250-localhost\r\n250 8BITMIME")
+		case "MAIL":
+			msg = Message{From: smtpPath(arg)}
+			err = tp.PrintfLine("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, smtpPath(arg))
+			err = tp.PrintfLine("250 OK")
+		case "DATA":
+			if err = tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>"); err != nil {
+				return
+			}
+			data, rerr := tp.ReadDotBytes()
+			if rerr != nil {
+				return
+			}
+			msg.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = Message{}
+			err = tp.PrintfLine("250 OK")
+		case "RSET":
+			msg = Message{}
+			err = tp.PrintfLine("250 OK")
+		case "NOOP":
+			err = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 Bye")
+			return
+		default:
+			err = tp.PrintfLine("502 Command not implemented")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// smtpPath extracts the address from a "FROM:<addr> [params]" or "TO:<addr>" argument.
+func smtpPath(arg string) string {
+	_, path, found := strings.Cut(arg, ":")
+	if !found {
+		path = arg
+	}
+	path, _, _ = strings.Cut(strings.TrimSpace(path), " ")
+	return strings.Trim(path, "<>")
+}
@@ -0,0 +1,45 @@
+package probes
+
+import (
+	"net/smtp"
+	"testing"
+)
+
+func TestSMTPServer(t *testing.T) {
+	srv, err := NewSMTPServer(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	body := []byte("Subject: test\r\n\r\nhello\r\n")
+	if err := smtp.SendMail(srv.Addr(), nil, "from@example.test", []string{"to@example.test"}, body); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages, want 1", len(msgs))
+	}
+	if msgs[0].From != "from@example.test" {
+		t.Errorf("got from %s, want from@example.test", msgs[0].From)
+	}
+	if len(msgs[0].To) != 1 || msgs[0].To[0] != "to@example.test" {
+		t.Errorf("got to %v, want [to@example.test]", msgs[0].To)
+	}
+	if msgs[0].Data != "Subject: test\n\nhello\n" {
+		t.Errorf("got data %q", msgs[0].Data)
+	}
+}
+
+func TestSMTPServerReset(t *testing.T) {
+	srv, err := NewSMTPServer(Options{Mode: Reset})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	if _, err := smtp.Dial(srv.Addr()); err == nil {
+		t.Error("dial to resetting server succeeded, want error")
+	}
+}
@@ -0,0 +1,82 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// StateStore keeps values between plugin runs. A *state.Store implements it; it is an
+// interface here because the state package builds on this one.
+type StateStore interface {
+	// Get decodes the value stored under key into v and reports whether there was one.
+	Get(key string, v any) (bool, error)
+	// Put stores v under key. A ttl of 0 keeps the value until it is replaced or deleted.
+	Put(key string, v any, ttl time.Duration) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(key string) error
+}
+
+// GracePeriod downgrades Critical results to Warning for the first part of a new failure, so
+// brief blips page less while sustained outages still escalate. When the failure started is
+// kept in a StateStore between runs.
+// - `Store` records when the current failure was first seen Critical, under the key "grace:" followed by Key.
+// - `Key` names the check, so several checks can share a Store.
+// - `Duration` is how long a failure stays Warning before it is reported as Critical.
+// - `Clock` dates the failure. nil means SystemClock.
+type GracePeriod struct {
+	Store    StateStore
+	Key      string
+	Duration time.Duration
+	Clock    Clock
+}
+
+// Apply updates the Store with the result's ExitCode and downgrades a Critical result that is
+// still within the grace period to Warning, noting so in the long output. An OK result ends
+// the failure; Warning and Unknown results leave it running, so a failure that briefly changes
+// state does not restart its grace period. If the Store cannot be read or written the result
+// is left unchanged and the error is returned.
+func (g GracePeriod) Apply(cr *CheckResult) error {
+	key := "grace:" + g.Key
+	if cr.ExitCode == OK {
+		if err := g.Store.Delete(key); err != nil {
+			return fmt.Errorf("gomonitor: clearing grace period state: %w", err)
+		}
+		return nil
+	}
+	if cr.ExitCode != Critical {
+		return nil
+	}
+
+	now := ClockOrSystem(g.Clock).Now()
+	var since time.Time
+	found, err := g.Store.Get(key, &since)
+	if err == nil && !found {
+		since = now
+		err = g.Store.Put(key, since, 0)
+	}
+	if err != nil {
+		return fmt.Errorf("gomonitor: grace period state: %w", err)
+	}
+	if failing := now.Sub(since); failing < g.Duration {
+		cr.ExitCode = Warning
+		cr.AddLongOutput(fmt.Sprintf("Critical for %s, reported as Warning during the %s grace period",
+			failing.Truncate(time.Second), g.Duration))
+	}
+	return nil
+}
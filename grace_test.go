@@ -0,0 +1,125 @@
+package gomonitor
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// memStore is a StateStore that keeps JSON values in memory. A non-nil err fails every call.
+type memStore struct {
+	values map[string][]byte
+	err    error
+}
+
+func (s *memStore) Get(key string, v any) (bool, error) {
+	raw, ok := s.values[key]
+	if s.err != nil || !ok {
+		return false, s.err
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+func (s *memStore) Put(key string, v any, ttl time.Duration) error {
+	if s.err != nil {
+		return s.err
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.values == nil {
+		s.values = make(map[string][]byte)
+	}
+	s.values[key] = raw
+	return nil
+}
+
+func (s *memStore) Delete(key string) error {
+	if s.err != nil {
+		return s.err
+	}
+	delete(s.values, key)
+	return nil
+}
+
+func TestGracePeriod(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	testCases := []struct {
+		name      string
+		since     time.Time
+		exitCode  ExitCode
+		wantState ExitCode
+		wantKept  bool
+	}{
+		{"Test new failure", time.Time{}, Critical, Warning, true},
+		{"Test within grace period", now.Add(-time.Minute), Critical, Warning, true},
+		{"Test grace period over", now.Add(-time.Hour), Critical, Critical, true},
+		{"Test recovered", now.Add(-time.Hour), OK, OK, false},
+		{"Test warning keeps failure", now.Add(-time.Hour), Warning, Warning, true},
+		{"Test unknown keeps failure", now.Add(-time.Hour), Unknown, Unknown, true},
+		{"Test OK without failure", time.Time{}, OK, OK, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &memStore{}
+			g := GracePeriod{Store: store, Key: "web", Duration: 5 * time.Minute, Clock: NewManualClock(now)}
+			if !tc.since.IsZero() {
+				if err := store.Put("grace:web", tc.since, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			result := NewCheckResult()
+			result.SetResult(tc.exitCode, "Test message")
+			if err := g.Apply(result); err != nil {
+				t.Fatal(err)
+			}
+			if result.ExitCode != tc.wantState {
+				t.Errorf("got exitCode %s, want %s", result.ExitCode, tc.wantState)
+			}
+			if _, ok := store.values["grace:web"]; ok != tc.wantKept {
+				t.Errorf("got failure kept %t, want %t", ok, tc.wantKept)
+			}
+		})
+	}
+}
+
+func TestGracePeriodLongOutput(t *testing.T) {
+	g := GracePeriod{Store: &memStore{}, Duration: 5 * time.Minute, Clock: NewManualClock(time.Unix(1700000000, 0))}
+	result := NewCheckResult()
+	result.SetResult(Critical, "down")
+	if err := g.Apply(result); err != nil {
+		t.Fatal(err)
+	}
+	want := "Warning - down\nCritical for 0s, reported as Warning during the 5m0s grace period"
+	if got := result.FormatResult(); got != want {
+		t.Errorf("FormatResult got %q, want %q", got, want)
+	}
+}
+
+func TestGracePeriodStoreError(t *testing.T) {
+	g := GracePeriod{Store: &memStore{err: errors.New("store down")}, Duration: 5 * time.Minute}
+	result := NewCheckResult()
+	result.SetResult(Critical, "down")
+	if err := g.Apply(result); err == nil || result.ExitCode != Critical {
+		t.Errorf("got error %v and exitCode %s, want an error and Critical", err, result.ExitCode)
+	}
+}
+
+func TestGracePeriodExpires(t *testing.T) {
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	g := GracePeriod{Store: &memStore{}, Duration: 5 * time.Minute, Clock: clock}
+	for _, want := range []ExitCode{Warning, Warning, Critical} {
+		result := NewCheckResult()
+		result.SetResult(Critical, "down")
+		if err := g.Apply(result); err != nil {
+			t.Fatal(err)
+		}
+		if result.ExitCode != want {
+			t.Errorf("got exitCode %s at %s, want %s", result.ExitCode, clock.Now().UTC(), want)
+		}
+		clock.Advance(150 * time.Second)
+	}
+}
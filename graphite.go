@@ -0,0 +1,88 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// graphiteTimeout bounds connecting to and writing to a Graphite server.
+const graphiteTimeout = 10 * time.Second
+
+// Graphite renders the performance metrics in the Graphite plaintext protocol, one
+// "prefix.metric value timestamp" line per metric. Dots in perfdata names are kept as path
+// separators and other characters Graphite does not accept in a path are replaced with
+// underscores. Metrics without a finite value are left out. An empty prefix writes bare
+// metric names.
+func (cr *CheckResult) Graphite(prefix string, ts time.Time) string {
+	var b strings.Builder
+	suffix := " " + strconv.FormatInt(ts.Unix(), 10) + "\n"
+	for name, metric := range cr.Metrics() {
+		if metric.Unknown || math.IsNaN(metric.Value) || math.IsInf(metric.Value, 0) {
+			continue
+		}
+		path := graphitePath(name)
+		if prefix != "" {
+			path = strings.TrimSuffix(prefix, ".") + "." + path
+		}
+		b.WriteString(path + " " + strconv.FormatFloat(metric.Value, 'f', -1, 64) + suffix)
+	}
+	return b.String()
+}
+
+// SendToGraphite sends the performance metrics to the Graphite server at addr, timestamped
+// now, as formatted by Graphite. The address is "host:port" or "tcp://host:port" for TCP and
+// "udp://host:port" for UDP. Nothing is sent when there are no metrics.
+func (cr *CheckResult) SendToGraphite(addr, prefix string) error {
+	network := "tcp"
+	if rest, ok := strings.CutPrefix(addr, "udp://"); ok {
+		network, addr = "udp", rest
+	} else {
+		addr = strings.TrimPrefix(addr, "tcp://")
+	}
+	payload := cr.Graphite(prefix, time.Now())
+	if payload == "" {
+		return nil
+	}
+	conn, err := net.DialTimeout(network, addr, graphiteTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetWriteDeadline(time.Now().Add(graphiteTimeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write([]byte(payload))
+	return err
+}
+
+// graphitePath turns a perfdata name into a Graphite metric path.
+func graphitePath(name string) string {
+	path := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	return strings.Trim(path, ".")
+}
@@ -0,0 +1,90 @@
+package gomonitor
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphite(t *testing.T) {
+	result := NewCheckResult()
+	result.AddPerformanceData("rta", PerformanceMetric{Value: 0.25, UnitOM: UOMSeconds})
+	result.AddPerformanceData("disk /var", PerformanceMetric{Value: 91})
+	result.AddPerformanceData("cpu.user", PerformanceMetric{Value: 3.5})
+	result.AddPerformanceData("load", PerformanceMetric{Unknown: true})
+
+	ts := time.Unix(1700000000, 0)
+	want := "nagios.web01.rta 0.25 1700000000\n" +
+		"nagios.web01.disk__var 91 1700000000\n" +
+		"nagios.web01.cpu.user 3.5 1700000000\n"
+	if got := result.Graphite("nagios.web01.", ts); got != want {
+		t.Errorf("Graphite got %q, want %q", got, want)
+	}
+	if got := result.Graphite("", ts); !strings.HasPrefix(got, "rta 0.25 ") {
+		t.Errorf("Graphite without prefix got %q", got)
+	}
+}
+
+func TestSendToGraphite(t *testing.T) {
+	result := NewCheckResult()
+	result.AddPerformanceData("rta", PerformanceMetric{Value: 0.25})
+
+	t.Run("Test TCP", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		lines := make(chan string, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				lines <- err.Error()
+				return
+			}
+			defer conn.Close()
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			lines <- line
+		}()
+		if err := result.SendToGraphite(ln.Addr().String(), "web01"); err != nil {
+			t.Fatal(err)
+		}
+		if got := <-lines; !strings.HasPrefix(got, "web01.rta 0.25 ") {
+			t.Errorf("got %q, want the rta metric", got)
+		}
+	})
+
+	t.Run("Test UDP", func(t *testing.T) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pc.Close()
+		if err := result.SendToGraphite("udp://"+pc.LocalAddr().String(), "web01"); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 512)
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); !strings.HasPrefix(got, "web01.rta 0.25 ") {
+			t.Errorf("got %q, want the rta metric", got)
+		}
+	})
+
+	t.Run("Test connection refused", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ln.Addr().String()
+		ln.Close()
+		if err := result.SendToGraphite("tcp://"+addr, "web01"); err == nil {
+			t.Error("SendToGraphite to a closed port returned no error")
+		}
+	})
+}
@@ -0,0 +1,97 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SubCheck is a single check run as part of a CheckGroup.
+// - `Name` identifies the check in the combined message and prefixes its metrics.
+// - `Run` performs the check against the group's shared resource.
+type SubCheck[T any] struct {
+	Name string
+	Run  func(resource T) *CheckResult
+}
+
+// CheckGroup runs several checks against one shared resource, such as a database connection
+// or an SNMP session, that is expensive to set up.
+// - `Setup` creates the shared resource. It is called once per Run.
+// - `Teardown` releases the resource once every check has run. It may be nil.
+// - `Checks` are run in order against the resource.
+// - `Separator` joins each check's name to its metric names. Empty means "_".
+type CheckGroup[T any] struct {
+	Setup     func() (T, error)
+	Teardown  func(resource T) error
+	Checks    []SubCheck[T]
+	Separator string
+}
+
+// Run sets up the shared resource, runs every check against it, tears it down and returns the
+// combined result. The combined ExitCode is the worst of the individual results, the message
+// lists each check's message prefixed by its name, and each metric is renamed to
+// "<check><Separator><metric>". A failed setup returns an Unknown result without running any
+// checks.
+func (g *CheckGroup[T]) Run() *CheckResult {
+	result := NewCheckResult()
+	resource, err := g.Setup()
+	if err != nil {
+		result.SetResult(Unknown, fmt.Sprintf("setup failed: %v", err))
+		return result
+	}
+
+	sep := g.Separator
+	if sep == "" {
+		sep = "_"
+	}
+	var messages []string
+	for _, check := range g.Checks {
+		messages = mergeResult(result, messages, check.Name, sep, "", check.Run(resource))
+	}
+
+	if g.Teardown != nil {
+		if err := g.Teardown(resource); err != nil {
+			result.ExitCode = worse(result.ExitCode, Unknown)
+			messages = append(messages, fmt.Sprintf("teardown failed: %v", err))
+		}
+	}
+	result.Message = strings.Join(messages, ", ")
+	return result
+}
+
+// severity orders exit codes from least to most severe: OK, Unknown, Warning, Critical.
+func severity(ec ExitCode) int {
+	switch ec {
+	case OK:
+		return 0
+	case Unknown:
+		return 1
+	case Warning:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// worse returns whichever of a and b is more severe.
+func worse(a, b ExitCode) ExitCode {
+	if severity(b) > severity(a) {
+		return b
+	}
+	return a
+}
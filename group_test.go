@@ -0,0 +1,107 @@
+package gomonitor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckGroupRun(t *testing.T) {
+	setups, teardowns := 0, 0
+	group := &CheckGroup[string]{
+		Setup: func() (string, error) {
+			setups++
+			return "conn", nil
+		},
+		Teardown: func(resource string) error {
+			teardowns++
+			return nil
+		},
+		Checks: []SubCheck[string]{
+			{Name: "users", Run: func(resource string) *CheckResult {
+				result := NewCheckResult()
+				result.SetResult(OK, "42 users via "+resource)
+				result.AddPerformanceData("count", PerformanceMetric{Value: 42})
+				return result
+			}},
+			{Name: "locks", Run: func(resource string) *CheckResult {
+				result := NewCheckResult()
+				result.SetResult(Warning, "7 locks")
+				result.AddPerformanceData("count", PerformanceMetric{Value: 7})
+				return result
+			}},
+		},
+	}
+
+	result := group.Run()
+	if setups != 1 || teardowns != 1 {
+		t.Errorf("got %d setups and %d teardowns, want 1 of each", setups, teardowns)
+	}
+	if result.ExitCode != Warning {
+		t.Errorf("got exitCode %s, want Warning", result.ExitCode)
+	}
+	if want := "users: 42 users via conn, locks: 7 locks"; result.Message != want {
+		t.Errorf("got message %q, want %q", result.Message, want)
+	}
+	if len(result.PerfOrder) != 2 || result.PerfOrder[0] != "users_count" || result.PerfOrder[1] != "locks_count" {
+		t.Errorf("got metrics %v, want [users_count locks_count]", result.PerfOrder)
+	}
+}
+
+func TestCheckGroupFailures(t *testing.T) {
+	ran := false
+	group := &CheckGroup[int]{
+		Setup: func() (int, error) { return 0, errors.New("refused") },
+		Checks: []SubCheck[int]{
+			{Name: "a", Run: func(int) *CheckResult { ran = true; return NewCheckResult() }},
+		},
+	}
+	result := group.Run()
+	if ran || result.ExitCode != Unknown || result.Message != "setup failed: refused" {
+		t.Errorf("got %s %q (ran=%t), want Unknown setup failure without running checks", result.ExitCode, result.Message, ran)
+	}
+
+	group.Setup = func() (int, error) { return 1, nil }
+	group.Teardown = func(int) error { return errors.New("close failed") }
+	result = group.Run()
+	if result.ExitCode != Unknown || result.Message != "a: , teardown failed: close failed" {
+		t.Errorf("got %s %q, want Unknown teardown failure", result.ExitCode, result.Message)
+	}
+}
+
+func TestCheckGroupSeparator(t *testing.T) {
+	group := &CheckGroup[int]{
+		Setup:     func() (int, error) { return 0, nil },
+		Separator: ".",
+		Checks: []SubCheck[int]{
+			{Name: "users", Run: func(int) *CheckResult {
+				result := NewCheckResult()
+				result.AddPerformanceData("count", PerformanceMetric{Value: 42})
+				return result
+			}},
+		},
+	}
+	if result := group.Run(); len(result.PerfOrder) != 1 || result.PerfOrder[0] != "users.count" {
+		t.Errorf("got metrics %v, want [users.count]", result.PerfOrder)
+	}
+}
+
+func TestWorse(t *testing.T) {
+	testCases := []struct {
+		name string
+		a, b ExitCode
+		want ExitCode
+	}{
+		{"Test OK Warning", OK, Warning, Warning},
+		{"Test Critical Warning", Critical, Warning, Critical},
+		{"Test Unknown OK", Unknown, OK, Unknown},
+		{"Test Warning Unknown", Warning, Unknown, Warning},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := worse(tc.a, tc.b); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
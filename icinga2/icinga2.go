@@ -0,0 +1,166 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package icinga2 submits check results to Icinga 2 through its REST API, so a program built
+// with gomonitor can report passive results as well as run as an active plugin.
+package icinga2
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// maxResponseSize bounds each API response the client will read.
+const maxResponseSize = 1 << 20
+
+// Client submits results to a single Icinga 2 API endpoint.
+// - `Endpoint` is the API's base URL, such as "https://icinga.example.com:5665".
+// - `Username` and `Password` are an ApiUser's credentials, sent with Basic authentication. Leave them empty to authenticate with a client certificate in TLSConfig instead.
+// - `TLSConfig` configures the connection when HTTPClient is nil, such as the RootCAs that trust the Icinga CA or a client certificate. nil uses the system roots.
+// - `HTTPClient` sends the requests. nil means a client using TLSConfig.
+// - `CheckSource` is reported as the result's check source. Empty leaves it to Icinga.
+// - `TTL` marks the object's state as stale when no new result is submitted within it. 0 sets no TTL.
+type Client struct {
+	Endpoint    string
+	Username    string
+	Password    string
+	TLSConfig   *tls.Config
+	HTTPClient  *http.Client
+	CheckSource string
+	TTL         time.Duration
+}
+
+// request is the body of a process-check-result action.
+type request struct {
+	Type            string            `json:"type"`
+	Filter          string            `json:"filter"`
+	FilterVars      map[string]string `json:"filter_vars"`
+	ExitStatus      int               `json:"exit_status"`
+	PluginOutput    string            `json:"plugin_output"`
+	PerformanceData []string          `json:"performance_data,omitempty"`
+	CheckSource     string            `json:"check_source,omitempty"`
+	TTL             float64           `json:"ttl,omitempty"`
+}
+
+// response is the body Icinga answers an action with.
+type response struct {
+	Results []struct {
+		Code   float64 `json:"code"`
+		Status string  `json:"status"`
+	} `json:"results"`
+	Error  float64 `json:"error"`
+	Status string  `json:"status"`
+}
+
+// Submit reports cr as the current result of service on host. An empty service submits a host
+// result, for which OK is reported as UP and every other state as DOWN, the only two states
+// Icinga accepts for hosts. The output is cr's summary and long output, and each perfdata
+// entry is sent as written by FormatResult.
+func (c *Client) Submit(ctx context.Context, host, service string, cr *gomonitor.CheckResult) error {
+	switch {
+	case host == "":
+		return errors.New("no host given")
+	case cr == nil && service == "":
+		return fmt.Errorf("no check result given for host %q", host)
+	case cr == nil:
+		return fmt.Errorf("no check result given for service %q on host %q", service, host)
+	}
+	body := request{
+		Type:            "Host",
+		Filter:          "host.name == h",
+		FilterVars:      map[string]string{"h": host},
+		ExitStatus:      cr.ExitCode.Int(),
+		PluginOutput:    cr.PluginOutput(),
+		PerformanceData: cr.PerfdataEntries(),
+		CheckSource:     c.CheckSource,
+		TTL:             c.TTL.Seconds(),
+	}
+	if service == "" {
+		body.ExitStatus = min(body.ExitStatus, 1)
+	} else {
+		body.Type = "Service"
+		body.Filter = "host.name == h && service.name == s"
+		body.FilterVars["s"] = service
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(c.Endpoint, "/")+"/v1/actions/process-check-result", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+
+	var result response
+	decodeErr := json.Unmarshal(raw, &result)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && result.Status != "" {
+			return fmt.Errorf("icinga2 API error %s: %s", resp.Status, result.Status)
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("decoding response: %w", decodeErr)
+	}
+	if len(result.Results) == 0 {
+		return errors.New("icinga2 API returned no results")
+	}
+	for _, r := range result.Results {
+		if r.Code != http.StatusOK {
+			return fmt.Errorf("icinga2 API error %.0f: %s", r.Code, r.Status)
+		}
+	}
+	return nil
+}
+
+// client returns the HTTP client requests are sent with.
+func (c *Client) client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	if c.TLSConfig == nil {
+		return http.DefaultClient
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = c.TLSConfig
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,130 @@
+package icinga2
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// newFakeAPI returns a TLS Icinga 2 API that records each request body and answers with
+// status and reply, and a client trusting it.
+func newFakeAPI(t *testing.T, status int, reply string, got *request) (*httptest.Server, *Client) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/actions/process-check-result" || r.Method != http.MethodPost {
+			t.Errorf("got request %s %s", r.Method, r.URL.Path)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "monitor" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		raw, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(raw, got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(srv.Close)
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	return srv, &Client{
+		Endpoint:  srv.URL + "/",
+		Username:  "monitor",
+		Password:  "secret",
+		TLSConfig: &tls.Config{RootCAs: roots},
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	const ok = `{"results":[{"code":200.0,"status":"Successfully processed check result."}]}`
+	testCases := []struct {
+		name       string
+		service    string
+		exitCode   gomonitor.ExitCode
+		wantType   string
+		wantFilter string
+		wantStatus int
+	}{
+		{"Test service warning", "disk", gomonitor.Warning, "Service", "host.name == h && service.name == s", 1},
+		{"Test service unknown", "disk", gomonitor.Unknown, "Service", "host.name == h && service.name == s", 3},
+		{"Test host up", "", gomonitor.OK, "Host", "host.name == h", 0},
+		{"Test host down", "", gomonitor.Critical, "Host", "host.name == h", 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got request
+			_, client := newFakeAPI(t, http.StatusOK, ok, &got)
+			client.CheckSource = "poller01"
+			client.TTL = 5 * time.Minute
+
+			cr := gomonitor.NewCheckResult()
+			cr.SetResult(tc.exitCode, "disk 91% used")
+			cr.AddLongOutput("/var 91%")
+			cr.AddPerformanceData("used", gomonitor.PerformanceMetric{Value: 91, UnitOM: "%"})
+			if err := client.Submit(context.Background(), "web01", tc.service, cr); err != nil {
+				t.Fatalf("Submit returned %v", err)
+			}
+			if got.Type != tc.wantType || got.Filter != tc.wantFilter || got.ExitStatus != tc.wantStatus {
+				t.Errorf("got %s %q exit_status %d, want %s %q %d", got.Type, got.Filter, got.ExitStatus,
+					tc.wantType, tc.wantFilter, tc.wantStatus)
+			}
+			if got.FilterVars["h"] != "web01" || got.FilterVars["s"] != tc.service {
+				t.Errorf("got filter_vars %v", got.FilterVars)
+			}
+			if !strings.HasSuffix(got.PluginOutput, "disk 91% used\n/var 91%") {
+				t.Errorf("got plugin_output %q", got.PluginOutput)
+			}
+			if want := []string{cr.PerfdataEntries()[0]}; !slices.Equal(got.PerformanceData, want) {
+				t.Errorf("got performance_data %q, want %q", got.PerformanceData, want)
+			}
+			if got.CheckSource != "poller01" || got.TTL != 300 {
+				t.Errorf("got check_source %q ttl %v, want poller01 300", got.CheckSource, got.TTL)
+			}
+		})
+	}
+}
+
+func TestSubmitErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		status  int
+		reply   string
+		wantErr string
+	}{
+		{"Test no objects", http.StatusNotFound, `{"error":404.0,"status":"No objects found."}`, "No objects found."},
+		{"Test plain error", http.StatusInternalServerError, `oops`, "unexpected status"},
+		{"Test result error", http.StatusOK, `{"results":[{"code":500.0,"status":"Invalid exit status"}]}`, "Invalid exit status"},
+		{"Test no results", http.StatusOK, `{"results":[]}`, "no results"},
+		{"Test bad response", http.StatusOK, `<html>`, "decoding response"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got request
+			_, client := newFakeAPI(t, tc.status, tc.reply, &got)
+			err := client.Submit(context.Background(), "web01", "disk", gomonitor.NewCheckResult())
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	if err := (&Client{}).Submit(context.Background(), "", "disk", gomonitor.NewCheckResult()); err == nil {
+		t.Error("Submit without a host returned no error")
+	}
+	if err := (&Client{}).Submit(context.Background(), "web01", "disk", nil); err == nil ||
+		err.Error() != `no check result given for service "disk" on host "web01"` {
+		t.Errorf("got error %v, want no check result", err)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InfluxOptions configures InfluxDB line protocol output.
+// - `Measurement` is the measurement of the metric lines. "" means "nagios". The state line uses it with a "_state" suffix.
+// - `Tags` are added to every line, after the CheckResult's Tags.
+// - `Time` is the timestamp of every line. The zero Time means now.
+type InfluxOptions struct {
+	Measurement string
+	Tags        map[string]string
+	Time        time.Time
+}
+
+// InfluxLineProtocol renders the result as InfluxDB line protocol, in the layout Telegraf's
+// Nagios parser uses. The first line holds the state and message in the "state" and
+// "service_output" fields. Each performance metric is a line tagged with its "perfdata" name,
+// or its Series when set, its "unit" and its Labels, carrying its "value" and, when set,
+// "warning_lt", "warning_gt", "critical_lt", "critical_gt", "min" and "max" fields. Thresholds
+// are written as range bounds, so a plain Warn limit becomes warning_lt=0 and warning_gt=Warn;
+// a Warn of 0 is only written when WarnSet is true, and likewise for Crit. Metrics without a
+// finite value are left out, as line protocol cannot represent them.
+func (cr *CheckResult) InfluxLineProtocol(opts InfluxOptions) string {
+	if opts.Measurement == "" {
+		opts.Measurement = "nagios"
+	}
+	if opts.Time.IsZero() {
+		opts.Time = time.Now()
+	}
+	tags := make(map[string]string)
+	maps.Copy(tags, cr.Tags)
+	maps.Copy(tags, opts.Tags)
+	ts := " " + strconv.FormatInt(opts.Time.UnixNano(), 10) + "\n"
+
+	var b strings.Builder
+	b.WriteString(influxEscape(opts.Measurement+"_state", ", ") + influxTags(tags))
+	b.WriteString(" state=" + strconv.Itoa(cr.ExitCode.Int()) + "i")
+	b.WriteString(`,service_output="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(cr.Message) + `"`)
+	b.WriteString(ts)
+
+	for name, metric := range cr.Metrics() {
+		if metric.Unknown || math.IsNaN(metric.Value) || math.IsInf(metric.Value, 0) {
+			continue
+		}
+		lineTags := maps.Clone(tags)
+		maps.Copy(lineTags, metric.Labels)
+		lineTags["perfdata"] = name
+		if metric.Series != "" {
+			lineTags["perfdata"] = metric.Series
+		}
+		lineTags["unit"] = metric.UnitOM
+
+		fields := []string{"value=" + influxNumber(metric.Value, metric.Integer)}
+		bounds := func(prefix string, limit float64, set bool, r *Range) {
+			if r == nil && (limit != 0 || set) {
+				r = &Range{End: limit}
+			}
+			if r == nil {
+				return
+			}
+			if !math.IsInf(r.Start, 0) {
+				fields = append(fields, prefix+"_lt="+influxNumber(r.Start, false))
+			}
+			if !math.IsInf(r.End, 0) {
+				fields = append(fields, prefix+"_gt="+influxNumber(r.End, false))
+			}
+		}
+		bounds("warning", metric.Warn, metric.WarnSet, metric.WarnRange)
+		bounds("critical", metric.Crit, metric.CritSet, metric.CritRange)
+		if metric.Min != 0 || metric.MinSet {
+			fields = append(fields, "min="+influxNumber(metric.Min, false))
+		}
+		if metric.Max != 0 || metric.MaxSet {
+			fields = append(fields, "max="+influxNumber(metric.Max, false))
+		}
+		b.WriteString(influxEscape(opts.Measurement, ", ") + influxTags(lineTags) + " " + strings.Join(fields, ",") + ts)
+	}
+	return b.String()
+}
+
+// influxTags renders tags as a sorted ",key=value" tag set. Tags with an empty key or value
+// are left out, as line protocol does not allow them.
+func influxTags(tags map[string]string) string {
+	var b strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		if k == "" || tags[k] == "" {
+			continue
+		}
+		b.WriteString("," + influxEscape(k, ",= ") + "=" + influxEscape(tags[k], ",= "))
+	}
+	return b.String()
+}
+
+// influxEscape backslash-escapes the special characters in a measurement, tag key or tag
+// value. Newlines cannot be escaped and are replaced with spaces, which are.
+func influxEscape(s, special string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", " ")
+	for _, c := range special {
+		s = strings.ReplaceAll(s, string(c), `\`+string(c))
+	}
+	return s
+}
+
+// influxNumber formats a field value as a float, or as an integer when integer is set.
+func influxNumber(v float64, integer bool) string {
+	if integer {
+		return strconv.FormatInt(int64(math.Round(v)), 10) + "i"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,54 @@
+package gomonitor
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestInfluxLineProtocol(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(Warning, `disk "C:" at 91%`)
+	result.SetTag("env", "prod")
+	result.AddPerformanceData("C: used", PerformanceMetric{Value: 91.5, Warn: 90, CritRange: &Range{Start: 10, End: 95}, MinSet: true, Max: 100, UnitOM: UOMPercent})
+	result.AddPerformanceData("files", PerformanceMetric{Value: 12, Integer: true, WarnRange: &Range{Start: 20, End: math.Inf(1)}})
+	result.AddPerformanceData("errors", PerformanceMetric{Value: 0, Integer: true, CritSet: true})
+	result.AddPerformanceData("load", PerformanceMetric{Unknown: true})
+	result.AddPerformanceData("db01::load1", PerformanceMetric{Value: 0.5, Series: "load1", Labels: map[string]string{"host": "db01"}})
+
+	got := result.InfluxLineProtocol(InfluxOptions{
+		Tags: map[string]string{"host": "web 01", "empty": ""},
+		Time: time.Unix(1700000000, 5),
+	})
+	want := `nagios_state,env=prod,host=web\ 01 state=1i,service_output="disk \"C:\" at 91%" 1700000000000000005
+nagios,env=prod,host=web\ 01,perfdata=C:\ used,unit=% value=91.5,warning_lt=0,warning_gt=90,critical_lt=10,critical_gt=95,min=0,max=100 1700000000000000005
+nagios,env=prod,host=web\ 01,perfdata=files value=12i,warning_lt=20 1700000000000000005
+nagios,env=prod,host=web\ 01,perfdata=errors value=0i,critical_lt=0,critical_gt=0 1700000000000000005
+nagios,env=prod,host=db01,perfdata=load1 value=0.5 1700000000000000005
+`
+	if got != want {
+		t.Errorf("InfluxLineProtocol got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestInfluxEscape(t *testing.T) {
+	testCases := []struct {
+		name    string
+		in      string
+		special string
+		want    string
+	}{
+		{"Test plain", "cpu", ",= ", "cpu"},
+		{"Test tag specials", "a,b=c d", ",= ", `a\,b\=c\ d`},
+		{"Test measurement keeps equals", "a=b c", ", ", `a=b\ c`},
+		{"Test newline", "a\nb", ",= ", `a\ b`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := influxEscape(tc.in, tc.special); got != tc.want {
+				t.Errorf("influxEscape(%q) got %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// jsonResult is the JSON form of a CheckResult. Metrics are a list in PerfOrder so the order
+// survives the round trip.
+type jsonResult struct {
+	ExitCode       ExitCode          `json:"exit_code"`
+	State          string            `json:"state"`
+	Message        string            `json:"message"`
+	LongOutput     []string          `json:"long_output,omitempty"`
+	Links          []Link            `json:"links,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	Verbosity      Verbosity         `json:"verbosity,omitempty"`
+	VerboseOutput  []jsonVerbose     `json:"verbose_output,omitempty"`
+	Perfdata       []jsonMetric      `json:"perfdata,omitempty"`
+	Format         string            `json:"format"`
+	Profile        jsonProfile       `json:"profile"`
+	StderrFallback bool              `json:"stderr_fallback,omitempty"`
+	OutputStats    bool              `json:"output_stats,omitempty"`
+	StrictFormat   bool              `json:"strict_format,omitempty"`
+	OmitZeroFields bool              `json:"omit_zero_fields,omitempty"`
+	Precision      int               `json:"precision,omitempty"`
+	NonFinite      NonFinitePolicy   `json:"non_finite,omitempty"`
+}
+
+// jsonVerbose is the JSON form of a line added with AddVerboseOutput.
+type jsonVerbose struct {
+	Level Verbosity `json:"level"`
+	Text  string    `json:"text"`
+}
+
+// jsonProfile is the JSON form of a Profile.
+type jsonProfile struct {
+	Name            string    `json:"name,omitempty"`
+	MaxOutputLength int       `json:"max_output_length,omitempty"`
+	StrictUOM       bool      `json:"strict_uom,omitempty"`
+	UpperCaseState  bool      `json:"upper_case_state,omitempty"`
+	PlainLabels     bool      `json:"plain_labels,omitempty"`
+	ASCII           ASCIIMode `json:"ascii,omitempty"`
+}
+
+// jsonMetric is the JSON form of a PerformanceMetric. Name is only set for the metrics of a
+// CheckResult. Unordered marks a metric that is in PerformanceData but not in PerfOrder, and
+// so is not output.
+type jsonMetric struct {
+	Name      string            `json:"name,omitempty"`
+	Unordered bool              `json:"unordered,omitempty"`
+	Value     jsonNumber        `json:"value"`
+	Warn      jsonNumber        `json:"warn,omitempty"`
+	Crit      jsonNumber        `json:"crit,omitempty"`
+	WarnRange *jsonRange        `json:"warn_range,omitempty"`
+	CritRange *jsonRange        `json:"crit_range,omitempty"`
+	Min       jsonNumber        `json:"min,omitempty"`
+	Max       jsonNumber        `json:"max,omitempty"`
+	UnitOM    string            `json:"uom,omitempty"`
+	Unknown   bool              `json:"unknown,omitempty"`
+	Precision int               `json:"precision,omitempty"`
+	Integer   bool              `json:"integer,omitempty"`
+	WarnSet   bool              `json:"warn_set,omitempty"`
+	CritSet   bool              `json:"crit_set,omitempty"`
+	MinSet    bool              `json:"min_set,omitempty"`
+	MaxSet    bool              `json:"max_set,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Series    string            `json:"series,omitempty"`
+}
+
+// jsonNumber is a float64 that JSON-encodes NaN and the infinities, which JSON numbers cannot
+// represent, as the strings "NaN", "+Inf" and "-Inf".
+type jsonNumber float64
+
+// MarshalJSON implements json.Marshaler.
+func (n jsonNumber) MarshalJSON() ([]byte, error) {
+	v := float64(n)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return json.Marshal(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *jsonNumber) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) != nil {
+		return json.Unmarshal(data, (*float64)(n))
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || !math.IsNaN(v) && !math.IsInf(v, 0) {
+		return fmt.Errorf("gomonitor: invalid number %q", s)
+	}
+	*n = jsonNumber(v)
+	return nil
+}
+
+// jsonRange is a Range encoded in the Nagios range syntax.
+type jsonRange Range
+
+// MarshalJSON implements json.Marshaler.
+func (r jsonRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Range(r).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *jsonRange) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseRange(s)
+	if err != nil {
+		return err
+	}
+	*r = jsonRange(parsed)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. Non-finite numbers are written as the strings "NaN",
+// "+Inf" and "-Inf" and threshold ranges in the Nagios range syntax.
+func (m PerformanceMetric) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newJSONMetric("", m))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting the output of MarshalJSON.
+func (m *PerformanceMetric) UnmarshalJSON(data []byte) error {
+	var jm jsonMetric
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return err
+	}
+	*m = jm.metric()
+	return nil
+}
+
+// newJSONMetric converts a named metric to its JSON form.
+func newJSONMetric(name string, m PerformanceMetric) jsonMetric {
+	return jsonMetric{
+		Name:      name,
+		Value:     jsonNumber(m.Value),
+		Warn:      jsonNumber(m.Warn),
+		Crit:      jsonNumber(m.Crit),
+		WarnRange: (*jsonRange)(m.WarnRange),
+		CritRange: (*jsonRange)(m.CritRange),
+		Min:       jsonNumber(m.Min),
+		Max:       jsonNumber(m.Max),
+		UnitOM:    m.UnitOM,
+		Unknown:   m.Unknown,
+		Precision: m.Precision,
+		Integer:   m.Integer,
+		WarnSet:   m.WarnSet,
+		CritSet:   m.CritSet,
+		MinSet:    m.MinSet,
+		MaxSet:    m.MaxSet,
+		Labels:    m.Labels,
+		Series:    m.Series,
+	}
+}
+
+// metric converts the JSON form back to a PerformanceMetric.
+func (jm jsonMetric) metric() PerformanceMetric {
+	return PerformanceMetric{
+		Value:     float64(jm.Value),
+		Warn:      float64(jm.Warn),
+		Crit:      float64(jm.Crit),
+		WarnRange: (*Range)(jm.WarnRange),
+		CritRange: (*Range)(jm.CritRange),
+		Min:       float64(jm.Min),
+		Max:       float64(jm.Max),
+		UnitOM:    jm.UnitOM,
+		Unknown:   jm.Unknown,
+		Precision: jm.Precision,
+		Integer:   jm.Integer,
+		WarnSet:   jm.WarnSet,
+		CritSet:   jm.CritSet,
+		MinSet:    jm.MinSet,
+		MaxSet:    jm.MaxSet,
+		Labels:    jm.Labels,
+		Series:    jm.Series,
+	}
+}
+
+// MarshalJSON implements json.Marshaler. Metrics are written as a list in PerfOrder, followed
+// by any metrics missing from PerfOrder marked "unordered", and lines added with
+// AddVerboseOutput are kept with their levels, so UnmarshalJSON reconstructs the result
+// exactly. A name repeated in PerfOrder is written once. The Serializer cannot be encoded and
+// is left out. The receiver is a value so CheckResult values encode the same way as pointers.
+func (cr CheckResult) MarshalJSON() ([]byte, error) {
+	jr := jsonResult{
+		ExitCode:       cr.ExitCode,
+		State:          cr.ExitCode.String(),
+		Message:        cr.Message,
+		LongOutput:     cr.LongOutput,
+		Links:          cr.Links,
+		Tags:           cr.Tags,
+		Verbosity:      cr.Verbosity,
+		Format:         cr.Format,
+		Profile:        jsonProfile(cr.Profile),
+		StderrFallback: cr.StderrFallback,
+		OutputStats:    cr.OutputStats,
+		StrictFormat:   cr.StrictFormat,
+		OmitZeroFields: cr.OmitZeroFields,
+		Precision:      cr.Precision,
+		NonFinite:      cr.NonFinite,
+	}
+	for _, line := range cr.verboseOutput {
+		jr.VerboseOutput = append(jr.VerboseOutput, jsonVerbose{Level: line.level, Text: line.text})
+	}
+	written := make(map[string]bool, len(cr.PerformanceData))
+	for name, metric := range cr.Metrics() {
+		if written[name] {
+			continue
+		}
+		written[name] = true
+		jr.Perfdata = append(jr.Perfdata, newJSONMetric(name, metric))
+	}
+	for _, name := range slices.Sorted(maps.Keys(cr.PerformanceData)) {
+		if !written[name] {
+			jm := newJSONMetric(name, cr.PerformanceData[name])
+			jm.Unordered = true
+			jr.Perfdata = append(jr.Perfdata, jm)
+		}
+	}
+	return json.Marshal(jr)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting the output of MarshalJSON. The "state"
+// field is informational; the exit code is taken from "exit_code".
+func (cr *CheckResult) UnmarshalJSON(data []byte) error {
+	var jr jsonResult
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return err
+	}
+	*cr = CheckResult{
+		ExitCode:        jr.ExitCode,
+		Message:         jr.Message,
+		LongOutput:      jr.LongOutput,
+		Links:           jr.Links,
+		Tags:            jr.Tags,
+		Verbosity:       jr.Verbosity,
+		PerformanceData: make(map[string]PerformanceMetric),
+		Format:          jr.Format,
+		Profile:         Profile(jr.Profile),
+		StderrFallback:  jr.StderrFallback,
+		OutputStats:     jr.OutputStats,
+		StrictFormat:    jr.StrictFormat,
+		OmitZeroFields:  jr.OmitZeroFields,
+		Precision:       jr.Precision,
+		NonFinite:       jr.NonFinite,
+	}
+	for _, line := range jr.VerboseOutput {
+		cr.AddVerboseOutput(line.Level, line.Text)
+	}
+	for _, jm := range jr.Perfdata {
+		if _, ok := cr.PerformanceData[jm.Name]; ok {
+			return fmt.Errorf("gomonitor: duplicate metric %q", jm.Name)
+		}
+		if jm.Unordered {
+			cr.PerformanceData[jm.Name] = jm.metric()
+			continue
+		}
+		cr.AddPerformanceData(jm.Name, jm.metric())
+	}
+	return nil
+}
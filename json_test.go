@@ -0,0 +1,175 @@
+package gomonitor
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckResultJSONRoundTrip(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(Critical, "2 of 3 disks failed")
+	result.AddLongOutput("sda: failed")
+	result.AddLink("runbook", "https://wiki.example.com/runbooks/disk")
+	result.SetTag("env", "prod")
+	result.AddVerboseOutput(VerbosityDebug, "ran smartctl")
+	result.Verbosity = VerbosityDetail
+	result.Profile = ProfileNagios3
+	result.Profile.PlainLabels = true
+	result.Profile.ASCII = ASCIITransliterate
+	result.Precision = PrecisionShortest
+	result.OmitZeroFields = true
+	result.NonFinite = NonFiniteDrop
+	// Added out of alphabetical order to check PerfOrder survives
+	result.AddPerformanceData("zeta", PerformanceMetric{Value: 3, Integer: true})
+	result.AddPerformanceData("alpha", PerformanceMetric{
+		Value:     1.5,
+		Warn:      1,
+		CritRange: &Range{Start: 10, End: 20, Invert: true},
+		WarnRange: &Range{Start: math.Inf(-1), End: 5},
+		Min:       0,
+		MinSet:    true,
+		Max:       100,
+		UnitOM:    UOMPercent,
+		Precision: 3,
+	})
+	result.AddPerformanceData("beta", PerformanceMetric{Unknown: true})
+	result.AddPerformanceData("web01::load1", PerformanceMetric{Value: 0.5, Series: "load1", Labels: map[string]string{"host": "web01"}})
+	result.UpdatePerformanceData("hidden", PerformanceMetric{Value: 7})
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &CheckResult{}
+	if err := json.Unmarshal(data, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, result) {
+		t.Errorf("round trip got %+v, want %+v", got, result)
+	}
+	if got.FormatResult() != result.FormatResult() {
+		t.Errorf("round trip FormatResult got %q, want %q", got.FormatResult(), result.FormatResult())
+	}
+}
+
+func TestCheckResultJSONFields(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(Warning, "high load")
+	result.AddPerformanceData("load", PerformanceMetric{Value: 2.5, Warn: 2, WarnRange: &Range{End: 2}})
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"exit_code":1`,
+		`"state":"Warning"`,
+		`"message":"high load"`,
+		`"perfdata":[{"name":"load","value":2.5,"warn":2,"warn_range":"0:2"}]`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("got %s, want it to contain %s", data, want)
+		}
+	}
+}
+
+func TestCheckResultJSONDuplicateOrder(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(OK, "fine")
+	result.AddPerformanceData("load", PerformanceMetric{Value: 1})
+	result.PerfOrder = append(result.PerfOrder, "load")
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), `"name":"load"`); n != 1 {
+		t.Errorf("got %d load metrics in %s, want 1", n, data)
+	}
+	got := &CheckResult{}
+	if err := json.Unmarshal(data, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.PerfOrder, []string{"load"}) {
+		t.Errorf("round trip got PerfOrder %v, want [load]", got.PerfOrder)
+	}
+}
+
+func TestCheckResultJSONValue(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(Critical, "down")
+	result.AddPerformanceData("rta", PerformanceMetric{Value: 0.5, UnitOM: UOMSeconds})
+	want, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := json.Marshal(*result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("value got %s, want %s", got, want)
+	}
+
+	// Results embedded by value in other types encode the same way
+	wrapped, err := json.Marshal(struct{ Result CheckResult }{*result})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantWrapped := `{"Result":` + string(want) + `}`; string(wrapped) != wantWrapped {
+		t.Errorf("wrapped got %s, want %s", wrapped, wantWrapped)
+	}
+}
+
+func TestPerformanceMetricJSONNonFinite(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value float64
+		want  string
+	}{
+		{"Test NaN", math.NaN(), `{"value":"NaN"}`},
+		{"Test +Inf", math.Inf(1), `{"value":"+Inf"}`},
+		{"Test -Inf", math.Inf(-1), `{"value":"-Inf"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(PerformanceMetric{Value: tc.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tc.want {
+				t.Errorf("got %s, want %s", data, tc.want)
+			}
+			var m PerformanceMetric
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Value; got != tc.value && !(math.IsNaN(got) && math.IsNaN(tc.value)) {
+				t.Errorf("round trip got %v, want %v", got, tc.value)
+			}
+		})
+	}
+}
+
+func TestCheckResultJSONErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{"Test duplicate metric", `{"perfdata":[{"name":"a","value":1},{"name":"a","value":2}]}`},
+		{"Test invalid range", `{"perfdata":[{"name":"a","value":1,"warn_range":"20:10"}]}`},
+		{"Test invalid number", `{"perfdata":[{"name":"a","value":"lots"}]}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var result CheckResult
+			if err := json.Unmarshal([]byte(tc.data), &result); err == nil {
+				t.Errorf("Unmarshal(%s) returned no error", tc.data)
+			}
+		})
+	}
+}
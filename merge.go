@@ -0,0 +1,93 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// DefaultSourceSeparator joins a source's name to its metric names in Merge when no separator
+// is given.
+const DefaultSourceSeparator = "::"
+
+// Source is the result of a check against one of several hosts or targets, for Merge.
+// - `Name` identifies the source, such as a host name. It prefixes the source's message and metrics.
+// - `Result` is the source's result. nil is reported as Unknown.
+type Source struct {
+	Name   string
+	Result *CheckResult
+}
+
+// Merge combines the results of a check run against several hosts or targets. The combined
+// ExitCode is the worst of the sources' results, the message lists each source's message
+// prefixed by its name, and each metric is renamed to "<source><sep><metric>", such as
+// "web01::load1", so graphs downstream tell the sources apart. An empty sep means
+// DefaultSourceSeparator.
+func Merge(sep string, sources ...Source) *CheckResult {
+	return merge(sep, "", sources)
+}
+
+// MergeLabeled is Merge with DefaultSourceSeparator, except that each metric is also labeled
+// with label=<source> and keeps its own name as its Series. Outputs with labels then tell the
+// sources apart the way their queries expect: OpenMetrics writes "load1{host="web01"}" and
+// InfluxDB a "host" tag, instead of a "web01::load1" name. Plugin perfdata has no labels and
+// keeps the prefixed names.
+func MergeLabeled(label string, sources ...Source) *CheckResult {
+	return merge(DefaultSourceSeparator, label, sources)
+}
+
+// merge implements Merge and MergeLabeled.
+func merge(sep, label string, sources []Source) *CheckResult {
+	if sep == "" {
+		sep = DefaultSourceSeparator
+	}
+	result := NewCheckResult()
+	var messages []string
+	for _, source := range sources {
+		messages = mergeResult(result, messages, source.Name, sep, label, source.Result)
+	}
+	result.Message = strings.Join(messages, ", ")
+	return result
+}
+
+// mergeResult folds sub, named name, into result: its ExitCode is combined with worse, its
+// message is appended to messages, which is returned, and its metrics are added with name and
+// sep prefixed. A non-empty label also labels every metric with label=name. A nil sub is
+// Unknown.
+func mergeResult(result *CheckResult, messages []string, name, sep, label string, sub *CheckResult) []string {
+	if sub == nil {
+		result.ExitCode = worse(result.ExitCode, Unknown)
+		return append(messages, fmt.Sprintf("%s: no result", name))
+	}
+	result.ExitCode = worse(result.ExitCode, sub.ExitCode)
+	for metricName, metric := range sub.Metrics() {
+		if label != "" {
+			if metric.Series == "" {
+				metric.Series = metricName
+			}
+			metric.Labels = maps.Clone(metric.Labels)
+			if metric.Labels == nil {
+				metric.Labels = make(map[string]string)
+			}
+			metric.Labels[label] = name
+		}
+		result.AddPerformanceData(name+sep+metricName, metric)
+	}
+	return append(messages, fmt.Sprintf("%s: %s", name, sub.Message))
+}
@@ -0,0 +1,89 @@
+package gomonitor
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// mergeHost returns a result for one source of a merge, with a single load1 metric.
+func mergeHost(ec ExitCode, msg string, load float64) *CheckResult {
+	result := NewCheckResult()
+	result.SetResult(ec, msg)
+	result.AddPerformanceData("load1", PerformanceMetric{Value: load})
+	return result
+}
+
+func TestMerge(t *testing.T) {
+	sources := []Source{
+		{Name: "web01", Result: mergeHost(OK, "load 0.5", 0.5)},
+		{Name: "web02", Result: mergeHost(Warning, "load 5.2", 5.2)},
+		{Name: "web03"},
+	}
+
+	testCases := []struct {
+		name        string
+		sep         string
+		wantMetrics []string
+	}{
+		{"Test default separator", "", []string{"web01::load1", "web02::load1"}},
+		{"Test custom separator", "/", []string{"web01/load1", "web02/load1"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Merge(tc.sep, sources...)
+			if result.ExitCode != Warning {
+				t.Errorf("got exitCode %s, want Warning", result.ExitCode)
+			}
+			if want := "web01: load 0.5, web02: load 5.2, web03: no result"; result.Message != want {
+				t.Errorf("got message %q, want %q", result.Message, want)
+			}
+			if !slices.Equal(result.PerfOrder, tc.wantMetrics) {
+				t.Errorf("got metrics %v, want %v", result.PerfOrder, tc.wantMetrics)
+			}
+		})
+	}
+
+	if got, want := Merge("", sources[:1]...).FormatResult(), "OK - web01: load 0.5 | 'web01::load1'=0.50;0.00;0.00;0.00;0.00 "; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
+
+func TestMergeLabeled(t *testing.T) {
+	result := MergeLabeled("host",
+		Source{Name: "web01", Result: mergeHost(OK, "load 0.5", 0.5)},
+		Source{Name: "web02", Result: mergeHost(Warning, "load 5.2", 5.2)},
+	)
+	if result.ExitCode != Warning {
+		t.Errorf("got exitCode %s, want Warning", result.ExitCode)
+	}
+	if want := []string{"web01::load1", "web02::load1"}; !slices.Equal(result.PerfOrder, want) {
+		t.Errorf("got metrics %v, want %v", result.PerfOrder, want)
+	}
+	for _, name := range []string{"web01", "web02"} {
+		metric := result.PerformanceData[name+"::load1"]
+		if metric.Series != "load1" || metric.Labels["host"] != name {
+			t.Errorf("got series %q labels %v for %s, want load1 and host=%s", metric.Series, metric.Labels, name, name)
+		}
+	}
+
+	want := `# TYPE load1 gauge
+# HELP load1 Performance metric load1.
+load1{host="web01"} 0.5
+load1{host="web02"} 5.2
+`
+	if got := result.OpenMetrics(OpenMetricsOptions{}); !strings.Contains(got, want) {
+		t.Errorf("OpenMetrics got\n%s\nwant it to contain\n%s", got, want)
+	}
+
+	// Merging again adds the outer label and keeps the inner one and the series
+	outer := MergeLabeled("dc", Source{Name: "ams", Result: result})
+	metric := outer.PerformanceData["ams::web01::load1"]
+	if metric.Series != "load1" || metric.Labels["host"] != "web01" || metric.Labels["dc"] != "ams" {
+		t.Errorf("got series %q labels %v, want load1 with host=web01 and dc=ams", metric.Series, metric.Labels)
+	}
+	if len(result.PerformanceData["web01::load1"].Labels) != 1 {
+		t.Errorf("merging again changed the inner result's labels: %v", result.PerformanceData["web01::load1"].Labels)
+	}
+}
@@ -0,0 +1,196 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package nagios submits passive check results to a local Nagios through its external command
+// file or its check result spool directory, so a plugin can run outside the Nagios scheduler.
+package nagios
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// spoolNameChars are the characters of the random part of a spool file name.
+const spoolNameChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Result is a check result to submit.
+// - `Host` is the host the result belongs to.
+// - `Service` is the service the result belongs to. Empty submits a host result.
+// - `Result` is the result. Its output is written as FormatResult writes it, with backslashes and newlines escaped as Nagios expects.
+type Result struct {
+	Host    string
+	Service string
+	Result  *gomonitor.CheckResult
+}
+
+// ExternalCommand returns the external command that submits r, timestamped ts, including its
+// trailing newline: PROCESS_SERVICE_CHECK_RESULT for a service result and
+// PROCESS_HOST_CHECK_RESULT for a host result. Host results report OK as UP and every other
+// state as DOWN.
+func ExternalCommand(r Result, ts time.Time) (string, error) {
+	if err := r.validate(); err != nil {
+		return "", err
+	}
+	prefix := "[" + strconv.FormatInt(ts.Unix(), 10) + "] "
+	if r.Service == "" {
+		return prefix + "PROCESS_HOST_CHECK_RESULT;" + r.Host + ";" + strconv.Itoa(r.state()) + ";" + r.output() + "\n", nil
+	}
+	return prefix + "PROCESS_SERVICE_CHECK_RESULT;" + r.Host + ";" + r.Service + ";" +
+		strconv.Itoa(r.state()) + ";" + r.output() + "\n", nil
+}
+
+// WriteCommands writes the external command for each result, timestamped now, to the command
+// file at path, usually the named pipe /usr/local/nagios/var/rw/nagios.cmd. Each command is
+// written with a single write, which the pipe keeps whole when it is no longer than 4096
+// bytes. The file is opened without blocking, so a Nagios that is not reading the pipe is an
+// error instead of a hang.
+func WriteCommands(path string, results ...Result) error {
+	now := time.Now()
+	commands := make([]string, 0, len(results))
+	for _, r := range results {
+		command, err := ExternalCommand(r, now)
+		if err != nil {
+			return err
+		}
+		commands = append(commands, command)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("nagios: opening command file: %w", err)
+	}
+	defer f.Close()
+	for _, command := range commands {
+		if _, err := f.WriteString(command); err != nil {
+			return fmt.Errorf("nagios: writing command: %w", err)
+		}
+	}
+	return f.Close()
+}
+
+// WriteCheckResults writes results to a new file in the check result spool directory dir,
+// usually /usr/local/nagios/var/spool/checkresults, as passive results finished now. The file
+// is named like Nagios's own, "c" and six random characters, and its ".ok" marker is created
+// once it is complete, so Nagios never reads a partial file.
+func WriteCheckResults(dir string, results ...Result) error {
+	now := time.Now()
+	var b strings.Builder
+	b.WriteString("### Passive Check Result File ###\n")
+	b.WriteString("file_time=" + strconv.FormatInt(now.Unix(), 10) + "\n")
+	finished := strconv.FormatFloat(float64(now.UnixMicro())/1e6, 'f', 6, 64)
+	for _, r := range results {
+		if err := r.validate(); err != nil {
+			return err
+		}
+		if r.Service == "" {
+			b.WriteString("\n### Nagios Host Check Result ###\n")
+		} else {
+			b.WriteString("\n### Nagios Service Check Result ###\n")
+		}
+		b.WriteString("# Time: " + now.Format(time.ANSIC) + "\n")
+		b.WriteString("host_name=" + r.Host + "\n")
+		if r.Service != "" {
+			b.WriteString("service_description=" + r.Service + "\n")
+		}
+		b.WriteString("check_type=1\ncheck_options=0\nscheduled_check=0\nreschedule_check=0\n")
+		b.WriteString("latency=0.000000\n")
+		b.WriteString("start_time=" + finished + "\nfinish_time=" + finished + "\n")
+		b.WriteString("early_timeout=0\nexited_ok=1\n")
+		b.WriteString("return_code=" + strconv.Itoa(r.state()) + "\n")
+		b.WriteString("output=" + r.output() + "\n")
+	}
+
+	f, err := createSpoolFile(dir)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("nagios: writing check result: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("nagios: writing check result: %w", err)
+	}
+	ok, err := os.OpenFile(f.Name()+".ok", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("nagios: marking check result: %w", err)
+	}
+	return ok.Close()
+}
+
+// createSpoolFile creates a new, uniquely named check result file in dir.
+func createSpoolFile(dir string) (*os.File, error) {
+	for {
+		var buf [6]byte
+		if _, err := rand.Read(buf[:]); err != nil {
+			return nil, fmt.Errorf("nagios: %w", err)
+		}
+		name := []byte("c")
+		for _, c := range buf {
+			name = append(name, spoolNameChars[int(c)%len(spoolNameChars)])
+		}
+		f, err := os.OpenFile(filepath.Join(dir, string(name)), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("nagios: creating check result: %w", err)
+		}
+		return f, nil
+	}
+}
+
+// validate checks that r has a check result and that its names can be written in a command or
+// spool file.
+func (r Result) validate() error {
+	switch {
+	case r.Host == "":
+		return errors.New("nagios: result without a host")
+	case strings.ContainsAny(r.Host, ";\n"):
+		return fmt.Errorf("nagios: invalid host name %q", r.Host)
+	case strings.ContainsAny(r.Service, ";\n"):
+		return fmt.Errorf("nagios: invalid service description %q", r.Service)
+	case r.Result == nil && r.Service == "":
+		return fmt.Errorf("nagios: no check result for host %q", r.Host)
+	case r.Result == nil:
+		return fmt.Errorf("nagios: no check result for service %q on host %q", r.Service, r.Host)
+	}
+	return nil
+}
+
+// state returns the return code Nagios is given for r.
+func (r Result) state() int {
+	if r.Service == "" {
+		return min(r.Result.ExitCode.Int(), 1)
+	}
+	return r.Result.ExitCode.Int()
+}
+
+// output returns r's output on a single line, escaped as Nagios unescapes passive results.
+func (r Result) output() string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(r.Result.FormatResult())
+}
@@ -0,0 +1,119 @@
+package nagios
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// newResult returns a result with state ec and message msg.
+func newResult(ec gomonitor.ExitCode, msg string) *gomonitor.CheckResult {
+	cr := gomonitor.NewCheckResult()
+	cr.SetResult(ec, msg)
+	return cr
+}
+
+func TestExternalCommand(t *testing.T) {
+	multiline := newResult(gomonitor.Warning, `C:\ 91% used`)
+	multiline.AddLongOutput("D:\\ 40% used")
+	ts := time.Unix(1700000000, 0)
+	testCases := []struct {
+		name    string
+		result  Result
+		want    string
+		wantErr bool
+	}{
+		{"Test service", Result{Host: "web01", Service: "disk", Result: newResult(gomonitor.Critical, "disk full; 99%")},
+			"[1700000000] PROCESS_SERVICE_CHECK_RESULT;web01;disk;2;Critical - disk full; 99%\n", false},
+		{"Test host down", Result{Host: "web01", Result: newResult(gomonitor.Unknown, "no route")},
+			"[1700000000] PROCESS_HOST_CHECK_RESULT;web01;1;Unknown - no route\n", false},
+		{"Test escaped", Result{Host: "win01", Service: "disk", Result: multiline},
+			`[1700000000] PROCESS_SERVICE_CHECK_RESULT;win01;disk;1;Warning - C:\\ 91% used\nD:\\ 40% used` + "\n", false},
+		{"Test no host", Result{Service: "disk", Result: newResult(gomonitor.OK, "")}, "", true},
+		{"Test semicolon in host", Result{Host: "web;01", Result: newResult(gomonitor.OK, "")}, "", true},
+		{"Test newline in service", Result{Host: "web01", Service: "disk\n", Result: newResult(gomonitor.OK, "")}, "", true},
+		{"Test no check result", Result{Host: "web01", Service: "disk"}, "", true},
+		{"Test no host check result", Result{Host: "web01"}, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ExternalCommand(tc.result, ts)
+			if got != tc.want || (err != nil) != tc.wantErr {
+				t.Errorf("got %q, %v, want %q", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nagios.cmd")
+	if err := WriteCommands(path, Result{Host: "web01", Result: newResult(gomonitor.OK, "up")}); err == nil {
+		t.Error("WriteCommands to a missing command file returned no error")
+	}
+
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := WriteCommands(path,
+		Result{Host: "web01", Service: "load", Result: newResult(gomonitor.OK, "load ok")},
+		Result{Host: "web01", Result: newResult(gomonitor.OK, "up")})
+	if err != nil {
+		t.Fatalf("WriteCommands returned %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	want := regexp.MustCompile(`^\[\d+\] PROCESS_SERVICE_CHECK_RESULT;web01;load;0;OK - load ok\n` +
+		`\[\d+\] PROCESS_HOST_CHECK_RESULT;web01;0;OK - up\n$`)
+	if !want.Match(data) {
+		t.Errorf("got commands %q", data)
+	}
+
+	if err := WriteCommands(path, Result{Host: "", Result: newResult(gomonitor.OK, "")}); err == nil {
+		t.Error("WriteCommands of an invalid result returned no error")
+	}
+}
+
+func TestWriteCheckResults(t *testing.T) {
+	dir := t.TempDir()
+	cr := newResult(gomonitor.Warning, "disk 91% used")
+	cr.AddLongOutput("/var 91%")
+	err := WriteCheckResults(dir,
+		Result{Host: "web01", Service: "disk", Result: cr},
+		Result{Host: "web02", Result: newResult(gomonitor.Critical, "down")})
+	if err != nil {
+		t.Fatalf("WriteCheckResults returned %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 || !regexp.MustCompile(`^c[A-Za-z0-9]{6}$`).MatchString(entries[0].Name()) ||
+		entries[1].Name() != entries[0].Name()+".ok" {
+		t.Fatalf("got spool files %v, want a result file and its .ok marker", entries)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	text := string(data)
+	for _, want := range []string{
+		"### Nagios Service Check Result ###\n# Time: ",
+		"host_name=web01\nservice_description=disk\ncheck_type=1\n",
+		"return_code=1\noutput=Warning - disk 91% used\\n/var 91%\n",
+		"### Nagios Host Check Result ###\n",
+		"host_name=web02\ncheck_type=1\n",
+		"return_code=1\noutput=Critical - down\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("check result file missing %q:\n%s", want, text)
+		}
+	}
+	if !regexp.MustCompile(`(?m)^file_time=\d+$`).MatchString(text) ||
+		!regexp.MustCompile(`(?m)^finish_time=\d+\.\d{6}$`).MatchString(text) {
+		t.Errorf("check result file missing timestamps:\n%s", text)
+	}
+
+	if err := WriteCheckResults(filepath.Join(dir, "missing"), Result{Host: "web01", Result: cr}); err == nil {
+		t.Error("WriteCheckResults to a missing directory returned no error")
+	}
+}
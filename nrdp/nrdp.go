@@ -0,0 +1,288 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package nrdp submits check results to Nagios through NRDP, the Nagios Remote Data Processor,
+// which carries passive results over HTTPS for agents behind firewalls that cannot use NSCA.
+package nrdp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Client defaults.
+const (
+	defaultBatchSize  = 100
+	defaultRetryDelay = time.Second
+	maxResponseSize   = 1 << 20
+)
+
+// Format is the payload format results are submitted in.
+type Format int
+
+const (
+	// FormatXML submits the results as the XMLDATA field, which every NRDP version accepts.
+	FormatXML Format = iota
+	// FormatJSON submits the results as the JSONDATA field, which NRDP 1.3 and later accept.
+	FormatJSON
+)
+
+// Client submits results to a single NRDP server.
+// - `URL` is the NRDP endpoint, such as "https://nagios.example.com/nrdp/".
+// - `Token` is one of the server's authorized tokens.
+// - `Format` is the payload format. The default is FormatXML.
+// - `BatchSize` is the most results sent in one request. 0 means 100.
+// - `Retries` is how many more times a batch is sent after a network error or a 5xx response.
+// - `RetryDelay` is the wait before the first retry, doubled before each following one. 0 means 1 second.
+// - `TLSConfig` configures the connection when HTTPClient is nil. nil uses the system roots.
+// - `HTTPClient` sends the requests. nil means a client using TLSConfig.
+type Client struct {
+	URL        string
+	Token      string
+	Format     Format
+	BatchSize  int
+	Retries    int
+	RetryDelay time.Duration
+	TLSConfig  *tls.Config
+	HTTPClient *http.Client
+}
+
+// Result is a check result to submit.
+// - `Host` is the host the result belongs to.
+// - `Service` is the service the result belongs to. Empty submits a host result.
+// - `Result` is the result. Its output is written as FormatResult writes it.
+type Result struct {
+	Host    string
+	Service string
+	Result  *gomonitor.CheckResult
+}
+
+// checkResult is a result as NRDP's JSON and XML payloads carry it.
+type checkResult struct {
+	XMLName     xml.Name `xml:"checkresult" json:"-"`
+	Type        string   `xml:"type,attr" json:"-"`
+	CheckType   string   `xml:"checktype,attr" json:"-"`
+	Hostname    string   `xml:"hostname" json:"hostname"`
+	ServiceName string   `xml:"servicename,omitempty" json:"servicename,omitempty"`
+	State       string   `xml:"state" json:"state"`
+	Output      string   `xml:"output" json:"output"`
+}
+
+// response is the result NRDP answers a submission with.
+type response struct {
+	Status  string `xml:"status"`
+	Message string `xml:"message"`
+}
+
+// Error is a submission NRDP rejected, such as for a bad token.
+// - `Status` is the status NRDP reported, "-1" for most errors.
+// - `Message` is NRDP's description of the error.
+type Error struct {
+	Status  string
+	Message string
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("NRDP error %s: %s", e.Status, e.Message)
+}
+
+// Submit sends results to the server in batches of BatchSize, in order. Every result is checked
+// before the first batch is sent. It stops at the first batch that fails after its retries, so
+// the batches before it have been accepted. Host results report OK as UP and every other state
+// as DOWN.
+func (c *Client) Submit(ctx context.Context, results ...Result) error {
+	size := c.BatchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	for _, r := range results {
+		if err := r.validate(); err != nil {
+			return err
+		}
+	}
+	batches := (len(results) + size - 1) / size
+	for i := 0; i < batches; i++ {
+		batch := results[i*size : min((i+1)*size, len(results))]
+		if err := c.submitBatch(ctx, batch); err != nil {
+			return fmt.Errorf("submitting batch %d of %d: %w", i+1, batches, err)
+		}
+	}
+	return nil
+}
+
+// submitBatch sends a batch, retrying network errors and 5xx responses.
+func (c *Client) submitBatch(ctx context.Context, batch []Result) error {
+	form, err := c.payload(batch)
+	if err != nil {
+		return err
+	}
+	delay := c.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	for attempt := 0; ; attempt++ {
+		retry, err := c.post(ctx, form)
+		if err == nil || !retry || attempt >= c.Retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// validate checks that r names a host and has a check result.
+func (r Result) validate() error {
+	switch {
+	case r.Host == "":
+		return errors.New("result without a host")
+	case r.Result == nil && r.Service == "":
+		return fmt.Errorf("no check result for host %q", r.Host)
+	case r.Result == nil:
+		return fmt.Errorf("no check result for service %q on host %q", r.Service, r.Host)
+	}
+	return nil
+}
+
+// payload encodes a batch of validated results as the form fields of a submitcheck request.
+func (c *Client) payload(batch []Result) (url.Values, error) {
+	crs := make([]checkResult, 0, len(batch))
+	for _, r := range batch {
+		cr := checkResult{
+			Type:      "service",
+			CheckType: "1",
+			Hostname:  r.Host,
+			State:     strconv.Itoa(r.Result.ExitCode.Int()),
+			Output:    r.Result.FormatResult(),
+		}
+		if r.Service == "" {
+			cr.Type = "host"
+			cr.State = strconv.Itoa(min(r.Result.ExitCode.Int(), 1))
+		} else {
+			cr.ServiceName = r.Service
+		}
+		crs = append(crs, cr)
+	}
+
+	form := url.Values{"token": {c.Token}, "cmd": {"submitcheck"}}
+	if c.Format == FormatJSON {
+		type jsonCheckResult struct {
+			CheckResult struct {
+				Type      string `json:"type"`
+				CheckType string `json:"checktype"`
+			} `json:"checkresult"`
+			checkResult
+		}
+		data := struct {
+			CheckResults []jsonCheckResult `json:"checkresults"`
+		}{}
+		for _, cr := range crs {
+			j := jsonCheckResult{checkResult: cr}
+			j.CheckResult.Type, j.CheckResult.CheckType = cr.Type, cr.CheckType
+			data.CheckResults = append(data.CheckResults, j)
+		}
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("JSONDATA", string(raw))
+		return form, nil
+	}
+	raw, err := xml.Marshal(struct {
+		XMLName      xml.Name      `xml:"checkresults"`
+		CheckResults []checkResult `xml:"checkresult"`
+	}{CheckResults: crs})
+	if err != nil {
+		return nil, err
+	}
+	form.Set("XMLDATA", xml.Header+string(raw))
+	return form, nil
+}
+
+// post sends a submitcheck request and reports whether a failure is worth retrying.
+func (c *Client) post(ctx context.Context, form url.Values) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return true, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode >= 500, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var result response
+	raw = bytes.TrimSpace(raw)
+	if bytes.HasPrefix(raw, []byte("{")) {
+		// NRDP writes the status as a number or a string depending on the version
+		var wrapped struct {
+			Result struct {
+				Status  json.RawMessage `json:"status"`
+				Message string          `json:"message"`
+			} `json:"result"`
+		}
+		err = json.Unmarshal(raw, &wrapped)
+		result.Status = strings.Trim(string(wrapped.Result.Status), `"`)
+		result.Message = wrapped.Result.Message
+	} else {
+		err = xml.Unmarshal(raw, &result)
+	}
+	if err != nil {
+		return false, fmt.Errorf("decoding response: %w", err)
+	}
+	if result.Status != "0" {
+		return false, &Error{Status: result.Status, Message: result.Message}
+	}
+	return false, nil
+}
+
+// client returns the HTTP client requests are sent with.
+func (c *Client) client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	if c.TLSConfig == nil {
+		return http.DefaultClient
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = c.TLSConfig
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,201 @@
+package nrdp
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeServer is an NRDP endpoint that answers requests with replies in turn and records the
+// submitted form fields.
+type fakeServer struct {
+	mu      sync.Mutex
+	replies []reply
+	forms   []map[string]string
+}
+
+// reply is a response a fakeServer sends.
+type reply struct {
+	status int
+	body   string
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	form := make(map[string]string)
+	for key := range r.PostForm {
+		form[key] = r.PostForm.Get(key)
+	}
+	s.forms = append(s.forms, form)
+	next := reply{http.StatusOK, "<result><status>0</status><message>OK</message></result>"}
+	if len(s.replies) > 0 {
+		next, s.replies = s.replies[0], s.replies[1:]
+	}
+	w.WriteHeader(next.status)
+	w.Write([]byte(next.body))
+}
+
+// newResult returns a result with state ec and message msg.
+func newResult(ec gomonitor.ExitCode, msg string) *gomonitor.CheckResult {
+	cr := gomonitor.NewCheckResult()
+	cr.SetResult(ec, msg)
+	return cr
+}
+
+func TestSubmitXML(t *testing.T) {
+	fake := &fakeServer{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	cr := newResult(gomonitor.Warning, "disk 91% used")
+	cr.AddPerformanceData("used", gomonitor.PerformanceMetric{Value: 91, UnitOM: "%"})
+	c := &Client{URL: srv.URL, Token: "s3cret"}
+	err := c.Submit(context.Background(),
+		Result{Host: "web01", Service: "disk", Result: cr},
+		Result{Host: "web01", Result: newResult(gomonitor.Critical, "host down")})
+	if err != nil {
+		t.Fatalf("Submit returned %v", err)
+	}
+
+	if len(fake.forms) != 1 {
+		t.Fatalf("got %d requests, want 1", len(fake.forms))
+	}
+	form := fake.forms[0]
+	if form["token"] != "s3cret" || form["cmd"] != "submitcheck" {
+		t.Errorf("got form %v", form)
+	}
+	var data struct {
+		CheckResults []struct {
+			Type        string `xml:"type,attr"`
+			CheckType   string `xml:"checktype,attr"`
+			Hostname    string `xml:"hostname"`
+			ServiceName string `xml:"servicename"`
+			State       string `xml:"state"`
+			Output      string `xml:"output"`
+		} `xml:"checkresult"`
+	}
+	if err := xml.Unmarshal([]byte(form["XMLDATA"]), &data); err != nil || len(data.CheckResults) != 2 {
+		t.Fatalf("got XMLDATA %q, %v", form["XMLDATA"], err)
+	}
+	service, host := data.CheckResults[0], data.CheckResults[1]
+	if service.Type != "service" || service.CheckType != "1" || service.Hostname != "web01" ||
+		service.ServiceName != "disk" || service.State != "1" || service.Output != cr.FormatResult() {
+		t.Errorf("got service result %+v", service)
+	}
+	if host.Type != "host" || host.ServiceName != "" || host.State != "1" {
+		t.Errorf("got host result %+v", host)
+	}
+}
+
+func TestSubmitJSON(t *testing.T) {
+	fake := &fakeServer{replies: []reply{{http.StatusOK, `{"result":{"status":0,"message":"OK"}}`}}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	c := &Client{URL: srv.URL, Token: "s3cret", Format: FormatJSON}
+	if err := c.Submit(context.Background(), Result{Host: "web01", Service: "load", Result: newResult(gomonitor.OK, "load ok")}); err != nil {
+		t.Fatalf("Submit returned %v", err)
+	}
+
+	var data struct {
+		CheckResults []map[string]any `json:"checkresults"`
+	}
+	if err := json.Unmarshal([]byte(fake.forms[0]["JSONDATA"]), &data); err != nil || len(data.CheckResults) != 1 {
+		t.Fatalf("got JSONDATA %q, %v", fake.forms[0]["JSONDATA"], err)
+	}
+	got := data.CheckResults[0]
+	meta, _ := got["checkresult"].(map[string]any)
+	if meta["type"] != "service" || meta["checktype"] != "1" || got["hostname"] != "web01" ||
+		got["servicename"] != "load" || got["state"] != "0" || got["output"] != "OK - load ok" {
+		t.Errorf("got check result %v", got)
+	}
+}
+
+func TestSubmitBatches(t *testing.T) {
+	fake := &fakeServer{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	var results []Result
+	for range 5 {
+		results = append(results, Result{Host: "web01", Service: "ping", Result: newResult(gomonitor.OK, "up")})
+	}
+	if err := (&Client{URL: srv.URL, BatchSize: 2}).Submit(context.Background(), results...); err != nil {
+		t.Fatalf("Submit returned %v", err)
+	}
+	var sizes []int
+	for _, form := range fake.forms {
+		sizes = append(sizes, strings.Count(form["XMLDATA"], "<checkresult "))
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Errorf("got batch sizes %v, want [2 2 1]", sizes)
+	}
+}
+
+func TestSubmitErrors(t *testing.T) {
+	ok := reply{http.StatusOK, "<result><status>0</status><message>OK</message></result>"}
+	testCases := []struct {
+		name         string
+		replies      []reply
+		retries      int
+		wantErr      string
+		wantRequests int
+	}{
+		{"Test retried", []reply{{http.StatusBadGateway, ""}, {http.StatusServiceUnavailable, ""}, ok}, 2, "", 3},
+		{"Test retries exhausted", []reply{{http.StatusBadGateway, ""}, {http.StatusBadGateway, ""}}, 1, "502", 2},
+		{"Test bad token", []reply{{http.StatusOK, "<result><status>-1</status><message>BAD TOKEN</message></result>"}}, 2, "BAD TOKEN", 1},
+		{"Test client error", []reply{{http.StatusForbidden, ""}}, 2, "403", 1},
+		{"Test bad response", []reply{{http.StatusOK, "<html"}}, 2, "decoding response", 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeServer{replies: tc.replies}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+			c := &Client{URL: srv.URL, Retries: tc.retries, RetryDelay: time.Millisecond}
+			err := c.Submit(context.Background(), Result{Host: "web01", Service: "ping", Result: newResult(gomonitor.OK, "up")})
+			if tc.wantErr == "" && err != nil || tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("got error %v, want %q", err, tc.wantErr)
+			}
+			if len(fake.forms) != tc.wantRequests {
+				t.Errorf("got %d requests, want %d", len(fake.forms), tc.wantRequests)
+			}
+		})
+	}
+
+	var nrdpErr *Error
+	srv := httptest.NewServer(&fakeServer{replies: []reply{{http.StatusOK, `{"result":{"status":"-1","message":"BAD TOKEN"}}`}}})
+	defer srv.Close()
+	err := (&Client{URL: srv.URL}).Submit(context.Background(), Result{Host: "web01", Result: newResult(gomonitor.OK, "up")})
+	if !errors.As(err, &nrdpErr) || nrdpErr.Status != "-1" {
+		t.Errorf("got error %v, want an NRDP error", err)
+	}
+	if err := (&Client{URL: srv.URL}).Submit(context.Background(), Result{Result: newResult(gomonitor.OK, "up")}); err == nil {
+		t.Error("Submit without a host returned no error")
+	}
+
+	// A bad result in a later batch stops the submission before anything is sent
+	fake := &fakeServer{replies: []reply{ok}}
+	srv2 := httptest.NewServer(fake)
+	defer srv2.Close()
+	err = (&Client{URL: srv2.URL, BatchSize: 1}).Submit(context.Background(),
+		Result{Host: "web01", Result: newResult(gomonitor.OK, "up")}, Result{Host: "web01", Service: "ping"})
+	if err == nil || err.Error() != `no check result for service "ping" on host "web01"` {
+		t.Errorf("got error %v, want no check result", err)
+	}
+	if len(fake.forms) != 0 {
+		t.Errorf("got %d requests, want 0", len(fake.forms))
+	}
+}
@@ -0,0 +1,210 @@
+package nsca
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// received is a decoded data packet.
+type received struct {
+	version   uint16
+	timestamp uint32
+	state     uint16
+	host      string
+	service   string
+	output    string
+	crcOK     bool
+}
+
+// fakeDaemon accepts one connection, sends the IV and timestamp, and decodes count packets of
+// size bytes, decrypting them as the daemon would.
+func fakeDaemon(t *testing.T, method Encryption, password string, size, count int) (string, <-chan []received) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	done := make(chan []received, 1)
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		init := make([]byte, ivSize+4)
+		for i := range ivSize {
+			init[i] = byte(i*7 + 3)
+		}
+		binary.BigEndian.PutUint32(init[ivSize:], 1700000000)
+		conn.Write(init)
+
+		decrypt := newDecrypter(t, method, password, init[:ivSize])
+		var packets []received
+		for range count {
+			packet := make([]byte, size)
+			if _, err := io.ReadFull(conn, packet); err != nil {
+				t.Errorf("reading packet: %v", err)
+				break
+			}
+			decrypt(packet)
+			crc := binary.BigEndian.Uint32(packet[offsetCRC:])
+			binary.BigEndian.PutUint32(packet[offsetCRC:], 0)
+			packets = append(packets, received{
+				version:   binary.BigEndian.Uint16(packet),
+				timestamp: binary.BigEndian.Uint32(packet[offsetTimestamp:]),
+				state:     binary.BigEndian.Uint16(packet[offsetReturn:]),
+				host:      cString(packet[offsetHost:offsetService]),
+				service:   cString(packet[offsetService:offsetOutput]),
+				output:    cString(packet[offsetOutput:]),
+				crcOK:     crc == crc32.ChecksumIEEE(packet),
+			})
+		}
+		done <- packets
+	}()
+	return ln.Addr().String(), done
+}
+
+// newDecrypter is the daemon's side of newEncrypter.
+func newDecrypter(t *testing.T, method Encryption, password string, iv []byte) func([]byte) {
+	var block cipher.Block
+	var err error
+	switch method {
+	case EncryptionNone:
+		return func([]byte) {}
+	case EncryptionXOR:
+		return func(packet []byte) {
+			for i := range packet {
+				packet[i] ^= iv[i%len(iv)] ^ password[i%len(password)]
+			}
+		}
+	case EncryptionDES:
+		block, err = des.NewCipher([]byte(password + strings.Repeat("\x00", 8-len(password))))
+	case Encryption3DES:
+		block, err = des.NewTripleDESCipher([]byte(password + strings.Repeat("\x00", 24-len(password))))
+	case EncryptionAES:
+		block, err = aes.NewCipher([]byte(password + strings.Repeat("\x00", 32-len(password))))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	register := bytes.Clone(iv[:block.BlockSize()])
+	keystream := make([]byte, block.BlockSize())
+	return func(packet []byte) {
+		for i, c := range packet {
+			block.Encrypt(keystream, register)
+			packet[i] = c ^ keystream[0]
+			register = append(register[1:], c)
+		}
+	}
+}
+
+// cString returns the NUL-terminated string at the start of b.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return string(b[:i])
+	}
+	return string(b)
+}
+
+func TestSend(t *testing.T) {
+	testCases := []struct {
+		name      string
+		method    Encryption
+		maxOutput int
+		wantSize  int
+	}{
+		{"Test none", EncryptionNone, 0, 720},
+		{"Test XOR", EncryptionXOR, 0, 720},
+		{"Test DES", EncryptionDES, 0, 720},
+		{"Test 3DES", Encryption3DES, 0, 720},
+		{"Test AES", EncryptionAES, 0, 720},
+		{"Test large packets", EncryptionAES, 4096, 4304},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr, done := fakeDaemon(t, tc.method, "s3cret", tc.wantSize, 2)
+			cr := gomonitor.NewCheckResult()
+			cr.SetResult(gomonitor.Warning, "disk 91% used")
+			cr.AddLongOutput("/var 91%")
+			down := gomonitor.NewCheckResult()
+			down.SetResult(gomonitor.Critical, "unreachable")
+			c := &Client{Addr: addr, Password: "s3cret", Encryption: tc.method, MaxOutput: tc.maxOutput}
+			err := c.Send(context.Background(),
+				Result{Host: "web01", Service: "disk", Result: cr},
+				Result{Host: "web01", Result: down})
+			if err != nil {
+				t.Fatalf("Send returned %v", err)
+			}
+
+			packets := <-done
+			if len(packets) != 2 {
+				t.Fatalf("got %d packets, want 2", len(packets))
+			}
+			want := []received{
+				{3, 1700000000, 1, "web01", "disk", `Warning - disk 91% used\n/var 91%`, true},
+				{3, 1700000000, 1, "web01", "", "Critical - unreachable", true},
+			}
+			for i := range want {
+				if packets[i] != want[i] {
+					t.Errorf("got packet %+v, want %+v", packets[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSendTruncatesOutput(t *testing.T) {
+	addr, done := fakeDaemon(t, EncryptionNone, "", 720, 1)
+	cr := gomonitor.NewCheckResult()
+	cr.SetResult(gomonitor.OK, strings.Repeat("x", 600))
+	if err := (&Client{Addr: addr}).Send(context.Background(), Result{Host: "web01", Service: "log", Result: cr}); err != nil {
+		t.Fatal(err)
+	}
+	packets := <-done
+	if len(packets) != 1 || len(packets[0].output) != 511 || !packets[0].crcOK {
+		t.Errorf("got packets %+v, want a 511-byte output", packets)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	cr := gomonitor.NewCheckResult()
+	testCases := []struct {
+		name   string
+		client *Client
+		result Result
+	}{
+		{"Test no host", &Client{Addr: "127.0.0.1:1"}, Result{Service: "disk", Result: cr}},
+		{"Test long host", &Client{Addr: "127.0.0.1:1"}, Result{Host: strings.Repeat("h", 64), Result: cr}},
+		{"Test long service", &Client{Addr: "127.0.0.1:1"}, Result{Host: "web01", Service: strings.Repeat("s", 128), Result: cr}},
+		{"Test no check result", &Client{Addr: "127.0.0.1:1"}, Result{Host: "web01", Service: "disk"}},
+		{"Test unreachable", &Client{Addr: "127.0.0.1:1", Timeout: time.Second}, Result{Host: "web01", Result: cr}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.client.Send(context.Background(), tc.result); err == nil {
+				t.Error("Send returned no error")
+			}
+		})
+	}
+
+	addr, _ := fakeDaemon(t, EncryptionNone, "", 720, 0)
+	if err := (&Client{Addr: addr, Encryption: 8}).Send(context.Background(), Result{Host: "web01", Result: cr}); err == nil ||
+		!strings.Contains(err.Error(), "unsupported encryption") {
+		t.Errorf("got error %v, want unsupported encryption", err)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// OpenMetricsOptions configures OpenMetrics output.
+// - `Namespace` is prepended to every metric name with an underscore, such as "nagios".
+// - `Help` gives the # HELP text of metrics by perfdata name. Metrics without one are described by their name.
+// - `Labels` are added to every sample, after the CheckResult's Tags.
+type OpenMetricsOptions struct {
+	Namespace string
+	Help      map[string]string
+	Labels    map[string]string
+}
+
+// openMetricsUnits maps units of measure to an OpenMetrics base unit and the factor that
+// converts a value to it. Counters are handled separately.
+var openMetricsUnits = map[string]struct {
+	unit  string
+	scale float64
+}{
+	UOMSeconds:      {"seconds", 1},
+	UOMMilliseconds: {"seconds", 1e-3},
+	UOMMicroseconds: {"seconds", 1e-6},
+	UOMPercent:      {"ratio", 1e-2},
+	UOMBytes:        {"bytes", 1},
+	UOMKilobytes:    {"bytes", 1 << 10},
+	UOMMegabytes:    {"bytes", 1 << 20},
+	UOMGigabytes:    {"bytes", 1 << 30},
+	UOMTerabytes:    {"bytes", 1 << 40},
+}
+
+// OpenMetrics renders the result in the OpenMetrics 1.0 text format. The check state is
+// exposed as the gauge "check_state" with the ExitCode as its value, and every performance
+// metric becomes a metric family with # TYPE, # HELP and, when it has one, # UNIT lines.
+// Values are converted to base units, so "ms" becomes "_seconds" and "%" becomes a "_ratio"
+// between 0 and 1. Metrics with the "c" unit are counters. Perfdata names are reduced to the
+// characters OpenMetrics allows; a metric whose name and labels collide with an earlier one is
+// left out. Tags become labels on every sample. A metric with a Series is written under that
+// name with its Labels added, so the metrics of several sources merged with MergeLabeled form
+// one family.
+func (cr *CheckResult) OpenMetrics(opts OpenMetricsOptions) string {
+	labels := make(map[string]string)
+	for k, v := range cr.Tags {
+		labels[openMetricsName(k)] = v
+	}
+	for k, v := range opts.Labels {
+		labels[openMetricsName(k)] = v
+	}
+
+	// Samples are collected per family, so metrics of one Series that only differ in their
+	// Labels are written together as OpenMetrics requires
+	type omFamily struct {
+		name, typ, unit, help string
+		samples               []string
+	}
+	var families []*omFamily
+	byName := make(map[string]*omFamily)
+	family := func(name, typ, unit, help, sample string, sampleLabels map[string]string, value float64) {
+		f := byName[name]
+		if f == nil {
+			f = &omFamily{name: name, typ: typ, unit: unit, help: help}
+			byName[name] = f
+			families = append(families, f)
+		} else if f.typ != typ || f.unit != unit {
+			return
+		}
+		series := name + sample + openMetricsLabels(sampleLabels)
+		for _, s := range f.samples {
+			if strings.HasPrefix(s, series+" ") {
+				return
+			}
+		}
+		f.samples = append(f.samples, series+" "+strconv.FormatFloat(value, 'g', -1, 64))
+	}
+
+	prefix := ""
+	if opts.Namespace != "" {
+		prefix = openMetricsName(opts.Namespace) + "_"
+	}
+	family(prefix+"check_state", "gauge", "", "Check state: 0 OK, 1 Warning, 2 Critical, 3 Unknown.", "", labels, float64(cr.ExitCode.Int()))
+	for name, metric := range cr.Metrics() {
+		if metric.Series != "" {
+			name = metric.Series
+		}
+		sampleLabels := labels
+		if len(metric.Labels) > 0 {
+			sampleLabels = maps.Clone(labels)
+			for k, v := range metric.Labels {
+				sampleLabels[openMetricsName(k)] = v
+			}
+		}
+		help, ok := opts.Help[name]
+		if !ok {
+			help = "Performance metric " + name + "."
+		}
+		value := metric.Value
+		if metric.Unknown {
+			value = math.NaN()
+		}
+		base := prefix + openMetricsName(name)
+		if metric.UnitOM == UOMCounter {
+			family(strings.TrimSuffix(base, "_total"), "counter", "", help, "_total", sampleLabels, value)
+			continue
+		}
+		if u, ok := openMetricsUnits[metric.UnitOM]; ok {
+			if !strings.HasSuffix(base, "_"+u.unit) {
+				base += "_" + u.unit
+			}
+			family(base, "gauge", u.unit, help, "", sampleLabels, value*u.scale)
+			continue
+		}
+		family(base, "gauge", "", help, "", sampleLabels, value)
+	}
+
+	var b strings.Builder
+	for _, f := range families {
+		b.WriteString("# TYPE " + f.name + " " + f.typ + "\n")
+		if f.unit != "" {
+			b.WriteString("# UNIT " + f.name + " " + f.unit + "\n")
+		}
+		b.WriteString("# HELP " + f.name + " " + openMetricsEscape(f.help, false) + "\n")
+		for _, sample := range f.samples {
+			b.WriteString(sample + "\n")
+		}
+	}
+	b.WriteString("# EOF\n")
+	return b.String()
+}
+
+// openMetricsName replaces the characters a metric or label name may not contain with
+// underscores, and prefixes names that would start with a digit.
+func openMetricsName(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// openMetricsLabels renders labels as a sorted label set, or "" if there are none.
+func openMetricsLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var pairs []string
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, k+`="`+openMetricsEscape(labels[k], true)+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// openMetricsEscape escapes backslashes and newlines, and double quotes in label values.
+func openMetricsEscape(s string, quotes bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quotes {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+package gomonitor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOpenMetrics(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(Warning, "slow")
+	result.SetTag("env", "prod")
+	result.AddPerformanceData("response time", PerformanceMetric{Value: 250, UnitOM: UOMMilliseconds})
+	result.AddPerformanceData("disk", PerformanceMetric{Value: 75, UnitOM: UOMPercent})
+	result.AddPerformanceData("used", PerformanceMetric{Value: 2, UnitOM: UOMKilobytes})
+	result.AddPerformanceData("requests_total", PerformanceMetric{Value: 1234, UnitOM: UOMCounter})
+	result.AddPerformanceData("users", PerformanceMetric{Value: 3})
+	result.AddPerformanceData("load", PerformanceMetric{Unknown: true})
+
+	got := result.OpenMetrics(OpenMetricsOptions{
+		Namespace: "nagios",
+		Help:      map[string]string{"users": "Logged in users."},
+		Labels:    map[string]string{"host": `web"01`},
+	})
+	want := `# TYPE nagios_check_state gauge
+# HELP nagios_check_state Check state: 0 OK, 1 Warning, 2 Critical, 3 Unknown.
+nagios_check_state{env="prod",host="web\"01"} 1
+# TYPE nagios_response_time_seconds gauge
+# UNIT nagios_response_time_seconds seconds
+# HELP nagios_response_time_seconds Performance metric response time.
+nagios_response_time_seconds{env="prod",host="web\"01"} 0.25
+# TYPE nagios_disk_ratio gauge
+# UNIT nagios_disk_ratio ratio
+# HELP nagios_disk_ratio Performance metric disk.
+nagios_disk_ratio{env="prod",host="web\"01"} 0.75
+# TYPE nagios_used_bytes gauge
+# UNIT nagios_used_bytes bytes
+# HELP nagios_used_bytes Performance metric used.
+nagios_used_bytes{env="prod",host="web\"01"} 2048
+# TYPE nagios_requests counter
+# HELP nagios_requests Performance metric requests_total.
+nagios_requests_total{env="prod",host="web\"01"} 1234
+# TYPE nagios_users gauge
+# HELP nagios_users Logged in users.
+nagios_users{env="prod",host="web\"01"} 3
+# TYPE nagios_load gauge
+# HELP nagios_load Performance metric load.
+nagios_load{env="prod",host="web\"01"} NaN
+# EOF
+`
+	if got != want {
+		t.Errorf("OpenMetrics got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestOpenMetricsNames(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"Test valid", "cpu_user:rate", "cpu_user:rate"},
+		{"Test spaces and symbols", "/var used %", "_var_used__"},
+		{"Test leading digit", "5min load", "_5min_load"},
+		{"Test non-ASCII", "température", "temp_rature"},
+		{"Test empty", "", "_"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := openMetricsName(tc.in); got != tc.want {
+				t.Errorf("openMetricsName(%q) got %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOpenMetricsCollision(t *testing.T) {
+	result := NewCheckResult()
+	result.AddPerformanceData("a b", PerformanceMetric{Value: 1})
+	result.AddPerformanceData("a_b", PerformanceMetric{Value: 2})
+	got := result.OpenMetrics(OpenMetricsOptions{})
+	if strings.Count(got, "# TYPE a_b ") != 1 || !strings.Contains(got, "a_b 1\n") {
+		t.Errorf("OpenMetrics got %q, want only the first of the colliding metrics", got)
+	}
+	if !strings.HasSuffix(got, "# EOF\n") {
+		t.Errorf("OpenMetrics got %q, want it to end with # EOF", got)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParsePerformanceData parses a perfdata string (the part of the plugin output after the "|")
+// following the Nagios plugin API grammar:
+//
+//	'label'=value[UOM];[warn];[crit];[min];[max]
+//
+// It returns the metric names in the order they appear along with the parsed metrics, in the
+// same shape as CheckResult's PerfOrder and PerformanceData. Empty or missing fields parse as 0
+// and a "U" value sets Unknown.
+// Warn and crit fields that are ranges rather than plain numbers are parsed into WarnRange and
+// CritRange.
+func ParsePerformanceData(perfdata string) ([]string, map[string]PerformanceMetric, error) {
+	var order []string
+	metrics := make(map[string]PerformanceMetric)
+	s := perfdata
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			break
+		}
+		label, rest, err := parseLabel(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !strings.HasPrefix(rest, "=") {
+			return nil, nil, fmt.Errorf("perfdata: missing '=' after label %q", label)
+		}
+		field, remaining, _ := strings.Cut(rest[1:], " ")
+		s = remaining
+		metric, err := parseMetric(field)
+		if err != nil {
+			return nil, nil, fmt.Errorf("perfdata: label %q: %w", label, err)
+		}
+		if _, ok := metrics[label]; ok {
+			return nil, nil, fmt.Errorf("perfdata: duplicate label %q", label)
+		}
+		order = append(order, label)
+		metrics[label] = metric
+	}
+	return order, metrics, nil
+}
+
+// parseLabel reads a quoted or unquoted label from the start of s and returns it along with
+// the unconsumed input.
+func parseLabel(s string) (string, string, error) {
+	if s[0] != '\'' {
+		end := strings.IndexAny(s, "= \t")
+		if end == -1 || s[end] != '=' {
+			return "", "", fmt.Errorf("perfdata: invalid label in %q", s)
+		}
+		if end == 0 {
+			return "", "", fmt.Errorf("perfdata: empty label in %q", s)
+		}
+		return s[:end], s[end:], nil
+	}
+	var label strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != '\'' {
+			label.WriteByte(s[i])
+			continue
+		}
+		// A doubled quote is an escaped quote inside the label
+		if i+1 < len(s) && s[i+1] == '\'' {
+			label.WriteByte('\'')
+			i++
+			continue
+		}
+		if label.Len() == 0 {
+			return "", "", fmt.Errorf("perfdata: empty label in %q", s)
+		}
+		return label.String(), s[i+1:], nil
+	}
+	return "", "", fmt.Errorf("perfdata: unterminated label in %q", s)
+}
+
+// parseMetric parses the value[UOM];[warn];[crit];[min];[max] part of a perfdata entry.
+func parseMetric(field string) (PerformanceMetric, error) {
+	var metric PerformanceMetric
+	parts := strings.Split(field, ";")
+	if len(parts) > 5 {
+		return metric, fmt.Errorf("too many fields in %q", field)
+	}
+
+	if parts[0] == "U" {
+		metric.Unknown = true
+	} else if err := parseValue(parts[0], &metric); err != nil {
+		return metric, err
+	}
+
+	fields := []*float64{&metric.Warn, &metric.Crit, &metric.Min, &metric.Max}
+	ranges := []**Range{&metric.WarnRange, &metric.CritRange}
+	for i, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		v, err := parseNumber(part)
+		if err == nil {
+			*fields[i] = v
+			continue
+		}
+		if i >= len(ranges) {
+			return metric, fmt.Errorf("invalid field %q", part)
+		}
+		r, err := ParseRange(part)
+		if err != nil {
+			return metric, fmt.Errorf("invalid threshold %q", part)
+		}
+		*ranges[i] = &r
+	}
+	return metric, nil
+}
+
+// parseValue parses the value[UOM] field of a perfdata entry into metric.
+func parseValue(field string, metric *PerformanceMetric) error {
+	numEnd := strings.IndexFunc(field, func(r rune) bool {
+		return !strings.ContainsRune(numberChars, r)
+	})
+	if numEnd == -1 {
+		numEnd = len(field)
+	}
+	value, err := parseNumber(field[:numEnd])
+	if err != nil {
+		return fmt.Errorf("invalid value %q", field)
+	}
+	metric.Value = value
+	metric.UnitOM = field[numEnd:]
+	for _, r := range metric.UnitOM {
+		if r != '%' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return fmt.Errorf("invalid unit of measure %q", metric.UnitOM)
+		}
+	}
+	return nil
+}
+
+// numberChars are the characters the plugin API allows in perfdata numbers.
+const numberChars = "-0123456789."
+
+// parseNumber parses a perfdata number. Unlike strconv.ParseFloat it rejects exponents, hex
+// and the Inf/NaN spellings, none of which are valid perfdata.
+func parseNumber(s string) (float64, error) {
+	if s == "" || strings.Trim(s, numberChars) != "" {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// formatNumber formats a perfdata number with prec decimals, or the fewest that represent it
+// exactly when prec is -1. The output never uses exponent notation, however large or small v
+// is, and always uses "." as the decimal separator, as strconv ignores the locale. Values that
+// round to zero are written without a minus sign so the output does not depend on the sign of
+// rounding noise.
+func formatNumber(v float64, prec int) string {
+	s := strconv.FormatFloat(v, 'f', prec, 64)
+	if strings.HasPrefix(s, "-") && strings.Trim(s, "-0.") == "" {
+		s = s[1:]
+	}
+	return s
+}
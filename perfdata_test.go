@@ -0,0 +1,314 @@
+package gomonitor
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// perfdataGrammar matches a single perfdata entry as defined by the Nagios plugin API:
+// 'label'=value[UOM];[warn];[crit];[min];[max]
+var perfdataGrammar = regexp.MustCompile(
+	`^(?:'(?:[^']|'')+'|[^'=\s]+)=(?:U|` + number + `(?:s|ms|us|%|B|KB|MB|GB|TB|c)?)` +
+		`(?:;(?:` + threshold + `)?(?:;(?:` + threshold + `)?(?:;(?:` + number + `)?(?:;(?:` + number + `)?)?)?)?)?$`)
+
+// number and threshold are the numeric and range field patterns of perfdataGrammar.
+const (
+	number    = `-?[0-9]+(?:\.[0-9]+)?`
+	threshold = `@?(?:(?:~|` + number + `)?:)?(?:` + number + `)?`
+)
+
+// specUOMs are the units of measure the plugin API allows.
+var specUOMs = []string{"", "s", "ms", "us", "%", "B", "KB", "MB", "GB", "TB", "c"}
+
+// assertConformance checks that every perfdata entry in output matches the plugin API grammar
+// and that the perfdata parses back into the metrics of cr.
+func assertConformance(t *testing.T, cr *CheckResult) {
+	t.Helper()
+	output := cr.FormatResult()
+	sep := " | "
+	if cr.StrictFormat {
+		sep = "|"
+	}
+	_, perfdata, found := strings.Cut(output, sep)
+	if !found {
+		t.Fatalf("no perfdata in output %q", output)
+	}
+	for _, entry := range splitPerfdata(perfdata) {
+		if !perfdataGrammar.MatchString(entry) {
+			t.Errorf("perfdata entry %q does not match the plugin API grammar", entry)
+		}
+	}
+
+	order, metrics, err := ParsePerformanceData(perfdata)
+	if err != nil {
+		t.Fatalf("ParsePerformanceData(%q) failed: %v", perfdata, err)
+	}
+	if !reflect.DeepEqual(order, cr.PerfOrder) {
+		t.Errorf("got order %v, want %v", order, cr.PerfOrder)
+	}
+	for _, name := range cr.PerfOrder {
+		want := roundedMetric(cr.PerformanceData[name])
+		if got := metrics[name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("metric %q got %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+// splitPerfdata splits perfdata into entries on spaces outside quoted labels.
+func splitPerfdata(perfdata string) []string {
+	var entries []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range perfdata {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if current.Len() > 0 {
+				entries = append(entries, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		entries = append(entries, current.String())
+	}
+	return entries
+}
+
+// roundedMetric returns m with its numbers rounded the way the formatter writes them.
+func roundedMetric(m PerformanceMetric) PerformanceMetric {
+	round := func(v float64) float64 {
+		r, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'f', 2, 64), 64)
+		return r
+	}
+	m.Value = round(m.Value)
+	m.Warn = round(m.Warn)
+	m.Crit = round(m.Crit)
+	m.Min = round(m.Min)
+	m.Max = round(m.Max)
+	if m.Unknown {
+		// Only "U" is written for unknown values
+		m.Value, m.UnitOM = 0, ""
+	}
+	// The set flags only decide which fields are written; parsing does not restore them
+	m.WarnSet, m.CritSet, m.MinSet, m.MaxSet = false, false, false, false
+	return m
+}
+
+func TestParsePerformanceData(t *testing.T) {
+	testCases := []struct {
+		name      string
+		perfdata  string
+		wantOrder []string
+		want      map[string]PerformanceMetric
+		wantErr   bool
+	}{
+		{"Test Empty", "", nil, map[string]PerformanceMetric{}, false},
+		{"Test Full", "'load'=1.50;2;3;0;10", []string{"load"},
+			map[string]PerformanceMetric{"load": {Value: 1.5, Warn: 2, Crit: 3, Max: 10}}, false},
+		{"Test Unquoted With UOM", "time=12ms;;;0", []string{"time"},
+			map[string]PerformanceMetric{"time": {Value: 12, UnitOM: "ms"}}, false},
+		{"Test Value Only", "'a b'=5", []string{"a b"},
+			map[string]PerformanceMetric{"a b": {Value: 5}}, false},
+		{"Test Escaped Quote", "'it''s'=1", []string{"it's"},
+			map[string]PerformanceMetric{"it's": {Value: 1}}, false},
+		{"Test Multiple", "a=1 'b'=-2.5%  c=3c ", []string{"a", "b", "c"},
+			map[string]PerformanceMetric{
+				"a": {Value: 1},
+				"b": {Value: -2.5, UnitOM: "%"},
+				"c": {Value: 3, UnitOM: "c"},
+			}, false},
+		{"Test Ranges", "a=5;10:20;@~:30", []string{"a"},
+			map[string]PerformanceMetric{"a": {Value: 5, WarnRange: &Range{Start: 10, End: 20},
+				CritRange: &Range{Start: math.Inf(-1), End: 30, Invert: true}}}, false},
+		{"Test Unknown Value", "'latency'=U;100;200;0", []string{"latency"},
+			map[string]PerformanceMetric{"latency": {Unknown: true, Warn: 100, Crit: 200}}, false},
+		{"Test Unknown With UOM", "a=Ums", nil, nil, true},
+		{"Test Range In Min", "a=5;;;0:1", nil, nil, true},
+		{"Test Bad Range", "a=5;20:10", nil, nil, true},
+		{"Test Missing Equals", "'a' 1", nil, nil, true},
+		{"Test Unterminated Label", "'a=1", nil, nil, true},
+		{"Test Empty Label", "''=1", nil, nil, true},
+		{"Test Bad Value", "a=abc", nil, nil, true},
+		{"Test Exponent", "a=1e5", nil, nil, true},
+		{"Test Too Many Fields", "a=1;2;3;4;5;6", nil, nil, true},
+		{"Test Duplicate", "a=1 a=2", nil, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			order, metrics, err := ParsePerformanceData(tc.perfdata)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(order, tc.wantOrder) {
+				t.Errorf("got order %v, want %v", order, tc.wantOrder)
+			}
+			if !reflect.DeepEqual(metrics, tc.want) {
+				t.Errorf("got metrics %+v, want %+v", metrics, tc.want)
+			}
+		})
+	}
+}
+
+func TestPerformanceDataConformance(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(OK, "all good")
+	for i, uom := range specUOMs {
+		result.AddPerformanceData("metric "+uom, PerformanceMetric{
+			Value:  float64(i) * 1.25,
+			Warn:   10,
+			Crit:   20,
+			Min:    0,
+			Max:    100,
+			UnitOM: uom,
+		})
+	}
+	result.AddPerformanceData("negative", PerformanceMetric{Value: -3.5, Warn: -1, Crit: -2})
+	result.AddPerformanceData("large", PerformanceMetric{Value: 1e15, Max: 1e16})
+	result.AddPerformanceData("tiny", PerformanceMetric{Value: 0.004})
+	result.AddPerformanceData("unknown", PerformanceMetric{Value: 7, Warn: 1, UnitOM: "ms", Unknown: true})
+	result.AddPerformanceData("ranges", PerformanceMetric{
+		Value:     1,
+		WarnRange: &Range{Start: 10, End: math.Inf(1)},
+		CritRange: &Range{Start: math.Inf(-1), End: 2.5, Invert: true},
+	})
+
+	assertConformance(t, result)
+	result.StrictFormat = true
+	assertConformance(t, result)
+}
+
+func FuzzPerformanceDataRoundTrip(f *testing.F) {
+	f.Add("load", 1.5, 2.0, 3.0, 0.0, 10.0, 0)
+	f.Add("disk /", 95.123, 90.0, 95.0, 0.0, 100.0, 4)
+	f.Add("rx=bytes", -1.0, 0.0, 0.0, -10.0, 1e9, 5)
+
+	f.Fuzz(func(t *testing.T, label string, value, warn, crit, min, max float64, uom int) {
+		// Labels the serializer leaves out of the output
+		if ValidateLabel(label) != nil {
+			t.Skip()
+		}
+		for _, v := range []float64{value, warn, crit, min, max} {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				t.Skip()
+			}
+		}
+		if uom < 0 {
+			uom = -uom
+		}
+
+		result := NewCheckResult()
+		result.AddPerformanceData(label, PerformanceMetric{
+			Value:  value,
+			Warn:   warn,
+			Crit:   crit,
+			Min:    min,
+			Max:    max,
+			UnitOM: specUOMs[uom%len(specUOMs)],
+		})
+		assertConformance(t, result)
+		result.StrictFormat = true
+		assertConformance(t, result)
+		result.OmitZeroFields = true
+		assertConformance(t, result)
+	})
+}
+
+func FuzzParsePerformanceData(f *testing.F) {
+	f.Add("'load'=1.50;2;3;0;10")
+	f.Add("a=1 'b c'=2ms;;;0 'it''s'=3%")
+	f.Add("'x'=U;;;;")
+	f.Add("a=1;10:;@~:2.5")
+
+	f.Fuzz(func(t *testing.T, perfdata string) {
+		order, metrics, err := ParsePerformanceData(perfdata)
+		if err != nil {
+			return
+		}
+		// Anything the parser accepts must survive a format/parse cycle unchanged.
+		result := NewCheckResult()
+		for _, name := range order {
+			if ValidateLabel(name) != nil {
+				t.Skip()
+			}
+			result.AddPerformanceData(name, metrics[name])
+		}
+		if len(order) == 0 {
+			return
+		}
+		first := result.formatPerformanceData()
+		order2, metrics2, err := ParsePerformanceData(first)
+		if err != nil {
+			t.Fatalf("formatted perfdata %q does not parse: %v", first, err)
+		}
+		result2 := NewCheckResult()
+		for _, name := range order2 {
+			result2.AddPerformanceData(name, metrics2[name])
+		}
+		if second := result2.formatPerformanceData(); second != first {
+			t.Errorf("format/parse cycle not stable: %q != %q", second, first)
+		}
+	})
+}
+
+func TestFormatNumber(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value float64
+		prec  int
+		want  string
+	}{
+		{"Test Fixed", 1.5, 2, "1.50"},
+		{"Test Large", 1e7, 2, "10000000.00"},
+		{"Test Very Large", 1e21, 0, "1000000000000000000000"},
+		{"Test Small", 1e-7, 2, "0.00"},
+		{"Test Small Shortest", 1e-7, -1, "0.0000001"},
+		{"Test Negative", -2.5, 1, "-2.5"},
+		{"Test Negative Zero", math.Copysign(0, -1), 2, "0.00"},
+		{"Test Negative Rounds To Zero", -0.001, 2, "0.00"},
+		{"Test Negative Rounds To Zero Integer", -0.4, 0, "0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := formatNumber(tc.value, tc.prec); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	// The largest float64 is 309 digits long but must still be written in full
+	got := formatNumber(math.MaxFloat64, -1)
+	if len(got) != 309 || strings.Trim(got, "0123456789") != "" {
+		t.Errorf("formatNumber(math.MaxFloat64) got %q", got)
+	}
+}
+
+func TestPerformanceDataNoExponent(t *testing.T) {
+	result := NewCheckResult()
+	for _, precision := range []int{0, PrecisionShortest, 6} {
+		result.Precision = precision
+		result.AddPerformanceData("bytes", PerformanceMetric{Value: 1.5e15, Warn: 1e20, Crit: 1e-9, Max: math.MaxFloat64})
+		result.AddPerformanceData("range", PerformanceMetric{Value: 1e-12, WarnRange: &Range{Start: 1e-10, End: 1e25}})
+		perfdata := result.formatPerformanceData()
+		for _, entry := range splitPerfdata(perfdata) {
+			_, fields, _ := strings.Cut(entry, "=")
+			if strings.ContainsAny(fields, "eE,") {
+				t.Errorf("precision %d: perfdata %q uses exponent notation or a decimal comma", precision, perfdata)
+			}
+		}
+		result.DeletePerformanceData("bytes")
+		result.DeletePerformanceData("range")
+	}
+}
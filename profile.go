@@ -0,0 +1,129 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Profile describes what a particular monitoring core accepts from a plugin.
+// - `Name` identifies the profile.
+// - `MaxOutputLength` is the number of bytes of plugin output the core reads. 0 means unlimited.
+// - `StrictUOM` drops units of measure that are not in the plugin guidelines instead of emitting them.
+// - `UpperCaseState` writes the state in the summary line in upper case, such as "CRITICAL", as the reference plugins do.
+// - `PlainLabels` writes perfdata labels unquoted, replacing whitespace, quotes and equals signs with underscores.
+// - `ASCII` rewrites non-ASCII characters in the output for cores that cannot handle UTF-8.
+//
+// The zero Profile applies no adjustments.
+type Profile struct {
+	Name            string
+	MaxOutputLength int
+	StrictUOM       bool
+	UpperCaseState  bool
+	PlainLabels     bool
+	ASCII           ASCIIMode
+}
+
+var (
+	// ProfileNagios3 matches Nagios Core 3, which reads only the first 4KB of output
+	ProfileNagios3 = Profile{Name: "nagios3", MaxOutputLength: 4096, StrictUOM: true}
+	// ProfileIcinga2 matches Icinga 2, which has no output limit and accepts extended units
+	ProfileIcinga2 = Profile{Name: "icinga2"}
+	// ProfileNaemon matches Naemon, which reads up to 64KB of output
+	ProfileNaemon = Profile{Name: "naemon", MaxOutputLength: 65536, StrictUOM: true}
+	// ProfileShinken matches Shinken, whose perfdata parser only knows the standard units
+	ProfileShinken = Profile{Name: "shinken", StrictUOM: true}
+	// ProfileMRPE matches Checkmk's MRPE, which expects upper case states and unquoted labels
+	ProfileMRPE = Profile{Name: "mrpe", UpperCaseState: true, PlainLabels: true}
+	// ProfileNRPE matches NRPE 2, which passes on only the first 1KB of output
+	ProfileNRPE = Profile{Name: "nrpe", MaxOutputLength: 1024, StrictUOM: true}
+)
+
+// profiles indexes the predefined profiles by name.
+var profiles = map[string]Profile{
+	ProfileNagios3.Name: ProfileNagios3,
+	ProfileIcinga2.Name: ProfileIcinga2,
+	ProfileNaemon.Name:  ProfileNaemon,
+	ProfileShinken.Name: ProfileShinken,
+	ProfileNRPE.Name:    ProfileNRPE,
+	ProfileMRPE.Name:    ProfileMRPE,
+}
+
+// LookupProfile returns the predefined profile with the given name, such as one taken from a
+// command line flag. The boolean is false if there is no such profile.
+func LookupProfile(name string) (Profile, bool) {
+	p, ok := profiles[name]
+	return p, ok
+}
+
+// stateWord returns the word for ec in the summary line.
+func (p Profile) stateWord(ec ExitCode) string {
+	if p.UpperCaseState {
+		return strings.ToUpper(ec.String())
+	}
+	return ec.String()
+}
+
+// truncatedMarker is the line fitOutput ends shortened output with.
+const truncatedMarker = "(output truncated)"
+
+// fitOutput shortens the output so that join(text, entries) is at most max bytes, where text
+// is the summary followed by the long output lines. If everything fits it is returned as is.
+// Otherwise truncatedMarker is added as the last line and long output lines are dropped from
+// the end first, then whole perfdata entries, so the first line survives and the core never
+// sees half a metric. The summary is only cut once nothing else is left. The boolean reports
+// whether anything was dropped.
+func fitOutput(summary string, lines, entries []string, max int, join func(string, []string) string) (string, []string, bool) {
+	if text := joinLines(summary, lines); len(join(text, entries)) <= max {
+		return text, entries, false
+	}
+	marked := func(n int) string {
+		return joinLines(summary, append(lines[:n:n], truncatedMarker))
+	}
+	n := len(lines)
+	for n > 0 && len(join(marked(n), entries)) > max {
+		n--
+	}
+	text := marked(n)
+	for len(entries) > 0 && len(join(text, entries)) > max {
+		entries = entries[:len(entries)-1]
+	}
+	if len(text) > max {
+		// Keep the marker if there is room for it at all
+		suffix := "\n" + truncatedMarker
+		if max < len(suffix) {
+			suffix = ""
+		}
+		text = truncateUTF8(summary, max-len(suffix)) + suffix
+	}
+	return text, entries, true
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a multi-byte character.
+func truncateUTF8(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
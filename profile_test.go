@@ -0,0 +1,136 @@
+package gomonitor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupProfile(t *testing.T) {
+	for _, name := range []string{"nagios3", "icinga2", "naemon", "shinken", "nrpe", "mrpe"} {
+		p, ok := LookupProfile(name)
+		if !ok || p.Name != name {
+			t.Errorf("LookupProfile(%q) got %+v, %t", name, p, ok)
+		}
+	}
+	if _, ok := LookupProfile("bogus"); ok {
+		t.Error("LookupProfile found a profile for an unknown name")
+	}
+}
+
+func TestProfileStrictUOM(t *testing.T) {
+	testCases := []struct {
+		name    string
+		profile Profile
+		want    string
+	}{
+		{"Test Strict", ProfileNagios3, "OK - fine | 'rx'=1.00;0.00;0.00;0.00;0.00 'rt'=2.00ms;0.00;0.00;0.00;0.00 "},
+		{"Test Lenient", ProfileIcinga2, "OK - fine | 'rx'=1.00Mbit;0.00;0.00;0.00;0.00 'rt'=2.00ms;0.00;0.00;0.00;0.00 "},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NewCheckResult()
+			result.Profile = tc.profile
+			result.SetResult(OK, "fine")
+			result.AddPerformanceData("rx", PerformanceMetric{Value: 1, UnitOM: "Mbit"})
+			result.AddPerformanceData("rt", PerformanceMetric{Value: 2, UnitOM: "ms"})
+			if got := result.FormatResult(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProfileMaxOutputLength(t *testing.T) {
+	result := NewCheckResult()
+	result.Profile = Profile{MaxOutputLength: 65}
+	result.SetResult(OK, "fine")
+	result.AddPerformanceData("a", PerformanceMetric{Value: 1})
+	result.AddPerformanceData("b", PerformanceMetric{Value: 2})
+
+	want := "OK - fine\n(output truncated) | 'a'=1.00;0.00;0.00;0.00;0.00 "
+	if got := result.FormatResult(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	result.SetResult(OK, strings.Repeat("é", 40))
+	got := result.FormatResult()
+	if len(got) > 65 {
+		t.Errorf("got %d bytes, want at most 65", len(got))
+	}
+	first, _, _ := strings.Cut(got, "\n")
+	if !strings.HasPrefix(got, "OK - é") || strings.Contains(got, "|") || !strings.HasSuffix(first, "é") ||
+		!strings.HasSuffix(got, "\n(output truncated)") {
+		t.Errorf("got %q, want message cut on a character boundary with no perfdata and a marker", got)
+	}
+}
+
+func TestProfileMaxOutputLengthLongOutput(t *testing.T) {
+	result := NewCheckResult()
+	result.SetResult(Critical, "3 disks failed")
+	result.AddLongOutput("sda: failed")
+	result.AddLongOutput("sdb: failed")
+	result.AddLongOutput("sdc: failed")
+	result.AddPerformanceData("failed", PerformanceMetric{Value: 3, Integer: true})
+
+	testCases := []struct {
+		name string
+		max  int
+		want string
+	}{
+		{"Test fits", 100, "Critical - 3 disks failed\nsda: failed\nsdb: failed\nsdc: failed | 'failed'=3;0;0;0;0 "},
+		{"Test drops detail lines first", 80, "Critical - 3 disks failed\nsda: failed\n(output truncated) | 'failed'=3;0;0;0;0 "},
+		{"Test keeps first line and perfdata", 70, "Critical - 3 disks failed\n(output truncated) | 'failed'=3;0;0;0;0 "},
+		{"Test drops perfdata last", 50, "Critical - 3 disks failed\n(output truncated)"},
+		{"Test no room for marker", 10, "Critical -"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result.Profile = Profile{MaxOutputLength: tc.max}
+			got := result.FormatResult()
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if len(got) > tc.max {
+				t.Errorf("got %d bytes, want at most %d", len(got), tc.max)
+			}
+		})
+	}
+}
+
+func TestProfileMRPE(t *testing.T) {
+	result := NewCheckResult()
+	result.Profile = ProfileMRPE
+	result.SetResult(Critical, "disk full")
+	result.AddPerformanceData("/var used", PerformanceMetric{Value: 100, UnitOM: UOMPercent, Integer: true})
+	result.AddPerformanceData("it's=ok", PerformanceMetric{Value: 1, Integer: true})
+
+	want := "CRITICAL - disk full | /var_used=100%;0;0;0;0 it_s_ok=1;0;0;0;0 "
+	if got := result.FormatResult(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	testCases := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{"Test fits", "disk", 10, "disk"},
+		{"Test cut", "disk full", 4, "disk"},
+		{"Test multi-byte", "größe", 3, "gr"},
+		{"Test zero", "disk", 0, ""},
+		{"Test negative", "disk", -26, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := truncateUTF8(tc.s, tc.n); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Sampler summarizes the results of a check that runs more often than its results should be
+// delivered, such as a check embedded in a service that runs every few seconds. Results are
+// collected with Add and one summary per Interval is handed to Sink.
+// - `Interval` is how often a summary is delivered.
+// - `Sink` receives each summary.
+// - `Smooth` reduces each metric's values within an interval to one. nil means the mean.
+// - `Clock` times the intervals. nil means SystemClock.
+//
+// A Sampler is safe for concurrent use. Sink is called from Add or Flush, with the Sampler's
+// lock released.
+type Sampler struct {
+	Interval time.Duration
+	Sink     func(*CheckResult)
+	Smooth   Smoothing
+	Clock    Clock
+
+	mu      sync.Mutex
+	start   time.Time
+	results []*CheckResult
+}
+
+// Add records a result. If Interval has passed since the first result of the current window,
+// the window's summary is delivered to Sink and a new window starts.
+func (s *Sampler) Add(cr *CheckResult) {
+	s.mu.Lock()
+	t := ClockOrSystem(s.Clock).Now()
+	if len(s.results) == 0 {
+		s.start = t
+	}
+	s.results = append(s.results, cr)
+	var summary *CheckResult
+	if t.Sub(s.start) >= s.Interval {
+		summary = s.summarize()
+	}
+	s.mu.Unlock()
+	if summary != nil {
+		s.Sink(summary)
+	}
+}
+
+// Flush delivers the summary of the results recorded so far, if any, without waiting for the
+// interval to pass. Call it before shutting down so the last window is not lost.
+func (s *Sampler) Flush() {
+	s.mu.Lock()
+	var summary *CheckResult
+	if len(s.results) > 0 {
+		summary = s.summarize()
+	}
+	s.mu.Unlock()
+	if summary != nil {
+		s.Sink(summary)
+	}
+}
+
+// summarize combines the recorded results into one and starts a new window. The summary has
+// the most severe ExitCode, the message of the latest result with that ExitCode, and every
+// metric reduced with Smooth. Other metric fields, such as thresholds, are taken from the
+// latest result that has the metric. A metric that was unknown in every result stays unknown.
+func (s *Sampler) summarize() *CheckResult {
+	results := s.results
+	s.results = nil
+	smooth := s.Smooth
+	if smooth == nil {
+		smooth = TrimmedMean(0)
+	}
+
+	summary := NewCheckResult()
+	var worst *CheckResult
+	values := make(map[string][]float64)
+	latest := make(map[string]PerformanceMetric)
+	var order []string
+	for _, cr := range results {
+		if worst == nil || severity(cr.ExitCode) >= severity(worst.ExitCode) {
+			worst = cr
+		}
+		for name, metric := range cr.Metrics() {
+			if _, ok := latest[name]; !ok {
+				order = append(order, name)
+			}
+			latest[name] = metric
+			if !metric.Unknown && !math.IsNaN(metric.Value) {
+				values[name] = append(values[name], metric.Value)
+			}
+		}
+	}
+	summary.SetResult(worst.ExitCode, fmt.Sprintf("%s (worst of %d results)", worst.Message, len(results)))
+	for _, name := range order {
+		metric := latest[name]
+		metric.Unknown = len(values[name]) == 0
+		if !metric.Unknown {
+			metric.Value = smooth(values[name])
+		}
+		summary.AddPerformanceData(name, metric)
+	}
+	return summary
+}
@@ -0,0 +1,81 @@
+package gomonitor
+
+import (
+	"testing"
+	"time"
+)
+
+// sampleResult returns a result with a single "rt" metric.
+func sampleResult(ec ExitCode, msg string, rt float64) *CheckResult {
+	cr := NewCheckResult()
+	cr.SetResult(ec, msg)
+	cr.AddPerformanceData("rt", PerformanceMetric{Value: rt, Warn: 1, UnitOM: UOMSeconds})
+	return cr
+}
+
+func TestSampler(t *testing.T) {
+	var summaries []*CheckResult
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	s := &Sampler{
+		Interval: time.Minute,
+		Sink:     func(cr *CheckResult) { summaries = append(summaries, cr) },
+		Clock:    clock,
+	}
+
+	s.Add(sampleResult(OK, "fast", 0.2))
+	clock.Advance(20 * time.Second)
+	s.Add(sampleResult(Critical, "timeout", 3))
+	clock.Advance(20 * time.Second)
+	s.Add(sampleResult(Warning, "slow", 1.3))
+	if len(summaries) != 0 {
+		t.Fatalf("got %d summaries before the interval passed, want 0", len(summaries))
+	}
+	clock.Advance(20 * time.Second)
+	s.Add(sampleResult(OK, "fast", 0.3))
+	if len(summaries) != 1 {
+		t.Fatalf("got %d summaries after the interval passed, want 1", len(summaries))
+	}
+
+	got := summaries[0]
+	if got.ExitCode != Critical || got.Message != "timeout (worst of 4 results)" {
+		t.Errorf("got %s %q, want Critical with the timeout message", got.ExitCode, got.Message)
+	}
+	if rt := got.PerformanceData["rt"]; rt.Value != 1.2 || rt.Warn != 1 || rt.UnitOM != UOMSeconds {
+		t.Errorf("got rt %+v, want the mean 1.2 with the thresholds kept", rt)
+	}
+
+	// The next window starts empty
+	clock.Advance(time.Second)
+	s.Add(sampleResult(Warning, "slow", 2))
+	s.Flush()
+	s.Flush()
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries after Flush, want 2", len(summaries))
+	}
+	if got := summaries[1]; got.ExitCode != Warning || got.Message != "slow (worst of 1 results)" {
+		t.Errorf("got %s %q, want the single Warning result", got.ExitCode, got.Message)
+	}
+}
+
+func TestSamplerSmoothAndUnknown(t *testing.T) {
+	var summary *CheckResult
+	s := &Sampler{Interval: time.Hour, Smooth: Median, Sink: func(cr *CheckResult) { summary = cr }}
+	for _, rt := range []float64{1, 9, 2} {
+		s.Add(sampleResult(OK, "ok", rt))
+	}
+	unknown := NewCheckResult()
+	unknown.AddPerformanceData("rt", PerformanceMetric{Unknown: true})
+	unknown.AddPerformanceData("lost", PerformanceMetric{Unknown: true})
+	s.Add(unknown)
+	s.Flush()
+
+	if rt := summary.PerformanceData["rt"]; rt.Value != 2 || rt.Unknown {
+		t.Errorf("got rt %+v, want the median 2 ignoring the unknown sample", rt)
+	}
+	if lost := summary.PerformanceData["lost"]; !lost.Unknown {
+		t.Errorf("got lost %+v, want it unknown", lost)
+	}
+	if got, want := summary.PerfOrder, []string{"rt", "lost"}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got PerfOrder %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"errors"
+	"math"
+	"slices"
+)
+
+// Smoothing reduces several samples of the same measurement to a single value, so one noisy
+// sample does not decide the state of a check. Smoothing functions return NaN when given no
+// samples.
+type Smoothing func(samples []float64) float64
+
+// Median returns the median of the samples.
+func Median(samples []float64) float64 {
+	if len(samples) == 0 {
+		return math.NaN()
+	}
+	sorted := slices.Sorted(slices.Values(samples))
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+// TrimmedMean returns a Smoothing that discards the given fraction of samples from each end
+// before averaging the rest. A fraction of 0.1 drops the lowest and highest 10%. Fractions are
+// clamped to [0, 0.5); at least one sample is always kept.
+func TrimmedMean(fraction float64) Smoothing {
+	fraction = max(0, min(fraction, 0.5))
+	return func(samples []float64) float64 {
+		if len(samples) == 0 {
+			return math.NaN()
+		}
+		sorted := slices.Sorted(slices.Values(samples))
+		trim := int(float64(len(sorted)) * fraction)
+		if 2*trim >= len(sorted) {
+			trim = (len(sorted) - 1) / 2
+		}
+		kept := sorted[trim : len(sorted)-trim]
+		sum := 0.0
+		for _, v := range kept {
+			sum += v
+		}
+		return sum / float64(len(kept))
+	}
+}
+
+// SampleProbe calls probe n times and returns the samples reduced with smooth. It stops at the
+// first error returned by probe.
+func SampleProbe(n int, smooth Smoothing, probe func() (float64, error)) (float64, error) {
+	if n < 1 {
+		return 0, errors.New("gomonitor: SampleProbe needs at least one sample")
+	}
+	samples := make([]float64, 0, n)
+	for range n {
+		v, err := probe()
+		if err != nil {
+			return 0, err
+		}
+		samples = append(samples, v)
+	}
+	return smooth(samples), nil
+}
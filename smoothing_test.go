@@ -0,0 +1,77 @@
+package gomonitor
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestMedian(t *testing.T) {
+	testCases := []struct {
+		name    string
+		samples []float64
+		want    float64
+	}{
+		{"Test Odd", []float64{5, 1, 300}, 5},
+		{"Test Even", []float64{4, 1, 3, 2}, 2.5},
+		{"Test Single", []float64{7}, 7},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Median(tc.samples); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if got := Median(nil); !math.IsNaN(got) {
+		t.Errorf("Median(nil) got %v, want NaN", got)
+	}
+}
+
+func TestTrimmedMean(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fraction float64
+		samples  []float64
+		want     float64
+	}{
+		{"Test No Trim", 0, []float64{1, 2, 3, 10}, 4},
+		{"Test Trim Outliers", 0.2, []float64{100, 2, 3, 4, 1}, 3},
+		{"Test Clamped", 0.9, []float64{1, 2, 3, 4}, 2.5},
+		{"Test Single", 0.4, []float64{9}, 9},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := TrimmedMean(tc.fraction)(tc.samples); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSampleProbe(t *testing.T) {
+	samples := []float64{10, 500, 12}
+	calls := 0
+	got, err := SampleProbe(3, Median, func() (float64, error) {
+		v := samples[calls]
+		calls++
+		return v, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 12 || calls != 3 {
+		t.Errorf("got %v after %d calls, want 12 after 3 calls", got, calls)
+	}
+
+	probeErr := errors.New("timeout")
+	if _, err := SampleProbe(3, Median, func() (float64, error) { return 0, probeErr }); !errors.Is(err, probeErr) {
+		t.Errorf("got error %v, want %v", err, probeErr)
+	}
+	if _, err := SampleProbe(0, Median, nil); err == nil {
+		t.Error("SampleProbe with no samples succeeded, want error")
+	}
+}
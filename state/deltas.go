@@ -0,0 +1,96 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package state
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Deltas notes in a result's message how selected metrics changed since the previous run,
+// such as "connections=1500 (+400 since last run)", so a jump stands out without a graph.
+// The previous values are read from the check's latest run in a History, and the annotated
+// result is recorded there for the next run to compare with.
+// - `History` keeps the check's runs. Apply records each result, so do not also Record it.
+// - `Check` names the check in History.
+// - `Metrics` are the names of the performance metrics to annotate, in the order they are noted.
+type Deltas struct {
+	History *History
+	Check   string
+	Metrics []string
+}
+
+// Apply appends each selected metric's change since the previous run to the result's Message,
+// then records the result in History. Metrics that are missing, unknown or not finite in either
+// run are skipped. If History cannot be read the result is left unchanged and the error is
+// returned, as is an error recording the result.
+func (d Deltas) Apply(cr *gomonitor.CheckResult) error {
+	runs, err := d.History.Runs(d.Check)
+	if err != nil {
+		return err
+	}
+	var previous map[string]gomonitor.PerformanceMetric
+	if len(runs) > 0 {
+		previous = runs[len(runs)-1].Perfdata
+	}
+	var notes []string
+	for _, name := range d.Metrics {
+		metric, ok := cr.PerformanceData[name]
+		if !ok || !finite(metric) {
+			continue
+		}
+		last, ok := previous[name]
+		if !ok || !finite(last) {
+			continue
+		}
+		prec := cr.MetricPrecision(metric)
+		delta := formatValue(metric.Value-last.Value, prec) + metric.UnitOM
+		if !strings.HasPrefix(delta, "-") {
+			delta = "+" + delta
+		}
+		notes = append(notes, name+"="+formatValue(metric.Value, prec)+metric.UnitOM+
+			" ("+delta+" since last run)")
+	}
+	if len(notes) > 0 {
+		if cr.Message != "" {
+			cr.Message += ", "
+		}
+		cr.Message += strings.Join(notes, ", ")
+	}
+	return d.History.Record(d.Check, cr)
+}
+
+// finite reports whether the metric has a known, finite value to take a difference of.
+func finite(m gomonitor.PerformanceMetric) bool {
+	return !m.Unknown && !math.IsNaN(m.Value) && !math.IsInf(m.Value, 0)
+}
+
+// formatValue rounds v to prec decimals, unless prec is gomonitor.PrecisionShortest, and writes
+// it without trailing zeros, as suits a message.
+func formatValue(v float64, prec int) string {
+	if prec >= 0 {
+		v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'f', prec, 64), 64)
+	}
+	if v == 0 {
+		// Rounding can leave a negative zero
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,81 @@
+package state
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+func TestDeltas(t *testing.T) {
+	d := Deltas{
+		History: &History{Store: &Store{Path: filepath.Join(t.TempDir(), "state.json")}},
+		Check:   "db",
+		Metrics: []string{"connections", "latency", "cache", "missing"},
+	}
+	runs := []struct {
+		name        string
+		connections float64
+		latency     float64
+		cache       float64
+		want        string
+	}{
+		{"Test first run", 1100, 0.25, 80, "db ok"},
+		{"Test increase", 1500, 0.1, 80, "db ok, connections=1500 (+400 since last run), latency=0.1s (-0.15s since last run), cache=80% (+0% since last run)"},
+		{"Test unknown skipped", 1500, 0.1, math.NaN(), "db ok, connections=1500 (+0 since last run), latency=0.1s (+0s since last run)"},
+		{"Test after skipped", 1400, 0.1, 75.5, "db ok, connections=1400 (-100 since last run), latency=0.1s (+0s since last run)"},
+	}
+
+	for _, run := range runs {
+		t.Run(run.name, func(t *testing.T) {
+			cr := gomonitor.NewCheckResult()
+			cr.SetResult(gomonitor.OK, "db ok")
+			cr.AddPerformanceData("connections", gomonitor.PerformanceMetric{Value: run.connections, Integer: true})
+			cr.AddPerformanceData("latency", gomonitor.PerformanceMetric{Value: run.latency, UnitOM: "s"})
+			cr.AddPerformanceData("cache", gomonitor.PerformanceMetric{Value: run.cache, UnitOM: "%"})
+			if err := d.Apply(cr); err != nil {
+				t.Fatal(err)
+			}
+			if cr.Message != run.want {
+				t.Errorf("got message %q, want %q", cr.Message, run.want)
+			}
+		})
+	}
+
+	recorded, err := d.History.Runs("db")
+	if err != nil || len(recorded) != len(runs) || recorded[len(recorded)-1].Message != runs[len(runs)-1].want {
+		t.Errorf("got runs %+v, %v, want every annotated run recorded", recorded, err)
+	}
+}
+
+func TestDeltasError(t *testing.T) {
+	cr := gomonitor.NewCheckResult()
+	cr.SetResult(gomonitor.OK, "db ok")
+	cr.AddPerformanceData("connections", gomonitor.PerformanceMetric{Value: 1})
+	if err := (Deltas{History: &History{Store: &Store{}}, Metrics: []string{"connections"}}).Apply(cr); err == nil || cr.Message != "db ok" {
+		t.Errorf("got %v, message %q, want an error and the message unchanged", err, cr.Message)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	testCases := []struct {
+		name string
+		v    float64
+		prec int
+		want string
+	}{
+		{"Test integer", 1500, 0, "1500"},
+		{"Test rounded", 0.1 + 0.2, 2, "0.3"},
+		{"Test negative zero", -0.001, 2, "0"},
+		{"Test shortest", 0.00025, gomonitor.PrecisionShortest, "0.00025"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := formatValue(tc.v, tc.prec); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Lock timing. A lock file older than staleLock was left behind by a crashed run and is
+// removed.
+const (
+	lockTimeout = 5 * time.Second
+	lockRetry   = 10 * time.Millisecond
+	staleLock   = 30 * time.Second
+)
+
+// File is a Backend that keeps every value in the JSON file at Path. Each operation locks the
+// file, so plugin runs on the same host that overlap see each other's writes.
+// - `Path` is the file the values are kept in.
+// - `Clock` decides when values expire. nil means gomonitor.SystemClock. Waiting for the lock
+// always takes real time, as the holder is another process.
+type File struct {
+	Path  string
+	Clock gomonitor.Clock
+}
+
+// entry is a stored value and when it expires. A nil Expires never expires.
+type entry struct {
+	Value   json.RawMessage `json:"value"`
+	Expires *time.Time      `json:"expires,omitempty"`
+}
+
+// Get implements Backend.
+func (f *File) Get(key string) ([]byte, bool, error) {
+	var value []byte
+	var found bool
+	err := f.update(false, func(entries map[string]entry) error {
+		e, ok := entries[key]
+		value, found = e.Value, ok
+		return nil
+	})
+	return value, found, err
+}
+
+// Set implements Backend.
+func (f *File) Set(key string, value []byte, ttl time.Duration) error {
+	return f.update(true, func(entries map[string]entry) error {
+		entries[key] = newEntry(value, ttl, f.now())
+		return nil
+	})
+}
+
+// Delete implements Backend.
+func (f *File) Delete(key string) error {
+	return f.update(true, func(entries map[string]entry) error {
+		delete(entries, key)
+		return nil
+	})
+}
+
+// Add implements Backend.
+func (f *File) Add(key string, delta int64) (int64, error) {
+	var n int64
+	err := f.update(true, func(entries map[string]entry) error {
+		e, ok := entries[key]
+		if ok {
+			var err error
+			if n, err = parseCounter(e.Value); err != nil {
+				return err
+			}
+		}
+		n += delta
+		e.Value = strconv.AppendInt(nil, n, 10)
+		entries[key] = e
+		return nil
+	})
+	return n, err
+}
+
+// CompareAndSwap implements Backend.
+func (f *File) CompareAndSwap(key string, old, new []byte, ttl time.Duration) (bool, error) {
+	var swapped bool
+	err := f.update(true, func(entries map[string]entry) error {
+		e, ok := entries[key]
+		if ok != (old != nil) || ok && !bytes.Equal(e.Value, old) {
+			return nil
+		}
+		entries[key] = newEntry(new, ttl, f.now())
+		swapped = true
+		return nil
+	})
+	return swapped, err
+}
+
+// now returns the time on the file's Clock.
+func (f *File) now() time.Time {
+	return gomonitor.ClockOrSystem(f.Clock).Now()
+}
+
+// newEntry returns an entry for raw stored at now that expires after ttl, or never if ttl is 0.
+func newEntry(raw []byte, ttl time.Duration, now time.Time) entry {
+	e := entry{Value: raw}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		e.Expires = &expires
+	}
+	return e
+}
+
+// update locks the store, loads its unexpired entries and calls fn with them. If write is set
+// and fn succeeds, the entries are written back atomically.
+func (f *File) update(write bool, fn func(map[string]entry) error) error {
+	if f.Path == "" {
+		return errors.New("state: no store path")
+	}
+	unlock, err := lock(f.Path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries := make(map[string]entry)
+	data, err := os.ReadFile(f.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("state: %w", err)
+	default:
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return fmt.Errorf("state: corrupt store %s: %w", f.Path, err)
+		}
+	}
+	now := f.now()
+	for key, e := range entries {
+		if e.Expires != nil && !now.Before(*e.Expires) {
+			delete(entries, key)
+		}
+	}
+	if err := fn(entries); err != nil || !write {
+		return err
+	}
+
+	data, err = json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	return nil
+}
+
+// lock takes the lock file at path, waiting up to lockTimeout for another holder, and returns
+// the function that releases it.
+func lock(path string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("state: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("state: timed out waiting for lock %s", path)
+		}
+		time.Sleep(lockRetry)
+	}
+}
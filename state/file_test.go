@@ -0,0 +1,48 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := (&Store{Path: path}).Put("k", 7, 0); err != nil {
+		t.Fatal(err)
+	}
+	// A second Store on the same file sees the value
+	if n, ok, err := Get[int](&Store{Backend: &File{Path: path}}, "k"); err != nil || !ok || n != 7 {
+		t.Errorf("Get got %d, %t, %v, want 7", n, ok, err)
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	if err := (&Store{}).Put("k", 1, 0); err == nil {
+		t.Error("Put without a path returned no error")
+	}
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Store{Path: path}).Get("k", new(int)); err == nil {
+		t.Error("Get from a corrupt store returned no error")
+	}
+}
+
+func TestFileStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	lockPath := path + ".lock"
+	if err := os.WriteFile(lockPath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Store{Path: path}).Put("k", 1, 0); err != nil {
+		t.Errorf("Put with a stale lock got %v, want it removed", err)
+	}
+}
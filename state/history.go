@@ -0,0 +1,158 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// defaultHistorySize is how many runs a History keeps per check when Size is 0.
+const defaultHistorySize = 10
+
+// History keeps the most recent results of each check in a Store, for policies such as
+// "3 of the last 5 runs failed" and for troubleshooting without a time series database.
+// It is also an http.Handler serving a check's runs as JSON.
+// - `Store` keeps the runs, under the key "history:" followed by the check name.
+// - `Size` is how many runs are kept per check. 0 means 10.
+type History struct {
+	Store *Store
+	Size  int
+}
+
+// Run is a check result recorded by a History.
+// - `Time` is when the result was recorded.
+// - `ExitCode` is the result's state.
+// - `Message` is the result's message.
+// - `Perfdata` holds the result's performance metrics by name, as they were output.
+type Run struct {
+	Time     time.Time                              `json:"time"`
+	ExitCode gomonitor.ExitCode                     `json:"exit_code"`
+	Message  string                                 `json:"message"`
+	Perfdata map[string]gomonitor.PerformanceMetric `json:"perfdata,omitempty"`
+}
+
+// Record appends cr to the runs of check, dropping the oldest once there are more than Size.
+// The run keeps the metrics cr outputs, so trends can be read back without a time series
+// database. Concurrent Records of the same check are all kept.
+func (h *History) Record(check string, cr *gomonitor.CheckResult) error {
+	size := h.Size
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	run := Run{Time: gomonitor.ClockOrSystem(h.Store.Clock).Now(), ExitCode: cr.ExitCode, Message: cr.Message}
+	for name, metric := range cr.Metrics() {
+		if run.Perfdata == nil {
+			run.Perfdata = make(map[string]gomonitor.PerformanceMetric)
+		}
+		run.Perfdata[name] = metric
+	}
+	backend := h.Store.backend()
+	key := historyKey(check)
+	for {
+		old, _, err := backend.Get(key)
+		if err != nil {
+			return err
+		}
+		runs, err := decodeRuns(old)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+		runs = runs[max(len(runs)-size, 0):]
+		raw, err := json.Marshal(runs)
+		if err != nil {
+			return err
+		}
+		// The stored bytes are swapped as read, so a concurrent Record makes this one retry
+		swapped, err := backend.CompareAndSwap(key, old, raw, 0)
+		if err != nil || swapped {
+			return err
+		}
+	}
+}
+
+// Runs returns the recorded runs of check, oldest first.
+func (h *History) Runs(check string) ([]Run, error) {
+	raw, _, err := h.Store.backend().Get(historyKey(check))
+	if err != nil {
+		return nil, err
+	}
+	return decodeRuns(raw)
+}
+
+// Failed returns how many of the last n recorded runs of check were not OK. Fewer than n runs
+// may have been recorded, which only counts those.
+func (h *History) Failed(check string, n int) (int, error) {
+	runs, err := h.Runs(check)
+	if err != nil {
+		return 0, err
+	}
+	failed := 0
+	for _, run := range runs[max(len(runs)-n, 0):] {
+		if run.ExitCode != gomonitor.OK {
+			failed++
+		}
+	}
+	return failed, nil
+}
+
+// ServeHTTP writes the runs of the check named by the "check" query parameter as a JSON
+// array, oldest first.
+func (h *History) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	check := r.URL.Query().Get("check")
+	if check == "" {
+		http.Error(w, "missing check parameter", http.StatusBadRequest)
+		return
+	}
+	runs, err := h.Runs(check)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []Run{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(runs)
+}
+
+// historyKey returns the Store key the runs of check are kept under.
+func historyKey(check string) string {
+	return "history:" + check
+}
+
+// decodeRuns decodes stored runs. nil decodes to no runs.
+func decodeRuns(raw []byte) ([]Run, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var runs []Run
+	if err := json.Unmarshal(raw, &runs); err != nil {
+		return nil, fmt.Errorf("state: corrupt history: %w", err)
+	}
+	return runs, nil
+}
@@ -0,0 +1,134 @@
+package state
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+func TestHistory(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			h := &History{Store: b.store, Size: 5}
+			states := []gomonitor.ExitCode{gomonitor.OK, gomonitor.Critical, gomonitor.OK,
+				gomonitor.Warning, gomonitor.Critical, gomonitor.OK, gomonitor.Unknown}
+			for i, ec := range states {
+				cr := gomonitor.NewCheckResult()
+				cr.SetResult(ec, "run "+string(rune('0'+i)))
+				cr.AddPerformanceData("used", gomonitor.PerformanceMetric{Value: float64(i * 10), Crit: 90, UnitOM: gomonitor.UOMPercent})
+				cr.AddPerformanceData("inodes", gomonitor.PerformanceMetric{Unknown: true})
+				if err := h.Record("disk", cr); err != nil {
+					t.Fatal(err)
+				}
+				b.clock.Advance(time.Minute)
+			}
+
+			runs, err := h.Runs("disk")
+			if err != nil || len(runs) != 5 {
+				t.Fatalf("Runs got %d runs, %v, want 5", len(runs), err)
+			}
+			if runs[0].Message != "run 2" || runs[4].ExitCode != gomonitor.Unknown {
+				t.Errorf("got runs %+v, want runs 2 to 6", runs)
+			}
+			if want := time.Unix(1700000000, 0).Add(2 * time.Minute); !runs[0].Time.Equal(want) {
+				t.Errorf("got run 2 recorded at %s, want %s", runs[0].Time, want)
+			}
+			want := gomonitor.PerformanceMetric{Value: 60, Crit: 90, UnitOM: gomonitor.UOMPercent}
+			if got := runs[4].Perfdata["used"]; !reflect.DeepEqual(got, want) {
+				t.Errorf("got run 6 used %+v, want %+v", got, want)
+			}
+			if !runs[4].Perfdata["inodes"].Unknown {
+				t.Errorf("got run 6 inodes %+v, want it unknown", runs[4].Perfdata["inodes"])
+			}
+
+			testCases := []struct {
+				name string
+				n    int
+				want int
+			}{
+				{"Test last 3", 3, 2},
+				{"Test last 5", 5, 3},
+				{"Test more than kept", 10, 3},
+			}
+			for _, tc := range testCases {
+				if got, err := h.Failed("disk", tc.n); err != nil || got != tc.want {
+					t.Errorf("%s: Failed got %d, %v, want %d", tc.name, got, err, tc.want)
+				}
+			}
+
+			if runs, err := h.Runs("missing"); err != nil || len(runs) != 0 {
+				t.Errorf("Runs of an unrecorded check got %v, %v, want none", runs, err)
+			}
+		})
+	}
+}
+
+func TestHistoryConcurrent(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			h := &History{Store: b.store}
+			var wg sync.WaitGroup
+			for range 8 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := h.Record("load", gomonitor.NewCheckResult()); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			if runs, _ := h.Runs("load"); len(runs) != 8 {
+				t.Errorf("got %d runs, want 8", len(runs))
+			}
+		})
+	}
+}
+
+func TestHistoryServeHTTP(t *testing.T) {
+	h := &History{Store: &Store{Path: t.TempDir() + "/state.json"}}
+	cr := gomonitor.NewCheckResult()
+	cr.SetResult(gomonitor.Critical, "disk full")
+	if err := h.Record("disk", cr); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantRuns   int
+	}{
+		{"Test runs", http.MethodGet, "/?check=disk", http.StatusOK, 1},
+		{"Test unrecorded", http.MethodGet, "/?check=load", http.StatusOK, 0},
+		{"Test missing check", http.MethodGet, "/", http.StatusBadRequest, 0},
+		{"Test post", http.MethodPost, "/?check=disk", http.StatusMethodNotAllowed, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("got status %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var runs []Run
+			if err := json.Unmarshal(rec.Body.Bytes(), &runs); err != nil || len(runs) != tc.wantRuns {
+				t.Errorf("got body %s, want %d runs", rec.Body, tc.wantRuns)
+			}
+			if len(runs) > 0 && (runs[0].ExitCode != gomonitor.Critical || runs[0].Message != "disk full") {
+				t.Errorf("got run %+v", runs[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package state
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Rate raises a result's state when a metric changes faster than a limit, such as "Warning if
+// errors grow by more than 100 per minute", rather than when its value crosses one. The rate
+// is taken against the metric's value in the check's latest run in a History, and is added to
+// the result as a derived metric with the thresholds, so it can be graphed. Apply only reads
+// the History: record each result there afterwards, with History.Record or Deltas.Apply.
+// - `History` keeps the check's runs.
+// - `Check` names the check in History.
+// - `Metric` is the name of the performance metric whose rate of change is evaluated.
+// - `Per` is the interval the rate and its thresholds are counted over, such as time.Minute. 0 means a second.
+// - `Warn` and `Crit` are rates above which the result is Warning or Critical. 0 disables a threshold.
+// - `Name` names the derived metric. Empty means Metric followed by "_rate".
+type Rate struct {
+	History *History
+	Check   string
+	Metric  string
+	Per     time.Duration
+	Warn    float64
+	Crit    float64
+	Name    string
+}
+
+// Apply adds the metric's rate of change since the previous run to the result and raises the
+// result's ExitCode to Warning or Critical if the rate violates that threshold, noting the rate
+// in the Message. Nothing is added if either run lacks a known, finite value, if no time
+// passed between the runs, or if a counter ("c" unit) went down, which is a reset. An error
+// reading History is returned with the result unchanged.
+func (r Rate) Apply(cr *gomonitor.CheckResult) error {
+	metric, ok := cr.PerformanceData[r.Metric]
+	if !ok || !finite(metric) {
+		return nil
+	}
+	runs, err := r.History.Runs(r.Check)
+	if err != nil || len(runs) == 0 {
+		return err
+	}
+	last := runs[len(runs)-1]
+	previous, ok := last.Perfdata[r.Metric]
+	if !ok || !finite(previous) {
+		return nil
+	}
+	elapsed := gomonitor.ClockOrSystem(r.History.Store.Clock).Now().Sub(last.Time)
+	delta := metric.Value - previous.Value
+	if elapsed <= 0 || metric.UnitOM == gomonitor.UOMCounter && delta < 0 {
+		return nil
+	}
+
+	per := r.Per
+	if per <= 0 {
+		per = time.Second
+	}
+	rate := gomonitor.PerformanceMetric{Value: delta / elapsed.Seconds() * per.Seconds(), Warn: r.Warn, Crit: r.Crit}
+	name := r.Name
+	if name == "" {
+		name = r.Metric + "_rate"
+	}
+	cr.AddPerformanceData(name, rate)
+
+	state := rate.State()
+	switch {
+	case state == gomonitor.OK:
+		return nil
+	case state == gomonitor.Critical, cr.ExitCode != gomonitor.Critical:
+		cr.ExitCode = state
+	}
+	change := formatValue(rate.Value, cr.MetricPrecision(rate))
+	if !strings.HasPrefix(change, "-") {
+		change = "+" + change
+	}
+	if cr.Message != "" {
+		cr.Message += ", "
+	}
+	cr.Message += r.Metric + " changing by " + change + "/" + perUnit(per)
+	return nil
+}
+
+// perUnit names the interval a rate is counted over, as "s", "min" or "h" for whole units and
+// as a duration otherwise.
+func perUnit(per time.Duration) string {
+	switch per {
+	case time.Second:
+		return "s"
+	case time.Minute:
+		return "min"
+	case time.Hour:
+		return "h"
+	}
+	return per.String()
+}
@@ -0,0 +1,90 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+func TestRate(t *testing.T) {
+	clock := gomonitor.NewManualClock(time.Unix(1700000000, 0))
+	h := &History{Store: &Store{Path: filepath.Join(t.TempDir(), "state.json"), Clock: clock}}
+	r := Rate{History: h, Check: "app", Metric: "errors", Per: time.Minute, Warn: 100, Crit: 500}
+
+	runs := []struct {
+		name      string
+		after     time.Duration
+		errors    float64
+		exitCode  gomonitor.ExitCode
+		wantState gomonitor.ExitCode
+		wantRate  float64
+		wantMsg   string
+	}{
+		{"Test first run", 0, 1000, gomonitor.OK, gomonitor.OK, -1, "app ok"},
+		{"Test slow growth", time.Minute, 1050, gomonitor.OK, gomonitor.OK, 50, "app ok"},
+		{"Test at warning", 2 * time.Minute, 1250, gomonitor.OK, gomonitor.OK, 100, "app ok"},
+		{"Test warning", 30 * time.Second, 1320, gomonitor.OK, gomonitor.Warning, 140, "app ok, errors changing by +140/min"},
+		{"Test critical", time.Minute, 2000, gomonitor.Warning, gomonitor.Critical, 680, "app ok, errors changing by +680/min"},
+		{"Test warning keeps critical", time.Minute, 2200, gomonitor.Critical, gomonitor.Critical, 200, "app ok, errors changing by +200/min"},
+		{"Test counter reset", time.Minute, 10, gomonitor.OK, gomonitor.OK, -1, "app ok"},
+		{"Test no time passed", 0, 900, gomonitor.OK, gomonitor.OK, -1, "app ok"},
+	}
+
+	for _, run := range runs {
+		t.Run(run.name, func(t *testing.T) {
+			clock.Advance(run.after)
+			cr := gomonitor.NewCheckResult()
+			cr.SetResult(run.exitCode, "app ok")
+			cr.AddPerformanceData("errors", gomonitor.PerformanceMetric{Value: run.errors, UnitOM: gomonitor.UOMCounter})
+			if err := r.Apply(cr); err != nil {
+				t.Fatal(err)
+			}
+			if cr.ExitCode != run.wantState || cr.Message != run.wantMsg {
+				t.Errorf("got %s %q, want %s %q", cr.ExitCode, cr.Message, run.wantState, run.wantMsg)
+			}
+			rate, ok := cr.PerformanceData["errors_rate"]
+			if run.wantRate < 0 && ok || run.wantRate >= 0 && rate.Value != run.wantRate {
+				t.Errorf("got rate %+v, %t, want %v", rate, ok, run.wantRate)
+			}
+			if err := h.Record("app", cr); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRateGauge(t *testing.T) {
+	clock := gomonitor.NewManualClock(time.Unix(1700000000, 0))
+	h := &History{Store: &Store{Path: filepath.Join(t.TempDir(), "state.json"), Clock: clock}}
+	r := Rate{History: h, Check: "disk", Metric: "free", Name: "free_change", Warn: 5}
+	for _, free := range []float64{100, 40} {
+		cr := gomonitor.NewCheckResult()
+		cr.SetResult(gomonitor.OK, "disk ok")
+		cr.AddPerformanceData("free", gomonitor.PerformanceMetric{Value: free, UnitOM: gomonitor.UOMGigabytes})
+		if err := r.Apply(cr); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Record("disk", cr); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(10 * time.Second)
+		if free == 40 {
+			// A falling gauge has a negative rate, which stays below an upper limit
+			if got := cr.PerformanceData["free_change"].Value; got != -6 || cr.ExitCode != gomonitor.OK {
+				t.Errorf("got rate %v and %s, want -6 and OK", got, cr.ExitCode)
+			}
+		}
+	}
+}
+
+func TestRateError(t *testing.T) {
+	cr := gomonitor.NewCheckResult()
+	cr.SetResult(gomonitor.OK, "app ok")
+	cr.AddPerformanceData("errors", gomonitor.PerformanceMetric{Value: 1})
+	r := Rate{History: &History{Store: &Store{}}, Metric: "errors", Warn: 1}
+	if err := r.Apply(cr); err == nil || cr.Message != "app ok" || len(cr.PerfOrder) != 1 {
+		t.Errorf("got %v, message %q, want an error and the result unchanged", err, cr.Message)
+	}
+}
@@ -0,0 +1,219 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package state
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRedisTimeout bounds each Redis operation when the backend sets no Timeout.
+const defaultRedisTimeout = 5 * time.Second
+
+// casScript swaps in ARGV[2], with a TTL of ARGV[3] milliseconds or none if it is 0, only if
+// the key holds ARGV[1], so CompareAndSwap is atomic on the server.
+const casScript = `if redis.call('GET', KEYS[1]) ~= ARGV[1] then return 0 end ` +
+	`if ARGV[3] == '0' then redis.call('SET', KEYS[1], ARGV[2]) ` +
+	`else redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3]) end return 1`
+
+// Redis is a Backend that keeps values in a Redis server shared by several pollers. Each
+// operation uses a new connection, which suits plugins that run once and exit. Expiry uses
+// Redis's own key TTLs.
+// - `Addr` is the server's "host:port".
+// - `Username` and `Password` authenticate with AUTH when Password is set. An empty Username uses the default user.
+// - `DB` is the database number to SELECT. 0 is Redis's default database.
+// - `Prefix` is prepended to every key, such as "gomonitor:", to share a database with other applications.
+// - `Timeout` bounds each operation, including connecting. 0 means 5 seconds.
+type Redis struct {
+	Addr     string
+	Username string
+	Password string
+	DB       int
+	Prefix   string
+	Timeout  time.Duration
+}
+
+// RedisError is an error reply from the Redis server.
+type RedisError string
+
+// Error implements error.
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// Get implements Backend.
+func (r *Redis) Get(key string) ([]byte, bool, error) {
+	reply, err := r.do("GET", r.Prefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("state: unexpected GET reply %v", reply)
+	}
+	return value, true, nil
+}
+
+// Set implements Backend.
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", r.Prefix + key, string(value)}
+	if ms := ttl.Milliseconds(); ms > 0 {
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+	_, err := r.do(args...)
+	return err
+}
+
+// Delete implements Backend.
+func (r *Redis) Delete(key string) error {
+	_, err := r.do("DEL", r.Prefix+key)
+	return err
+}
+
+// Add implements Backend with INCRBY, which keeps the key's TTL.
+func (r *Redis) Add(key string, delta int64) (int64, error) {
+	reply, err := r.do("INCRBY", r.Prefix+key, strconv.FormatInt(delta, 10))
+	var redisErr RedisError
+	if errors.As(err, &redisErr) && strings.Contains(string(redisErr), "not an integer") {
+		return 0, ErrNotCounter
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("state: unexpected INCRBY reply %v", reply)
+	}
+	return n, nil
+}
+
+// CompareAndSwap implements Backend. A nil old uses SET NX; otherwise a Lua script compares
+// and sets the value in one step.
+func (r *Redis) CompareAndSwap(key string, old, new []byte, ttl time.Duration) (bool, error) {
+	ms := max(ttl.Milliseconds(), 0)
+	if old == nil {
+		args := []string{"SET", r.Prefix + key, string(new), "NX"}
+		if ms > 0 {
+			args = append(args, "PX", strconv.FormatInt(ms, 10))
+		}
+		reply, err := r.do(args...)
+		return reply != nil, err
+	}
+	reply, err := r.do("EVAL", casScript, "1", r.Prefix+key, string(old), string(new), strconv.FormatInt(ms, 10))
+	return reply == int64(1), err
+}
+
+// do connects to the server, authenticates, selects the database and sends a single command,
+// returning its reply: nil, a string for a status, []byte for a bulk string or an int64.
+func (r *Redis) do(args ...string) (any, error) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = defaultRedisTimeout
+	}
+	conn, err := net.DialTimeout("tcp", r.Addr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("state: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, fmt.Errorf("state: %w", err)
+	}
+
+	var commands [][]string
+	if r.Password != "" {
+		auth := []string{"AUTH", r.Password}
+		if r.Username != "" {
+			auth = []string{"AUTH", r.Username, r.Password}
+		}
+		commands = append(commands, auth)
+	}
+	if r.DB != 0 {
+		commands = append(commands, []string{"SELECT", strconv.Itoa(r.DB)})
+	}
+	commands = append(commands, args)
+	var req strings.Builder
+	for _, command := range commands {
+		req.WriteString("*" + strconv.Itoa(len(command)) + "\r\n")
+		for _, arg := range command {
+			req.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+		}
+	}
+	if _, err := io.WriteString(conn, req.String()); err != nil {
+		return nil, fmt.Errorf("state: %w", err)
+	}
+
+	// Replies arrive in order; the setup commands' replies only matter if they are errors
+	rd := bufio.NewReader(conn)
+	var reply any
+	for range commands {
+		if reply, err = readReply(rd); err != nil {
+			return nil, err
+		}
+	}
+	return reply, nil
+}
+
+// readReply reads one RESP reply.
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("state: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("state: empty redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("state: invalid redis integer %q", line)
+		}
+		return n, nil
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("state: invalid redis bulk length %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, fmt.Errorf("state: %w", err)
+		}
+		return buf[:size], nil
+	case '*':
+		// Only a nil array is expected, such as a SET NX that did not set
+		if line == "*-1" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("state: unexpected redis reply %q", line)
+	default:
+		return nil, fmt.Errorf("state: unexpected redis reply %q", line)
+	}
+}
@@ -0,0 +1,211 @@
+package state
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// fakeRedis is an in-memory server for the commands the Redis backend sends.
+type fakeRedis struct {
+	mu      sync.Mutex
+	clock   gomonitor.Clock
+	values  map[string]string
+	expires map[string]time.Time
+	// commands records every command name received, in order
+	commands []string
+}
+
+// newFakeRedis starts a fake server requiring the password "secret", expiring keys by clock,
+// and returns a backend connected to it.
+func newFakeRedis(t *testing.T, clock gomonitor.Clock) *Redis {
+	srv, addr := startFakeRedis(t)
+	srv.mu.Lock()
+	srv.clock = clock
+	srv.mu.Unlock()
+	return &Redis{Addr: addr, Password: "secret", DB: 2, Prefix: "gm:"}
+}
+
+// startFakeRedis starts a fake server and returns it and its address.
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	srv := &fakeRedis{values: make(map[string]string), expires: make(map[string]time.Time)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv, ln.Addr().String()
+}
+
+// serve answers the commands on conn until it is closed.
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	authed := false
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args[0])
+		var reply string
+		if args[0] == "AUTH" {
+			authed = args[len(args)-1] == "secret"
+			reply = "+OK\r\n"
+			if !authed {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		} else if !authed {
+			reply = "-NOAUTH Authentication required.\r\n"
+		} else {
+			reply = s.exec(args)
+		}
+		s.mu.Unlock()
+		io.WriteString(conn, reply)
+	}
+}
+
+// exec runs a command and returns its encoded reply.
+func (s *fakeRedis) exec(args []string) string {
+	get := func(key string) (string, bool) {
+		if exp, ok := s.expires[key]; ok && !gomonitor.ClockOrSystem(s.clock).Now().Before(exp) {
+			delete(s.values, key)
+			delete(s.expires, key)
+		}
+		v, ok := s.values[key]
+		return v, ok
+	}
+	set := func(key, value string, px string) {
+		s.values[key] = value
+		delete(s.expires, key)
+		if ms, _ := strconv.Atoi(px); ms > 0 {
+			s.expires[key] = gomonitor.ClockOrSystem(s.clock).Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+	}
+	bulk := func(v string) string { return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n" }
+
+	switch args[0] {
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		if v, ok := get(args[1]); ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "SET":
+		var nx bool
+		var px string
+		for i := 3; i < len(args); i++ {
+			switch args[i] {
+			case "NX":
+				nx = true
+			case "PX":
+				i++
+				px = args[i]
+			}
+		}
+		if _, ok := get(args[1]); ok && nx {
+			return "*-1\r\n"
+		}
+		set(args[1], args[2], px)
+		return "+OK\r\n"
+	case "DEL":
+		delete(s.values, args[1])
+		delete(s.expires, args[1])
+		return ":1\r\n"
+	case "INCRBY":
+		v, _ := get(args[1])
+		if v == "" {
+			v = "0"
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		delta, _ := strconv.ParseInt(args[2], 10, 64)
+		s.values[args[1]] = strconv.FormatInt(n+delta, 10)
+		return ":" + s.values[args[1]] + "\r\n"
+	case "EVAL":
+		if args[1] != casScript {
+			return "-ERR unknown script\r\n"
+		}
+		if v, ok := get(args[3]); !ok || v != args[4] {
+			return ":0\r\n"
+		}
+		set(args[3], args[5], args[6])
+		return ":1\r\n"
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+// readCommand reads a RESP array of bulk strings.
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, errors.New("bad command")
+	}
+	args := make([]string, n)
+	for i := range args {
+		reply, err := readReply(rd)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := reply.([]byte)
+		if !ok {
+			return nil, errors.New("bad argument")
+		}
+		args[i] = string(b)
+	}
+	return args, nil
+}
+
+func TestRedisCommands(t *testing.T) {
+	srv, addr := startFakeRedis(t)
+	r := &Redis{Addr: addr, Password: "secret", DB: 2, Prefix: "gm:"}
+	if err := r.Set("k", []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if got, want := strings.Join(srv.commands, " "), "AUTH SELECT SET"; got != want {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+	if _, ok := srv.values["gm:k"]; !ok {
+		t.Errorf("got keys %v, want gm:k", srv.values)
+	}
+}
+
+func TestRedisErrors(t *testing.T) {
+	_, addr := startFakeRedis(t)
+	_, _, err := (&Redis{Addr: addr, Password: "wrong"}).Get("k")
+	var redisErr RedisError
+	if !errors.As(err, &redisErr) || !strings.HasPrefix(string(redisErr), "WRONGPASS") {
+		t.Errorf("got error %v, want WRONGPASS", err)
+	}
+
+	if _, _, err := (&Redis{Addr: "127.0.0.1:1", Timeout: time.Second}).Get("k"); err == nil {
+		t.Error("Get from an unreachable server returned no error")
+	}
+}
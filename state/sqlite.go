@@ -0,0 +1,204 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package state
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// defaultTable is the table a SQLite backend uses when none is given.
+const defaultTable = "gomonitor_state"
+
+// tablePattern matches the table names a SQLite backend accepts, since the name cannot be
+// passed as a query parameter.
+var tablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLite is a Backend that keeps values in a table of a SQLite database. Keep the database file
+// on a local disk: SQLite's file locking is unreliable on network file systems such as NFS and
+// can corrupt a shared database. Pollers on several hosts should share a SQLite server such as
+// rqlite instead. The caller opens DB with the driver of their choice; this package does not
+// import one. Every operation is a
+// single statement, so it is atomic without a transaction. SQLite 3.24 or later is required
+// for upserts.
+// - `DB` is the open database.
+// - `Table` is the table the values are kept in. Empty means "gomonitor_state".
+// - `Clock` decides when values expire. nil means gomonitor.SystemClock.
+type SQLite struct {
+	DB    *sql.DB
+	Table string
+	Clock gomonitor.Clock
+}
+
+// CreateTable creates the backend's table if it does not exist yet. Call it once before
+// using the backend.
+func (s *SQLite) CreateTable() error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.Exec("CREATE TABLE IF NOT EXISTS " + table +
+		" (key TEXT PRIMARY KEY, value BLOB NOT NULL, expires INTEGER NOT NULL)")
+	return err
+}
+
+// Get implements Backend.
+func (s *SQLite) Get(key string) ([]byte, bool, error) {
+	table, err := s.table()
+	if err != nil {
+		return nil, false, err
+	}
+	var value []byte
+	err = s.DB.QueryRow("SELECT value FROM "+table+" WHERE key = ? AND (expires = 0 OR expires > ?)",
+		key, s.now()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("state: %w", err)
+	}
+	return value, true, nil
+}
+
+// Set implements Backend.
+func (s *SQLite) Set(key string, value []byte, ttl time.Duration) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.Exec("INSERT INTO "+table+" (key, value, expires) VALUES (?, ?, ?)"+
+		" ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires = excluded.expires",
+		key, value, s.expiresAt(ttl))
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Backend.
+func (s *SQLite) Delete(key string) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	if _, err := s.DB.Exec("DELETE FROM "+table+" WHERE key = ?", key); err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	return nil
+}
+
+// Add implements Backend. It reads the counter and swaps in the new value until no other
+// writer changed it in between.
+func (s *SQLite) Add(key string, delta int64) (int64, error) {
+	table, err := s.table()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		raw, ok, err := s.Get(key)
+		if err != nil {
+			return 0, err
+		}
+		var n int64
+		if ok {
+			if n, err = parseCounter(raw); err != nil {
+				return 0, err
+			}
+		}
+		n += delta
+		next := strconv.AppendInt(nil, n, 10)
+		var swapped bool
+		if ok {
+			// Keep the counter's expiry
+			swapped, err = s.exec("UPDATE "+table+" SET value = ? WHERE key = ? AND value = ?"+
+				" AND (expires = 0 OR expires > ?)", next, key, raw, s.now())
+		} else {
+			swapped, err = s.insertMissing(table, key, next, 0)
+		}
+		if err != nil || swapped {
+			return n, err
+		}
+	}
+}
+
+// CompareAndSwap implements Backend.
+func (s *SQLite) CompareAndSwap(key string, old, new []byte, ttl time.Duration) (bool, error) {
+	table, err := s.table()
+	if err != nil {
+		return false, err
+	}
+	if old == nil {
+		return s.insertMissing(table, key, new, s.expiresAt(ttl))
+	}
+	return s.exec("UPDATE "+table+" SET value = ?, expires = ? WHERE key = ? AND value = ?"+
+		" AND (expires = 0 OR expires > ?)", new, s.expiresAt(ttl), key, old, s.now())
+}
+
+// insertMissing stores value under key only if the key is missing or expired, and reports
+// whether it did.
+func (s *SQLite) insertMissing(table, key string, value []byte, expires int64) (bool, error) {
+	return s.exec("INSERT INTO "+table+" (key, value, expires) VALUES (?, ?, ?)"+
+		" ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires = excluded.expires"+
+		" WHERE expires != 0 AND expires <= ?", key, value, expires, s.now())
+}
+
+// exec runs a statement and reports whether it changed a row.
+func (s *SQLite) exec(query string, args ...any) (bool, error) {
+	res, err := s.DB.Exec(query, args...)
+	if err != nil {
+		return false, fmt.Errorf("state: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("state: %w", err)
+	}
+	return n > 0, nil
+}
+
+// table returns the validated table name.
+func (s *SQLite) table() (string, error) {
+	if s.DB == nil {
+		return "", errors.New("state: no database")
+	}
+	if s.Table == "" {
+		return defaultTable, nil
+	}
+	if !tablePattern.MatchString(s.Table) {
+		return "", fmt.Errorf("state: invalid table name %q", s.Table)
+	}
+	return s.Table, nil
+}
+
+// now returns the time on the backend's Clock as Unix milliseconds, as expiry times are kept.
+func (s *SQLite) now() int64 {
+	return gomonitor.ClockOrSystem(s.Clock).Now().UnixMilli()
+}
+
+// expiresAt returns the Unix millisecond time a value stored now with ttl expires at, or 0 if
+// it never does.
+func (s *SQLite) expiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return gomonitor.ClockOrSystem(s.Clock).Now().Add(ttl).UnixMilli()
+}
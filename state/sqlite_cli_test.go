@@ -0,0 +1,176 @@
+//go:build sqlite
+
+package state
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// cliDriver is a database/sql driver that runs every statement through the sqlite3 command
+// line shell, so the SQLite backend's SQL is tested against real SQLite without this module
+// depending on a driver. Arguments are inlined as SQL literals and each statement runs in its
+// own sqlite3 process. The data source name is the database file.
+type cliDriver struct{}
+
+func init() {
+	sql.Register("sqlite3cli", cliDriver{})
+	newSQLite = newCLISQLite
+}
+
+// newCLISQLite returns a SQLite backend on a new database file with its table created. The
+// test is skipped if sqlite3 is not installed.
+func newCLISQLite(t *testing.T) *SQLite {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	db, err := sql.Open("sqlite3cli", filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := &SQLite{DB: db}
+	if err := s.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (cliDriver) Open(name string) (driver.Conn, error) { return cliConn(name), nil }
+
+type cliConn string
+
+func (c cliConn) Prepare(query string) (driver.Stmt, error) { return &cliStmt{string(c), query}, nil }
+func (c cliConn) Close() error                              { return nil }
+func (c cliConn) Begin() (driver.Tx, error)                 { return nil, errors.New("transactions not supported") }
+
+type cliStmt struct {
+	path  string
+	query string
+}
+
+func (s *cliStmt) Close() error  { return nil }
+func (s *cliStmt) NumInput() int { return strings.Count(s.query, "?") }
+
+func (s *cliStmt) Exec(args []driver.Value) (driver.Result, error) {
+	query, err := inline(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := runSQLite(s.path, query+";\nSELECT changes();")
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseInt(lines[len(lines)-1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected changes() output %q", lines)
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (s *cliStmt) Query(args []driver.Value) (driver.Rows, error) {
+	query, err := inline(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := runSQLite(s.path, query+";")
+	if err != nil {
+		return nil, err
+	}
+	rows := &cliRows{}
+	for _, line := range lines {
+		v, err := parseLiteral(line)
+		if err != nil {
+			return nil, err
+		}
+		rows.values = append(rows.values, v)
+	}
+	return rows, nil
+}
+
+// cliRows holds the rows of a single-column query, which is all the backend sends.
+type cliRows struct {
+	values []driver.Value
+}
+
+func (r *cliRows) Columns() []string { return []string{"value"} }
+func (r *cliRows) Close() error      { return nil }
+
+func (r *cliRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+// runSQLite runs sql on the database at path and returns the non-empty output lines. Values are
+// output as SQL literals, so blobs and text come back exactly. The busy timeout lets concurrent
+// processes wait for each other's locks.
+func runSQLite(path, sql string) ([]string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("sqlite3", "-batch", "-bail", "-cmd", ".timeout 5000", "-cmd", ".mode quote", path)
+	cmd.Stdin = strings.NewReader(sql)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("sqlite3: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// inline replaces each ? in query with the next argument as a SQL literal. The backend's
+// queries have no ? inside string literals.
+func inline(query string, args []driver.Value) (string, error) {
+	var b strings.Builder
+	for _, arg := range args {
+		before, after, ok := strings.Cut(query, "?")
+		if !ok {
+			return "", errors.New("more arguments than placeholders")
+		}
+		b.WriteString(before)
+		switch v := arg.(type) {
+		case int64:
+			b.WriteString(strconv.FormatInt(v, 10))
+		case string:
+			b.WriteString("'" + strings.ReplaceAll(v, "'", "''") + "'")
+		case []byte:
+			b.WriteString("X'" + hex.EncodeToString(v) + "'")
+		default:
+			return "", fmt.Errorf("unsupported argument type %T", arg)
+		}
+		query = after
+	}
+	b.WriteString(query)
+	return b.String(), nil
+}
+
+// parseLiteral parses a value printed in the sqlite3 quote mode.
+func parseLiteral(s string) (driver.Value, error) {
+	switch {
+	case strings.HasPrefix(s, "X'") && strings.HasSuffix(s, "'"):
+		return hex.DecodeString(s[2 : len(s)-1])
+	case strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'"):
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s == "NULL":
+		return nil, nil
+	default:
+		return strconv.ParseInt(s, 10, 64)
+	}
+}
@@ -0,0 +1,180 @@
+package state
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDriver is a database/sql driver that understands exactly the statements the SQLite
+// backend sends, keeping one in-memory table per data source name. It checks the backend's
+// logic, not SQLite's handling of its SQL; build the tests with -tags sqlite to run them
+// against a real database instead.
+type fakeDriver struct {
+	mu     sync.Mutex
+	tables map[string]map[string]fakeRow
+}
+
+// fakeRow is a row of the state table.
+type fakeRow struct {
+	value   []byte
+	expires int64
+}
+
+var sqliteDriver = &fakeDriver{tables: make(map[string]map[string]fakeRow)}
+
+// newSQLite returns the SQLite backend the shared backend tests run on. The sqlite build tag
+// replaces the fake with a real database.
+var newSQLite = newFakeSQLite
+
+func init() {
+	sql.Register("fakesqlite", sqliteDriver)
+}
+
+// newFakeSQLite returns a SQLite backend on a new fake database with its table created.
+func newFakeSQLite(t *testing.T) *SQLite {
+	sqliteDriver.mu.Lock()
+	delete(sqliteDriver.tables, t.Name())
+	sqliteDriver.mu.Unlock()
+	db, err := sql.Open("fakesqlite", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := &SQLite{DB: db}
+	if err := s.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d: d, db: name}, nil
+}
+
+type fakeConn struct {
+	d  *fakeDriver
+	db string
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{c, query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("no transactions") }
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return strings.Count(s.query, "?") }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.c.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	q := s.query
+	if strings.HasPrefix(q, "CREATE TABLE IF NOT EXISTS gomonitor_state ") {
+		if d.tables[s.c.db] == nil {
+			d.tables[s.c.db] = make(map[string]fakeRow)
+		}
+		return driver.RowsAffected(0), nil
+	}
+	table := d.tables[s.c.db]
+	if table == nil {
+		return nil, errors.New("no such table")
+	}
+	live := func(key string, now int64) (fakeRow, bool) {
+		row, ok := table[key]
+		return row, ok && (row.expires == 0 || row.expires > now)
+	}
+	switch {
+	case strings.HasPrefix(q, "INSERT") && strings.HasSuffix(q, "WHERE expires != 0 AND expires <= ?"):
+		key, now := args[0].(string), args[3].(int64)
+		if _, ok := table[key]; ok {
+			if _, ok := live(key, now); ok {
+				return driver.RowsAffected(0), nil
+			}
+		}
+		table[key] = fakeRow{args[1].([]byte), args[2].(int64)}
+	case strings.HasPrefix(q, "INSERT"):
+		table[args[0].(string)] = fakeRow{args[1].([]byte), args[2].(int64)}
+	case strings.HasPrefix(q, "DELETE"):
+		delete(table, args[0].(string))
+	case strings.Contains(q, "SET value = ?, expires = ? WHERE key = ? AND value = ?"):
+		key := args[2].(string)
+		row, ok := live(key, args[4].(int64))
+		if !ok || !bytes.Equal(row.value, args[3].([]byte)) {
+			return driver.RowsAffected(0), nil
+		}
+		table[key] = fakeRow{args[0].([]byte), args[1].(int64)}
+	case strings.Contains(q, "SET value = ? WHERE key = ? AND value = ?"):
+		key := args[1].(string)
+		row, ok := live(key, args[3].(int64))
+		if !ok || !bytes.Equal(row.value, args[2].([]byte)) {
+			return driver.RowsAffected(0), nil
+		}
+		table[key] = fakeRow{args[0].([]byte), row.expires}
+	default:
+		return nil, fmt.Errorf("unexpected statement %q", q)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.c.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !strings.HasPrefix(s.query, "SELECT value FROM gomonitor_state WHERE key = ?") {
+		return nil, fmt.Errorf("unexpected query %q", s.query)
+	}
+	row, ok := d.tables[s.c.db][args[0].(string)]
+	if !ok || row.expires != 0 && row.expires <= args[1].(int64) {
+		return &fakeRows{}, nil
+	}
+	return &fakeRows{values: [][]byte{row.value}}, nil
+}
+
+type fakeRows struct {
+	values [][]byte
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+func TestSQLiteTable(t *testing.T) {
+	testCases := []struct {
+		name    string
+		backend *SQLite
+		want    string
+		wantErr bool
+	}{
+		{"Test default", &SQLite{DB: &sql.DB{}}, "gomonitor_state", false},
+		{"Test custom", &SQLite{DB: &sql.DB{}, Table: "poller_state"}, "poller_state", false},
+		{"Test injection", &SQLite{DB: &sql.DB{}, Table: "state; DROP TABLE x"}, "", true},
+		{"Test no database", &SQLite{}, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.backend.table()
+			if got != tc.want || (err != nil) != tc.wantErr {
+				t.Errorf("got table %q, %v, want %q", got, err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package state gives checks a small persistent key-value store for values that must survive
+// between runs, such as baselines, tokens and cursors. A Store keeps its values in a Backend:
+// a local JSON file by default, or a SQLite table or Redis server shared by several pollers,
+// so instances behind a load balancer see each other's rate, flap and acknowledgement state.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// ErrNotCounter is returned by Add when the value under the key is not an integer.
+var ErrNotCounter = errors.New("state: value is not a counter")
+
+// Backend stores raw JSON values by key for a Store. Every method must be atomic with respect
+// to the others, including across processes sharing the backend. Expired values are treated
+// as missing.
+type Backend interface {
+	// Get returns the value stored under key and whether there was one.
+	Get(key string) ([]byte, bool, error)
+	// Set stores value under key. A ttl of 0 keeps the value until it is replaced or deleted.
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(key string) error
+	// Add adds delta to the integer under key, starting a missing key at 0, and returns the
+	// new value. The key keeps its expiry time. A value that is not an integer is ErrNotCounter.
+	Add(key string, delta int64) (int64, error)
+	// CompareAndSwap stores new under key with ttl only if the current value is byte for byte
+	// old, and reports whether it did. A nil old means the key must be missing.
+	CompareAndSwap(key string, old, new []byte, ttl time.Duration) (bool, error)
+}
+
+// Store is a key-value store for values encoding/json handles.
+// - `Path` is the file the values are kept in when Backend is nil.
+// - `Backend` keeps the values. nil means a File at Path.
+// - `Clock` dates the runs a History records, and the expiry of values in the File at Path. A Backend given explicitly uses its own Clock. nil means gomonitor.SystemClock.
+type Store struct {
+	Path    string
+	Backend Backend
+	Clock   gomonitor.Clock
+}
+
+// A Store can keep the state of a gomonitor.GracePeriod
+var _ gomonitor.StateStore = (*Store)(nil)
+
+// backend returns the Backend the store's values are kept in.
+func (s *Store) backend() Backend {
+	if s.Backend != nil {
+		return s.Backend
+	}
+	return &File{Path: s.Path, Clock: s.Clock}
+}
+
+// Get decodes the value stored under key into v and reports whether there was one. Expired
+// values are treated as missing.
+func (s *Store) Get(key string, v any) (bool, error) {
+	raw, ok, err := s.backend().Get(key)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Get returns the value stored under key in s as a T, and whether there was one.
+func Get[T any](s *Store, key string) (T, bool, error) {
+	var v T
+	ok, err := s.Get(key, &v)
+	return v, ok, err
+}
+
+// Put stores v under key. A ttl of 0 keeps the value until it is replaced or deleted.
+func (s *Store) Put(key string, v any, ttl time.Duration) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.backend().Set(key, raw, ttl)
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (s *Store) Delete(key string) error {
+	return s.backend().Delete(key)
+}
+
+// Add atomically adds delta to the integer counter under key and returns the new value. A
+// missing or expired counter starts at 0. The counter keeps its expiry time, if it has one.
+func (s *Store) Add(key string, delta int64) (int64, error) {
+	n, err := s.backend().Add(key, delta)
+	if errors.Is(err, ErrNotCounter) {
+		return 0, fmt.Errorf("state: %q is not a counter: %w", key, err)
+	}
+	return n, err
+}
+
+// CompareAndSwap stores new under key with the given ttl only if the current value is old, and
+// reports whether it did. Values are compared by their JSON encoding. A nil old means the key
+// must be missing or expired.
+func (s *Store) CompareAndSwap(key string, old, new any, ttl time.Duration) (bool, error) {
+	newRaw, err := json.Marshal(new)
+	if err != nil {
+		return false, err
+	}
+	var oldRaw []byte
+	if old != nil {
+		if oldRaw, err = json.Marshal(old); err != nil {
+			return false, err
+		}
+	}
+	return s.backend().CompareAndSwap(key, oldRaw, newRaw, ttl)
+}
+
+// parseCounter parses a counter value stored by Add.
+func parseCounter(raw []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, ErrNotCounter
+	}
+	return n, nil
+}
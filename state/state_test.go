@@ -0,0 +1,179 @@
+package state
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// backends returns a new, empty Store on each backend, with the clock the store and its
+// backend tell the time by.
+func backends(t *testing.T) []struct {
+	name  string
+	store *Store
+	clock *gomonitor.ManualClock
+} {
+	fileClock := gomonitor.NewManualClock(time.Unix(1700000000, 0))
+	sqliteClock := gomonitor.NewManualClock(time.Unix(1700000000, 0))
+	sqlite := newSQLite(t)
+	sqlite.Clock = sqliteClock
+	redisClock := gomonitor.NewManualClock(time.Unix(1700000000, 0))
+	return []struct {
+		name  string
+		store *Store
+		clock *gomonitor.ManualClock
+	}{
+		{"File", &Store{Path: filepath.Join(t.TempDir(), "state.json"), Clock: fileClock}, fileClock},
+		{"SQLite", &Store{Backend: sqlite, Clock: sqliteClock}, sqliteClock},
+		{"Redis", &Store{Backend: newFakeRedis(t, redisClock), Clock: redisClock}, redisClock},
+	}
+}
+
+type cursor struct {
+	File   string
+	Offset int64
+}
+
+func TestPutGet(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			s := b.store
+			if _, ok, err := Get[cursor](s, "log"); ok || err != nil {
+				t.Fatalf("Get on an empty store got %t, %v, want false, nil", ok, err)
+			}
+			want := cursor{File: "/var/log/syslog", Offset: 4096}
+			if err := s.Put("log", want, 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Put("token", "abc", time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			got, ok, err := Get[cursor](s, "log")
+			if err != nil || !ok || got != want {
+				t.Errorf("Get got %+v, %t, %v, want %+v", got, ok, err, want)
+			}
+			if token, ok, err := Get[string](s, "token"); err != nil || !ok || token != "abc" {
+				t.Errorf("Get token got %q, %t, %v, want abc", token, ok, err)
+			}
+
+			if err := s.Delete("log"); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, _ := Get[cursor](s, "log"); ok {
+				t.Error("Get found a deleted key")
+			}
+			if err := s.Delete("missing"); err != nil {
+				t.Errorf("Delete of a missing key got %v", err)
+			}
+		})
+	}
+}
+
+func TestTTL(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			s := b.store
+			if err := s.Put("baseline", 42.5, time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			b.clock.Advance(5 * time.Millisecond)
+			if _, ok, err := Get[float64](s, "baseline"); ok || err != nil {
+				t.Errorf("Get after expiry got %t, %v, want false, nil", ok, err)
+			}
+			if swapped, err := s.CompareAndSwap("baseline", nil, 1.5, 0); !swapped || err != nil {
+				t.Errorf("CompareAndSwap on an expired key got %t, %v, want true", swapped, err)
+			}
+		})
+	}
+}
+
+func TestAdd(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			s := b.store
+			var wg sync.WaitGroup
+			for range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := s.Add("runs", 1); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			if n, err := s.Add("runs", -5); err != nil || n != 15 {
+				t.Errorf("Add got %d, %v, want 15", n, err)
+			}
+			if n, _, err := Get[int64](s, "runs"); err != nil || n != 15 {
+				t.Errorf("Get counter got %d, %v, want 15", n, err)
+			}
+
+			if err := s.Put("name", "web01", 0); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Add("name", 1); !errors.Is(err, ErrNotCounter) {
+				t.Errorf("Add to a string got %v, want ErrNotCounter", err)
+			}
+		})
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	testCases := []struct {
+		name string
+		old  any
+		new  any
+		want bool
+	}{
+		{"Test create missing", nil, "v1", true},
+		{"Test create existing", nil, "v2", false},
+		{"Test stale value", "v0", "v2", false},
+		{"Test current value", "v1", "v2", true},
+	}
+
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			for _, tc := range testCases {
+				swapped, err := b.store.CompareAndSwap("leader", tc.old, tc.new, time.Hour)
+				if err != nil || swapped != tc.want {
+					t.Errorf("%s: CompareAndSwap got %t, %v, want %t", tc.name, swapped, err, tc.want)
+				}
+			}
+			if v, _, _ := Get[string](b.store, "leader"); v != "v2" {
+				t.Errorf("got leader %q, want v2", v)
+			}
+		})
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			g := gomonitor.GracePeriod{Store: b.store, Key: "web", Duration: 5 * time.Minute, Clock: b.clock}
+			for _, step := range []struct {
+				exitCode gomonitor.ExitCode
+				want     gomonitor.ExitCode
+			}{
+				{gomonitor.Critical, gomonitor.Warning},
+				{gomonitor.Critical, gomonitor.Critical},
+				{gomonitor.OK, gomonitor.OK},
+				{gomonitor.Critical, gomonitor.Warning},
+			} {
+				cr := gomonitor.NewCheckResult()
+				cr.SetResult(step.exitCode, "web")
+				if err := g.Apply(cr); err != nil {
+					t.Fatal(err)
+				}
+				if cr.ExitCode != step.want {
+					t.Errorf("got exitCode %s at %s, want %s", cr.ExitCode, b.clock.Now().UTC(), step.want)
+				}
+				b.clock.Advance(10 * time.Minute)
+			}
+		})
+	}
+}
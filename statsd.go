@@ -0,0 +1,145 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"math"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits for sending to StatsD. Each datagram stays within a typical Ethernet MTU so it is not
+// fragmented.
+const (
+	statsdTimeout   = 10 * time.Second
+	statsdMaxPacket = 1432
+)
+
+// statsdTimers maps time units of measure to the factor that converts a value to milliseconds.
+var statsdTimers = map[string]float64{
+	UOMSeconds:      1e3,
+	UOMMilliseconds: 1,
+	UOMMicroseconds: 1e-3,
+}
+
+// StatsDOptions configures StatsD output.
+// - `Prefix` is prepended to every metric name with a dot, such as "nagios.web01".
+// - `SampleRate` sends each timer with this probability, between 0 and 1, and tags it with "|@rate" so StatsD scales it back up. 0 and 1 send every one. Gauges, counters included, are always sent.
+type StatsDOptions struct {
+	Prefix     string
+	SampleRate float64
+}
+
+// statsdLine is a rendered metric and whether it is subject to sampling.
+type statsdLine struct {
+	text    string
+	sampled bool
+}
+
+// StatsD renders the performance metrics in the StatsD line protocol, one "name:value|type"
+// line per metric, including every sampled line. Metrics with a time unit of measure are
+// timers in milliseconds. All others are gauges, including those with the "c" unit: those hold
+// a running total, and a StatsD counter would add the whole total again on every run. A negative
+// gauge is preceded by a zero gauge, since StatsD reads a signed gauge value as a change. Metric names are cleaned as for Graphite and metrics without a
+// finite value are left out.
+func (cr *CheckResult) StatsD(opts StatsDOptions) string {
+	var b strings.Builder
+	for _, line := range cr.statsdLines(opts) {
+		b.WriteString(line.text + "\n")
+	}
+	return b.String()
+}
+
+// SendStatsD sends the performance metrics, as rendered by StatsD, to the StatsD server at the
+// UDP address addr, such as "localhost:8125". Timers are sampled at the options'
+// SampleRate. Nothing is sent when there are no metrics.
+func (cr *CheckResult) SendStatsD(addr string, opts StatsDOptions) error {
+	var packets []string
+	var packet strings.Builder
+	for _, line := range cr.statsdLines(opts) {
+		if line.sampled && rand.Float64() >= opts.SampleRate {
+			continue
+		}
+		if packet.Len() > 0 && packet.Len()+1+len(line.text) > statsdMaxPacket {
+			packets = append(packets, packet.String())
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line.text)
+	}
+	if packet.Len() > 0 {
+		packets = append(packets, packet.String())
+	}
+	if len(packets) == 0 {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("udp", addr, statsdTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetWriteDeadline(time.Now().Add(statsdTimeout)); err != nil {
+		return err
+	}
+	for _, p := range packets {
+		if _, err := conn.Write([]byte(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statsdLines renders each performance metric as StatsD lines.
+func (cr *CheckResult) statsdLines(opts StatsDOptions) []statsdLine {
+	rate := ""
+	sampling := opts.SampleRate > 0 && opts.SampleRate < 1
+	if sampling {
+		rate = "|@" + strconv.FormatFloat(opts.SampleRate, 'f', -1, 64)
+	}
+	prefix := strings.Trim(opts.Prefix, ".")
+	if prefix != "" {
+		prefix += "."
+	}
+
+	var lines []statsdLine
+	for name, metric := range cr.Metrics() {
+		if metric.Unknown || math.IsNaN(metric.Value) || math.IsInf(metric.Value, 0) {
+			continue
+		}
+		name = prefix + graphitePath(name)
+		if scale, ok := statsdTimers[metric.UnitOM]; ok {
+			lines = append(lines, statsdLine{name + ":" + statsdNumber(metric.Value*scale) + "|ms" + rate, sampling})
+			continue
+		}
+		if metric.Value < 0 {
+			lines = append(lines, statsdLine{name + ":0|g", false})
+		}
+		lines = append(lines, statsdLine{name + ":" + statsdNumber(metric.Value) + "|g", false})
+	}
+	return lines
+}
+
+// statsdNumber formats a value for StatsD, which does not accept exponents.
+func statsdNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,118 @@
+package gomonitor
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsD(t *testing.T) {
+	result := NewCheckResult()
+	result.AddPerformanceData("rta", PerformanceMetric{Value: 0.25, UnitOM: UOMSeconds})
+	result.AddPerformanceData("requests", PerformanceMetric{Value: 120, UnitOM: UOMCounter})
+	result.AddPerformanceData("disk /var", PerformanceMetric{Value: 91, UnitOM: UOMPercent})
+	result.AddPerformanceData("offset", PerformanceMetric{Value: -0.5})
+	result.AddPerformanceData("load", PerformanceMetric{Unknown: true})
+
+	testCases := []struct {
+		name string
+		opts StatsDOptions
+		want string
+	}{
+		{"Test prefix", StatsDOptions{Prefix: "nagios.web01."}, "nagios.web01.rta:250|ms\n" +
+			"nagios.web01.requests:120|g\n" +
+			"nagios.web01.disk__var:91|g\n" +
+			"nagios.web01.offset:0|g\n" +
+			"nagios.web01.offset:-0.5|g\n"},
+		{"Test sample rate", StatsDOptions{SampleRate: 0.25}, "rta:250|ms|@0.25\n" +
+			"requests:120|g\n" +
+			"disk__var:91|g\n" +
+			"offset:0|g\n" +
+			"offset:-0.5|g\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := result.StatsD(tc.opts); got != tc.want {
+				t.Errorf("StatsD got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// listenStatsD returns a UDP listener and a function reading the datagrams sent to it.
+func listenStatsD(t *testing.T) (*net.UDPConn, func() []string) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, func() []string {
+		var packets []string
+		buf := make([]byte, 65536)
+		for {
+			conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, err := conn.Read(buf)
+			if err != nil {
+				return packets
+			}
+			packets = append(packets, string(buf[:n]))
+		}
+	}
+}
+
+func TestSendStatsD(t *testing.T) {
+	t.Run("Test send", func(t *testing.T) {
+		conn, read := listenStatsD(t)
+		result := NewCheckResult()
+		result.AddPerformanceData("rta", PerformanceMetric{Value: 12, UnitOM: UOMMilliseconds})
+		result.AddPerformanceData("users", PerformanceMetric{Value: 3})
+		if err := result.SendStatsD(conn.LocalAddr().String(), StatsDOptions{Prefix: "web01"}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := read(), []string{"web01.rta:12|ms\nweb01.users:3|g"}; len(got) != 1 || got[0] != want[0] {
+			t.Errorf("got packets %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Test sampled out", func(t *testing.T) {
+		conn, read := listenStatsD(t)
+		result := NewCheckResult()
+		result.AddPerformanceData("rta", PerformanceMetric{Value: 12, UnitOM: UOMMilliseconds})
+		result.AddPerformanceData("users", PerformanceMetric{Value: 3})
+		if err := result.SendStatsD(conn.LocalAddr().String(), StatsDOptions{SampleRate: 1e-12}); err != nil {
+			t.Fatal(err)
+		}
+		if got := read(); len(got) != 1 || got[0] != "users:3|g" {
+			t.Errorf("got packets %q, want only the gauge", got)
+		}
+	})
+
+	t.Run("Test split packets", func(t *testing.T) {
+		conn, read := listenStatsD(t)
+		result := NewCheckResult()
+		for i := range 200 {
+			result.AddPerformanceData(fmt.Sprintf("interface_%03d_octets", i), PerformanceMetric{Value: float64(i)})
+		}
+		if err := result.SendStatsD(conn.LocalAddr().String(), StatsDOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		packets := read()
+		lines := 0
+		for _, p := range packets {
+			if len(p) > statsdMaxPacket {
+				t.Errorf("got a %d byte packet, want at most %d", len(p), statsdMaxPacket)
+			}
+			lines += len(strings.Split(p, "\n"))
+		}
+		if len(packets) < 2 || lines != 200 {
+			t.Errorf("got %d packets with %d lines, want 200 lines over several packets", len(packets), lines)
+		}
+	})
+
+	if err := NewCheckResult().SendStatsD("invalid address", StatsDOptions{}); err != nil {
+		t.Errorf("SendStatsD without metrics got %v, want nothing sent", err)
+	}
+}
@@ -0,0 +1,262 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package syslog turns syslog messages into passive check results. A Listener receives
+// messages over UDP, matches them against a list of rules and hands a CheckResult for every
+// match to a handler, giving devices that only speak syslog a way into the monitoring pipeline.
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// Severity is a syslog message severity as defined by RFC 5424.
+type Severity int
+
+const (
+	// Emergency means the system is unusable
+	Emergency Severity = iota
+	// Alert means action must be taken immediately
+	Alert
+	// Crit is a critical condition
+	Crit
+	// Err is an error condition
+	Err
+	// Warn is a warning condition
+	Warn
+	// Notice is a normal but significant condition
+	Notice
+	// Info is an informational message
+	Info
+	// Debug is a debug-level message
+	Debug
+)
+
+// DefaultStates maps syslog severities to check states: Emergency through Err are Critical,
+// Warn is Warning and everything else is OK.
+var DefaultStates = map[Severity]gomonitor.ExitCode{
+	Emergency: gomonitor.Critical,
+	Alert:     gomonitor.Critical,
+	Crit:      gomonitor.Critical,
+	Err:       gomonitor.Critical,
+	Warn:      gomonitor.Warning,
+	Notice:    gomonitor.OK,
+	Info:      gomonitor.OK,
+	Debug:     gomonitor.OK,
+}
+
+// Message is a parsed syslog message.
+type Message struct {
+	Facility int
+	Severity Severity
+	Host     string
+	App      string
+	Text     string
+}
+
+// Rule maps matching syslog messages to a check result.
+// - `Service` names the service the result is for.
+// - `Pattern` is matched against the message text.
+// - `States` overrides DefaultStates for this rule.
+type Rule struct {
+	Service string
+	Pattern *regexp.Regexp
+	States  map[Severity]gomonitor.ExitCode
+}
+
+// state returns the check state the rule assigns to a message of the given severity.
+func (r Rule) state(sev Severity) gomonitor.ExitCode {
+	if ec, ok := r.States[sev]; ok {
+		return ec
+	}
+	if ec, ok := DefaultStates[sev]; ok {
+		return ec
+	}
+	return gomonitor.Unknown
+}
+
+// Result is a passive check result produced from a syslog message.
+// - `Host` is the host name from the message, or the sender's address if it had none.
+// - `Service` is the Service of the rule that matched.
+type Result struct {
+	Host    string
+	Service string
+	*gomonitor.CheckResult
+}
+
+// Listener receives syslog messages over UDP and converts those matching a rule into results.
+type Listener struct {
+	conn    net.PacketConn
+	rules   []Rule
+	handler func(Result)
+	wg      sync.WaitGroup
+}
+
+// Listen starts a Listener on the UDP address addr. Each message is checked against rules in
+// order and the first match is converted into a Result and passed to handler. Messages that
+// match no rule or cannot be parsed are ignored. handler is called from a single goroutine.
+func Listen(addr string, rules []Rule, handler func(Result)) (*Listener, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	l := &Listener{conn: conn, rules: rules, handler: handler}
+	l.wg.Add(1)
+	go l.serve()
+	return l, nil
+}
+
+// Addr returns the address the listener is receiving on.
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// Close stops the listener and waits for the handler to return.
+func (l *Listener) Close() error {
+	err := l.conn.Close()
+	l.wg.Wait()
+	return err
+}
+
+func (l *Listener) serve() {
+	defer l.wg.Done()
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		msg, err := Parse(buf[:n])
+		if err != nil {
+			continue
+		}
+		if msg.Host == "" {
+			if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+				msg.Host = host
+			}
+		}
+		if result, ok := l.match(msg); ok {
+			l.handler(result)
+		}
+	}
+}
+
+// match converts msg using the first rule whose pattern matches its text.
+func (l *Listener) match(msg Message) (Result, bool) {
+	for _, rule := range l.rules {
+		if !rule.Pattern.MatchString(msg.Text) {
+			continue
+		}
+		cr := gomonitor.NewCheckResult()
+		cr.SetResult(rule.state(msg.Severity), msg.Text)
+		return Result{Host: msg.Host, Service: rule.Service, CheckResult: cr}, true
+	}
+	return Result{}, false
+}
+
+// Parse parses an RFC 5424 or RFC 3164 (BSD) syslog message.
+func Parse(b []byte) (Message, error) {
+	var msg Message
+	s := strings.TrimRight(string(b), "\r\n\x00")
+	if !strings.HasPrefix(s, "<") {
+		return msg, errors.New("syslog: missing priority")
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 2 || end > 4 {
+		return msg, errors.New("syslog: invalid priority")
+	}
+	pri, err := strconv.Atoi(s[1:end])
+	if err != nil || pri > 191 {
+		return msg, fmt.Errorf("syslog: invalid priority %q", s[1:end])
+	}
+	msg.Facility = pri / 8
+	msg.Severity = Severity(pri % 8)
+	s = s[end+1:]
+
+	if strings.HasPrefix(s, "1 ") {
+		return parse5424(msg, s[2:])
+	}
+	return parse3164(msg, s), nil
+}
+
+// parse5424 parses the part of an RFC 5424 message after the version:
+// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+func parse5424(msg Message, s string) (Message, error) {
+	fields := strings.SplitN(s, " ", 6)
+	if len(fields) < 6 {
+		return msg, errors.New("syslog: truncated RFC 5424 header")
+	}
+	msg.Host = nilValue(fields[1])
+	msg.App = nilValue(fields[2])
+	rest := fields[5]
+	if strings.HasPrefix(rest, "-") {
+		rest = rest[1:]
+	} else {
+		// Skip structured data elements, which may contain escaped brackets
+		for strings.HasPrefix(rest, "[") {
+			i := 1
+			for ; i < len(rest) && rest[i] != ']'; i++ {
+				if rest[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(rest) {
+				return msg, errors.New("syslog: unterminated structured data")
+			}
+			rest = rest[i+1:]
+		}
+	}
+	msg.Text = strings.TrimPrefix(strings.TrimPrefix(rest, " "), "\ufeff")
+	return msg, nil
+}
+
+// parse3164 parses the part of a BSD syslog message after the priority:
+// Mmm dd hh:mm:ss HOSTNAME TAG: MSG
+// Messages without a timestamp are taken to be just a tag and text.
+func parse3164(msg Message, s string) Message {
+	if len(s) >= 16 && s[3] == ' ' && s[6] == ' ' && s[9] == ':' && s[12] == ':' && s[15] == ' ' {
+		s = s[16:]
+		if host, rest, ok := strings.Cut(s, " "); ok {
+			msg.Host = host
+			s = rest
+		}
+	}
+	if tag, rest, ok := strings.Cut(s, ": "); ok && !strings.Contains(tag, " ") {
+		if i := strings.IndexByte(tag, '['); i > 0 {
+			tag = tag[:i]
+		}
+		msg.App = tag
+		s = rest
+	}
+	msg.Text = s
+	return msg
+}
+
+// nilValue returns s, or "" if s is the RFC 5424 nil value "-".
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package syslog
+
+import (
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		want    Message
+		wantErr bool
+	}{
+		{"Test RFC 5424", "<165>1 2024-05-01T10:00:00Z router1 bgpd 123 ID47 - BGP peer down",
+			Message{Facility: 20, Severity: Notice, Host: "router1", App: "bgpd", Text: "BGP peer down"}, false},
+		{"Test RFC 5424 Structured Data", `<11>1 - sw2 - - - [a@1 k="v\]"][b@1 x="y"] link flap`,
+			Message{Facility: 1, Severity: Err, Host: "sw2", Text: "link flap"}, false},
+		{"Test RFC 3164", "<28>May  1 10:00:00 fw01 kernel[7]: disk full\n",
+			Message{Facility: 3, Severity: Warn, Host: "fw01", App: "kernel", Text: "disk full"}, false},
+		{"Test Bare", "<13>just text",
+			Message{Facility: 1, Severity: Notice, Text: "just text"}, false},
+		{"Test No Priority", "hello", Message{}, true},
+		{"Test Bad Priority", "<999>x", Message{}, true},
+		{"Test Truncated 5424", "<13>1 - host", Message{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse([]byte(tc.input))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+			if !tc.wantErr && got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestListener(t *testing.T) {
+	results := make(chan Result, 4)
+	rules := []Rule{
+		{Service: "BGP", Pattern: regexp.MustCompile(`BGP peer`)},
+		{Service: "Disk", Pattern: regexp.MustCompile(`disk`), States: map[Severity]gomonitor.ExitCode{Info: gomonitor.Warning}},
+	}
+	l, err := Listen("127.0.0.1:0", rules, func(r Result) { results <- r })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	conn, err := net.Dial("udp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, msg := range []string{
+		"<165>1 - - bgpd - - - ignored message",
+		"<11>1 - router1 bgpd - - - BGP peer 10.0.0.1 down",
+		"<14>disk usage high",
+	} {
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []struct {
+		host, service string
+		state         gomonitor.ExitCode
+		message       string
+	}{
+		{"router1", "BGP", gomonitor.Critical, "BGP peer 10.0.0.1 down"},
+		{"127.0.0.1", "Disk", gomonitor.Warning, "disk usage high"},
+	}
+	for _, w := range want {
+		select {
+		case r := <-results:
+			if r.Host != w.host || r.Service != w.service || r.ExitCode != w.state || r.Message != w.message {
+				t.Errorf("got %s/%s %s %q, want %s/%s %s %q",
+					r.Host, r.Service, r.ExitCode, r.Message, w.host, w.service, w.state, w.message)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for result")
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Range represents a Nagios threshold range as described in the plugin development guidelines.
+// - `Start` and `End` are the inclusive bounds of the range. Unbounded ends are -Inf and +Inf.
+// - `Invert` is set for ranges written with a leading "@", which alert when the value is inside the range.
+type Range struct {
+	Start  float64
+	End    float64
+	Invert bool
+}
+
+// ParseRange parses a range in the standard Nagios syntax:
+// - "10" is 0 to 10
+// - "10:" is 10 to infinity
+// - "~:10" is negative infinity to 10
+// - "10:20" is 10 to 20
+// - "@10:20" is 10 to 20, inverted
+func ParseRange(s string) (Range, error) {
+	r := Range{Start: 0, End: math.Inf(1)}
+	body := s
+	if strings.HasPrefix(body, "@") {
+		r.Invert = true
+		body = body[1:]
+	}
+	if body == "" {
+		return Range{}, fmt.Errorf("gomonitor: empty range %q", s)
+	}
+
+	startStr, endStr, hasColon := strings.Cut(body, ":")
+	if !hasColon {
+		startStr, endStr = "", startStr
+	}
+	switch startStr {
+	case "":
+	case "~":
+		r.Start = math.Inf(-1)
+	default:
+		v, err := parseRangeNumber(startStr)
+		if err != nil {
+			return Range{}, fmt.Errorf("gomonitor: invalid range start in %q", s)
+		}
+		r.Start = v
+	}
+	if endStr != "" {
+		v, err := parseRangeNumber(endStr)
+		if err != nil {
+			return Range{}, fmt.Errorf("gomonitor: invalid range end in %q", s)
+		}
+		r.End = v
+	} else if !hasColon {
+		return Range{}, fmt.Errorf("gomonitor: invalid range %q", s)
+	}
+	if r.Start > r.End {
+		return Range{}, fmt.Errorf("gomonitor: range start is greater than end in %q", s)
+	}
+	return r, nil
+}
+
+// ParseThreshold parses the value of a threshold flag such as -w or -c. An empty value means
+// the threshold was not given and returns nil; anything else is parsed with ParseRange.
+func ParseThreshold(s string) (*Range, error) {
+	if s == "" {
+		return nil, nil
+	}
+	r, err := ParseRange(s)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// LowerBound returns the range "limit:", which a value violates when it is below limit, for
+// thresholds such as a minimum number of peers or days before expiry. A limit of 0 disables the
+// threshold and returns nil.
+func LowerBound(limit float64) *Range {
+	if limit == 0 {
+		return nil
+	}
+	return &Range{Start: limit, End: math.Inf(1)}
+}
+
+// parseRangeNumber parses a finite range bound.
+func parseRangeNumber(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return v, nil
+}
+
+// InRange reports whether value lies between Start and End inclusive. It does not take Invert
+// into account.
+func (r Range) InRange(value float64) bool {
+	return value >= r.Start && value <= r.End
+}
+
+// Violates reports whether value should raise an alert: when it lies outside the range, or
+// inside it for an inverted range.
+func (r Range) Violates(value float64) bool {
+	return r.InRange(value) == r.Invert
+}
+
+// String returns the range in the Nagios range syntax. The start is always spelled out, so
+// "10" is written as "0:10": that is the one form that cannot be mistaken for a plain number in
+// a perfdata threshold field, and ParseRange reads it back as the same range.
+func (r Range) String() string {
+	var b strings.Builder
+	if r.Invert {
+		b.WriteByte('@')
+	}
+	if math.IsInf(r.Start, -1) {
+		b.WriteString("~:")
+	} else {
+		b.WriteString(formatNumber(r.Start, -1))
+		b.WriteByte(':')
+	}
+	if !math.IsInf(r.End, 1) {
+		b.WriteString(formatNumber(r.End, -1))
+	}
+	return b.String()
+}
@@ -0,0 +1,183 @@
+package gomonitor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	inf := math.Inf(1)
+	testCases := []struct {
+		name    string
+		input   string
+		want    Range
+		wantErr bool
+	}{
+		{"Test End Only", "10", Range{Start: 0, End: 10}, false},
+		{"Test Start Only", "10:", Range{Start: 10, End: inf}, false},
+		{"Test Negative Infinity", "~:10", Range{Start: math.Inf(-1), End: 10}, false},
+		{"Test Both", "10:20", Range{Start: 10, End: 20}, false},
+		{"Test Inverted", "@10:20", Range{Start: 10, End: 20, Invert: true}, false},
+		{"Test Inverted End Only", "@5", Range{Start: 0, End: 5, Invert: true}, false},
+		{"Test Negative And Fractional", "-1.5:2.25", Range{Start: -1.5, End: 2.25}, false},
+		{"Test Unbounded", "~:", Range{Start: math.Inf(-1), End: inf}, false},
+		{"Test Empty", "", Range{}, true},
+		{"Test Only At", "@", Range{}, true},
+		{"Test Start Greater Than End", "20:10", Range{}, true},
+		{"Test Garbage", "abc", Range{}, true},
+		{"Test Garbage End", "1:x", Range{}, true},
+		{"Test Tilde End", "1:~", Range{}, true},
+		{"Test Infinite Number", "inf", Range{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseRange(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseThreshold(t *testing.T) {
+	got, err := ParseThreshold("")
+	if got != nil || err != nil {
+		t.Errorf("ParseThreshold(\"\") got %v, %v, want nil, nil", got, err)
+	}
+	got, err = ParseThreshold("@1:2")
+	if err != nil || got == nil || *got != (Range{Start: 1, End: 2, Invert: true}) {
+		t.Errorf("ParseThreshold(\"@1:2\") got %v, %v", got, err)
+	}
+	if _, err := ParseThreshold("2:1"); err == nil {
+		t.Error("ParseThreshold(\"2:1\") succeeded, want error")
+	}
+}
+
+func TestLowerBound(t *testing.T) {
+	if got := LowerBound(0); got != nil {
+		t.Errorf("LowerBound(0) got %v, want nil", got)
+	}
+	r := LowerBound(3)
+	if r == nil || r.String() != "3:" {
+		t.Fatalf("LowerBound(3) got %v, want 3:", r)
+	}
+	testCases := []struct {
+		name  string
+		value float64
+		want  bool
+	}{
+		{"Test Below", 2, true},
+		{"Test At Limit", 3, false},
+		{"Test Above", 4, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := r.Violates(tc.value); got != tc.want {
+				t.Errorf("Violates(%v) got %t, want %t", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRangeInRange(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		value float64
+		want  bool
+	}{
+		{"Test Below", "10", -1, false},
+		{"Test Lower Bound", "10", 0, true},
+		{"Test Upper Bound", "10", 10, true},
+		{"Test Above", "10", 10.01, false},
+		{"Test Open End", "10:", 1e9, true},
+		{"Test Open Start", "~:10", -1e9, true},
+		{"Test Inverted Inside", "@10:20", 15, true},
+		{"Test Inverted Outside", "@10:20", 25, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ParseRange(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.InRange(tc.value); got != tc.want {
+				t.Errorf("got %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRangeViolates(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		value float64
+		want  bool
+	}{
+		{"Test Inside", "10", 5, false},
+		{"Test Above", "10", 11, true},
+		{"Test Below", "10", -1, true},
+		{"Test Open End", "10:", 5, true},
+		{"Test Inverted Inside", "@10:20", 15, true},
+		{"Test Inverted Bound", "@10:20", 20, true},
+		{"Test Inverted Outside", "@10:20", 25, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ParseRange(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.Violates(tc.value); got != tc.want {
+				t.Errorf("got %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRangeString(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Test Bare Upper Bound", "10", "0:10"},
+		{"Test Explicit Zero Start", "0:10", "0:10"},
+		{"Test Open End", "10:", "10:"},
+		{"Test Negative Infinity", "~:10", "~:10"},
+		{"Test Bounded", "10:20", "10:20"},
+		{"Test Inverted", "@10:20", "@10:20"},
+		{"Test Inverted Bare", "@5", "@0:5"},
+		{"Test Fractions", "-1.5:2.25", "-1.5:2.25"},
+		{"Test Unbounded", "~:", "~:"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ParseRange(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := r.String()
+			if got != tc.want {
+				t.Errorf("ParseRange(%q).String() got %q, want %q", tc.input, got, tc.want)
+			}
+			// The canonical form parses back to the same range and formats unchanged
+			again, err := ParseRange(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != r || again.String() != got {
+				t.Errorf("ParseRange(%q) got %+v (%q), want %+v", got, again, again.String(), r)
+			}
+		})
+	}
+}
@@ -0,0 +1,465 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package tracing records OpenTelemetry spans around check execution and probe steps and
+// exports them over OTLP/HTTP, so slow checks can be diagnosed with the same tooling as
+// application latency. Checks call Start with their context, and the network probes in checks
+// record their DNS lookups, connections, TLS handshakes and queries with Do, Dial and
+// LookupIP. Without a Tracer in the context the spans are no-ops, so instrumented code costs
+// nothing when tracing is off.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// scopeName is the instrumentation scope reported with every span.
+const scopeName = "github.com/dmabry/gomonitor"
+
+// Tracer collects finished spans and exports them to an OTLP/HTTP endpoint.
+// - `Service` is reported as the service.name resource attribute.
+// - `Endpoint` is the collector's base URL, such as "http://localhost:4318". Spans are posted to its /v1/traces path.
+// - `Client` sends the export requests. nil means http.DefaultClient.
+type Tracer struct {
+	Service  string
+	Endpoint string
+	Client   *http.Client
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// Span is a timed operation within a trace. A nil *Span is valid and does nothing, which is
+// what Start returns when tracing is off.
+type Span struct {
+	tracer  *Tracer
+	traceID [16]byte
+	spanID  [8]byte
+	parent  [8]byte
+	name    string
+	kind    int
+	start   time.Time
+	end     time.Time
+	attrs   map[string]string
+	errMsg  string
+	failed  bool
+}
+
+// Span kinds as defined by OTLP.
+const (
+	kindInternal = 1
+	kindClient   = 3
+)
+
+type tracerKey struct{}
+type spanKey struct{}
+
+// ContextWithTracer returns a context whose spans are recorded by t.
+func ContextWithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// Start begins a span named name as a child of the span in ctx, if any, and returns a context
+// carrying the new span. It returns ctx and a nil span if ctx has no Tracer.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, kindInternal)
+}
+
+// start begins a span of the given kind.
+func start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.traceID, s.parent = parent.traceID, parent.spanID
+	} else {
+		_, _ = rand.Read(s.traceID[:])
+	}
+	_, _ = rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttribute records a string attribute on the span.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	if s.attrs == nil {
+		s.attrs = make(map[string]string)
+	}
+	s.attrs[key] = value
+}
+
+// SetError marks the span as failed with err's message. A nil err does nothing.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.failed, s.errMsg = true, err.Error()
+}
+
+// End finishes the span and hands it to the Tracer for export. Only the first call has an
+// effect.
+func (s *Span) End() {
+	if s == nil || !s.end.IsZero() {
+		return
+	}
+	s.end = time.Now()
+	s.tracer.mu.Lock()
+	s.tracer.spans = append(s.tracer.spans, s)
+	s.tracer.mu.Unlock()
+}
+
+// Check runs check inside a span named name. The span records the resulting state and is
+// marked failed unless the state is OK.
+func Check(ctx context.Context, name string, check func(ctx context.Context) *gomonitor.CheckResult) *gomonitor.CheckResult {
+	ctx, span := Start(ctx, name)
+	defer span.End()
+	result := check(ctx)
+	span.SetAttribute("check.state", result.ExitCode.String())
+	if result.ExitCode != gomonitor.OK {
+		span.SetError(fmt.Errorf("%s: %s", result.ExitCode, result.Message))
+	}
+	return result
+}
+
+// WithClientTrace returns a context that records the DNS lookup, connection and TLS handshake
+// of HTTP requests made with it as child spans of the span in ctx. It returns ctx unchanged if
+// ctx has no Tracer.
+func WithClientTrace(ctx context.Context) context.Context {
+	if t, _ := ctx.Value(tracerKey{}).(*Tracer); t == nil {
+		return ctx
+	}
+	var mu sync.Mutex
+	var dns, tlsSpan *Span
+	connects := make(map[string]*Span)
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			_, span := start(ctx, "dns", kindClient)
+			span.SetAttribute("net.host.name", info.Host)
+			mu.Lock()
+			dns = span
+			mu.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dns.SetError(info.Err)
+			dns.End()
+		},
+		ConnectStart: func(network, addr string) {
+			_, span := start(ctx, "connect", kindClient)
+			span.SetAttribute("net.peer.name", addr)
+			mu.Lock()
+			connects[network+" "+addr] = span
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			span := connects[network+" "+addr]
+			span.SetError(err)
+			span.End()
+		},
+		TLSHandshakeStart: func() {
+			_, span := start(ctx, "tls", kindClient)
+			mu.Lock()
+			tlsSpan = span
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsSpan.SetError(err)
+			tlsSpan.End()
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace)
+}
+
+// Do sends req with client, nil meaning http.DefaultClient, inside a "query" span that is a
+// child of the span in the request's context. The request's DNS lookup, connection and TLS
+// handshake are recorded as child spans of the query and the response status as an attribute;
+// error statuses mark the span failed. The span ends when the response headers arrive. Without
+// a Tracer in the request's context Do is client.Do(req).
+func Do(client *http.Client, req *http.Request) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx, span := start(req.Context(), "query", kindClient)
+	if span == nil {
+		return client.Do(req)
+	}
+	defer span.End()
+	// The query string is left out as it can carry credentials
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+	resp, err := client.Do(req.WithContext(WithClientTrace(ctx)))
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", strconv.Itoa(resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetError(errors.New(resp.Status))
+	}
+	return resp, nil
+}
+
+// Dial connects like d.DialContext, nil meaning a zero net.Dialer, recording the name lookup
+// and the connection as "dns" and "connect" spans that are children of the span in ctx. The
+// returned connection carries a "query" span covering the exchange on it until it is closed,
+// which read and write errors other than io.EOF mark failed. When tracing, the resolved
+// addresses are tried in order. Without a Tracer in ctx Dial is d.DialContext.
+func Dial(ctx context.Context, d *net.Dialer, network, addr string) (net.Conn, error) {
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	if t, _ := ctx.Value(tracerKey{}).(*Tracer); t == nil {
+		return d.DialContext(ctx, network, addr)
+	}
+	targets := []string{addr}
+	if host, port, err := net.SplitHostPort(addr); err == nil && host != "" && net.ParseIP(host) == nil {
+		ips, err := LookupIP(ctx, d.Resolver, ipNetwork(network), host)
+		if err != nil {
+			return nil, err
+		}
+		targets = targets[:0]
+		for _, ip := range ips {
+			targets = append(targets, net.JoinHostPort(ip.String(), port))
+		}
+	}
+
+	_, span := start(ctx, "connect", kindClient)
+	span.SetAttribute("net.peer.name", addr)
+	var conn net.Conn
+	var err error
+	for _, target := range targets {
+		if conn, err = d.DialContext(ctx, network, target); err == nil {
+			span.SetAttribute("net.sock.peer.addr", target)
+			break
+		}
+	}
+	span.SetError(err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	_, query := start(ctx, "query", kindClient)
+	query.SetAttribute("net.peer.name", addr)
+	return &tracedConn{Conn: conn, span: query}, nil
+}
+
+// LookupIP resolves host like resolver.LookupIP, nil meaning net.DefaultResolver, inside a
+// "dns" span that is a child of the span in ctx. A name that does not exist is recorded as an
+// attribute rather than a failure, as probes such as DNSBL lookups expect it.
+func LookupIP(ctx context.Context, resolver *net.Resolver, network, host string) ([]net.IP, error) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	_, span := start(ctx, "dns", kindClient)
+	defer span.End()
+	span.SetAttribute("net.host.name", host)
+	ips, err := resolver.LookupIP(ctx, network, host)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		span.SetAttribute("dns.not_found", "true")
+	} else {
+		span.SetError(err)
+	}
+	return ips, err
+}
+
+// ipNetwork returns the LookupIP network matching a dial network.
+func ipNetwork(network string) string {
+	switch {
+	case strings.HasSuffix(network, "4"):
+		return "ip4"
+	case strings.HasSuffix(network, "6"):
+		return "ip6"
+	default:
+		return "ip"
+	}
+}
+
+// tracedConn is a connection returned by Dial. It ends its query span when closed.
+type tracedConn struct {
+	net.Conn
+	span *Span
+	mu   sync.Mutex
+}
+
+// Read implements net.Conn.
+func (c *tracedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.fail(err)
+	return n, err
+}
+
+// Write implements net.Conn.
+func (c *tracedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.fail(err)
+	return n, err
+}
+
+// Close implements net.Conn.
+func (c *tracedConn) Close() error {
+	err := c.Conn.Close()
+	c.mu.Lock()
+	c.span.End()
+	c.mu.Unlock()
+	return err
+}
+
+// fail marks the query span failed with an I/O error, unless the connection was closed and
+// the span handed to the Tracer.
+func (c *tracedConn) fail(err error) {
+	if err == nil || errors.Is(err, io.EOF) {
+		return
+	}
+	c.mu.Lock()
+	if c.span.end.IsZero() {
+		c.span.SetError(err)
+	}
+	c.mu.Unlock()
+}
+
+// Flush exports the finished spans and forgets them. It does nothing if there are none.
+// Plugins typically call it from an OnBeforeExit hook.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(t.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(t.Endpoint, "/")+"/v1/traces", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("tracing: exporting spans: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("tracing: exporting spans: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// otlpAttribute is an OTLP key/value attribute with a string value.
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+// otlpSpan is a span in the OTLP/JSON encoding, which writes IDs in hex and 64-bit
+// timestamps as strings.
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+// otlpStatus is a span status. Code 2 is an error.
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// request builds an OTLP ExportTraceServiceRequest for spans.
+func (t *Tracer) request(spans []*Span) map[string]any {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		o := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != ([8]byte{}) {
+			o.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, k := range slices.Sorted(maps.Keys(s.attrs)) {
+			o.Attributes = append(o.Attributes, attribute(k, s.attrs[k]))
+		}
+		if s.failed {
+			o.Status = &otlpStatus{Code: 2, Message: s.errMsg}
+		}
+		out = append(out, o)
+	}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []otlpAttribute{attribute("service.name", t.Service)}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": scopeName},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+// attribute returns a string attribute.
+func attribute(key, value string) otlpAttribute {
+	a := otlpAttribute{Key: key}
+	a.Value.StringValue = value
+	return a
+}
@@ -0,0 +1,282 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dmabry/gomonitor"
+)
+
+// exported is the part of an OTLP export request the tests look at.
+type exported struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []otlpSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+// newCollector returns an OTLP endpoint that decodes every export into requests.
+func newCollector(t *testing.T, requests *[]exported) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got request %s %s with %q", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var req exported
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		*requests = append(*requests, req)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// spans returns the spans of a single export request by name.
+func spans(t *testing.T, requests []exported) map[string]otlpSpan {
+	if len(requests) != 1 {
+		t.Fatalf("got %d export requests, want 1", len(requests))
+	}
+	byName := make(map[string]otlpSpan)
+	for _, s := range requests[0].ResourceSpans[0].ScopeSpans[0].Spans {
+		byName[s.Name] = s
+	}
+	return byName
+}
+
+func TestCheck(t *testing.T) {
+	var requests []exported
+	srv := newCollector(t, &requests)
+	tracer := &Tracer{Service: "check_web", Endpoint: srv.URL + "/"}
+	ctx := ContextWithTracer(context.Background(), tracer)
+
+	result := Check(ctx, "check_web", func(ctx context.Context) *gomonitor.CheckResult {
+		_, span := Start(ctx, "query")
+		span.SetAttribute("db.statement", "SELECT 1")
+		span.SetError(errors.New("timeout"))
+		span.End()
+		span.End()
+		result := gomonitor.NewCheckResult()
+		result.SetResult(gomonitor.Critical, "query timed out")
+		return result
+	})
+	if result.ExitCode != gomonitor.Critical {
+		t.Fatalf("got exitCode %s, want %s", result.ExitCode, gomonitor.Critical)
+	}
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := requests[0].ResourceSpans[0].Resource.Attributes[0]; got.Key != "service.name" || got.Value.StringValue != "check_web" {
+		t.Errorf("got resource attribute %+v, want service.name check_web", got)
+	}
+	byName := spans(t, requests)
+	if len(byName) != 2 {
+		t.Fatalf("got spans %+v, want check_web and query once each", byName)
+	}
+	check, query := byName["check_web"], byName["query"]
+	if query.TraceID != check.TraceID || query.ParentSpanID != check.SpanID || check.ParentSpanID != "" {
+		t.Errorf("got check %+v and query %+v, want query to be a child of check", check, query)
+	}
+	if len(check.TraceID) != 32 || len(check.SpanID) != 16 {
+		t.Errorf("got IDs %q and %q, want 16 and 8 hex-encoded bytes", check.TraceID, check.SpanID)
+	}
+	if check.Status == nil || check.Status.Code != 2 || check.Status.Message != "Critical: query timed out" {
+		t.Errorf("got check status %+v, want an error with the result", check.Status)
+	}
+	if len(check.Attributes) != 1 || check.Attributes[0].Value.StringValue != "Critical" {
+		t.Errorf("got check attributes %+v, want check.state Critical", check.Attributes)
+	}
+	if query.Status == nil || query.Status.Message != "timeout" {
+		t.Errorf("got query status %+v, want the timeout error", query.Status)
+	}
+
+	// Flushed spans are not exported again
+	if err := tracer.Flush(context.Background()); err != nil || len(requests) != 1 {
+		t.Errorf("second Flush got error %v and %d requests, want nothing sent", err, len(requests))
+	}
+}
+
+func TestStartWithoutTracer(t *testing.T) {
+	ctx := context.Background()
+	got, span := Start(ctx, "noop")
+	if span != nil || got != ctx {
+		t.Errorf("Start without a tracer got span %v, want nil and the same context", span)
+	}
+	// A nil span must be safe to use
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("ignored"))
+	span.End()
+	if WithClientTrace(ctx) != ctx {
+		t.Error("WithClientTrace without a tracer changed the context")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conn, err := Dial(ctx, nil, "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*tracedConn); ok {
+		t.Error("Dial without a tracer returned a traced connection")
+	}
+}
+
+func TestWithClientTrace(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	var requests []exported
+	srv := newCollector(t, &requests)
+	tracer := &Tracer{Service: "check_http", Endpoint: srv.URL}
+
+	ctx, span := Start(ContextWithTracer(context.Background(), tracer), "check_http")
+	req, err := http.NewRequestWithContext(WithClientTrace(ctx), http.MethodGet, target.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A fresh transport makes sure a new connection is traced
+	resp, err := (&http.Client{Transport: &http.Transport{}}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	span.End()
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := spans(t, requests)
+	connect, ok := byName["connect"]
+	if !ok {
+		t.Fatalf("got spans %+v, want a connect span", byName)
+	}
+	if connect.ParentSpanID != byName["check_http"].SpanID || connect.Kind != kindClient {
+		t.Errorf("got connect span %+v, want a client child of check_http", connect)
+	}
+}
+
+// attrs returns the attributes of a span by key.
+func attrs(s otlpSpan) map[string]string {
+	m := make(map[string]string)
+	for _, a := range s.Attributes {
+		m[a.Key] = a.Value.StringValue
+	}
+	return m
+}
+
+func TestDo(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer target.Close()
+	var requests []exported
+	srv := newCollector(t, &requests)
+	tracer := &Tracer{Service: "check_http", Endpoint: srv.URL}
+
+	ctx, span := Start(ContextWithTracer(context.Background(), tracer), "check_http")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL+"/health?token=secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := Do(&http.Client{Transport: &http.Transport{}}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	span.End()
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := spans(t, requests)
+	query := byName["query"]
+	if query.ParentSpanID != byName["check_http"].SpanID || query.Status == nil || query.Status.Code != 2 {
+		t.Errorf("got query span %+v, want a failed child of check_http", query)
+	}
+	want := map[string]string{"http.method": "GET", "http.url": target.URL + "/health", "http.status_code": "500"}
+	if got := attrs(query); !maps.Equal(got, want) {
+		t.Errorf("got query attributes %v, want %v", got, want)
+	}
+	if connect := byName["connect"]; connect.ParentSpanID != query.SpanID {
+		t.Errorf("got connect span %+v, want a child of query", connect)
+	}
+}
+
+func TestDial(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("imok"))
+		conn.Close()
+	}()
+	var requests []exported
+	srv := newCollector(t, &requests)
+	tracer := &Tracer{Service: "check_zookeeper", Endpoint: srv.URL}
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ctx, span := Start(ContextWithTracer(context.Background(), tracer), "check_zookeeper")
+	conn, err := Dial(ctx, nil, "tcp4", net.JoinHostPort("localhost", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := io.ReadAll(conn); err != nil || string(reply) != "imok" {
+		t.Errorf("got reply %q, %v, want imok", reply, err)
+	}
+	conn.Close()
+	span.End()
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := spans(t, requests)
+	for _, name := range []string{"dns", "connect", "query"} {
+		s, ok := byName[name]
+		if !ok {
+			t.Fatalf("got spans %+v, want a %s span", byName, name)
+		}
+		if s.ParentSpanID != byName["check_zookeeper"].SpanID || s.Status != nil {
+			t.Errorf("got %s span %+v, want a successful child of check_zookeeper", name, s)
+		}
+	}
+	if got := attrs(byName["dns"])["net.host.name"]; got != "localhost" {
+		t.Errorf("got dns host %q, want localhost", got)
+	}
+	if got := attrs(byName["connect"])["net.sock.peer.addr"]; got != ln.Addr().String() {
+		t.Errorf("got connect address %q, want %s", got, ln.Addr())
+	}
+}
+
+func TestFlushError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	tracer := &Tracer{Service: "check", Endpoint: srv.URL}
+	_, span := Start(ContextWithTracer(context.Background(), tracer), "check")
+	span.End()
+	if err := tracer.Flush(context.Background()); err == nil {
+		t.Error("Flush to a failing collector returned no error")
+	}
+}
@@ -0,0 +1,132 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gomonitor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// UOM is a perfdata unit of measure.
+type UOM string
+
+// Units of measure defined by the plugin development guidelines. They are untyped, so they can
+// be assigned to PerformanceMetric.UnitOM, a plain string, as well as used as UOM values.
+const (
+	// UOMNone is a plain number, such as a count of users
+	UOMNone = ""
+	// UOMSeconds is seconds
+	UOMSeconds = "s"
+	// UOMMilliseconds is milliseconds
+	UOMMilliseconds = "ms"
+	// UOMMicroseconds is microseconds
+	UOMMicroseconds = "us"
+	// UOMPercent is a percentage
+	UOMPercent = "%"
+	// UOMBytes is bytes
+	UOMBytes = "B"
+	// UOMKilobytes is kilobytes
+	UOMKilobytes = "KB"
+	// UOMMegabytes is megabytes
+	UOMMegabytes = "MB"
+	// UOMGigabytes is gigabytes
+	UOMGigabytes = "GB"
+	// UOMTerabytes is terabytes
+	UOMTerabytes = "TB"
+	// UOMCounter is a continuous counter, such as bytes transmitted on an interface
+	UOMCounter = "c"
+)
+
+// standardUOMs are the units of measure defined by the plugin development guidelines.
+var standardUOMs = []UOM{
+	UOMNone, UOMSeconds, UOMMilliseconds, UOMMicroseconds, UOMPercent,
+	UOMBytes, UOMKilobytes, UOMMegabytes, UOMGigabytes, UOMTerabytes, UOMCounter,
+}
+
+// Validate returns an error if u is not one of the units of measure defined by the plugin
+// development guidelines. Units that differ from a standard one only in case, such as "Mb",
+// are reported with the unit that was probably meant.
+func (u UOM) Validate() error {
+	for _, std := range standardUOMs {
+		if u == std {
+			return nil
+		}
+	}
+	for _, std := range standardUOMs {
+		if strings.EqualFold(string(u), string(std)) {
+			return fmt.Errorf("gomonitor: unknown unit of measure %q, did you mean %q?", u, std)
+		}
+	}
+	return fmt.Errorf("gomonitor: unknown unit of measure %q", u)
+}
+
+// byteUnits are the byte units of measure from smallest to largest. Each is 1024 times the
+// previous one, as in the monitoring-plugins disk and memory checks.
+var byteUnits = []UOM{UOMBytes, UOMKilobytes, UOMMegabytes, UOMGigabytes, UOMTerabytes}
+
+// byteUnit returns the largest byte unit in which bytes is at least 1, and its size.
+func byteUnit(bytes float64) (UOM, float64) {
+	unit, size := byteUnits[0], 1.0
+	for _, u := range byteUnits[1:] {
+		if math.Abs(bytes) < size*1024 {
+			break
+		}
+		unit, size = u, size*1024
+	}
+	return unit, size
+}
+
+// ScaleBytes converts a metric whose numbers are in bytes to the largest byte unit in which
+// its Value is at least 1, such as 1536 B to 1.5 KB. Value, Warn, Crit, Min, Max and the
+// threshold ranges are all divided by the same factor. Metrics with a unit other than bytes or
+// none are returned unchanged.
+//
+// To keep raw bytes in the perfdata, for graphing, while showing a friendly size in the
+// message, leave the metric alone and format the message with FormatBytes instead.
+func ScaleBytes(m PerformanceMetric) PerformanceMetric {
+	if m.UnitOM != UOMNone && m.UnitOM != UOMBytes {
+		return m
+	}
+	unit, size := byteUnit(m.Value)
+	m.UnitOM = string(unit)
+	m.Value /= size
+	m.Warn /= size
+	m.Crit /= size
+	m.Min /= size
+	m.Max /= size
+	m.WarnRange = scaleRange(m.WarnRange, size)
+	m.CritRange = scaleRange(m.CritRange, size)
+	return m
+}
+
+// scaleRange returns a copy of r with its bounds divided by size, or nil if r is nil.
+func scaleRange(r *Range, size float64) *Range {
+	if r == nil {
+		return nil
+	}
+	scaled := Range{Start: r.Start / size, End: r.End / size, Invert: r.Invert}
+	return &scaled
+}
+
+// FormatBytes formats a number of bytes in the largest byte unit in which it is at least 1,
+// with up to two decimals, such as "1.5 GB" or "512 B".
+func FormatBytes(bytes float64) string {
+	unit, size := byteUnit(bytes)
+	return strconv.FormatFloat(math.Round(bytes/size*100)/100, 'f', -1, 64) + " " + string(unit)
+}
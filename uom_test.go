@@ -0,0 +1,107 @@
+package gomonitor
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUOMValidate(t *testing.T) {
+	for _, uom := range standardUOMs {
+		if err := uom.Validate(); err != nil {
+			t.Errorf("UOM(%q).Validate() got %v, want nil", uom, err)
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		uom        UOM
+		suggestion string
+	}{
+		{"Test Wrong Case Megabytes", "Mb", `did you mean "MB"`},
+		{"Test Wrong Case Milliseconds", "MS", `did you mean "ms"`},
+		{"Test Wrong Case Counter", "C", `did you mean "c"`},
+		{"Test Unknown", "Mbit", ""},
+		{"Test Whitespace", " s", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.uom.Validate()
+			if err == nil {
+				t.Fatalf("UOM(%q).Validate() succeeded, want error", tc.uom)
+			}
+			if got := strings.Contains(err.Error(), "did you mean"); got != (tc.suggestion != "") {
+				t.Errorf("got error %q, want suggestion %t", err, tc.suggestion != "")
+			}
+			if tc.suggestion != "" && !strings.Contains(err.Error(), tc.suggestion) {
+				t.Errorf("got error %q, want it to contain %q", err, tc.suggestion)
+			}
+		})
+	}
+}
+
+func TestScaleBytes(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input PerformanceMetric
+		want  PerformanceMetric
+	}{
+		{"Test Bytes", PerformanceMetric{Value: 512, Max: 1024, UnitOM: UOMBytes},
+			PerformanceMetric{Value: 512, Max: 1024, UnitOM: UOMBytes}},
+		{"Test Kilobytes", PerformanceMetric{Value: 1536, UnitOM: UOMBytes},
+			PerformanceMetric{Value: 1.5, UnitOM: UOMKilobytes}},
+		{"Test No Unit", PerformanceMetric{Value: 3 << 30, Warn: 4 << 30, Crit: 5 << 30, Max: 8 << 30},
+			PerformanceMetric{Value: 3, Warn: 4, Crit: 5, Max: 8, UnitOM: UOMGigabytes}},
+		{"Test Terabytes", PerformanceMetric{Value: 2 << 50, UnitOM: UOMBytes},
+			PerformanceMetric{Value: 2048, UnitOM: UOMTerabytes}},
+		{"Test Ranges", PerformanceMetric{Value: 1 << 20, WarnRange: &Range{Start: 1 << 19, End: math.Inf(1)},
+			CritRange: &Range{Start: 0, End: 1 << 21, Invert: true}},
+			PerformanceMetric{Value: 1, UnitOM: UOMMegabytes, WarnRange: &Range{Start: 0.5, End: math.Inf(1)},
+				CritRange: &Range{Start: 0, End: 2, Invert: true}}},
+		{"Test Other Unit", PerformanceMetric{Value: 2048, UnitOM: UOMSeconds},
+			PerformanceMetric{Value: 2048, UnitOM: UOMSeconds}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ScaleBytes(tc.input); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	testCases := []struct {
+		name  string
+		bytes float64
+		want  string
+	}{
+		{"Test Zero", 0, "0 B"},
+		{"Test Bytes", 1023, "1023 B"},
+		{"Test Kilobytes", 1024, "1 KB"},
+		{"Test Rounded", 1.5*(1<<30) + 12345, "1.5 GB"},
+		{"Test Two Decimals", 1.256 * (1 << 20), "1.26 MB"},
+		{"Test Negative", -2048, "-2 KB"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := FormatBytes(tc.bytes); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnitOMString(t *testing.T) {
+	// UnitOM is a plain string, so units held in string variables can be assigned directly,
+	// and the untyped constants work alongside them
+	unit := "ms"
+	m := PerformanceMetric{Value: 5, UnitOM: unit}
+	if m.UnitOM != UOMMilliseconds || UOM(m.UnitOM).Validate() != nil {
+		t.Errorf("got UnitOM %q, want a valid %q", m.UnitOM, UOMMilliseconds)
+	}
+}
@@ -0,0 +1,261 @@
+/*
+   Copyright 2024 David Mabry
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package winrm runs commands on Windows hosts over Windows Remote Management, so checks can
+// collect from Windows fleets without an agent. Only Basic authentication is supported, which
+// WinRM accepts over HTTPS when it is enabled on the listener.
+package winrm
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxResponseSize bounds each WinRM response the client will read.
+const maxResponseSize = 10 << 20
+
+// WS-Management actions and URIs used by the client.
+const (
+	actionCreate  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Create"
+	actionDelete  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Delete"
+	actionCommand = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Command"
+	actionReceive = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Receive"
+	shellURI      = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/cmd"
+	stateDone     = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done"
+	// faultTimedOut is the WSManFault code for a Receive that saw no output before the
+	// operation timeout. The command is still running and Receive is simply repeated.
+	faultTimedOut = "2150858793"
+)
+
+// Client runs commands on a single Windows host.
+// - `Endpoint` is the WinRM listener URL, such as "https://host:5986/wsman".
+// - `Username` and `Password` are sent with Basic authentication.
+// - `HTTPClient` sends the requests. nil means http.DefaultClient.
+type Client struct {
+	Endpoint   string
+	Username   string
+	Password   string
+	HTTPClient *http.Client
+}
+
+// Output is the result of a command.
+type Output struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// Run runs command with args in a new remote shell and waits for it to finish. The shell is
+// deleted afterwards. A non-zero exit code is not an error; it is reported in the Output.
+func (c *Client) Run(ctx context.Context, command string, args ...string) (Output, error) {
+	var created struct {
+		ShellID string `xml:"Body>Shell>ShellId"`
+	}
+	options := `<w:OptionSet><w:Option Name="WINRS_NOPROFILE">TRUE</w:Option>` +
+		`<w:Option Name="WINRS_CODEPAGE">65001</w:Option></w:OptionSet>`
+	body := `<rsp:Shell><rsp:InputStreams>stdin</rsp:InputStreams>` +
+		`<rsp:OutputStreams>stdout stderr</rsp:OutputStreams></rsp:Shell>`
+	if err := c.call(ctx, actionCreate, "", options, body, &created); err != nil {
+		return Output{}, fmt.Errorf("creating shell: %w", err)
+	}
+	if created.ShellID == "" {
+		return Output{}, errors.New("creating shell: no shell ID in response")
+	}
+	defer func() {
+		// The command has finished or failed; deleting the shell is best effort
+		_ = c.call(context.WithoutCancel(ctx), actionDelete, created.ShellID, "", "", nil)
+	}()
+
+	var started struct {
+		CommandID string `xml:"Body>CommandResponse>CommandId"`
+	}
+	var line strings.Builder
+	line.WriteString("<rsp:CommandLine><rsp:Command>")
+	line.WriteString(escape(command))
+	line.WriteString("</rsp:Command>")
+	for _, arg := range args {
+		line.WriteString("<rsp:Arguments>" + escape(arg) + "</rsp:Arguments>")
+	}
+	line.WriteString("</rsp:CommandLine>")
+	options = `<w:OptionSet><w:Option Name="WINRS_CONSOLEMODE_STDIN">TRUE</w:Option>` +
+		`<w:Option Name="WINRS_SKIP_CMD_SHELL">FALSE</w:Option></w:OptionSet>`
+	if err := c.call(ctx, actionCommand, created.ShellID, options, line.String(), &started); err != nil {
+		return Output{}, fmt.Errorf("running %s: %w", command, err)
+	}
+
+	var out Output
+	for {
+		var received struct {
+			Streams []struct {
+				Name string `xml:"Name,attr"`
+				Data string `xml:",chardata"`
+			} `xml:"Body>ReceiveResponse>Stream"`
+			State struct {
+				State    string `xml:"State,attr"`
+				ExitCode int    `xml:"ExitCode"`
+			} `xml:"Body>ReceiveResponse>CommandState"`
+		}
+		body := `<rsp:Receive><rsp:DesiredStream CommandId="` + escape(started.CommandID) +
+			`">stdout stderr</rsp:DesiredStream></rsp:Receive>`
+		err := c.call(ctx, actionReceive, created.ShellID, "", body, &received)
+		var fault *Fault
+		if errors.As(err, &fault) && fault.Code == faultTimedOut {
+			continue
+		}
+		if err != nil {
+			return Output{}, fmt.Errorf("reading output of %s: %w", command, err)
+		}
+		for _, stream := range received.Streams {
+			data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stream.Data))
+			if err != nil {
+				return Output{}, fmt.Errorf("reading output of %s: %w", command, err)
+			}
+			switch stream.Name {
+			case "stdout":
+				out.Stdout = append(out.Stdout, data...)
+			case "stderr":
+				out.Stderr = append(out.Stderr, data...)
+			}
+		}
+		if received.State.State == stateDone {
+			out.ExitCode = received.State.ExitCode
+			return out, nil
+		}
+	}
+}
+
+// RunPowerShell runs script with powershell.exe, passing it with -EncodedCommand so it needs
+// no quoting.
+func (c *Client) RunPowerShell(ctx context.Context, script string) (Output, error) {
+	return c.Run(ctx, "powershell", "-NoProfile", "-NonInteractive", "-EncodedCommand", EncodePowerShell(script))
+}
+
+// EncodePowerShell encodes script for powershell.exe's -EncodedCommand option, which takes
+// base64 of the UTF-16LE script.
+func EncodePowerShell(script string) string {
+	units := utf16.Encode([]rune(script))
+	buf := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(buf[2*i:], u)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// Fault is a SOAP fault returned by the WinRM service.
+// - `Code` is the WSManFault code, such as "5" for access denied, when the service sent one.
+// - `Reason` is the fault's human-readable text.
+type Fault struct {
+	Code   string
+	Reason string
+}
+
+// Error implements error.
+func (f *Fault) Error() string {
+	if f.Code != "" {
+		return fmt.Sprintf("WinRM fault %s: %s", f.Code, f.Reason)
+	}
+	return "WinRM fault: " + f.Reason
+}
+
+// call sends a WS-Management request and decodes the response envelope into v, if not nil.
+func (c *Client) call(ctx context.Context, action, shellID, options, body string, v any) error {
+	id, err := messageID()
+	if err != nil {
+		return err
+	}
+	var env bytes.Buffer
+	env.WriteString(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"` +
+		` xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing"` +
+		` xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd"` +
+		` xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell"><s:Header>`)
+	env.WriteString("<a:To>" + escape(c.Endpoint) + "</a:To>")
+	env.WriteString(`<a:ReplyTo><a:Address s:mustUnderstand="true">` +
+		`http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous</a:Address></a:ReplyTo>`)
+	env.WriteString(`<a:Action s:mustUnderstand="true">` + action + "</a:Action>")
+	env.WriteString("<a:MessageID>uuid:" + id + "</a:MessageID>")
+	env.WriteString(`<w:ResourceURI s:mustUnderstand="true">` + shellURI + "</w:ResourceURI>")
+	env.WriteString(`<w:MaxEnvelopeSize s:mustUnderstand="true">153600</w:MaxEnvelopeSize>`)
+	env.WriteString("<w:OperationTimeout>PT20S</w:OperationTimeout>")
+	if shellID != "" {
+		env.WriteString(`<w:SelectorSet><w:Selector Name="ShellId">` + escape(shellID) + "</w:Selector></w:SelectorSet>")
+	}
+	env.WriteString(options)
+	env.WriteString("</s:Header><s:Body>" + body + "</s:Body></s:Envelope>")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, &env)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	req.SetBasicAuth(c.Username, c.Password)
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var fault struct {
+			Detail struct {
+				Code string `xml:"Code,attr"`
+			} `xml:"Body>Fault>Detail>WSManFault"`
+			Reason string `xml:"Body>Fault>Reason>Text"`
+		}
+		if xml.Unmarshal(raw, &fault) == nil && fault.Reason != "" {
+			return &Fault{Code: fault.Detail.Code, Reason: strings.TrimSpace(fault.Reason)}
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	return xml.Unmarshal(raw, v)
+}
+
+// messageID returns a random UUID for a request's MessageID header.
+func messageID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// escape escapes s for use as XML text or an attribute value.
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,156 @@
+package winrm
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// actionPattern extracts the WS-Management action from a request envelope.
+var actionPattern = regexp.MustCompile(`<a:Action [^>]*>([^<]+)</a:Action>`)
+
+// fakeResponse wraps body in a response envelope.
+func fakeResponse(body string) string {
+	return `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"` +
+		` xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell"><s:Header/><s:Body>` +
+		body + `</s:Body></s:Envelope>`
+}
+
+// fakeFault returns a fault envelope with a WSManFault code.
+func fakeFault(code, reason string) string {
+	return `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"` +
+		` xmlns:f="http://schemas.microsoft.com/wbem/wsman/1/wsmanfault"><s:Body><s:Fault>` +
+		`<s:Reason><s:Text xml:lang="en-US">` + reason + `</s:Text></s:Reason>` +
+		`<s:Detail><f:WSManFault Code="` + code + `"/></s:Detail></s:Fault></s:Body></s:Envelope>`
+}
+
+// stream returns a Receive stream element carrying data.
+func stream(name, data string) string {
+	return `<rsp:Stream Name="` + name + `" CommandId="C1">` + base64.StdEncoding.EncodeToString([]byte(data)) + `</rsp:Stream>`
+}
+
+// newFakeServer returns a WinRM endpoint that answers Receive requests with receives in turn
+// and records each request's action and body.
+func newFakeServer(t *testing.T, receives []string, requests *[]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "monitor" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		raw, _ := io.ReadAll(r.Body)
+		m := actionPattern.FindStringSubmatch(string(raw))
+		if m == nil {
+			t.Errorf("request without action: %s", raw)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*requests = append(*requests, m[1]+" "+string(raw))
+		switch m[1] {
+		case actionCreate:
+			io.WriteString(w, fakeResponse(`<rsp:Shell><rsp:ShellId>S1</rsp:ShellId></rsp:Shell>`))
+		case actionCommand:
+			io.WriteString(w, fakeResponse(`<rsp:CommandResponse><rsp:CommandId>C1</rsp:CommandId></rsp:CommandResponse>`))
+		case actionReceive:
+			next := receives[0]
+			receives = receives[1:]
+			if strings.Contains(next, "Fault") {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			io.WriteString(w, next)
+		case actionDelete:
+			io.WriteString(w, fakeResponse(""))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRun(t *testing.T) {
+	var requests []string
+	srv := newFakeServer(t, []string{
+		fakeResponse(`<rsp:ReceiveResponse>` + stream("stdout", "hello ") + `<rsp:CommandState CommandId="C1" State="http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Running"/></rsp:ReceiveResponse>`),
+		fakeFault(faultTimedOut, "The WS-Management service cannot complete the operation within the time specified in OperationTimeout."),
+		fakeResponse(`<rsp:ReceiveResponse>` + stream("stdout", "world") + stream("stderr", "warning") +
+			`<rsp:CommandState CommandId="C1" State="` + stateDone + `"><rsp:ExitCode>3</rsp:ExitCode></rsp:CommandState></rsp:ReceiveResponse>`),
+	}, &requests)
+	c := &Client{Endpoint: srv.URL + "/wsman", Username: "monitor", Password: "secret"}
+
+	out, err := c.Run(context.Background(), "cmd", "/c", "echo <hi>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out.Stdout) != "hello world" || string(out.Stderr) != "warning" || out.ExitCode != 3 {
+		t.Errorf("got %q, %q, exit %d, want %q, %q, exit 3", out.Stdout, out.Stderr, out.ExitCode, "hello world", "warning")
+	}
+	if len(requests) != 6 {
+		t.Fatalf("got %d requests, want create, command, 3 receives and delete", len(requests))
+	}
+	if !strings.Contains(requests[1], "<rsp:Arguments>echo &lt;hi&gt;</rsp:Arguments>") {
+		t.Errorf("command request %s does not carry the escaped arguments", requests[1])
+	}
+	if !strings.HasPrefix(requests[5], actionDelete) || !strings.Contains(requests[5], `<w:Selector Name="ShellId">S1</w:Selector>`) {
+		t.Errorf("last request %s does not delete the shell", requests[5])
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		password string
+		receives []string
+		want     string
+	}{
+		{"Test unauthorized", "wrong", nil, "401 Unauthorized"},
+		{"Test fault", "secret", []string{fakeFault("5", "Access is denied.")}, "WinRM fault 5: Access is denied."},
+		{"Test invalid stream", "secret", []string{fakeResponse(`<rsp:ReceiveResponse><rsp:Stream Name="stdout">!!!</rsp:Stream></rsp:ReceiveResponse>`)}, "illegal base64"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []string
+			srv := newFakeServer(t, tc.receives, &requests)
+			c := &Client{Endpoint: srv.URL + "/wsman", Username: "monitor", Password: tc.password}
+			_, err := c.Run(context.Background(), "hostname")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got error %v, want it to contain %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestFaultError(t *testing.T) {
+	var requests []string
+	srv := newFakeServer(t, []string{fakeFault("5", "Access is denied.")}, &requests)
+	c := &Client{Endpoint: srv.URL + "/wsman", Username: "monitor", Password: "secret"}
+	_, err := c.Run(context.Background(), "hostname")
+	var fault *Fault
+	if !errors.As(err, &fault) || fault.Code != "5" {
+		t.Errorf("got error %v, want a Fault with code 5", err)
+	}
+}
+
+func TestEncodePowerShell(t *testing.T) {
+	testCases := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"Test ASCII", "dir", "ZABpAHIA"},
+		{"Test non-ASCII", "é", "6QA="},
+		{"Test surrogate pair", "😀", "PdgA3g=="},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := EncodePowerShell(tc.script); got != tc.want {
+				t.Errorf("EncodePowerShell(%q) got %q, want %q", tc.script, got, tc.want)
+			}
+		})
+	}
+}